@@ -0,0 +1,192 @@
+package toml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// AfterDecoder is implemented by types that validate or normalize their value
+// once it has been decoded.
+//
+// After the whole document has been decoded, AfterDecode is called on every
+// struct and map of the target that implements it, including the ones that the
+// document did not mention. Values are visited bottom-up: the AfterDecode
+// method of a struct is called after the ones of its fields. Values of maps are
+// visited in no particular order.
+//
+// The first error returned by AfterDecode stops the decoding. It is wrapped in
+// an error mentioning the keys leading to the value.
+//
+// The method can have a pointer receiver, in which case it is only called on
+// values the decoder can take the address of, and on values of maps.
+type AfterDecoder interface {
+	AfterDecode() error
+}
+
+var afterDecoderType = reflect.TypeOf(new(AfterDecoder)).Elem()
+
+// afterDecode calls the AfterDecode methods of the values contained in the
+// decoded value v.
+func afterDecode(v reflect.Value) error {
+	err := afterDecodeValue(v)
+	if err == nil {
+		return nil
+	}
+	if len(err.path) == 0 {
+		return fmt.Errorf("toml: %w", err.err)
+	}
+	return fmt.Errorf("toml: %s: %w", err.keys(), err.err)
+}
+
+// afterDecodeError is an error returned by an AfterDecode method, with the
+// keys leading to the value, innermost first. The keys are only gathered once
+// an error is returned.
+type afterDecodeError struct {
+	path []string
+	err  error
+}
+
+// at adds the key leading to the value that returned e.
+func (e *afterDecodeError) at(key string) *afterDecodeError {
+	e.path = append(e.path, key)
+	return e
+}
+
+// keys returns the keys leading to the value that returned e, joined like
+// they would be written in a document, with the indexes of arrays.
+func (e *afterDecodeError) keys() string {
+	var b strings.Builder
+	for i := len(e.path) - 1; i >= 0; i-- {
+		k := e.path[i]
+		if b.Len() > 0 && !strings.HasPrefix(k, "[") {
+			b.WriteByte('.')
+		}
+		b.WriteString(k)
+	}
+	return b.String()
+}
+
+func afterDecodeValue(v reflect.Value) *afterDecodeError {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	// Values reached through unexported embedded structs cannot be used.
+	if !v.CanInterface() || !mayContainAfterDecoder(v.Type()) {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, f := range cachedStructInfo(v.Type()).keyed {
+			if f.opts.source || f.opts.headerComment {
+				continue
+			}
+			x, ok := fieldByIndex(v, f.path)
+			if !ok {
+				continue
+			}
+			if err := afterDecodeValue(x); err != nil {
+				return err.at(f.name)
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// Values of maps are not addressable: the methods with a
+			// pointer receiver are called on a copy that replaces the
+			// value.
+			x := reflect.New(iter.Value().Type()).Elem()
+			x.Set(iter.Value())
+			if err := afterDecodeValue(x); err != nil {
+				return err.at(fmt.Sprint(iter.Key().Interface()))
+			}
+			v.SetMapIndex(iter.Key(), x)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := afterDecodeValue(v.Index(i)); err != nil {
+				return err.at("[" + strconv.Itoa(i) + "]")
+			}
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	var a AfterDecoder
+	if v.CanAddr() && v.Addr().Type().Implements(afterDecoderType) {
+		a = v.Addr().Interface().(AfterDecoder)
+	} else if v.Type().Implements(afterDecoderType) {
+		a = v.Interface().(AfterDecoder)
+	} else {
+		return nil
+	}
+
+	err := a.AfterDecode()
+	if err == nil {
+		return nil
+	}
+	return &afterDecodeError{err: err}
+}
+
+var afterDecoderCache atomic.Value // map[reflect.Type]bool
+
+// mayContainAfterDecoder returns false if values of type t cannot contain a
+// value implementing AfterDecoder. The result is cached per type.
+func mayContainAfterDecoder(t reflect.Type) bool {
+	cache, _ := afterDecoderCache.Load().(map[reflect.Type]bool)
+	may, ok := cache[t]
+	if ok {
+		return may
+	}
+
+	// Only the result for t is cached: the ones of the types visited to
+	// compute it can depend on the types being visited.
+	may = typeMayContainAfterDecoder(t, map[reflect.Type]bool{})
+
+	newCache := make(map[reflect.Type]bool, len(cache)+1)
+	newCache[t] = may
+	for k, v := range cache {
+		newCache[k] = v
+	}
+	afterDecoderCache.Store(newCache)
+
+	return may
+}
+
+// typeMayContainAfterDecoder computes mayContainAfterDecoder. visiting holds
+// the struct types being traversed, which are not traversed again.
+func typeMayContainAfterDecoder(t reflect.Type, visiting map[reflect.Type]bool) bool {
+	if t.Implements(afterDecoderType) || reflect.PtrTo(t).Implements(afterDecoderType) {
+		return true
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return typeMayContainAfterDecoder(t.Elem(), visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			return false
+		}
+		visiting[t] = true
+		for _, f := range cachedStructInfo(t).keyed {
+			if f.opts.source || f.opts.headerComment {
+				continue
+			}
+			if typeMayContainAfterDecoder(t.FieldByIndex(f.path).Type, visiting) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+package toml_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type afterDecodeRange struct {
+	Start int
+	End   int
+	Name  string
+}
+
+func (r *afterDecodeRange) AfterDecode() error {
+	if r.Start > r.End {
+		return fmt.Errorf("start %d is after end %d", r.Start, r.End)
+	}
+	r.Name = strings.ToLower(r.Name)
+	return nil
+}
+
+type afterDecodeConfig struct {
+	Ranges  []afterDecodeRange
+	Named   map[string]afterDecodeRange
+	Default afterDecodeRange
+
+	order []string
+}
+
+func (c *afterDecodeConfig) AfterDecode() error {
+	for _, r := range c.Ranges {
+		c.order = append(c.order, r.Name)
+	}
+	return nil
+}
+
+func TestDecoderAfterDecode(t *testing.T) {
+	doc := `
+[[ranges]]
+start = 1
+end = 2
+name = "A"
+
+[named.x]
+start = 3
+end = 4
+name = "X"
+`
+
+	var c afterDecodeConfig
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+
+	require.Equal(t, "a", c.Ranges[0].Name)
+	require.Equal(t, "x", c.Named["x"].Name)
+	// Fields are handled before the struct that contains them.
+	require.Equal(t, []string{"a"}, c.order)
+}
+
+func TestDecoderAfterDecodeErrors(t *testing.T) {
+	examples := []struct {
+		desc string
+		doc  string
+		err  string
+	}{
+		{
+			desc: "array table",
+			doc:  "[[ranges]]\nstart = 1\nend = 2\n[[ranges]]\nstart = 2\nend = 1",
+			err:  "toml: Ranges[1]: start 2 is after end 1",
+		},
+		{
+			desc: "map",
+			doc:  "[named.x]\nstart = 2\nend = 1",
+			err:  "toml: Named.x: start 2 is after end 1",
+		},
+		{
+			desc: "field",
+			doc:  "default = { start = 2, end = 1 }",
+			err:  "toml: Default: start 2 is after end 1",
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var c afterDecodeConfig
+			err := toml.Unmarshal([]byte(e.doc), &c)
+			require.EqualError(t, err, e.err)
+		})
+	}
+
+	t.Run("root", func(t *testing.T) {
+		var r afterDecodeRange
+		err := toml.Unmarshal([]byte("start = 2\nend = 1"), &r)
+		require.EqualError(t, err, "toml: start 2 is after end 1")
+		require.EqualError(t, errors.Unwrap(err), "start 2 is after end 1")
+	})
+}
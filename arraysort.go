@@ -0,0 +1,130 @@
+package toml
+
+import (
+	"math"
+	"reflect"
+	"sort"
+)
+
+// sortSlice returns a copy of the slice v with its elements sorted by the key
+// set with SetSortArraysByField, or v itself when none of its elements has the
+// key.
+func (enc *Encoder) sortSlice(v reflect.Value) reflect.Value {
+	keys := make([]sortKey, v.Len())
+	found := false
+	for i := range keys {
+		keys[i] = enc.elementSortKey(v.Index(i))
+		found = found || keys[i].kind != sortKeyMissing
+	}
+	if !found {
+		return v
+	}
+
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return keys[order[i]].less(keys[order[j]])
+	})
+
+	s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	for i, j := range order {
+		s.Index(i).Set(v.Index(j))
+	}
+	return s
+}
+
+const (
+	sortKeyNumber = iota
+	sortKeyString
+	sortKeyMissing
+)
+
+// sortKey is the value an element of a slice is sorted by.
+type sortKey struct {
+	kind int
+
+	s string
+
+	// Integers are compared exactly when both keys are integers, and as
+	// floats otherwise.
+	f     float64
+	i     int64
+	isInt bool
+}
+
+func (k sortKey) less(o sortKey) bool {
+	if k.kind != o.kind {
+		return k.kind < o.kind
+	}
+
+	switch k.kind {
+	case sortKeyString:
+		return k.s < o.s
+	case sortKeyNumber:
+		if k.isInt && o.isInt {
+			return k.i < o.i
+		}
+		return k.f < o.f
+	default:
+		return false
+	}
+}
+
+// elementSortKey returns the sort key of the element e of a slice.
+func (enc *Encoder) elementSortKey(e reflect.Value) sortKey {
+	name := enc.arraysSortKey
+
+	for e.Kind() == reflect.Ptr || e.Kind() == reflect.Interface {
+		if e.IsNil() {
+			return sortKey{kind: sortKeyMissing}
+		}
+		e = e.Elem()
+	}
+
+	var x reflect.Value
+	switch {
+	case e.Type() == orderedMapType:
+		m := e.Interface().(OrderedMap)
+		if y, ok := m.Get(name); ok {
+			x = reflect.ValueOf(y)
+		}
+	case e.Kind() == reflect.Map:
+		if e.Type().Key().Kind() == reflect.String {
+			x = e.MapIndex(reflect.ValueOf(name).Convert(e.Type().Key()))
+		}
+	case e.Kind() == reflect.Struct:
+		path, ok := structFieldPath(e, name)
+		if !ok && enc.keyMapper != nil {
+			path, ok = structFieldPath(e, enc.keyMapper.FieldName(name))
+		}
+		if ok {
+			x, _ = fieldByIndex(e, path)
+		}
+	}
+
+	for x.IsValid() && (x.Kind() == reflect.Ptr || x.Kind() == reflect.Interface) {
+		if x.IsNil() {
+			return sortKey{kind: sortKeyMissing}
+		}
+		x = x.Elem()
+	}
+	if !x.IsValid() {
+		return sortKey{kind: sortKeyMissing}
+	}
+
+	switch x.Kind() {
+	case reflect.String:
+		return sortKey{kind: sortKeyString, s: x.String()}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sortKey{kind: sortKeyNumber, f: float64(x.Int()), i: x.Int(), isInt: true}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := x.Uint()
+		return sortKey{kind: sortKeyNumber, f: float64(u), i: int64(u), isInt: u <= math.MaxInt64}
+	case reflect.Float32, reflect.Float64:
+		return sortKey{kind: sortKeyNumber, f: x.Float()}
+	default:
+		return sortKey{kind: sortKeyMissing}
+	}
+}
@@ -0,0 +1,57 @@
+//go:build go1.19
+// +build go1.19
+
+package toml
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+func init() {
+	atomicTypes[reflect.TypeOf((*atomic.Bool)(nil)).Elem()] = atomicType{
+		value: reflect.TypeOf(false),
+		load: func(v reflect.Value) reflect.Value {
+			return reflect.ValueOf(v.Addr().Interface().(*atomic.Bool).Load())
+		},
+		store: func(v reflect.Value, x reflect.Value) {
+			v.Addr().Interface().(*atomic.Bool).Store(x.Bool())
+		},
+	}
+	atomicTypes[reflect.TypeOf((*atomic.Int32)(nil)).Elem()] = atomicType{
+		value: reflect.TypeOf(int32(0)),
+		load: func(v reflect.Value) reflect.Value {
+			return reflect.ValueOf(v.Addr().Interface().(*atomic.Int32).Load())
+		},
+		store: func(v reflect.Value, x reflect.Value) {
+			v.Addr().Interface().(*atomic.Int32).Store(int32(x.Int()))
+		},
+	}
+	atomicTypes[reflect.TypeOf((*atomic.Int64)(nil)).Elem()] = atomicType{
+		value: reflect.TypeOf(int64(0)),
+		load: func(v reflect.Value) reflect.Value {
+			return reflect.ValueOf(v.Addr().Interface().(*atomic.Int64).Load())
+		},
+		store: func(v reflect.Value, x reflect.Value) {
+			v.Addr().Interface().(*atomic.Int64).Store(x.Int())
+		},
+	}
+	atomicTypes[reflect.TypeOf((*atomic.Uint32)(nil)).Elem()] = atomicType{
+		value: reflect.TypeOf(uint32(0)),
+		load: func(v reflect.Value) reflect.Value {
+			return reflect.ValueOf(v.Addr().Interface().(*atomic.Uint32).Load())
+		},
+		store: func(v reflect.Value, x reflect.Value) {
+			v.Addr().Interface().(*atomic.Uint32).Store(uint32(x.Uint()))
+		},
+	}
+	atomicTypes[reflect.TypeOf((*atomic.Uint64)(nil)).Elem()] = atomicType{
+		value: reflect.TypeOf(uint64(0)),
+		load: func(v reflect.Value) reflect.Value {
+			return reflect.ValueOf(v.Addr().Interface().(*atomic.Uint64).Load())
+		},
+		store: func(v reflect.Value, x reflect.Value) {
+			v.Addr().Interface().(*atomic.Uint64).Store(x.Uint())
+		},
+	}
+}
@@ -0,0 +1,66 @@
+//go:build go1.19
+// +build go1.19
+
+package toml_test
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type atomicConfig struct {
+	Enabled  atomic.Bool
+	Small    atomic.Int32
+	Count    atomic.Int64
+	Workers  atomic.Uint32
+	Limit    atomic.Uint64
+	Missing  atomic.Int64 `toml:",omitempty"`
+	Counters map[string]*atomic.Int64
+}
+
+func TestMarshalAtomic(t *testing.T) {
+	var c atomicConfig
+	c.Enabled.Store(true)
+	c.Small.Store(-2)
+	c.Count.Store(42)
+	c.Workers.Store(8)
+	c.Limit.Store(1 << 40)
+	c.Counters = map[string]*atomic.Int64{"a": new(atomic.Int64)}
+	c.Counters["a"].Store(3)
+
+	b, err := toml.Marshal(&c)
+	require.NoError(t, err)
+	require.Equal(t, "Enabled = true\nSmall = -2\nCount = 42\nWorkers = 8\nLimit = 1099511627776\n[Counters]\na = 3\n\n", string(b))
+}
+
+func TestUnmarshalAtomic(t *testing.T) {
+	doc := `
+Enabled = true
+Small = -2
+Count = 42
+Workers = 8
+Limit = 1099511627776
+Counters = { a = 3 }
+`
+
+	var c atomicConfig
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+	require.True(t, c.Enabled.Load())
+	require.Equal(t, int32(-2), c.Small.Load())
+	require.Equal(t, int64(42), c.Count.Load())
+	require.Equal(t, uint32(8), c.Workers.Load())
+	require.Equal(t, uint64(1<<40), c.Limit.Load())
+	require.Equal(t, int64(0), c.Missing.Load())
+	require.Equal(t, int64(3), c.Counters["a"].Load())
+
+	err = toml.Unmarshal([]byte(`Workers = -1`), &c)
+	require.Error(t, err)
+
+	err = toml.Unmarshal([]byte(`Enabled = 1`), &c)
+	require.Error(t, err)
+	require.True(t, c.Enabled.Load())
+}
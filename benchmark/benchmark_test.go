@@ -3,6 +3,8 @@ package benchmark_test
 import (
 	"bytes"
 	"io/ioutil"
+	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -91,6 +93,46 @@ func BenchmarkUnmarshal(b *testing.B) {
 		})
 	})
 
+	b.Run("FlatTable10k", func(b *testing.B) {
+		values := map[string]string{
+			"string":  `"value"`,
+			"int64":   "42",
+			"float64": "4.2",
+			"bool":    "true",
+		}
+		targets := map[string]func() interface{}{
+			"string":  func() interface{} { return &map[string]string{} },
+			"int64":   func() interface{} { return &map[string]int64{} },
+			"float64": func() interface{} { return &map[string]float64{} },
+			"bool":    func() interface{} { return &map[string]bool{} },
+		}
+
+		for name, value := range values {
+			var buf bytes.Buffer
+			for i := 0; i < 10000; i++ {
+				buf.WriteString("key")
+				buf.WriteString(strconv.Itoa(i))
+				buf.WriteString(" = ")
+				buf.WriteString(value)
+				buf.WriteByte('\n')
+			}
+			doc := buf.Bytes()
+			target := targets[name]
+
+			b.Run(name, func(b *testing.B) {
+				b.SetBytes(int64(len(doc)))
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					err := toml.Unmarshal(doc, target())
+					if err != nil {
+						panic(err)
+					}
+				}
+			})
+		}
+	})
+
 	b.Run("HugoFrontMatter", func(b *testing.B) {
 		b.SetBytes(int64(len(hugoFrontMatterbytes)))
 		b.ReportAllocs()
@@ -112,6 +154,46 @@ func marshal(v interface{}) ([]byte, error) {
 	return b.Bytes(), err
 }
 
+func BenchmarkEncoderReset(b *testing.B) {
+	d := struct {
+		A string
+		B []int
+	}{A: "hello", B: []int{1, 2, 3}}
+
+	b.Run("new", func(b *testing.B) {
+		var buf bytes.Buffer
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			err := toml.NewEncoder(&buf).SetIndentTables(true).Encode(d)
+			if err != nil {
+				panic(err)
+			}
+		}
+	})
+
+	b.Run("pooled", func(b *testing.B) {
+		pool := sync.Pool{
+			New: func() interface{} {
+				return toml.NewEncoder(nil).SetIndentTables(true)
+			},
+		}
+
+		var buf bytes.Buffer
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			enc := pool.Get().(*toml.Encoder)
+			enc.Reset(&buf)
+			err := enc.Encode(d)
+			if err != nil {
+				panic(err)
+			}
+			pool.Put(enc)
+		}
+	})
+}
+
 func BenchmarkMarshal(b *testing.B) {
 	b.Run("SimpleDocument", func(b *testing.B) {
 		doc := []byte(`A = "hello"`)
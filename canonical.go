@@ -0,0 +1,77 @@
+package toml
+
+import (
+	"bytes"
+	"time"
+)
+
+// Canonicalize returns a normalized representation of the TOML document data.
+//
+// Two documents that decode to the same values produce the same canonical
+// form, regardless of comments, whitespace, key order, the kind of table used
+// (standard, dotted, or inline), or the way numbers and strings are written.
+// It is useful to hash or compare documents.
+//
+// The document is decoded into a map[string]interface{}, then encoded using
+// the default Encoder options, which sort keys and use a fixed formatting for
+// all values.
+func Canonicalize(data []byte) ([]byte, error) {
+	return canonicalize(data, false)
+}
+
+// Canonical returns the canonical form of the TOML document data, suited to
+// sign or cache documents: it is the form of Canonicalize, with offset
+// date-times converted to UTC. Documents holding the same values, with
+// date-times designating the same instants, produce byte-identical output.
+//
+// The output is a valid TOML document, with sorted keys, standard tables, no
+// comments, and a single space around the equal signs. Its canonical form is
+// itself.
+func Canonical(data []byte) ([]byte, error) {
+	return canonicalize(data, true)
+}
+
+func canonicalize(data []byte, utc bool) ([]byte, error) {
+	var v map[string]interface{}
+
+	err := Unmarshal(data, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	if utc {
+		toUTC(v)
+	}
+
+	var buf bytes.Buffer
+
+	err = NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// toUTC converts the offset date-times contained in the decoded value v to
+// UTC, in place.
+func toUTC(v interface{}) {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for k, e := range x {
+			if t, ok := e.(time.Time); ok {
+				x[k] = t.UTC()
+			} else {
+				toUTC(e)
+			}
+		}
+	case []interface{}:
+		for i, e := range x {
+			if t, ok := e.(time.Time); ok {
+				x[i] = t.UTC()
+			} else {
+				toUTC(e)
+			}
+		}
+	}
+}
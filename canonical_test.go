@@ -0,0 +1,115 @@
+package toml_test
+
+import (
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	examples := []struct {
+		desc string
+		a    string
+		b    string
+	}{
+		{
+			desc: "key order and comments",
+			a: `
+# comment
+b = 2
+a = 1 # another comment
+`,
+			b: `a=1
+b=2`,
+		},
+		{
+			desc: "numbers and strings",
+			a:    `a = 1_000` + "\n" + `b = 0x10` + "\n" + `c = "hello"` + "\n" + `d = 1e2`,
+			b:    `a = 1000` + "\n" + `b = 16` + "\n" + `c = 'hello'` + "\n" + `d = 100.0`,
+		},
+		{
+			desc: "table forms",
+			a: `
+[server]
+host = "localhost"
+port = 80
+[server.tls]
+enabled = true
+`,
+			b: `server = { port = 80, host = "localhost", tls.enabled = true }`,
+		},
+		{
+			desc: "array of tables",
+			a: `
+[[points]]
+x = 1
+[[points]]
+x = 2
+`,
+			b: `points = [{x = 1}, {x = 2}]`,
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			ca, err := toml.Canonicalize([]byte(e.a))
+			require.NoError(t, err)
+			cb, err := toml.Canonicalize([]byte(e.b))
+			require.NoError(t, err)
+			require.Equal(t, string(ca), string(cb))
+
+			again, err := toml.Canonicalize(ca)
+			require.NoError(t, err)
+			require.Equal(t, string(ca), string(again))
+		})
+	}
+}
+
+func TestCanonicalizeDifferent(t *testing.T) {
+	ca, err := toml.Canonicalize([]byte(`a = 1`))
+	require.NoError(t, err)
+	cb, err := toml.Canonicalize([]byte(`a = "1"`))
+	require.NoError(t, err)
+	require.NotEqual(t, string(ca), string(cb))
+}
+
+func TestCanonicalizeInvalid(t *testing.T) {
+	_, err := toml.Canonicalize([]byte(`a = `))
+	require.Error(t, err)
+}
+
+func TestCanonical(t *testing.T) {
+	a := `
+# deployed
+[release]
+at = 2021-06-01T12:00:00+02:00
+hosts = [{ name = "a", seen = 2021-06-01T05:00:00-05:00 }]
+size = 1_024
+`
+	b := `release.size = 0x400
+release.hosts = [{ seen = 2021-06-01T10:00:00Z, name = 'a' }]
+release.at     =   2021-06-01T10:00:00Z
+`
+
+	ca, err := toml.Canonical([]byte(a))
+	require.NoError(t, err)
+	cb, err := toml.Canonical([]byte(b))
+	require.NoError(t, err)
+	require.Equal(t, string(ca), string(cb))
+
+	again, err := toml.Canonical(ca)
+	require.NoError(t, err)
+	require.Equal(t, string(ca), string(again))
+
+	// Canonicalize keeps the offsets.
+	ca, err = toml.Canonicalize([]byte(a))
+	require.NoError(t, err)
+	cb, err = toml.Canonicalize([]byte(b))
+	require.NoError(t, err)
+	require.NotEqual(t, string(ca), string(cb))
+
+	_, err = toml.Canonical([]byte(`a = `))
+	require.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package toml
+
+import (
+	"reflect"
+	"time"
+)
+
+// CompatMode selects the Go types used to store the TOML values decoded into
+// an interface{}.
+type CompatMode int
+
+const (
+	// CompatDefault stores values in the types described in the documentation
+	// of Decoder.Decode.
+	CompatDefault CompatMode = iota
+
+	// CompatBurntSushi stores values in the same types as
+	// github.com/BurntSushi/toml:
+	//
+	//   | TOML       | Go                                                |
+	//   | ---------- | ------------------------------------------------- |
+	//   | String     | string                                            |
+	//   | Integer    | int64                                             |
+	//   | Float      | float64                                           |
+	//   | Boolean    | bool                                              |
+	//   | Offset DT  | time.Time                                         |
+	//   | Local DT   | time.Time in the "datetime-local" zone            |
+	//   | Local Date | time.Time in the "date-local" zone, at midnight   |
+	//   | Local Time | time.Time in the "time-local" zone, on 0000-01-01 |
+	//   | Array      | []interface{}                                     |
+	//   | Table      | map[string]interface{}                            |
+	//
+	// The zones are fixed zones with an offset of 0, named like the ones of
+	// BurntSushi/toml, so that the kind of local value can be told from the
+	// name of the location of the time.Time.
+	CompatBurntSushi
+)
+
+// Zones used by BurntSushi/toml for local date and time values.
+var (
+	burntSushiLocalDatetime = time.FixedZone("datetime-local", 0)
+	burntSushiLocalDate     = time.FixedZone("date-local", 0)
+	burntSushiLocalTime     = time.FixedZone("time-local", 0)
+)
+
+// SetCompatMode selects the Go types used to store the TOML values decoded
+// into an interface{}, to match the ones of other TOML libraries. Values
+// decoded into typed targets are not affected. Defaults to CompatDefault.
+func (d *Decoder) SetCompatMode(mode CompatMode) *Decoder {
+	d.compatMode = mode
+	return d
+}
+
+// compatLocal returns the value to store the local date or time x into the
+// interface v, when the compatibility mode calls for a different type than the
+// one of x.
+func (d *decoder) compatLocal(v reflect.Value, x interface{}) (reflect.Value, bool) {
+	if d.compatMode != CompatBurntSushi || v.Kind() != reflect.Interface {
+		return reflect.Value{}, false
+	}
+
+	var t time.Time
+	switch x := x.(type) {
+	case LocalDate:
+		t = x.AsTime(burntSushiLocalDate)
+	case LocalTime:
+		t = time.Date(0, time.January, 1, x.Hour, x.Minute, x.Second, x.Nanosecond, burntSushiLocalTime)
+	case LocalDateTime:
+		t = x.AsTime(burntSushiLocalDatetime)
+	default:
+		return reflect.Value{}, false
+	}
+
+	return reflect.ValueOf(t), true
+}
@@ -0,0 +1,71 @@
+package toml_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoderSetCompatModeBurntSushi(t *testing.T) {
+	doc := `
+s = "a"
+i = 1
+f = 1.5
+b = true
+odt = 2021-01-02T03:04:05Z
+ldt = 2021-01-02T03:04:05.5
+ld = 2021-01-02
+lt = 03:04:05
+arr = [2021-01-02, 1]
+tbl = { ld = 2021-01-02 }
+`
+
+	var v interface{}
+	err := toml.NewDecoder(strings.NewReader(doc)).SetCompatMode(toml.CompatBurntSushi).Decode(&v)
+	require.NoError(t, err)
+
+	m := v.(map[string]interface{})
+	require.Equal(t, "a", m["s"])
+	require.Equal(t, int64(1), m["i"])
+	require.Equal(t, 1.5, m["f"])
+	require.Equal(t, true, m["b"])
+	require.Equal(t, time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), m["odt"])
+
+	ldt := m["ldt"].(time.Time)
+	require.Equal(t, "datetime-local", ldt.Location().String())
+	require.Equal(t, "2021-01-02T03:04:05.5", ldt.Format("2006-01-02T15:04:05.999999999"))
+
+	ld := m["ld"].(time.Time)
+	require.Equal(t, "date-local", ld.Location().String())
+	require.Equal(t, "2021-01-02T00:00:00", ld.Format("2006-01-02T15:04:05"))
+
+	lt := m["lt"].(time.Time)
+	require.Equal(t, "time-local", lt.Location().String())
+	require.Equal(t, "0000-01-01T03:04:05", lt.Format("2006-01-02T15:04:05"))
+
+	arr := m["arr"].([]interface{})
+	require.Equal(t, "date-local", arr[0].(time.Time).Location().String())
+	require.Equal(t, int64(1), arr[1])
+
+	tbl := m["tbl"].(map[string]interface{})
+	require.Equal(t, "date-local", tbl["ld"].(time.Time).Location().String())
+}
+
+func TestDecoderSetCompatModeTypedTargets(t *testing.T) {
+	var v struct {
+		LD toml.LocalDate
+		LT interface{}
+	}
+
+	err := toml.NewDecoder(strings.NewReader("ld = 2021-01-02\nlt = 03:04:05")).SetCompatMode(toml.CompatBurntSushi).Decode(&v)
+	require.NoError(t, err)
+	require.Equal(t, toml.LocalDate{Year: 2021, Month: 1, Day: 2}, v.LD)
+	require.IsType(t, time.Time{}, v.LT)
+
+	err = toml.NewDecoder(strings.NewReader("ld = 2021-01-02\nlt = 03:04:05")).SetCompatMode(toml.CompatDefault).Decode(&v)
+	require.NoError(t, err)
+	require.Equal(t, toml.LocalTime{Hour: 3, Minute: 4, Second: 5}, v.LT)
+}
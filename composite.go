@@ -0,0 +1,280 @@
+package toml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+)
+
+// CompositeFunc builds the value of a composite field from the values of its
+// source keys. Only the source keys present in the document are provided, with
+// the same types as when decoding into an interface{}.
+type CompositeFunc func(sources map[string]interface{}) (interface{}, error)
+
+type composite struct {
+	fieldPath string
+	path      []string
+	sources   []string
+	fn        CompositeFunc
+}
+
+// RegisterComposite declares that the value of the field at fieldPath is built
+// by fn from the keys listed in sources, instead of being decoded from a single
+// key. For example:
+//
+//   year = 2021
+//   month = 3
+//
+// can be decoded into the Period field of:
+//
+//   type Report struct {
+//     Period YearMonth
+//   }
+//
+// with:
+//
+//   dec.RegisterComposite("period", []string{"year", "month"}, func(m map[string]interface{}) (interface{}, error) {
+//     return YearMonth{Year: m["year"].(int64), Month: m["month"].(int64)}, nil
+//   })
+//
+// fieldPath is the dotted list of keys leading to the field, matched against
+// struct fields like keys of the document. Sources are keys of the table that
+// contains the field. fn is not called when none of the sources are present in
+// the document. The source keys are not reported as unknown fields when
+// DisallowUnknownFields is used.
+//
+// The value returned by fn must be assignable or convertible to the type of the
+// field. Composite fields cannot be inside arrays of tables.
+func (d *Decoder) RegisterComposite(fieldPath string, sources []string, fn CompositeFunc) *Decoder {
+	d.composites = append(d.composites, composite{
+		fieldPath: fieldPath,
+		path:      strings.Split(fieldPath, "."),
+		sources:   sources,
+		fn:        fn,
+	})
+	return d
+}
+
+// compositeSources holds the values of the source keys of the composite
+// fields, collected while the document is decoded.
+type compositeSources struct {
+	// Keys of the current table, and whether it is inside an array of
+	// tables.
+	table   []string
+	inArray bool
+
+	// Keys of the arrays of tables of the document, joined by
+	// requiredPathSeparator.
+	arrays map[string]bool
+
+	// Values of the source keys of each composite, by key.
+	values []map[string]interface{}
+
+	// First error returned when decoding a value.
+	err error
+}
+
+// collectComposites records the values of the source keys of the composite
+// fields found in the expression expr.
+func (d *decoder) collectComposites(expr *ast.Node) {
+	if len(d.composites) == 0 {
+		return
+	}
+
+	s := &d.sources
+	switch expr.Kind {
+	case ast.Table, ast.ArrayTable:
+		s.table = s.table[:0]
+		s.inArray = false
+		it := expr.Key()
+		for it.Next() {
+			s.table = append(s.table, string(it.Node().Data))
+			if s.arrays[strings.Join(s.table, requiredPathSeparator)] && !it.IsLast() {
+				s.inArray = true
+			}
+		}
+		if expr.Kind == ast.ArrayTable {
+			if s.arrays == nil {
+				s.arrays = map[string]bool{}
+			}
+			s.arrays[strings.Join(s.table, requiredPathSeparator)] = true
+			s.inArray = true
+		}
+	case ast.KeyValue:
+		if !s.inArray {
+			// Values are decoded like the ones of an interface{} field.
+			ctx := d.errorContext
+			d.errorContext = nil
+			d.collectCompositeKeyValue(s.table, expr)
+			d.errorContext = ctx
+		}
+	}
+}
+
+// collectCompositeKeyValue records the values of the key-value node of the
+// table at parent that are source keys of composite fields, including the
+// ones of the inline tables it holds.
+func (d *decoder) collectCompositeKeyValue(parent []string, node *ast.Node) {
+	path := parent[:len(parent):len(parent)]
+	it := node.Key()
+	for it.Next() {
+		path = append(path, string(it.Node().Data))
+	}
+
+	value := node.Value()
+	for i, c := range d.composites {
+		if isCompositeSource(path, d.rootPath, c) {
+			d.collectCompositeValue(i, path[len(path)-1], value)
+		}
+	}
+
+	if value.Kind == ast.InlineTable {
+		children := value.Children()
+		for children.Next() {
+			d.collectCompositeKeyValue(path, children.Node())
+		}
+	}
+}
+
+// isCompositeSource returns true if the key path of the document is one of
+// the source keys of the composite c, whose field is under root.
+func isCompositeSource(path []string, root []string, c composite) bool {
+	parent := c.path[:len(c.path)-1]
+	if len(path) != len(root)+len(parent)+1 {
+		return false
+	}
+	for i, k := range root {
+		if path[i] != k {
+			return false
+		}
+	}
+	for i, k := range parent {
+		if path[len(root)+i] != k {
+			return false
+		}
+	}
+	for _, src := range c.sources {
+		if path[len(path)-1] == src {
+			return true
+		}
+	}
+	return false
+}
+
+// collectCompositeValue records the value of the source key src of the
+// composite at index i.
+func (d *decoder) collectCompositeValue(i int, src string, value *ast.Node) {
+	s := &d.sources
+	if s.values == nil {
+		s.values = make([]map[string]interface{}, len(d.composites))
+	}
+	if s.values[i] == nil {
+		s.values[i] = map[string]interface{}{}
+	}
+
+	x := reflect.New(interfaceType).Elem()
+	var err error
+	if old, ok := s.values[i][src]; ok && d.seen.AllowRepeatedScalars && value.Kind.IsScalar() {
+		x.Set(reflect.ValueOf(old))
+		err = d.handleRepeatableValue(value, x)
+	} else {
+		err = d.handleValue(value, x)
+	}
+	if err != nil {
+		if s.err == nil {
+			s.err = d.wrapError(err)
+		}
+		return
+	}
+
+	s.values[i][src] = x.Interface()
+}
+
+// applyComposites sets the composite fields of root, once the document has been
+// decoded into it.
+func (d *decoder) applyComposites(root reflect.Value) error {
+	if len(d.composites) == 0 {
+		return nil
+	}
+
+	if d.sources.err != nil {
+		return d.sources.err
+	}
+
+	for i, c := range d.composites {
+		var values map[string]interface{}
+		if d.sources.values != nil {
+			values = d.sources.values[i]
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		parentPath := append(d.rootPath[:len(d.rootPath):len(d.rootPath)], c.path[:len(c.path)-1]...)
+		d.strict.Known(parentPath, c.sources)
+
+		f, err := compositeField(root, c)
+		if err != nil {
+			return err
+		}
+
+		x, err := c.fn(values)
+		if err != nil {
+			return fmt.Errorf("toml: composite field %s: %w", c.fieldPath, err)
+		}
+
+		err = setCompositeField(f, c, x)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func compositeField(v reflect.Value, c composite) (reflect.Value, error) {
+	for _, k := range c.path {
+		for v.Kind() == reflect.Ptr {
+			v = initAndDereferencePointer(v)
+		}
+
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("toml: composite field %s: cannot find field in %s", c.fieldPath, v.Type())
+		}
+
+		path, found := structFieldPath(v, k)
+		if !found {
+			return reflect.Value{}, fmt.Errorf("toml: composite field %s: no field %s in %s", c.fieldPath, k, v.Type())
+		}
+
+		v = v.FieldByIndex(path)
+	}
+
+	return v, nil
+}
+
+func setCompositeField(v reflect.Value, c composite, x interface{}) error {
+	if x == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	rv := reflect.ValueOf(x)
+	for v.Kind() == reflect.Ptr && !rv.Type().AssignableTo(v.Type()) {
+		v = initAndDereferencePointer(v)
+	}
+
+	switch {
+	case rv.Type().AssignableTo(v.Type()):
+	case rv.Type().ConvertibleTo(v.Type()):
+		rv = rv.Convert(v.Type())
+	default:
+		return fmt.Errorf("toml: composite field %s: cannot assign value of type %s to field of type %s", c.fieldPath, rv.Type(), v.Type())
+	}
+
+	v.Set(rv)
+
+	return nil
+}
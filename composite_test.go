@@ -0,0 +1,185 @@
+package toml_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type yearMonth struct {
+	Year  int64
+	Month int64
+}
+
+func buildYearMonth(m map[string]interface{}) (interface{}, error) {
+	ym := yearMonth{}
+	ym.Year, _ = m["year"].(int64)
+	ym.Month, _ = m["month"].(int64)
+	if ym.Month < 1 || ym.Month > 12 {
+		return nil, errors.New("invalid month")
+	}
+	return ym, nil
+}
+
+func TestDecoderRegisterComposite(t *testing.T) {
+	type report struct {
+		Name   string
+		Period yearMonth
+		Nested struct {
+			Period *yearMonth
+		}
+	}
+
+	doc := `name = "r"
+year = 2021
+month = 3
+
+[nested]
+year = 2020
+month = 12
+`
+
+	var r report
+	err := toml.NewDecoder(strings.NewReader(doc)).
+		RegisterComposite("period", []string{"year", "month"}, buildYearMonth).
+		RegisterComposite("nested.period", []string{"year", "month"}, buildYearMonth).
+		DisallowUnknownFields().
+		Decode(&r)
+	require.NoError(t, err)
+
+	assert.Equal(t, "r", r.Name)
+	assert.Equal(t, yearMonth{Year: 2021, Month: 3}, r.Period)
+	require.NotNil(t, r.Nested.Period)
+	assert.Equal(t, yearMonth{Year: 2020, Month: 12}, *r.Nested.Period)
+}
+
+func TestDecoderRegisterCompositeMissingSources(t *testing.T) {
+	type report struct {
+		Period yearMonth
+	}
+
+	called := false
+	r := report{Period: yearMonth{Year: 1, Month: 1}}
+	err := toml.NewDecoder(strings.NewReader(`other = 1`)).
+		RegisterComposite("period", []string{"year", "month"}, func(m map[string]interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		}).
+		Decode(&r)
+	require.NoError(t, err)
+	assert.False(t, called)
+	assert.Equal(t, yearMonth{Year: 1, Month: 1}, r.Period)
+}
+
+func TestDecoderRegisterCompositeErrors(t *testing.T) {
+	type report struct {
+		Period yearMonth
+	}
+
+	examples := []struct {
+		desc  string
+		field string
+		fn    toml.CompositeFunc
+		err   string
+	}{
+		{
+			desc:  "function error",
+			field: "period",
+			fn:    buildYearMonth,
+			err:   "toml: composite field period: invalid month",
+		},
+		{
+			desc:  "unknown field",
+			field: "missing",
+			fn:    buildYearMonth,
+			err:   "toml: composite field missing: no field missing in toml_test.report",
+		},
+		{
+			desc:  "wrong type",
+			field: "period",
+			fn: func(m map[string]interface{}) (interface{}, error) {
+				return "2021-13", nil
+			},
+			err: "toml: composite field period: cannot assign value of type string to field of type toml_test.yearMonth",
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var r report
+			err := toml.NewDecoder(strings.NewReader("year = 2021\nmonth = 13")).
+				RegisterComposite(e.field, []string{"year", "month"}, e.fn).
+				Decode(&r)
+			require.EqualError(t, err, e.err)
+		})
+	}
+}
+
+func TestDecoderRegisterCompositeOptions(t *testing.T) {
+	type report struct {
+		Tags   []string `toml:"tag"`
+		Period yearMonth
+	}
+
+	t.Run("repeated keys", func(t *testing.T) {
+		doc := "tag = 'a'\ntag = 'b'\nyear = 2021\nmonth = 3\n"
+
+		var r report
+		err := toml.NewDecoder(strings.NewReader(doc)).
+			SetRepeatedKeyAsArray(true).
+			RegisterComposite("period", []string{"year", "month"}, buildYearMonth).
+			Decode(&r)
+		require.NoError(t, err)
+		assert.Equal(t, report{Tags: []string{"a", "b"}, Period: yearMonth{Year: 2021, Month: 3}}, r)
+	})
+
+	t.Run("root key", func(t *testing.T) {
+		doc := "year = 1999\n\n[report]\nyear = 2021\nmonth = 3\n"
+
+		var r report
+		err := toml.NewDecoder(strings.NewReader(doc)).
+			SetRootKey("report").
+			RegisterComposite("period", []string{"year", "month"}, buildYearMonth).
+			Decode(&r)
+		require.NoError(t, err)
+		assert.Equal(t, yearMonth{Year: 2021, Month: 3}, r.Period)
+	})
+
+	t.Run("inline table", func(t *testing.T) {
+		type doc struct {
+			Nested struct {
+				Period yearMonth
+			}
+		}
+
+		var d doc
+		err := toml.NewDecoder(strings.NewReader("nested = { year = 2020, month = 12 }")).
+			RegisterComposite("nested.period", []string{"year", "month"}, buildYearMonth).
+			DisallowUnknownFields().
+			Decode(&d)
+		require.NoError(t, err)
+		assert.Equal(t, yearMonth{Year: 2020, Month: 12}, d.Nested.Period)
+	})
+
+	t.Run("array tables", func(t *testing.T) {
+		called := false
+		var d struct {
+			Items []struct {
+				Period yearMonth
+			}
+		}
+		err := toml.NewDecoder(strings.NewReader("[[items]]\nyear = 2020\nmonth = 12\n")).
+			RegisterComposite("items.period", []string{"year", "month"}, func(m map[string]interface{}) (interface{}, error) {
+				called = true
+				return nil, nil
+			}).
+			Decode(&d)
+		require.NoError(t, err)
+		assert.False(t, called)
+	})
+}
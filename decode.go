@@ -1,8 +1,10 @@
 package toml
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"math/big"
 	"strconv"
 	"time"
 )
@@ -283,30 +285,46 @@ func parseFloat(b []byte) (float64, error) {
 		return math.NaN(), nil
 	}
 
-	cleaned, err := checkAndRemoveUnderscoresFloats(b)
+	cleaned, err := cleanFloat(b)
 	if err != nil {
 		return 0, err
 	}
 
+	f, err := strconv.ParseFloat(string(cleaned), 64)
+	if err != nil {
+		return 0, newDecodeError(b, "unable to parse float: %w", err)
+	}
+
+	return f, nil
+}
+
+// cleanFloat returns the TOML float b without its underscores, or an error if
+// it is not a valid float.
+func cleanFloat(b []byte) ([]byte, error) {
+	cleaned, err := checkAndRemoveUnderscoresFloats(b)
+	if err != nil {
+		return nil, err
+	}
+
 	if cleaned[0] == '.' {
-		return 0, newDecodeError(b, "float cannot start with a dot")
+		return nil, newDecodeError(b, "float cannot start with a dot")
 	}
 
 	if cleaned[len(cleaned)-1] == '.' {
-		return 0, newDecodeError(b, "float cannot end with a dot")
+		return nil, newDecodeError(b, "float cannot end with a dot")
 	}
 
 	dotAlreadySeen := false
 	for i, c := range cleaned {
 		if c == '.' {
 			if dotAlreadySeen {
-				return 0, newDecodeError(b[i:i+1], "float can have at most one decimal point")
+				return nil, newDecodeError(b[i:i+1], "float can have at most one decimal point")
 			}
 			if !isDigit(cleaned[i-1]) {
-				return 0, newDecodeError(b[i-1:i+1], "float decimal point must be preceded by a digit")
+				return nil, newDecodeError(b[i-1:i+1], "float decimal point must be preceded by a digit")
 			}
 			if !isDigit(cleaned[i+1]) {
-				return 0, newDecodeError(b[i:i+2], "float decimal point must be followed by a digit")
+				return nil, newDecodeError(b[i:i+2], "float decimal point must be followed by a digit")
 			}
 			dotAlreadySeen = true
 		}
@@ -317,12 +335,81 @@ func parseFloat(b []byte) (float64, error) {
 		start = 1
 	}
 	if cleaned[start] == '0' && isDigit(cleaned[start+1]) {
-		return 0, newDecodeError(b, "float integer part cannot have leading zeroes")
+		return nil, newDecodeError(b, "float integer part cannot have leading zeroes")
 	}
 
-	f, err := strconv.ParseFloat(string(cleaned), 64)
+	return cleaned, nil
+}
+
+// parseBigInt parses the TOML integer b, whatever its size.
+func parseBigInt(b []byte) (*big.Int, error) {
+	base := 10
+	digits := b
+	if len(b) > 2 && b[0] == '0' {
+		switch b[1] {
+		case 'x':
+			base = 16
+		case 'b':
+			base = 2
+		case 'o':
+			base = 8
+		default:
+			panic(fmt.Errorf("invalid base '%c', should have been checked by scanIntOrFloat", b[1]))
+		}
+		digits = b[2:]
+	}
+
+	cleaned, err := checkAndRemoveUnderscoresIntegers(digits)
 	if err != nil {
-		return 0, newDecodeError(b, "unable to parse float: %w", err)
+		return nil, err
+	}
+
+	if base == 10 {
+		startIdx := 0
+		if isSign(cleaned[0]) {
+			startIdx++
+		}
+		if len(cleaned) > startIdx+1 && cleaned[startIdx] == '0' {
+			return nil, newDecodeError(b, "leading zero not allowed on decimal number")
+		}
+	}
+
+	i, ok := new(big.Int).SetString(string(cleaned), base)
+	if !ok {
+		return nil, newDecodeError(b, "couldn't parse number")
+	}
+
+	return i, nil
+}
+
+// parseBigFloat parses the TOML float b, with a precision large enough to
+// hold all its digits.
+func parseBigFloat(b []byte) (*big.Float, error) {
+	unsigned := b
+	if isSign(b[0]) {
+		unsigned = b[1:]
+	}
+	switch string(unsigned) {
+	case "inf":
+		return new(big.Float).SetInf(b[0] == '-'), nil
+	case "nan":
+		return nil, newDecodeError(b, "nan cannot be represented by a big.Float")
+	}
+
+	cleaned, err := cleanFloat(b)
+	if err != nil {
+		return nil, err
+	}
+
+	// Four bits per decimal digit are enough to represent them all.
+	prec := uint(len(cleaned)) * 4
+	if prec < 64 {
+		prec = 64
+	}
+
+	f, _, err := big.ParseFloat(string(cleaned), 10, prec, big.ToNearestEven)
+	if err != nil {
+		return nil, newDecodeError(b, "unable to parse float: %w", err)
 	}
 
 	return f, nil
@@ -336,7 +423,7 @@ func parseIntHex(b []byte) (int64, error) {
 
 	i, err := strconv.ParseInt(string(cleaned), 16, 64)
 	if err != nil {
-		return 0, newDecodeError(b, "couldn't parse hexadecimal number: %w", err)
+		return 0, parseIntError(b, "hexadecimal", err)
 	}
 
 	return i, nil
@@ -350,7 +437,7 @@ func parseIntOct(b []byte) (int64, error) {
 
 	i, err := strconv.ParseInt(string(cleaned), 8, 64)
 	if err != nil {
-		return 0, newDecodeError(b, "couldn't parse octal number: %w", err)
+		return 0, parseIntError(b, "octal", err)
 	}
 
 	return i, nil
@@ -364,12 +451,21 @@ func parseIntBin(b []byte) (int64, error) {
 
 	i, err := strconv.ParseInt(string(cleaned), 2, 64)
 	if err != nil {
-		return 0, newDecodeError(b, "couldn't parse binary number: %w", err)
+		return 0, parseIntError(b, "binary", err)
 	}
 
 	return i, nil
 }
 
+// parseIntError returns the error for the integer b, written in base, that
+// strconv.ParseInt failed to parse with err.
+func parseIntError(b []byte, base string, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return newDecodeError(b, "integer %s exceeds the 64-bit range of TOML integers, from %d to %d", b, math.MinInt64, math.MaxInt64)
+	}
+	return newDecodeError(b, "couldn't parse %s number: %w", base, err)
+}
+
 func isSign(b byte) bool {
 	return b == '+' || b == '-'
 }
@@ -392,7 +488,7 @@ func parseIntDec(b []byte) (int64, error) {
 
 	i, err := strconv.ParseInt(string(cleaned), 10, 64)
 	if err != nil {
-		return 0, newDecodeError(b, "couldn't parse decimal number: %w", err)
+		return 0, parseIntError(b, "decimal", err)
 	}
 
 	return i, nil
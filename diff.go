@@ -0,0 +1,174 @@
+package toml
+
+import (
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ChangeKind is the kind of a Change between two documents.
+type ChangeKind int
+
+const (
+	// ChangeAdded is a key or an array element only present in the second
+	// document.
+	ChangeAdded ChangeKind = iota
+
+	// ChangeRemoved is a key or an array element only present in the first
+	// document.
+	ChangeRemoved
+
+	// ChangeModified is a key or an array element present in both documents
+	// with different values.
+	ChangeModified
+)
+
+// String returns the name of the kind, like "added".
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	default:
+		return "ChangeKind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// Change is a difference between two documents, found by Diff.
+type Change struct {
+	// Keys leading to the value, from the root of the document. Elements of
+	// arrays are designated by their index, starting at 0.
+	Path []string
+
+	Kind ChangeKind
+
+	// Values in the first and the second document, as decoded into an
+	// interface{}. OldValue is nil for ChangeAdded, and NewValue for
+	// ChangeRemoved.
+	OldValue interface{}
+	NewValue interface{}
+}
+
+// Diff returns the differences between the TOML documents a and b, ordered by
+// path, with the keys of each table sorted.
+//
+// Documents are compared by their decoded values: comments, key order, the
+// kind of table used and the way values are written are ignored, so 1_000 and
+// 1000 are equal. Values of different types, like 1 and 1.0 or 1 and "1", are
+// modified. Tables are compared key by key, and arrays element by element:
+// inserting an element in an array modifies all the elements that follow it.
+func Diff(a, b []byte) ([]Change, error) {
+	var va, vb map[string]interface{}
+
+	err := Unmarshal(a, &va)
+	if err != nil {
+		return nil, err
+	}
+
+	err = Unmarshal(b, &vb)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []Change
+	changes = diffTables(changes, nil, va, vb)
+
+	return changes, nil
+}
+
+func diffTables(changes []Change, path []string, a, b map[string]interface{}) []Change {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		x, inA := a[k]
+		y, inB := b[k]
+		p := diffPath(path, k)
+
+		switch {
+		case !inA:
+			changes = append(changes, Change{Path: p, Kind: ChangeAdded, NewValue: y})
+		case !inB:
+			changes = append(changes, Change{Path: p, Kind: ChangeRemoved, OldValue: x})
+		default:
+			changes = diffValues(changes, p, x, y)
+		}
+	}
+
+	return changes
+}
+
+func diffArrays(changes []Change, path []string, a, b []interface{}) []Change {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		p := diffPath(path, strconv.Itoa(i))
+
+		switch {
+		case i >= len(a):
+			changes = append(changes, Change{Path: p, Kind: ChangeAdded, NewValue: b[i]})
+		case i >= len(b):
+			changes = append(changes, Change{Path: p, Kind: ChangeRemoved, OldValue: a[i]})
+		default:
+			changes = diffValues(changes, p, a[i], b[i])
+		}
+	}
+
+	return changes
+}
+
+func diffValues(changes []Change, path []string, a, b interface{}) []Change {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		if y, ok := b.(map[string]interface{}); ok {
+			return diffTables(changes, path, x, y)
+		}
+	case []interface{}:
+		if y, ok := b.([]interface{}); ok {
+			return diffArrays(changes, path, x, y)
+		}
+	}
+
+	if !equalValues(a, b) {
+		changes = append(changes, Change{Path: path, Kind: ChangeModified, OldValue: a, NewValue: b})
+	}
+
+	return changes
+}
+
+// equalValues reports whether the scalars a and b, decoded from a document,
+// are the same TOML value.
+func equalValues(a, b interface{}) bool {
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		return ok && (x == y || (math.IsNaN(x) && math.IsNaN(y)))
+	case time.Time:
+		// Offset date-times are the same value when they have the same
+		// offset, not only when they designate the same instant.
+		y, ok := b.(time.Time)
+		return ok && x.Format(time.RFC3339Nano) == y.Format(time.RFC3339Nano)
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// diffPath returns a copy of path with k appended, so that the paths of
+// different changes do not share their backing arrays.
+func diffPath(path []string, k string) []string {
+	p := make([]string, len(path)+1)
+	copy(p, path)
+	p[len(path)] = k
+	return p
+}
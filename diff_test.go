@@ -0,0 +1,55 @@
+package toml_test
+
+import (
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	a := `
+# comment
+name = "app"
+port = 1_000
+ratio = nan
+removed = true
+tags = ["a", "b"]
+
+[db]
+host = "localhost"
+timeout = 1
+`
+	b := `
+port = 1000
+name = "app"
+ratio = nan
+tags = ["a", "c", "d"]
+added = 2021-01-02
+
+db.host = "localhost"
+db.timeout = "1s"
+`
+
+	changes, err := toml.Diff([]byte(a), []byte(b))
+	require.NoError(t, err)
+	require.Equal(t, []toml.Change{
+		{Path: []string{"added"}, Kind: toml.ChangeAdded, NewValue: toml.LocalDate{Year: 2021, Month: 1, Day: 2}},
+		{Path: []string{"db", "timeout"}, Kind: toml.ChangeModified, OldValue: int64(1), NewValue: "1s"},
+		{Path: []string{"removed"}, Kind: toml.ChangeRemoved, OldValue: true},
+		{Path: []string{"tags", "1"}, Kind: toml.ChangeModified, OldValue: "b", NewValue: "c"},
+		{Path: []string{"tags", "2"}, Kind: toml.ChangeAdded, NewValue: "d"},
+	}, changes)
+
+	changes, err = toml.Diff([]byte(a), []byte(a))
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	changes, err = toml.Diff([]byte("t = 2021-01-02T00:00:00Z\nv = 1"), []byte("t = 2021-01-02T01:00:00+01:00\nv = 1.0"))
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+	require.Equal(t, "modified", changes[0].Kind.String())
+
+	_, err = toml.Diff([]byte("a = 1"), []byte("a = "))
+	require.Error(t, err)
+}
@@ -0,0 +1,411 @@
+package toml
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+	"github.com/pelletier/go-toml/v2/internal/danger"
+)
+
+// Document is a TOML document that can be edited while preserving its
+// formatting. Only the values that are set are rewritten: comments, blank
+// lines, indentation and the order of the keys are kept as they are, and a
+// Document that is not modified is emitted byte for byte.
+//
+//   doc, err := toml.ParseDocument(data)
+//   if err != nil {
+//     return err
+//   }
+//   err = doc.Set(toml.Key{"server", "port"}, 8080)
+//   if err != nil {
+//     return err
+//   }
+//   data = doc.Bytes()
+//
+// Keys are designated like in MetaData: the elements of arrays of tables are
+// designated by their index in decimal.
+//
+// The comment lines directly above a key-value or a table header are read
+// and written with Comment and SetComment.
+type Document struct {
+	data []byte
+
+	// Key-values of the document, including the ones of inline tables, in
+	// the order they appear.
+	values []documentValue
+
+	// Tables that have a header, preceded by the root table, in the order
+	// they appear.
+	tables []documentTable
+}
+
+type documentValue struct {
+	key Key
+
+	// Bytes of the value in the document.
+	offset int
+	length int
+
+	comment documentComment
+}
+
+type documentTable struct {
+	key Key
+
+	comment documentComment
+
+	// Offset of the end of the last line of the table.
+	end int
+
+	// Indentation of the last key-value of the table.
+	indent []byte
+}
+
+// documentComment is the range of the comment lines directly above a
+// key-value or a table header, which ends at the start of its line. Both
+// offsets are -1 for the key-values of inline tables and the root table,
+// which cannot have one.
+type documentComment struct {
+	start int
+	end   int
+}
+
+// ParseDocument parses the TOML document data. The document must be valid: it
+// is decoded as with Unmarshal, and the error is returned if it is not. The
+// Document keeps a copy of data, which can be modified afterwards.
+func ParseDocument(data []byte) (*Document, error) {
+	var v interface{}
+	err := Unmarshal(data, &v)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Document{data: append([]byte(nil), data...)}
+	d.index()
+
+	return d, nil
+}
+
+// index records the key-values and the tables of the document.
+func (d *Document) index() {
+	p := parser{}
+	p.Reset(d.data)
+
+	var meta MetaData
+	d.values = d.values[:0]
+	d.tables = append(d.tables[:0], documentTable{comment: documentComment{-1, -1}})
+
+	for p.NextExpression() {
+		expr := p.Expression()
+
+		switch expr.Kind {
+		case ast.Table, ast.ArrayTable:
+			key := meta.addTable(expr)
+			d.tables = append(d.tables, documentTable{
+				key:     key,
+				comment: d.commentRange(p.Comments(), lastKeyPart(expr)),
+				end:     d.lineEnd(lastKeyPart(expr)),
+			})
+		case ast.KeyValue:
+			t := &d.tables[len(d.tables)-1]
+			k := expr.Key()
+			k.Next()
+			offset := int(k.Node().Raw.Offset)
+			n := len(d.values)
+			end := d.addKeyValue(&p, t.key, expr)
+			d.values[n].comment = d.commentRange(p.Comments(), offset)
+			t.end = d.lineEnd(end)
+			t.indent = d.lineIndent(offset)
+		}
+	}
+}
+
+// addKeyValue records the key-value node of the table at parent, and returns
+// the offset of the end of its value.
+func (d *Document) addKeyValue(p *parser, parent Key, node *ast.Node) int {
+	key := append(Key(nil), parent...)
+	it := node.Key()
+	for it.Next() {
+		key = append(key, string(it.Node().Data))
+	}
+
+	value := node.Value()
+	raw := value.Data
+	if value.Raw.Length > 0 {
+		raw = p.Raw(value.Raw)
+	}
+
+	offset := d.offset(raw)
+	d.values = append(d.values, documentValue{
+		key:     key,
+		offset:  offset,
+		length:  len(raw),
+		comment: documentComment{-1, -1},
+	})
+
+	if value.Kind == ast.InlineTable {
+		children := value.Children()
+		for children.Next() {
+			d.addKeyValue(p, key, children.Node())
+		}
+	}
+
+	return offset + len(raw)
+}
+
+func (d *Document) offset(b []byte) int {
+	return danger.SubsliceOffset(d.data, b)
+}
+
+// lineEnd returns the offset following the end of the line containing the
+// offset i, newline included.
+func (d *Document) lineEnd(i int) int {
+	idx := bytes.IndexByte(d.data[i:], '\n')
+	if idx < 0 {
+		return len(d.data)
+	}
+	return i + idx + 1
+}
+
+// lineStart returns the offset of the start of the line containing the offset
+// i.
+func (d *Document) lineStart(i int) int {
+	return bytes.LastIndexByte(d.data[:i], '\n') + 1
+}
+
+// commentRange returns the range of the comment lines directly above the line
+// containing the offset i.
+func (d *Document) commentRange(comments [][]byte, i int) documentComment {
+	end := d.lineStart(i)
+	if len(comments) == 0 {
+		return documentComment{end, end}
+	}
+	return documentComment{d.lineStart(d.offset(comments[0])), end}
+}
+
+// lineIndent returns the whitespace preceding the offset i on its line.
+func (d *Document) lineIndent(i int) []byte {
+	start := d.lineStart(i)
+	line := d.data[start:i]
+	if len(bytes.TrimLeft(line, " \t")) > 0 {
+		return nil
+	}
+	return line
+}
+
+func lastKeyPart(expr *ast.Node) int {
+	end := 0
+	it := expr.Key()
+	for it.Next() {
+		n := it.Node()
+		end = int(n.Raw.Offset + n.Raw.Length)
+	}
+	return end
+}
+
+func (d *Document) value(key Key) (documentValue, bool) {
+	for _, v := range d.values {
+		if joinKey(v.key) == joinKey(key) {
+			return v, true
+		}
+	}
+	return documentValue{}, false
+}
+
+// Get returns the value of the key, decoded as with an interface{} target. It
+// returns false if the document does not contain a key-value with this key:
+// tables defined with a header are not returned.
+func (d *Document) Get(key ...string) (interface{}, bool) {
+	v, ok := d.value(key)
+	if !ok {
+		return nil, false
+	}
+
+	doc := append([]byte("v = "), d.data[v.offset:v.offset+v.length]...)
+
+	var x struct{ V interface{} }
+	err := Unmarshal(doc, &x)
+	if err != nil {
+		return nil, false
+	}
+
+	return x.V, true
+}
+
+// Set sets the value of the key to v, encoded like with MarshalValue. The
+// bytes of the previous value are replaced, and the rest of the document is
+// left untouched.
+//
+// A missing key is added at the end of the closest table containing it that is
+// defined with a header, or of the root table, with a dotted key if needed. It
+// is an error if the resulting document is not valid, for example when a key
+// would be added to an inline table.
+func (d *Document) Set(key Key, v interface{}) error {
+	if len(key) == 0 {
+		return fmt.Errorf("toml: cannot set the root table of a document")
+	}
+
+	value, err := MarshalValue(v)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if x, ok := d.value(key); ok {
+		data = splice(d.data, x.offset, x.length, value)
+	} else {
+		data = d.insert(key, value)
+	}
+
+	var tmp interface{}
+	err = Unmarshal(data, &tmp)
+	if err != nil {
+		return fmt.Errorf("toml: cannot set %s: %w", strings.Join(key, "."), err)
+	}
+
+	d.data = data
+	d.index()
+
+	return nil
+}
+
+// insert returns the document with a key-value added for the missing key.
+func (d *Document) insert(key Key, value []byte) []byte {
+	t := d.tables[0]
+	for _, x := range d.tables[1:] {
+		if len(x.key) > len(t.key) && len(x.key) < len(key) && joinKey(key[:len(x.key)]) == joinKey(x.key) {
+			t = x
+		}
+	}
+
+	eol := d.lineEnding()
+
+	var kv []byte
+	if t.end > 0 && d.data[t.end-1] != '\n' {
+		kv = append(kv, eol...)
+	}
+	kv = append(kv, t.indent...)
+	enc := NewEncoder(nil)
+	for i, k := range key[len(t.key):] {
+		if i > 0 {
+			kv = append(kv, '.')
+		}
+		kv = enc.encodeKey(kv, k)
+	}
+	kv = append(kv, " = "...)
+	kv = append(kv, value...)
+	kv = append(kv, eol...)
+
+	return splice(d.data, t.end, 0, kv)
+}
+
+// lineEnding returns the sequence ending the first line of the document, "\n"
+// if it has a single line.
+func (d *Document) lineEnding() string {
+	i := bytes.IndexByte(d.data, '\n')
+	if i > 0 && d.data[i-1] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+func splice(data []byte, offset int, length int, b []byte) []byte {
+	out := make([]byte, 0, len(data)-length+len(b))
+	out = append(out, data[:offset]...)
+	out = append(out, b...)
+	return append(out, data[offset+length:]...)
+}
+
+// comment returns the range of the comment of the key-value or the table
+// header of the key.
+func (d *Document) comment(key Key) (documentComment, bool) {
+	if v, ok := d.value(key); ok {
+		return v.comment, true
+	}
+	for _, t := range d.tables[1:] {
+		if joinKey(t.key) == joinKey(key) {
+			return t.comment, true
+		}
+	}
+	return documentComment{}, false
+}
+
+// Comment returns the comment lines directly above the key-value or the table
+// header of the key, without blank lines between them. Each line is stripped
+// of its # and of the space following it, and the lines are joined with
+// newlines. It returns an empty string if there is no such comment, or no
+// such key-value or table header.
+func (d *Document) Comment(key ...string) string {
+	c, ok := d.comment(key)
+	if !ok || c.start == c.end {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimRight(string(d.data[c.start:c.end]), "\r\n"), "\n")
+	for i, l := range lines {
+		l = strings.TrimSuffix(strings.TrimLeft(l, " \t"), "\r")
+		lines[i] = strings.TrimPrefix(l[1:], " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// SetComment replaces the comment lines directly above the key-value or the
+// table header of the key with text, emitting one comment line for each of
+// its lines. The comment lines are indented like the line they are above. An
+// empty text removes the comment lines. The rest of the document is left
+// untouched.
+//
+// It is an error if the document has no key-value or table header with this
+// key, or if the key belongs to an inline table.
+func (d *Document) SetComment(key Key, text string) error {
+	c, ok := d.comment(key)
+	if !ok {
+		return fmt.Errorf("toml: cannot set the comment of %s: no such key-value or table", strings.Join(key, "."))
+	}
+	if c.start < 0 {
+		return fmt.Errorf("toml: cannot set the comment of %s: it belongs to an inline table", strings.Join(key, "."))
+	}
+
+	line := d.data[c.end:d.lineEnd(c.end)]
+	indent := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+	eol := "\n"
+	if bytes.HasSuffix(line, []byte("\r\n")) {
+		eol = "\r\n"
+	}
+
+	var comment []byte
+	if text != "" {
+		for _, l := range strings.Split(text, "\n") {
+			comment = append(comment, indent...)
+			comment = append(comment, '#')
+			if l != "" {
+				comment = append(comment, ' ')
+				comment = append(comment, l...)
+			}
+			comment = append(comment, eol...)
+		}
+	}
+
+	data := splice(d.data, c.start, c.end-c.start, comment)
+
+	var tmp interface{}
+	err := Unmarshal(data, &tmp)
+	if err != nil {
+		return fmt.Errorf("toml: cannot set the comment of %s: %w", strings.Join(key, "."), err)
+	}
+
+	d.data = data
+	d.index()
+
+	return nil
+}
+
+// Bytes returns the document, with the values that were set.
+func (d *Document) Bytes() []byte {
+	b := make([]byte, len(d.data))
+	copy(b, d.data)
+	return b
+}
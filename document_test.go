@@ -0,0 +1,237 @@
+package toml_test
+
+import (
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const documentDoc = `# Configuration of the service.
+title = "example"   # shown in the UI
+
+[server]
+  host = 'localhost'
+  port = 80 # default
+
+  tags = [
+    "a", # first
+    "b",
+  ]
+
+[[workers]]
+name = "w1"
+limits = { cpu = 2, memory = "1G" }
+
+[[workers]]
+name = "w2"
+`
+
+func TestDocumentRoundTrip(t *testing.T) {
+	docs := []string{
+		documentDoc,
+		"",
+		"a = 1",
+		"# only a comment\r\n\r\na = 'x'\r\n",
+		"[a.b]\n\n\n[c]\nd = 1979-05-27T07:32:00Z\n",
+	}
+
+	for _, data := range docs {
+		doc, err := toml.ParseDocument([]byte(data))
+		require.NoError(t, err)
+		require.Equal(t, data, string(doc.Bytes()))
+	}
+}
+
+func TestDocumentGet(t *testing.T) {
+	doc, err := toml.ParseDocument([]byte(documentDoc))
+	require.NoError(t, err)
+
+	examples := []struct {
+		key      []string
+		expected interface{}
+	}{
+		{key: []string{"title"}, expected: "example"},
+		{key: []string{"server", "port"}, expected: int64(80)},
+		{key: []string{"server", "tags"}, expected: []interface{}{"a", "b"}},
+		{key: []string{"workers", "0", "limits", "cpu"}, expected: int64(2)},
+		{key: []string{"workers", "1", "name"}, expected: "w2"},
+	}
+
+	for _, e := range examples {
+		v, ok := doc.Get(e.key...)
+		require.True(t, ok, "%v", e.key)
+		require.Equal(t, e.expected, v)
+	}
+
+	_, ok := doc.Get("server")
+	require.False(t, ok)
+	_, ok = doc.Get("workers", "2", "name")
+	require.False(t, ok)
+}
+
+func TestDocumentSet(t *testing.T) {
+	doc, err := toml.ParseDocument([]byte(documentDoc))
+	require.NoError(t, err)
+
+	require.NoError(t, doc.Set(toml.Key{"server", "port"}, 8080))
+	require.NoError(t, doc.Set(toml.Key{"server", "tags"}, []string{"c"}))
+	require.NoError(t, doc.Set(toml.Key{"workers", "0", "limits", "memory"}, "2G"))
+	require.NoError(t, doc.Set(toml.Key{"workers", "1", "name"}, "w3"))
+
+	expected := `# Configuration of the service.
+title = "example"   # shown in the UI
+
+[server]
+  host = 'localhost'
+  port = 8080 # default
+
+  tags = ['c']
+
+[[workers]]
+name = "w1"
+limits = { cpu = 2, memory = '2G' }
+
+[[workers]]
+name = 'w3'
+`
+	require.Equal(t, expected, string(doc.Bytes()))
+
+	v, ok := doc.Get("server", "port")
+	require.True(t, ok)
+	require.Equal(t, int64(8080), v)
+}
+
+func TestDocumentSetMissingKey(t *testing.T) {
+	doc, err := toml.ParseDocument([]byte(documentDoc))
+	require.NoError(t, err)
+
+	require.NoError(t, doc.Set(toml.Key{"version"}, 2))
+	require.NoError(t, doc.Set(toml.Key{"server", "timeout"}, "30s"))
+	require.NoError(t, doc.Set(toml.Key{"server", "tls", "enabled"}, true))
+	require.NoError(t, doc.Set(toml.Key{"workers", "0", "queue"}, "q 1"))
+	require.NoError(t, doc.Set(toml.Key{"workers", "1", "my key"}, 1))
+
+	expected := `# Configuration of the service.
+title = "example"   # shown in the UI
+version = 2
+
+[server]
+  host = 'localhost'
+  port = 80 # default
+
+  tags = [
+    "a", # first
+    "b",
+  ]
+  timeout = '30s'
+  tls.enabled = true
+
+[[workers]]
+name = "w1"
+limits = { cpu = 2, memory = "1G" }
+queue = 'q 1'
+
+[[workers]]
+name = "w2"
+'my key' = 1
+`
+	require.Equal(t, expected, string(doc.Bytes()))
+
+	doc, err = toml.ParseDocument([]byte("a = 1"))
+	require.NoError(t, err)
+	require.NoError(t, doc.Set(toml.Key{"b"}, 2))
+	require.Equal(t, "a = 1\nb = 2\n", string(doc.Bytes()))
+
+	doc, err = toml.ParseDocument([]byte("x = 1\r\ny = 2\r\n[t]\r\na = 1"))
+	require.NoError(t, err)
+	require.NoError(t, doc.Set(toml.Key{"z"}, 5))
+	require.NoError(t, doc.Set(toml.Key{"t", "b"}, 6))
+	require.Equal(t, "x = 1\r\ny = 2\r\nz = 5\r\n[t]\r\na = 1\r\nb = 6\r\n", string(doc.Bytes()))
+}
+
+func TestParseDocumentCopiesData(t *testing.T) {
+	data := []byte("a = 1\n")
+	doc, err := toml.ParseDocument(data)
+	require.NoError(t, err)
+
+	data[4] = '2'
+	v, ok := doc.Get("a")
+	require.True(t, ok)
+	require.Equal(t, int64(1), v)
+	require.Equal(t, "a = 1\n", string(doc.Bytes()))
+}
+
+func TestDocumentComment(t *testing.T) {
+	doc, err := toml.ParseDocument([]byte(documentDoc))
+	require.NoError(t, err)
+
+	require.Equal(t, "Configuration of the service.", doc.Comment("title"))
+	require.Equal(t, "", doc.Comment("server"))
+	require.Equal(t, "", doc.Comment("server", "tags"))
+	require.Equal(t, "", doc.Comment("missing"))
+
+	require.NoError(t, doc.SetComment(toml.Key{"title"}, ""))
+	require.NoError(t, doc.SetComment(toml.Key{"server"}, "Server settings."))
+	require.NoError(t, doc.SetComment(toml.Key{"server", "port"}, "Port to listen on.\n\nMust be free."))
+	require.NoError(t, doc.SetComment(toml.Key{"workers", "1"}, "Second worker."))
+
+	expected := `title = "example"   # shown in the UI
+
+# Server settings.
+[server]
+  host = 'localhost'
+  # Port to listen on.
+  #
+  # Must be free.
+  port = 80 # default
+
+  tags = [
+    "a", # first
+    "b",
+  ]
+
+[[workers]]
+name = "w1"
+limits = { cpu = 2, memory = "1G" }
+
+# Second worker.
+[[workers]]
+name = "w2"
+`
+	require.Equal(t, expected, string(doc.Bytes()))
+	require.Equal(t, "Port to listen on.\n\nMust be free.", doc.Comment("server", "port"))
+
+	require.NoError(t, doc.SetComment(toml.Key{"server", "port"}, "Port."))
+	require.Equal(t, "Port.", doc.Comment("server", "port"))
+
+	err = doc.SetComment(toml.Key{"workers", "0", "limits", "cpu"}, "x")
+	require.Error(t, err)
+	err = doc.SetComment(toml.Key{"missing"}, "x")
+	require.Error(t, err)
+	err = doc.SetComment(toml.Key{"title"}, "bad\x00")
+	require.Error(t, err)
+
+	doc, err = toml.ParseDocument([]byte("# a\r\n# b\r\na = 1\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, "a\nb", doc.Comment("a"))
+	require.NoError(t, doc.SetComment(toml.Key{"a"}, "c"))
+	require.Equal(t, "# c\r\na = 1\r\n", string(doc.Bytes()))
+}
+
+func TestDocumentErrors(t *testing.T) {
+	_, err := toml.ParseDocument([]byte("a = 1\na = 2"))
+	require.Error(t, err)
+
+	doc, err := toml.ParseDocument([]byte(documentDoc))
+	require.NoError(t, err)
+
+	err = doc.Set(toml.Key{"workers", "0", "limits", "disk"}, "1T")
+	require.Error(t, err)
+	err = doc.Set(toml.Key{"title", "x"}, 1)
+	require.Error(t, err)
+	err = doc.Set(nil, 1)
+	require.Error(t, err)
+
+	require.Equal(t, documentDoc, string(doc.Bytes()))
+}
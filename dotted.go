@@ -0,0 +1,171 @@
+package toml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// dottedTable is the table designated by the first segment of the names of the
+// fields tagged with the "dotted" option, like server for the names
+// server.http.port and server.http.host.
+//
+// The table is decoded and encoded through structs built for the purpose, the
+// fields of which stand for the rest of the names. When decoding, their leaves
+// are pointers to the fields of the original struct, so that the values are
+// written in place. When encoding, they are copies of the fields.
+type dottedTable struct {
+	decodeType reflect.Type
+	encodeType reflect.Type
+	leaves     []dottedLeaf
+}
+
+// dottedLeaf maps a field of the struct built for a dottedTable to the field
+// of the original struct.
+type dottedLeaf struct {
+	view  []int
+	field []int
+}
+
+// dottedField is a field tagged with the "dotted" option, as collected by
+// cachedStructInfo.
+type dottedField struct {
+	segments []string
+	path     []int
+}
+
+type dottedNode struct {
+	name     string
+	children []*dottedNode
+
+	// For leaves only.
+	field []int
+	typ   reflect.Type
+	tag   string
+}
+
+func (n *dottedNode) child(name string) *dottedNode {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// newDottedTables groups the dotted fields of the struct type t by the first
+// segment of their name. It returns a description of the first conflict
+// between two of the names, if any.
+func newDottedTables(t reflect.Type, fields []dottedField) (map[string]*dottedTable, string) {
+	var roots []*dottedNode
+	conflict := ""
+
+	for _, f := range fields {
+		var n *dottedNode
+		for _, r := range roots {
+			if r.name == f.segments[0] {
+				n = r
+			}
+		}
+		if n == nil {
+			n = &dottedNode{name: f.segments[0]}
+			roots = append(roots, n)
+		}
+
+		for i, s := range f.segments[1:] {
+			c := n.child(s)
+			var other []int
+			key := f.segments[:i+2]
+			if n.field != nil {
+				other = n.field
+				key = key[:len(key)-1]
+			} else if c != nil && (c.field != nil || i == len(f.segments)-2) {
+				other = firstLeaf(c)
+			}
+			if other != nil {
+				if conflict == "" {
+					conflict = fmt.Sprintf("fields %s and %s of %s both use the key %s", fieldPathName(t, other), fieldPathName(t, f.path), t, strings.Join(key, "."))
+				}
+				break
+			}
+			if c == nil {
+				c = &dottedNode{name: s}
+				n.children = append(n.children, c)
+			}
+			n = c
+			if i == len(f.segments)-2 {
+				sf := t.FieldByIndex(f.path)
+				n.field = f.path
+				n.typ = sf.Type
+				n.tag = dottedLeafTag(s, sf.Tag.Get("toml"))
+			}
+		}
+	}
+
+	tables := make(map[string]*dottedTable, 2*len(roots))
+	for _, r := range roots {
+		dt := &dottedTable{}
+		dt.decodeType = dottedType(r, true, nil, &dt.leaves)
+		dt.encodeType = dottedType(r, false, nil, nil)
+		tables[r.name] = dt
+		if _, ok := tables[strings.ToLower(r.name)]; !ok {
+			tables[strings.ToLower(r.name)] = dt
+		}
+	}
+
+	return tables, conflict
+}
+
+func firstLeaf(n *dottedNode) []int {
+	for n.field == nil {
+		n = n.children[0]
+	}
+	return n.field
+}
+
+// dottedLeafTag returns the tag of the field standing for the last segment of
+// a dotted name, keeping the options of the original tag.
+func dottedLeafTag(name string, tag string) string {
+	opts := []string{name}
+	if idx := strings.Index(tag, ","); idx >= 0 {
+		for _, o := range strings.Split(tag[idx+1:], ",") {
+			if o != "dotted" {
+				opts = append(opts, o)
+			}
+		}
+	}
+	return strings.Join(opts, ",")
+}
+
+// dottedType builds the struct type standing for the children of n. When ptr
+// is true, the leaves are pointers to the types of the fields, and their paths
+// are recorded in leaves.
+func dottedType(n *dottedNode, ptr bool, path []int, leaves *[]dottedLeaf) reflect.Type {
+	fields := make([]reflect.StructField, 0, len(n.children))
+	for i, c := range n.children {
+		p := append(path[:len(path):len(path)], i)
+
+		var typ reflect.Type
+		tag := c.name
+		if c.field == nil {
+			typ = dottedType(c, ptr, p, leaves)
+		} else {
+			typ = c.typ
+			tag = c.tag
+			if ptr {
+				typ = reflect.PtrTo(typ)
+			}
+			if leaves != nil {
+				*leaves = append(*leaves, dottedLeaf{view: p, field: c.field})
+			}
+		}
+
+		fields = append(fields, reflect.StructField{
+			Name: "F" + strconv.Itoa(i),
+			Type: typ,
+			Tag:  reflect.StructTag("toml:" + strconv.Quote(tag)),
+		})
+	}
+	return reflect.StructOf(fields)
+}
@@ -0,0 +1,134 @@
+package toml_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type dottedConfig struct {
+	Name  string `toml:"name"`
+	Port  int    `toml:"server.http.port,dotted"`
+	Host  string `toml:"server.http.host,dotted"`
+	Debug bool   `toml:"server.debug,dotted"`
+	Level string `toml:"log.level,dotted,omitempty"`
+}
+
+func TestUnmarshalDotted(t *testing.T) {
+	examples := []struct {
+		desc string
+		doc  string
+	}{
+		{
+			desc: "tables",
+			doc:  "name = 'a'\n[server]\ndebug = true\n[server.http]\nport = 80\nhost = 'h'",
+		},
+		{
+			desc: "dotted keys",
+			doc:  "name = 'a'\nserver.http.port = 80\nserver.http.host = 'h'\nserver.debug = true",
+		},
+		{
+			desc: "inline tables",
+			doc:  "name = 'a'\nserver = { debug = true, http = { port = 80, host = 'h' } }",
+		},
+		{
+			desc: "case insensitive",
+			doc:  "name = 'a'\n[Server]\ndebug = true\n[Server.HTTP]\nPort = 80\nHost = 'h'",
+		},
+	}
+
+	expected := dottedConfig{Name: "a", Port: 80, Host: "h", Debug: true}
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var c dottedConfig
+			err := toml.Unmarshal([]byte(e.doc), &c)
+			require.NoError(t, err)
+			require.Equal(t, expected, c)
+		})
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		var c dottedConfig
+		d := toml.NewDecoder(strings.NewReader("[server.http]\nport = 80\ntimeout = 3"))
+		d.DisallowUnknownFields()
+		err := d.Decode(&c)
+		require.Error(t, err)
+		require.Equal(t, 80, c.Port)
+	})
+
+	t.Run("pointer", func(t *testing.T) {
+		var c struct {
+			Port *int `toml:"server.port,dotted"`
+		}
+		err := toml.Unmarshal([]byte("server.port = 80"), &c)
+		require.NoError(t, err)
+		require.Equal(t, 80, *c.Port)
+	})
+
+	t.Run("required", func(t *testing.T) {
+		var c struct {
+			Port int `toml:"server.http.port,dotted,required"`
+		}
+		d := toml.NewDecoder(strings.NewReader("[server.http]\nport = 80"))
+		d.EnforceRequiredFields()
+		err := d.Decode(&c)
+		require.NoError(t, err)
+		require.Equal(t, 80, c.Port)
+
+		d = toml.NewDecoder(strings.NewReader("[server.http]\nhost = 'h'"))
+		d.EnforceRequiredFields()
+		err = d.Decode(&c)
+		require.Error(t, err)
+	})
+
+	t.Run("without option", func(t *testing.T) {
+		var c struct {
+			Port int `toml:"server.port"`
+		}
+		err := toml.Unmarshal([]byte("'server.port' = 80"), &c)
+		require.NoError(t, err)
+		require.Equal(t, 80, c.Port)
+	})
+}
+
+func TestMarshalDotted(t *testing.T) {
+	b, err := toml.Marshal(dottedConfig{Name: "a", Port: 80, Host: "h"})
+	require.NoError(t, err)
+
+	expected := `name = 'a'
+[server]
+debug = false
+[server.http]
+port = 80
+host = 'h'
+
+
+`
+	require.Equal(t, expected, string(b))
+
+	var c dottedConfig
+	err = toml.Unmarshal(b, &c)
+	require.NoError(t, err)
+	require.Equal(t, dottedConfig{Name: "a", Port: 80, Host: "h"}, c)
+}
+
+func TestDottedConflicts(t *testing.T) {
+	type prefix struct {
+		A int `toml:"a.b,dotted"`
+		B int `toml:"a.b.c,dotted"`
+	}
+	type field struct {
+		A int `toml:"a"`
+		B int `toml:"a.c,dotted"`
+	}
+
+	_, err := toml.Marshal(prefix{})
+	require.EqualError(t, err, "toml: fields A and B of toml_test.prefix both use the key a.b")
+
+	var f field
+	err = toml.Unmarshal([]byte("a = 1"), &f)
+	require.EqualError(t, err, "toml: fields A and B of toml_test.field both use the key a")
+}
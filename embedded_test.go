@@ -0,0 +1,155 @@
+package toml_test
+
+import (
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type EmbeddedBase struct {
+	Name string
+	Port int
+}
+
+type EmbeddedOther struct {
+	Name string
+	Host string
+}
+
+type EmbeddedDeep struct {
+	EmbeddedBase
+}
+
+type EmbeddedTagged struct {
+	Label string `toml:"Name"`
+}
+
+type embeddedPrivate struct {
+	Secret string
+}
+
+func TestEmbeddedStructs(t *testing.T) {
+	type value struct {
+		EmbeddedBase
+		Extra int
+	}
+	type pointer struct {
+		*EmbeddedBase
+		Extra int
+	}
+	type named struct {
+		EmbeddedBase `toml:"base"`
+		Extra        int
+	}
+	type conflict struct {
+		EmbeddedBase
+		EmbeddedOther
+	}
+	type shallower struct {
+		EmbeddedDeep
+		Name string
+	}
+	type shallowerEmbedded struct {
+		EmbeddedDeep
+		EmbeddedOther
+	}
+	type tagged struct {
+		EmbeddedBase
+		EmbeddedTagged
+	}
+	type private struct {
+		embeddedPrivate
+		X int
+	}
+
+	examples := []struct {
+		desc    string
+		v       interface{}
+		doc     string
+		target  interface{}
+		decoded interface{}
+	}{
+		{
+			desc:    "value",
+			v:       value{EmbeddedBase{"a", 1}, 2},
+			doc:     "Name = 'a'\nPort = 1\nExtra = 2\n",
+			target:  &value{},
+			decoded: &value{EmbeddedBase{"a", 1}, 2},
+		},
+		{
+			desc:    "pointer",
+			v:       pointer{&EmbeddedBase{"a", 1}, 2},
+			doc:     "Name = 'a'\nPort = 1\nExtra = 2\n",
+			target:  &pointer{},
+			decoded: &pointer{&EmbeddedBase{"a", 1}, 2},
+		},
+		{
+			desc:    "nil pointer",
+			v:       pointer{nil, 2},
+			doc:     "Extra = 2\n",
+			target:  &pointer{},
+			decoded: &pointer{nil, 2},
+		},
+		{
+			desc:    "named",
+			v:       named{EmbeddedBase{"a", 1}, 2},
+			doc:     "Extra = 2\n[base]\nName = 'a'\nPort = 1\n\n",
+			target:  &named{},
+			decoded: &named{EmbeddedBase{"a", 1}, 2},
+		},
+		{
+			desc:    "conflict at the same depth",
+			v:       conflict{EmbeddedBase{"a", 1}, EmbeddedOther{"b", "h"}},
+			doc:     "Port = 1\nHost = 'h'\n",
+			target:  &conflict{},
+			decoded: &conflict{EmbeddedBase{"", 1}, EmbeddedOther{"", "h"}},
+		},
+		{
+			desc:    "shallower field",
+			v:       shallower{EmbeddedDeep{EmbeddedBase{"a", 1}}, "top"},
+			doc:     "Port = 1\nName = 'top'\n",
+			target:  &shallower{},
+			decoded: &shallower{EmbeddedDeep{EmbeddedBase{"", 1}}, "top"},
+		},
+		{
+			desc:    "shallower embedded field",
+			v:       shallowerEmbedded{EmbeddedDeep{EmbeddedBase{"a", 1}}, EmbeddedOther{"b", "h"}},
+			doc:     "Port = 1\nName = 'b'\nHost = 'h'\n",
+			target:  &shallowerEmbedded{},
+			decoded: &shallowerEmbedded{EmbeddedDeep{EmbeddedBase{"", 1}}, EmbeddedOther{"b", "h"}},
+		},
+		{
+			desc:    "tagged field at the same depth",
+			v:       tagged{EmbeddedBase{"a", 1}, EmbeddedTagged{"b"}},
+			doc:     "Port = 1\nName = 'b'\n",
+			target:  &tagged{},
+			decoded: &tagged{EmbeddedBase{"", 1}, EmbeddedTagged{"b"}},
+		},
+		{
+			desc:    "unexported embedded struct",
+			v:       private{embeddedPrivate{"s"}, 1},
+			doc:     "Secret = 's'\nX = 1\n",
+			target:  &private{},
+			decoded: &private{embeddedPrivate{"s"}, 1},
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			b, err := toml.Marshal(e.v)
+			require.NoError(t, err)
+			require.Equal(t, e.doc, string(b))
+
+			err = toml.Unmarshal(b, e.target)
+			require.NoError(t, err)
+			require.Equal(t, e.decoded, e.target)
+		})
+	}
+
+	var c conflict
+	err := toml.Unmarshal([]byte("name = 'a'\nport = 1"), &c)
+	require.NoError(t, err)
+	require.Equal(t, conflict{EmbeddedBase: EmbeddedBase{Port: 1}}, c)
+}
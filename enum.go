@@ -0,0 +1,47 @@
+package toml
+
+import (
+	"reflect"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+)
+
+// RegisterEnum restricts the strings that can be decoded into values of the
+// named string type t to the ones listed in allowed:
+//
+//   dec.RegisterEnum(reflect.TypeOf(LogLevel("")), []string{"debug", "info", "warn", "error"})
+//
+// Decoding any other string fails with a DecodeError pointing at the string,
+// like `invalid value "verbose" for LogLevel: want one of [debug info warn
+// error]`. Like the "oneof" option of struct fields, the comparison is case
+// sensitive. Types implementing encoding.TextUnmarshaler are not affected.
+func (d *Decoder) RegisterEnum(t reflect.Type, allowed []string) *Decoder {
+	if d.enums == nil {
+		d.enums = map[reflect.Type][]string{}
+	}
+	d.enums[t] = append([]string(nil), allowed...)
+	return d
+}
+
+// checkEnum returns an error when the string value is not one of the values
+// registered with RegisterEnum for the type of v.
+func (d *decoder) checkEnum(value *ast.Node, v reflect.Value) error {
+	allowed, ok := d.enums[v.Type()]
+	if !ok {
+		return nil
+	}
+
+	s := string(value.Data)
+	for _, a := range allowed {
+		if s == a {
+			return nil
+		}
+	}
+
+	name := v.Type().Name()
+	if name == "" {
+		name = v.Type().String()
+	}
+
+	return newDecodeError(d.p.Raw(value.Raw), "invalid value %q for %s: want one of %v", s, name, allowed)
+}
@@ -0,0 +1,46 @@
+package toml_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type LogLevel string
+
+func TestDecoderRegisterEnum(t *testing.T) {
+	type config struct {
+		Level  LogLevel
+		Levels []LogLevel
+		Name   string
+	}
+
+	levels := []string{"debug", "info", "warn", "error"}
+
+	decode := func(doc string) (config, error) {
+		var c config
+		err := toml.NewDecoder(strings.NewReader(doc)).
+			RegisterEnum(reflect.TypeOf(LogLevel("")), levels).
+			Decode(&c)
+		return c, err
+	}
+
+	c, err := decode("level = 'warn'\nlevels = ['debug', 'error']\nname = 'verbose'")
+	require.NoError(t, err)
+	require.Equal(t, config{Level: "warn", Levels: []LogLevel{"debug", "error"}, Name: "verbose"}, c)
+
+	_, err = decode("name = 'a'\nlevel = \"verbose\"")
+	var de *toml.DecodeError
+	require.ErrorAs(t, err, &de)
+	require.Equal(t, `toml: invalid value "verbose" for LogLevel: want one of [debug info warn error]`, de.Error())
+	row, col := de.Position()
+	require.Equal(t, 2, row)
+	require.Equal(t, 9, col)
+
+	_, err = decode("levels = ['info', 'Info']")
+	require.ErrorAs(t, err, &de)
+	require.Equal(t, `toml: array element 1: invalid value "Info" for LogLevel: want one of [debug info warn error]`, de.Error())
+}
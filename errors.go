@@ -1,6 +1,7 @@
 package toml
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -18,7 +19,11 @@ type DecodeError struct {
 	message string
 	line    int
 	column  int
+	offset  int
+	length  int
 	key     Key
+	table   string
+	cause   error
 
 	human string
 }
@@ -53,6 +58,113 @@ func (s *StrictMissingError) String() string {
 	return buf.String()
 }
 
+// DecodeErrors contains all the errors found while decoding a TOML document, in
+// the order they appear in the document.
+//
+// Emitted by Decoder when CollectErrors() was called.
+type DecodeErrors struct {
+	Errors []*DecodeError
+}
+
+// Error returns the message of the first error, followed by the number of
+// other errors.
+func (e *DecodeErrors) Error() string {
+	msg := e.Errors[0].Error()
+	switch len(e.Errors) {
+	case 1:
+		return msg
+	case 2:
+		return msg + " (and 1 more error)"
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", msg, len(e.Errors)-1)
+	}
+}
+
+// Unwrap returns the errors, so that errors.Is and errors.As look into each of
+// them.
+func (e *DecodeErrors) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// String returns a human readable description of all errors.
+func (e *DecodeErrors) String() string {
+	var buf strings.Builder
+
+	for i, err := range e.Errors {
+		if i > 0 {
+			buf.WriteString("\n---\n")
+		}
+
+		buf.WriteString(err.String())
+	}
+
+	return buf.String()
+}
+
+// EncodeError is returned by the Encoder when a value of the Go structure
+// being encoded cannot be emitted, like a map with unsupported keys or a value
+// whose MarshalTOML method failed. It tells where the value is in the
+// structure.
+type EncodeError struct {
+	// Keys leading to the value, from the root of the structure, as they
+	// would appear in the document. Elements of slices and arrays are
+	// designated by their index, starting at 0.
+	Path []string
+
+	err error
+
+	// Path in the format of SetValueInterceptor, like servers[2].tags.
+	path string
+}
+
+// Error returns the message of the error, followed by the path of the value.
+func (e *EncodeError) Error() string {
+	return e.err.Error() + " at " + e.path
+}
+
+// Unwrap returns the error that occurred while encoding the value.
+func (e *EncodeError) Unwrap() error {
+	return e.err
+}
+
+// keyEncodeError returns err, which occurred while encoding the value stored
+// at key k, with k prepended to its path.
+func keyEncodeError(err error, k string) error {
+	e := asEncodeError(err)
+	e.Path = append([]string{k}, e.Path...)
+	if e.path != "" && e.path[0] != '[' {
+		e.path = k + "." + e.path
+	} else {
+		e.path = k + e.path
+	}
+	return e
+}
+
+// indexEncodeError returns err, which occurred while encoding the element i
+// of a slice or an array, with i prepended to its path.
+func indexEncodeError(err error, i int) error {
+	e := asEncodeError(err)
+	index := strconv.Itoa(i)
+	e.Path = append([]string{index}, e.Path...)
+	if e.path != "" && e.path[0] != '[' {
+		e.path = "[" + index + "]." + e.path
+	} else {
+		e.path = "[" + index + "]" + e.path
+	}
+	return e
+}
+
+func asEncodeError(err error) *EncodeError {
+	if e, ok := err.(*EncodeError); ok {
+		return e
+	}
+	return &EncodeError{err: err}
+}
+
 type Key []string
 
 // internal version of DecodeError that is used as the base to create a
@@ -60,25 +172,44 @@ type Key []string
 type decodeError struct {
 	highlight []byte
 	message   string
-	key       Key // optional
+	key       Key    // optional
+	table     string // optional
+	cause     error  // optional
 }
 
 func (de *decodeError) Error() string {
 	return de.message
 }
 
+func (de *decodeError) Unwrap() error {
+	return de.cause
+}
+
+// newDecodeError creates a decodeError with a message formatted like
+// fmt.Errorf. The error wrapped with the %w verb, if any, is its cause.
 func newDecodeError(highlight []byte, format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
 	return &decodeError{
 		highlight: highlight,
-		message:   fmt.Errorf(format, args...).Error(),
+		message:   err.Error(),
+		cause:     errors.Unwrap(err),
 	}
 }
 
 // Error returns the error message contained in the DecodeError.
 func (e *DecodeError) Error() string {
+	if e.table != "" {
+		return "toml: " + e.message + " (in " + e.table + ")"
+	}
 	return "toml: " + e.message
 }
 
+// Unwrap returns the error that caused the DecodeError, like the error
+// returned by the UnmarshalText method of a field, or nil.
+func (e *DecodeError) Unwrap() error {
+	return e.cause
+}
+
 // String returns the human-readable contextualized error. This string is multi-line.
 func (e *DecodeError) String() string {
 	return e.human
@@ -90,12 +221,27 @@ func (e *DecodeError) Position() (row int, column int) {
 	return e.line, e.column
 }
 
+// Range returns the byte offset in the document of the start of the bytes the
+// error refers to, and their length. Together with Position, it locates the
+// error without parsing its message, for example to report it in an editor.
+func (e *DecodeError) Range() (offset int, length int) {
+	return e.offset, e.length
+}
+
 // Key that was being processed when the error occurred. The key is present only
 // if this DecodeError is part of a StrictMissingError.
 func (e *DecodeError) Key() Key {
 	return e.key
 }
 
+// Table returns a description of the table or the element of the array of
+// tables that contains the error, like "[server]" or "[[servers]] element 2",
+// where elements are counted from 1. It is empty when the error is outside of
+// any table, or not reported by decoding a document into a value.
+func (e *DecodeError) Table() string {
+	return e.table
+}
+
 // decodeErrorFromHighlight creates a DecodeError referencing a highlighted
 // range of bytes from document.
 //
@@ -164,6 +310,12 @@ func wrapDecodeError(document []byte, de *decodeError) *DecodeError {
 		buf.WriteString(errMessage)
 	}
 
+	if de.table != "" {
+		buf.WriteString(" (in ")
+		buf.WriteString(de.table)
+		buf.WriteString(")")
+	}
+
 	// Write the lines of context strictly after the error.
 
 	for i := 1; i < len(after); i++ {
@@ -182,7 +334,11 @@ func wrapDecodeError(document []byte, de *decodeError) *DecodeError {
 		message: errMessage,
 		line:    errLine,
 		column:  errColumn,
+		offset:  offset,
+		length:  len(de.highlight),
 		key:     de.key,
+		table:   de.table,
+		cause:   de.cause,
 		human:   buf.String(),
 	}
 }
@@ -193,6 +193,8 @@ func TestDecodeError_Accessors(t *testing.T) {
 		message: "foo",
 		line:    1,
 		column:  2,
+		offset:  3,
+		length:  4,
 		key:     []string{"one", "two"},
 		human:   "bar",
 	}
@@ -202,6 +204,24 @@ func TestDecodeError_Accessors(t *testing.T) {
 	assert.Equal(t, 2, c)
 	assert.Equal(t, Key{"one", "two"}, e.Key())
 	assert.Equal(t, "bar", e.String())
+	o, l := e.Range()
+	assert.Equal(t, 3, o)
+	assert.Equal(t, 4, l)
+}
+
+func TestDecodeError_Range(t *testing.T) {
+	doc := "a = 1\n[b]\nc = 'x'\n"
+
+	var v struct{ B struct{ C int } }
+	err := Unmarshal([]byte(doc), &v)
+
+	var derr *DecodeError
+	assert.True(t, errors.As(err, &derr))
+	offset, length := derr.Range()
+	assert.Equal(t, "'x'", doc[offset:offset+length])
+	row, column := derr.Position()
+	assert.Equal(t, 3, row)
+	assert.Equal(t, 5, column)
 }
 
 func ExampleDecodeError() {
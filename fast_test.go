@@ -1,6 +1,9 @@
 package toml_test
 
 import (
+	"fmt"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/pelletier/go-toml/v2"
@@ -98,3 +101,71 @@ func TestFastArrayTable(t *testing.T) {
 		},
 	}, m)
 }
+
+func TestFastHomogeneousMaps(t *testing.T) {
+	var s struct {
+		Strings map[string]string
+		Ints    map[string]int
+		Int64s  map[string]int64
+		Floats  map[string]float64
+		Bools   map[string]bool
+	}
+	err := toml.Unmarshal([]byte(`
+strings = { a = "x", 'b c' = 'y' }
+ints.a = 1
+int64s.a = 0x10
+[floats]
+a = 1.5
+b = inf
+[bools]
+a = true
+b = false
+`), &s)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"a": "x", "b c": "y"}, s.Strings)
+	require.Equal(t, map[string]int{"a": 1}, s.Ints)
+	require.Equal(t, map[string]int64{"a": 16}, s.Int64s)
+	require.Equal(t, 1.5, s.Floats["a"])
+	require.True(t, math.IsInf(s.Floats["b"], 1))
+	require.Equal(t, map[string]bool{"a": true, "b": false}, s.Bools)
+
+	// Values of other types are reported as before.
+	m := map[string]int64{}
+	err = toml.Unmarshal([]byte(`a = "1"`), &m)
+	require.EqualError(t, err, "toml: cannot store TOML string into a Go int64")
+	err = toml.Unmarshal([]byte(`a = 1.5`), &m)
+	require.Error(t, err)
+}
+
+func TestFastLargeTable(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&b, "k%d = %d\n", i, i)
+	}
+	doc := b.String()
+
+	m := map[string]int{}
+	err := toml.Unmarshal([]byte(doc), &m)
+	require.NoError(t, err)
+	require.Len(t, m, 100)
+	require.Equal(t, 42, m["k42"])
+
+	err = toml.Unmarshal([]byte(doc+"k42 = 0\n"), &m)
+	require.Error(t, err)
+
+	var tables struct {
+		T []map[string]int
+	}
+	b.Reset()
+	for i := 0; i < 3; i++ {
+		b.WriteString("[[t]]\n")
+		b.WriteString(doc)
+	}
+	err = toml.Unmarshal([]byte(b.String()), &tables)
+	require.NoError(t, err)
+	require.Len(t, tables.T, 3)
+	require.Equal(t, 99, tables.T[2]["k99"])
+
+	err = toml.Unmarshal([]byte(b.String()+"k0 = 1\n"), &tables)
+	require.Error(t, err)
+}
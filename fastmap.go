@@ -0,0 +1,76 @@
+package toml
+
+import (
+	"reflect"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+)
+
+// fastMapKeyValue stores the scalar value at the key k of v, when v is one of
+// the common map types of strings, integers, floats or booleans, without going
+// through the reflection of the general decoding of map values. It returns
+// false when the value has to be decoded the general way, for example when it
+// is not of the type of the elements of the map or when it is invalid, so that
+// the same errors are reported. Otherwise, it returns the new map when v was
+// nil, like handleKeyValuePart.
+func (d *decoder) fastMapKeyValue(k *ast.Node, value *ast.Node, v reflect.Value) (reflect.Value, bool, error) {
+	t := v.Type()
+	if (t != mapStringStringType && t != mapStringInt64Type && t != mapStringIntType && t != mapStringFloat64Type && t != mapStringBoolType) ||
+		d.seen.AllowRepeatedScalars || d.typeDecoders[t.Elem()] != nil || d.enums[t.Elem()] != nil {
+		return reflect.Value{}, false, nil
+	}
+
+	var rv reflect.Value
+	if v.IsNil() {
+		v = reflect.MakeMapWithSize(t, 0)
+		rv = v
+	}
+
+	key := string(k.Data)
+
+	switch m := v.Interface().(type) {
+	case map[string]string:
+		if value.Kind != ast.String {
+			return reflect.Value{}, false, nil
+		}
+		err := d.checkOneOf(value)
+		if err != nil {
+			return reflect.Value{}, true, err
+		}
+		m[key] = string(value.Data)
+	case map[string]int64:
+		if value.Kind != ast.Integer {
+			return reflect.Value{}, false, nil
+		}
+		i, err := parseInteger(value.Data)
+		if err != nil {
+			return reflect.Value{}, false, nil
+		}
+		m[key] = i
+	case map[string]int:
+		if value.Kind != ast.Integer {
+			return reflect.Value{}, false, nil
+		}
+		i, err := parseInteger(value.Data)
+		if err != nil || i < minInt || i > maxInt {
+			return reflect.Value{}, false, nil
+		}
+		m[key] = int(i)
+	case map[string]float64:
+		if value.Kind != ast.Float {
+			return reflect.Value{}, false, nil
+		}
+		f, err := parseFloat(value.Data)
+		if err != nil {
+			return reflect.Value{}, false, nil
+		}
+		m[key] = f
+	case map[string]bool:
+		if value.Kind != ast.Bool {
+			return reflect.Value{}, false, nil
+		}
+		m[key] = value.Data[0] == 't'
+	}
+
+	return rv, true, nil
+}
@@ -30,6 +30,11 @@ const (
 	DateTime
 )
 
+// IsScalar returns true for the kinds of values that are not containers.
+func (k Kind) IsScalar() bool {
+	return k >= String
+}
+
 func (k Kind) String() string {
 	switch k {
 	case Invalid:
@@ -965,8 +965,6 @@ func TestUnmarshalInvalidPointerKind(t *testing.T) {
 	assert.Error(t, err)
 }
 
-// TODO: Remove nolint once var is used by a test
-//nolint:deadcode,unused
 type testDuration struct {
 	Nanosec   time.Duration  `toml:"nanosec"`
 	Microsec1 time.Duration  `toml:"microsec1"`
@@ -979,8 +977,6 @@ type testDuration struct {
 	AString   string         `toml:"a_string"`
 }
 
-// TODO: Remove nolint once var is used by a test
-//nolint:deadcode,unused,varcheck
 var testDurationToml = []byte(`
 nanosec = "1ns"
 microsec1 = "1us"
@@ -1006,12 +1002,43 @@ nanosec = "1ns"
 sec = "1s"
 `)
 
-// TODO: Remove nolint once type is used by a test
-//nolint:deadcode,unused
 type testBadDuration struct {
 	Val time.Duration `toml:"val"`
 }
 
+func TestUnmarshalDuration(t *testing.T) {
+	var d testDuration
+	err := toml.Unmarshal(testDurationToml, &d)
+	require.NoError(t, err)
+
+	us := time.Microsecond
+	expected := testDuration{
+		Nanosec:   time.Nanosecond,
+		Microsec1: time.Microsecond,
+		Microsec2: &us,
+		Millisec:  time.Millisecond,
+		Sec:       time.Second,
+		Min:       time.Minute,
+		Hour:      time.Hour,
+		Mixed:     time.Hour + time.Minute + time.Second + time.Millisecond + time.Microsecond + time.Nanosecond,
+		AString:   "15s",
+	}
+	assert.Equal(t, expected, d)
+
+	var b testBadDuration
+	err = toml.Unmarshal([]byte("val = 1500"), &b)
+	require.NoError(t, err)
+	assert.Equal(t, 1500*time.Nanosecond, b.Val)
+
+	err = toml.Unmarshal([]byte(`val = "1z"`), &b)
+	var derr *toml.DecodeError
+	require.True(t, errors.As(err, &derr))
+	assert.Equal(t, `toml: invalid duration "1z"`, err.Error())
+	row, col := derr.Position()
+	assert.Equal(t, 1, row)
+	assert.Equal(t, 7, col)
+}
+
 // TODO: add back camelCase test
 var testCamelCaseKeyToml = []byte(`fooBar = 10`) //nolint:unused
 
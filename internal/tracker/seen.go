@@ -42,7 +42,8 @@ func (k keyKind) String() string {
 //
 // To find whether a given key (sequence of []byte) has already been visited,
 // the entries are linearly searched, looking for one with the right name and
-// parent id.
+// parent id. The children of entries that have many of them are indexed by
+// name instead, so that large tables are not searched linearly.
 //
 // Given that all keys appear in the document after their parent, it is
 // guaranteed that all descendants of a node are stored after the node, this
@@ -55,8 +56,40 @@ func (k keyKind) String() string {
 type SeenTracker struct {
 	entries    []entry
 	currentIdx int
+
+	// Key that created each entry, by index. Kept out of entry to keep it
+	// small.
+	raws []ast.Range
+
+	// When true, a key-value with a scalar value can repeat a key that was
+	// previously defined with a scalar value.
+	AllowRepeatedScalars bool
+
+	// When true, tables created by table headers cannot be extended with
+	// dotted keys, and tables created by dotted keys cannot be extended with
+	// table headers, even when the specification allows it.
+	DisallowMixedTables bool
+
+	// When true, errors about a key defined more than once also report where
+	// it was first defined, and AllowRepeatedScalars is ignored.
+	DisallowDuplicateKeys bool
+
+	// Whether the last expression repeated a key.
+	repeated bool
+
+	// Children of the entries with many children, by parent and name, so
+	// that large tables are not searched linearly.
+	index map[indexKey]int
+}
+
+type indexKey struct {
+	parent int
+	name   string
 }
 
+// Number of children of an entry above which they are indexed.
+const indexThreshold = 16
+
 var pool sync.Pool
 
 func (s *SeenTracker) reset() {
@@ -64,11 +97,17 @@ func (s *SeenTracker) reset() {
 	s.currentIdx = 0
 	if len(s.entries) == 0 {
 		s.entries = make([]entry, 1, 2)
+		s.raws = make([]ast.Range, 1, 2)
 	} else {
 		s.entries = s.entries[:1]
+		s.raws = s.raws[:1]
 	}
 	s.entries[0].child = -1
 	s.entries[0].next = -1
+	s.entries[0].indexed = false
+	for k := range s.index {
+		delete(s.index, k)
+	}
 }
 
 type entry struct {
@@ -80,19 +119,52 @@ type entry struct {
 	kind     keyKind
 	explicit bool
 	kv       bool
+	scalar   bool
+
+	// Whether the entry was created by a table header or by dotted keys.
+	header bool
+	dotted bool
+
+	// Whether the children of the entry are in SeenTracker.index.
+	indexed bool
 }
 
 // Find the index of the child of parentIdx with key k. Returns -1 if
 // it does not exist.
 func (s *SeenTracker) find(parentIdx int, k []byte) int {
+	if s.entries[parentIdx].indexed {
+		if i, ok := s.index[indexKey{parentIdx, string(k)}]; ok {
+			return i
+		}
+		return -1
+	}
+
+	n := 0
 	for i := s.entries[parentIdx].child; i >= 0; i = s.entries[i].next {
 		if bytes.Equal(s.entries[i].name, k) {
 			return i
 		}
+		n++
+	}
+
+	if n > indexThreshold {
+		s.indexChildren(parentIdx)
 	}
+
 	return -1
 }
 
+// indexChildren adds the children of the entry at parentIdx to the index.
+func (s *SeenTracker) indexChildren(parentIdx int) {
+	if s.index == nil {
+		s.index = map[indexKey]int{}
+	}
+	for i := s.entries[parentIdx].child; i >= 0; i = s.entries[i].next {
+		s.index[indexKey{parentIdx, string(s.entries[i].name)}] = i
+	}
+	s.entries[parentIdx].indexed = true
+}
+
 // Remove all descendants of node at position idx.
 func (s *SeenTracker) clear(idx int) {
 	if idx >= len(s.entries) {
@@ -100,6 +172,9 @@ func (s *SeenTracker) clear(idx int) {
 	}
 
 	for i := s.entries[idx].child; i >= 0; {
+		if s.entries[idx].indexed {
+			delete(s.index, indexKey{idx, string(s.entries[i].name)})
+		}
 		next := s.entries[i].next
 		n := s.entries[0].next
 		s.entries[0].next = i
@@ -110,29 +185,37 @@ func (s *SeenTracker) clear(idx int) {
 	}
 
 	s.entries[idx].child = -1
+	s.entries[idx].indexed = false
 }
 
-func (s *SeenTracker) create(parentIdx int, name []byte, kind keyKind, explicit bool, kv bool) int {
+func (s *SeenTracker) create(parentIdx int, key *ast.Node, kind keyKind, explicit bool, kv bool) int {
 	e := entry{
 		child: -1,
 		next:  s.entries[parentIdx].child,
 
-		name:     name,
+		name:     key.Data,
 		kind:     kind,
 		explicit: explicit,
 		kv:       kv,
+		header:   !kv,
+		dotted:   kv,
 	}
 	var idx int
 	if s.entries[0].next >= 0 {
 		idx = s.entries[0].next
 		s.entries[0].next = s.entries[idx].next
 		s.entries[idx] = e
+		s.raws[idx] = key.Raw
 	} else {
 		idx = len(s.entries)
 		s.entries = append(s.entries, e)
+		s.raws = append(s.raws, key.Raw)
 	}
 
 	s.entries[parentIdx].child = idx
+	if s.entries[parentIdx].indexed {
+		s.index[indexKey{parentIdx, string(key.Data)}] = idx
+	}
 
 	return idx
 }
@@ -147,6 +230,18 @@ func (s *SeenTracker) setExplicitFlag(parentIdx int) {
 	}
 }
 
+// duplicateError returns an error about the key node, which defines again the
+// key of the entry at idx. It records where the entry was first defined when
+// DisallowDuplicateKeys is set.
+func (s *SeenTracker) duplicateError(node *ast.Node, idx int, format string, args ...interface{}) error {
+	err := keyError(node, format, args...)
+	if s.DisallowDuplicateKeys {
+		first := s.raws[idx]
+		err.(*KeyError).First = &first
+	}
+	return err
+}
+
 // CheckExpression takes a top-level node and checks that it does not contain
 // keys that have been seen in previous calls, and validates that types are
 // consistent.
@@ -154,6 +249,7 @@ func (s *SeenTracker) CheckExpression(node *ast.Node) error {
 	if s.entries == nil {
 		s.reset()
 	}
+	s.repeated = false
 	switch node.Kind {
 	case ast.KeyValue:
 		return s.checkKeyValue(node)
@@ -166,6 +262,12 @@ func (s *SeenTracker) CheckExpression(node *ast.Node) error {
 	}
 }
 
+// Repeated returns true if the last expression passed to CheckExpression was a
+// key-value repeating a key, as permitted by AllowRepeatedScalars.
+func (s *SeenTracker) Repeated() bool {
+	return s.repeated
+}
+
 func (s *SeenTracker) checkTable(node *ast.Node) error {
 	if s.currentIdx >= 0 {
 		s.setExplicitFlag(s.currentIdx)
@@ -188,11 +290,14 @@ func (s *SeenTracker) checkTable(node *ast.Node) error {
 		idx := s.find(parentIdx, k)
 
 		if idx < 0 {
-			idx = s.create(parentIdx, k, tableKind, false, false)
+			idx = s.create(parentIdx, it.Node(), tableKind, false, false)
 		} else {
 			entry := s.entries[idx]
 			if entry.kind == valueKind {
-				return fmt.Errorf("toml: expected %s to be a table, not a %s", string(k), entry.kind)
+				return s.duplicateError(it.Node(), idx, "expected %s to be a table, not a %s", string(k), entry.kind)
+			}
+			if s.DisallowMixedTables && entry.dotted {
+				return keyError(it.Node(), "table %s is defined by dotted keys and cannot be extended by a table header", string(k))
 			}
 		}
 		parentIdx = idx
@@ -204,14 +309,14 @@ func (s *SeenTracker) checkTable(node *ast.Node) error {
 	if idx >= 0 {
 		kind := s.entries[idx].kind
 		if kind != tableKind {
-			return fmt.Errorf("toml: key %s should be a table, not a %s", string(k), kind)
+			return s.duplicateError(it.Node(), idx, "key %s should be a table, not a %s", string(k), kind)
 		}
 		if s.entries[idx].explicit {
-			return fmt.Errorf("toml: table %s already exists", string(k))
+			return s.duplicateError(it.Node(), idx, "table %s already exists", string(k))
 		}
 		s.entries[idx].explicit = true
 	} else {
-		idx = s.create(parentIdx, k, tableKind, true, false)
+		idx = s.create(parentIdx, it.Node(), tableKind, true, false)
 	}
 
 	s.currentIdx = idx
@@ -238,11 +343,14 @@ func (s *SeenTracker) checkArrayTable(node *ast.Node) error {
 		idx := s.find(parentIdx, k)
 
 		if idx < 0 {
-			idx = s.create(parentIdx, k, tableKind, false, false)
+			idx = s.create(parentIdx, it.Node(), tableKind, false, false)
 		} else {
 			entry := s.entries[idx]
 			if entry.kind == valueKind {
-				return fmt.Errorf("toml: expected %s to be a table, not a %s", string(k), entry.kind)
+				return s.duplicateError(it.Node(), idx, "expected %s to be a table, not a %s", string(k), entry.kind)
+			}
+			if s.DisallowMixedTables && entry.dotted {
+				return keyError(it.Node(), "table %s is defined by dotted keys and cannot be extended by a table header", string(k))
 			}
 		}
 
@@ -255,11 +363,11 @@ func (s *SeenTracker) checkArrayTable(node *ast.Node) error {
 	if idx >= 0 {
 		kind := s.entries[idx].kind
 		if kind != arrayTableKind {
-			return fmt.Errorf("toml: key %s already exists as a %s,  but should be an array table", kind, string(k))
+			return s.duplicateError(it.Node(), idx, "key %s already exists as a %s,  but should be an array table", kind, string(k))
 		}
 		s.clear(idx)
 	} else {
-		idx = s.create(parentIdx, k, arrayTableKind, true, false)
+		idx = s.create(parentIdx, it.Node(), arrayTableKind, true, false)
 	}
 
 	s.currentIdx = idx
@@ -277,25 +385,32 @@ func (s *SeenTracker) checkKeyValue(node *ast.Node) error {
 		idx := s.find(parentIdx, k)
 
 		if idx < 0 {
-			idx = s.create(parentIdx, k, tableKind, false, true)
+			idx = s.create(parentIdx, it.Node(), tableKind, false, true)
 		} else {
 			entry := s.entries[idx]
 			if it.IsLast() {
-				return fmt.Errorf("toml: key %s is already defined", string(k))
+				if s.AllowRepeatedScalars && !s.DisallowDuplicateKeys && entry.scalar && node.Value().Kind.IsScalar() {
+					s.repeated = true
+					return nil
+				}
+				return s.duplicateError(it.Node(), idx, "key %s is already defined", string(k))
 			} else if entry.kind != tableKind {
-				return fmt.Errorf("toml: expected %s to be a table, not a %s", string(k), entry.kind)
+				return s.duplicateError(it.Node(), idx, "expected %s to be a table, not a %s", string(k), entry.kind)
 			} else if entry.explicit {
-				return fmt.Errorf("toml: cannot redefine table %s that has already been explicitly defined", string(k))
+				return s.duplicateError(it.Node(), idx, "cannot redefine table %s that has already been explicitly defined", string(k))
+			} else if s.DisallowMixedTables && entry.header {
+				return keyError(it.Node(), "table %s is defined by a table header and cannot be extended by dotted keys", string(k))
 			}
 		}
 
 		parentIdx = idx
 	}
 
-	s.entries[parentIdx].kind = valueKind
-
 	value := node.Value()
 
+	s.entries[parentIdx].kind = valueKind
+	s.entries[parentIdx].scalar = value.Kind.IsScalar()
+
 	switch value.Kind {
 	case ast.InlineTable:
 		return s.checkInlineTable(value)
@@ -333,8 +448,10 @@ func (s *SeenTracker) checkInlineTable(node *ast.Node) error {
 		}
 	}
 
+	disallowDuplicates := s.DisallowDuplicateKeys
 	s = pool.Get().(*SeenTracker)
 	s.reset()
+	s.DisallowDuplicateKeys = disallowDuplicates
 
 	it := node.Children()
 	for it.Next() {
@@ -1 +1,30 @@
 package tracker
+
+import (
+	"fmt"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+)
+
+// KeyError is returned by the trackers when a key of the document is invalid.
+type KeyError struct {
+	// Part of the key at fault.
+	Raw ast.Range
+
+	// Key that first defined the key at fault, when it is defined more than
+	// once. Nil if not reported.
+	First *ast.Range
+
+	Message string
+}
+
+func (e *KeyError) Error() string {
+	return "toml: " + e.Message
+}
+
+func keyError(node *ast.Node, format string, args ...interface{}) error {
+	return &KeyError{
+		Raw:     node.Raw,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
@@ -0,0 +1,92 @@
+package toml
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeyMapper converts between the keys of a TOML document and the names of the
+// Go struct fields they correspond to. It only applies to fields that don't
+// have an explicit name in their "toml" struct tag.
+type KeyMapper interface {
+	// FieldName returns the Go field name corresponding to the TOML key. The
+	// field is matched case-insensitively if there is no exact match.
+	FieldName(key string) string
+
+	// Key returns the TOML key corresponding to the Go field name.
+	Key(fieldName string) string
+}
+
+// Built-in key mappers.
+var (
+	// KebabCase maps max-retries to MaxRetries.
+	KebabCase KeyMapper = caseMapper{separator: "-"}
+
+	// SnakeCase maps max_retries to MaxRetries.
+	SnakeCase KeyMapper = caseMapper{separator: "_"}
+
+	// ScreamingSnakeCase maps MAX_RETRIES to MaxRetries.
+	ScreamingSnakeCase KeyMapper = caseMapper{separator: "_", upper: true}
+)
+
+type caseMapper struct {
+	separator string
+	upper     bool
+}
+
+func (m caseMapper) FieldName(key string) string {
+	var b strings.Builder
+
+	for _, word := range strings.Split(key, m.separator) {
+		r, size := utf8.DecodeRuneInString(word)
+		if size == 0 {
+			continue
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		b.WriteString(strings.ToLower(word[size:]))
+	}
+
+	return b.String()
+}
+
+func (m caseMapper) Key(fieldName string) string {
+	words := splitCamelCase(fieldName)
+
+	for i, w := range words {
+		if m.upper {
+			words[i] = strings.ToUpper(w)
+		} else {
+			words[i] = strings.ToLower(w)
+		}
+	}
+
+	return strings.Join(words, m.separator)
+}
+
+// splitCamelCase splits a Go identifier into words. Acronyms are kept
+// together: HTTPServer is split into HTTP and Server.
+func splitCamelCase(s string) []string {
+	runes := []rune(s)
+	words := []string{}
+	start := 0
+
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		if !unicode.IsUpper(cur) {
+			continue
+		}
+
+		endOfAcronym := unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) || endOfAcronym {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
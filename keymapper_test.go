@@ -0,0 +1,85 @@
+package toml_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyMappers(t *testing.T) {
+	examples := []struct {
+		mapper toml.KeyMapper
+		field  string
+		key    string
+	}{
+		{toml.KebabCase, "MaxRetries", "max-retries"},
+		{toml.KebabCase, "HTTPServer", "http-server"},
+		{toml.KebabCase, "ID", "id"},
+		{toml.KebabCase, "Retry2Count", "retry2-count"},
+		{toml.SnakeCase, "MaxRetries", "max_retries"},
+		{toml.SnakeCase, "UserID", "user_id"},
+		{toml.ScreamingSnakeCase, "MaxRetries", "MAX_RETRIES"},
+	}
+
+	for _, e := range examples {
+		assert.Equal(t, e.key, e.mapper.Key(e.field))
+		assert.True(t, strings.EqualFold(e.field, e.mapper.FieldName(e.key)), "%s -> %s", e.key, e.mapper.FieldName(e.key))
+	}
+
+	assert.Equal(t, "MaxRetries", toml.KebabCase.FieldName("max-retries"))
+	assert.Equal(t, "MaxRetries", toml.ScreamingSnakeCase.FieldName("MAX_RETRIES"))
+}
+
+func TestKeyMapperDecodeEncode(t *testing.T) {
+	type server struct {
+		HTTPPort int
+	}
+
+	type config struct {
+		MaxRetries int
+		Tagged     string `toml:"the_tag"`
+		Server     server
+	}
+
+	doc := `max-retries = 3
+the_tag = 'x'
+
+[server]
+http-port = 80
+`
+
+	var c config
+	err := toml.NewDecoder(strings.NewReader(doc)).UseKebabCase().DisallowUnknownFields().Decode(&c)
+	require.NoError(t, err)
+	assert.Equal(t, config{MaxRetries: 3, Tagged: "x", Server: server{HTTPPort: 80}}, c)
+
+	var buf bytes.Buffer
+	err = toml.NewEncoder(&buf).SetKeyMapper(toml.KebabCase).Encode(c)
+	require.NoError(t, err)
+	expected := `max-retries = 3
+the_tag = 'x'
+[server]
+http-port = 80
+`
+	equalStringsIgnoreNewlines(t, expected, buf.String())
+
+	var c2 config
+	err = toml.NewDecoder(strings.NewReader("MAX_RETRIES = 4")).SetKeyMapper(toml.ScreamingSnakeCase).Decode(&c2)
+	require.NoError(t, err)
+	assert.Equal(t, 4, c2.MaxRetries)
+}
+
+func TestKeyMapperRequired(t *testing.T) {
+	type config struct {
+		MaxRetries int `toml:",required"`
+	}
+
+	var c config
+	err := toml.NewDecoder(strings.NewReader("max-retries = 3")).UseKebabCase().EnforceRequiredFields().Decode(&c)
+	require.NoError(t, err)
+	assert.Equal(t, 3, c.MaxRetries)
+}
@@ -0,0 +1,51 @@
+package toml
+
+import "strings"
+
+// NaturalOrder reports whether the key a sorts before the key b when the
+// numbers they contain are compared by value: item2 sorts before item10. The
+// other parts of the keys are compared byte-wise. It is meant to be used with
+// Encoder.SetKeyOrderFunc.
+func NaturalOrder(a, b string) bool {
+	x, y := a, b
+
+	for x != "" && y != "" {
+		if isDigit(x[0]) && isDigit(y[0]) {
+			var nx, ny string
+			nx, x = splitDigits(x)
+			ny, y = splitDigits(y)
+
+			tx := strings.TrimLeft(nx, "0")
+			ty := strings.TrimLeft(ny, "0")
+			if len(tx) != len(ty) {
+				return len(tx) < len(ty)
+			}
+			if tx != ty {
+				return tx < ty
+			}
+			continue
+		}
+
+		if x[0] != y[0] {
+			return x[0] < y[0]
+		}
+		x, y = x[1:], y[1:]
+	}
+
+	if x != y {
+		return x == ""
+	}
+
+	// Keys only differing by leading zeros are ordered byte-wise, so that
+	// the order is total.
+	return a < b
+}
+
+// splitDigits splits s after its leading digits.
+func splitDigits(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
@@ -0,0 +1,116 @@
+package toml_test
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNaturalOrder(t *testing.T) {
+	keys := []string{"item10", "item2", "item1", "b", "a10b", "a2c", "a2b", "item02", "10", "9", ""}
+	sort.Slice(keys, func(i, j int) bool {
+		return toml.NaturalOrder(keys[i], keys[j])
+	})
+
+	assert.Equal(t, []string{"", "9", "10", "a2b", "a2c", "a10b", "b", "item1", "item02", "item2", "item10"}, keys)
+
+	assert.False(t, toml.NaturalOrder("item1", "item1"))
+	assert.True(t, toml.NaturalOrder("item", "item1"))
+	assert.False(t, toml.NaturalOrder("item1", "item"))
+}
+
+func TestEncoderSetKeyOrderFunc(t *testing.T) {
+	v := map[string]interface{}{
+		"item10": 10,
+		"item2":  2,
+		"item1":  1,
+		"table10": map[string]int{
+			"x10": 1,
+			"x9":  2,
+		},
+		"table9": map[string]int{},
+	}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetKeyOrderFunc(toml.NaturalOrder).Encode(v)
+	require.NoError(t, err)
+
+	expected := `item1 = 1
+item2 = 2
+item10 = 10
+[table9]
+
+[table10]
+x9 = 2
+x10 = 1
+
+`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestEncoderSetTableKeyOrder(t *testing.T) {
+	type dep struct {
+		Path    string `toml:"path"`
+		Version string `toml:"version"`
+		Name    string `toml:"name"`
+	}
+	type manifest struct {
+		Authors []string       `toml:"authors"`
+		Version string         `toml:"version"`
+		Name    string         `toml:"name"`
+		Deps    map[string]dep `toml:"deps"`
+		Extra   map[string]int `toml:"extra"`
+	}
+
+	v := manifest{
+		Authors: []string{"a"},
+		Version: "1.0",
+		Name:    "m",
+		Deps: map[string]dep{
+			"z": {Path: "p", Version: "2", Name: "z"},
+		},
+		Extra: map[string]int{"b": 1, "version": 2, "a": 3},
+	}
+
+	pinned := func(keys []string) []string {
+		var first, rest []string
+		for _, k := range keys {
+			if k == "name" || k == "version" {
+				first = append(first, k)
+			} else {
+				rest = append(rest, k)
+			}
+		}
+		// Keys missing from the returned slice are emitted last.
+		if len(rest) > 0 && rest[len(rest)-1] == "extra" {
+			rest = rest[:len(rest)-1]
+		}
+		return append(append(first, "unknown"), rest...)
+	}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetTableKeyOrder(pinned).Encode(v)
+	require.NoError(t, err)
+
+	expected := `version = '1.0'
+name = 'm'
+authors = ['a']
+[deps]
+[deps.z]
+version = '2'
+name = 'z'
+path = 'p'
+
+
+[extra]
+version = 2
+a = 3
+b = 1
+
+`
+	assert.Equal(t, expected, buf.String())
+}
@@ -3,15 +3,24 @@ package toml
 import (
 	"bytes"
 	"encoding"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"math"
+	"math/big"
+	"net"
+	"net/url"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
+
+	"github.com/pelletier/go-toml/v2/internal/danger"
+	"github.com/pelletier/go-toml/v2/unstable"
 )
 
 // Marshal serializes a Go value as a TOML document.
@@ -29,26 +38,148 @@ func Marshal(v interface{}) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// MarshalValue serializes a Go value as the TOML representation it would have
+// on the right-hand side of a key-value pair, without a key or a document
+// around it. Maps and structs are serialized as inline tables, and slices as
+// arrays. For example, MarshalValue([]int{1, 2}) returns [1, 2].
+//
+// It uses the default options of the Encoder.
+func MarshalValue(v interface{}) ([]byte, error) {
+	if v == nil {
+		return nil, fmt.Errorf("toml: cannot encode a nil interface")
+	}
+
+	var ctx encoderCtx
+	ctx.setKey("")
+	ctx.shiftKey()
+	ctx.insideKv = true
+
+	enc := NewEncoder(nil)
+
+	return enc.encode(nil, ctx, reflect.ValueOf(v))
+}
+
 // Encoder writes a TOML document to an output stream.
 type Encoder struct {
 	// output
 	w io.Writer
 
+	// Buffer of the last call to Encode, reused by the next one.
+	buf []byte
+
 	// global settings
 	tablesInline    bool
+	arraysOfTables  bool
 	arraysMultiline bool
+	arraysMaxWidth  int
+	arraysSortKey   string
 	indentSymbol    string
 	indentTables    bool
+	alignEquals     bool
+	quoteAllKeys    bool
+	integerGrouping int
+	binaryMarshaler bool
+	keyMapper       KeyMapper
+	fieldComments   map[string]string
+	noComments      bool
+	flatten         bool
+	tableSpacing    int
+	floatStyle      FloatExponentStyle
+	escapeMode      StringEscapeMode
+	floatPrecision  int
+	floatFormat     byte
+	inlineMaxLen    int
+	inlineMaxKeys   int
+	keyOrder        func(a, b string) bool
+	tableKeyOrder   func(keys []string) []string
+	maxDepth        int
+	omitEmpty       bool
+	multilineMinLen int
+	durationNanos   bool
+	bigNumbers      bool
+	lineEnding      string
+	rootKey         string
+
+	// Used by Skeleton to emit the type of fields and ignore omitempty.
+	skeleton bool
+
+	// Used by Minify to omit the spaces around separators.
+	compact bool
+
+	// hooks
+	valueInterceptor ValueInterceptor
+	valueFormatter   ValueFormatter
 }
 
+// ValueInterceptor is called by the Encoder with the path and the value of
+// every key-value, table, and array element before it is emitted.
+//
+// Returning (newV, true) makes the encoder emit newV instead of v. Returning
+// (_, false) skips the value entirely.
+type ValueInterceptor func(path string, v interface{}) (interface{}, bool)
+
+// ValueFormatter is called by the Encoder with the path and the value of every
+// key-value and array element before it is emitted, to emit it in a custom
+// form.
+//
+// The path holds the keys leading to the value, with the elements of arrays
+// designated by their index in decimal. Returning (raw, true) makes the
+// encoder emit raw, which must be exactly one TOML value, instead of the
+// default representation of v. Returning (_, false) emits v as usual.
+type ValueFormatter func(path []string, v interface{}) (raw string, handled bool)
+
+// FloatExponentStyle controls how the Encoder emits floats.
+type FloatExponentStyle int
+
+const (
+	// FloatExponentNever never uses scientific notation: 1e6 is emitted as
+	// 1000000.0. This is the default.
+	FloatExponentNever FloatExponentStyle = iota
+
+	// FloatExponentShortest uses scientific notation for large and small
+	// values, whichever form is the shortest: 1e6 is emitted as 1e+06.
+	FloatExponentShortest
+
+	// FloatExponentDecimalPoint is like FloatExponentShortest, but the mantissa
+	// always contains a decimal point: 1e6 is emitted as 1.0e+06.
+	FloatExponentDecimalPoint
+)
+
+// StringEscapeMode controls which characters the Encoder escapes in strings
+// and quoted keys.
+type StringEscapeMode int
+
+const (
+	// EscapeMinimal only escapes the characters that cannot appear as they are
+	// in a basic string: quotes, backslashes and control characters. Other
+	// characters are emitted in UTF-8. This is the default.
+	EscapeMinimal StringEscapeMode = iota
+
+	// EscapeNonASCII also escapes the characters above U+007F, as \uXXXX, or
+	// as \UXXXXXXXX above U+FFFF, so that the output only contains ASCII.
+	EscapeNonASCII
+
+	// EscapeHTML also escapes <, > and &, as well as U+2028 and U+2029, so that
+	// strings can be embedded in HTML or JavaScript, like encoding/json does.
+	EscapeHTML
+)
+
 // NewEncoder returns a new Encoder that writes to w.
 func NewEncoder(w io.Writer) *Encoder {
 	return &Encoder{
-		w:            w,
-		indentSymbol: "  ",
+		w:              w,
+		indentSymbol:   "  ",
+		floatPrecision: -1,
 	}
 }
 
+// Reset makes the encoder write to w, keeping all its settings and the buffer
+// of the previous calls to Encode. It allows to reuse encoders, for example
+// with a sync.Pool, to encode many documents with fewer allocations.
+func (enc *Encoder) Reset(w io.Writer) {
+	enc.w = w
+}
+
 // SetTablesInline forces the encoder to emit all tables inline.
 //
 // This behavior can be controlled on an individual struct field basis with the
@@ -60,6 +191,18 @@ func (enc *Encoder) SetTablesInline(inline bool) *Encoder {
 	return enc
 }
 
+// SetArraysOfTables makes the encoder emit slices of structs or maps as arrays
+// of tables, with a [[header]] for each element, even when some of their
+// elements would otherwise force an inline array, like nil pointers which are
+// then emitted as the zero value of their type. Empty slices of structs or maps
+// are omitted.
+// Inline tables, and fields tagged with the inline option, still contain inline
+// arrays.
+func (enc *Encoder) SetArraysOfTables(enabled bool) *Encoder {
+	enc.arraysOfTables = enabled
+	return enc
+}
+
 // SetArraysMultiline forces the encoder to emit all arrays with one element per
 // line.
 //
@@ -71,6 +214,33 @@ func (enc *Encoder) SetArraysMultiline(multiline bool) *Encoder {
 	return enc
 }
 
+// SetArraysMultilineThreshold makes the encoder emit arrays with one element
+// per line only when their single-line form, like [1, 2, 3], would be longer
+// than columns characters. Nested arrays are considered on their own: the
+// elements of a multi-line array that fit are kept on a single line. When set,
+// it takes precedence over SetArraysMultiline, but not over the multiline tag
+// of struct fields. A value of 0 or less disables the threshold, which is the
+// default.
+func (enc *Encoder) SetArraysMultilineThreshold(columns int) *Encoder {
+	enc.arraysMaxWidth = columns
+	return enc
+}
+
+// SetSortArraysByField makes the encoder emit the elements of slices of structs
+// and maps sorted by the value of their key named key, like the name of each
+// [[servers]] table. Strings are compared byte-wise, and numbers by value,
+// before strings. Elements without the key, or holding another type of value
+// at the key, are emitted last, in their original order. Elements of other
+// types are not sorted.
+//
+// The key of a struct is matched like when decoding a document into it. The
+// slices being encoded are left unchanged. An empty key disables sorting,
+// which is the default.
+func (enc *Encoder) SetSortArraysByField(key string) *Encoder {
+	enc.arraysSortKey = key
+	return enc
+}
+
 // SetIndentSymbol defines the string that should be used for indentation. The
 // provided string is repeated for each indentation level. Defaults to two
 // spaces.
@@ -79,12 +249,362 @@ func (enc *Encoder) SetIndentSymbol(s string) *Encoder {
 	return enc
 }
 
-// SetIndentTables forces the encoder to intent tables and array tables.
+// SetIndentTables forces the encoder to intent tables and array tables. The
+// header of a table nested N levels deep and its key-values are indented by N
+// times the indent symbol, and the lines of multi-line arrays are indented
+// relative to their key. Inline tables stay on the line of their key.
 func (enc *Encoder) SetIndentTables(indent bool) *Encoder {
 	enc.indentTables = indent
 	return enc
 }
 
+// SetAlignEquals makes the encoder pad the keys of the consecutive key-values
+// of a table, so that their = signs are aligned after the longest key:
+//
+//   name    = 'app'
+//   version = 2
+//
+// The width of quoted and dotted keys is the one of their emitted form. The
+// key-values preceded by a comment, and the ones emitted with dotted keys from
+// an OrderedMap, start a new group of aligned key-values. Inline tables are not
+// aligned.
+func (enc *Encoder) SetAlignEquals(align bool) *Encoder {
+	enc.alignEquals = align
+	return enc
+}
+
+// SetQuoteAllKeys makes the encoder emit every key as a basic string, even
+// when it is a valid bare key, for the consumers that only accept quoted keys:
+//
+//   ["servers"."alpha"]
+//   "ip" = '10.0.0.1'
+//
+// The keys are escaped like the values of type string. Values are not
+// affected.
+func (enc *Encoder) SetQuoteAllKeys(quote bool) *Encoder {
+	enc.quoteAllKeys = quote
+	return enc
+}
+
+// SetFlatten makes the encoder emit the whole document without table headers.
+// The keys of tables are emitted as dotted keys instead:
+//
+//   server.http.port = 8080
+//
+// Arrays of tables cannot be represented with dotted keys, so they are
+// emitted as arrays of inline tables. Empty tables are emitted as empty inline
+// tables.
+func (enc *Encoder) SetFlatten(flatten bool) *Encoder {
+	enc.flatten = flatten
+	return enc
+}
+
+// SetTableSpacing makes the encoder separate tables with at least n blank
+// lines. It applies before the header of each top-level table, and between the
+// elements of arrays of tables:
+//
+//   [[servers]]
+//   name = 'a'
+//
+//   [[servers]]
+//   name = 'b'
+//
+// No blank line is added at the beginning of the document, or between the
+// header of a table and the first element of an array of tables it contains.
+// Defaults to 0, which keeps the default layout.
+func (enc *Encoder) SetTableSpacing(n int) *Encoder {
+	enc.tableSpacing = n
+	return enc
+}
+
+// SetFloatExponentStyle sets whether floats use scientific notation, and
+// whether their mantissa always contains a decimal point. Floats without a
+// fractional part always end with ".0" when they don't have an exponent, so
+// that they are not read back as integers. Defaults to FloatExponentNever.
+func (enc *Encoder) SetFloatExponentStyle(style FloatExponentStyle) *Encoder {
+	enc.floatStyle = style
+	return enc
+}
+
+// SetStringEscapeMode sets which characters are escaped in strings and quoted
+// keys. Strings and keys containing characters to escape are emitted as basic
+// strings, since literal strings cannot contain escape sequences. Control
+// characters are always escaped. Comments are emitted as they are. Defaults to
+// EscapeMinimal.
+func (enc *Encoder) SetStringEscapeMode(mode StringEscapeMode) *Encoder {
+	enc.escapeMode = mode
+	return enc
+}
+
+// SetFloatPrecision sets the precision used to emit floats, as defined by
+// strconv.FormatFloat: the number of digits after the decimal point for the
+// 'e' and 'f' formats, and the number of significant digits for the 'g'
+// format. A negative precision, the default, uses the smallest number of
+// digits that reads back as the same float.
+//
+// inf and nan are emitted as such whatever the precision, and floats without a
+// fractional part still end with ".0" when they don't have an exponent. Floats
+// that rounding would make overflow, like math.MaxFloat64 with a precision of
+// 2, use the smallest number of digits instead.
+func (enc *Encoder) SetFloatPrecision(prec int) *Encoder {
+	enc.floatPrecision = prec
+	return enc
+}
+
+// SetFloatFormat sets the format used to emit floats, as defined by
+// strconv.FormatFloat: 'e' for scientific notation, 'f' for no exponent, and
+// 'g' for 'e' for large exponents and 'f' otherwise. It takes precedence over
+// SetFloatExponentStyle. Any other value restores the default, which is to
+// follow SetFloatExponentStyle.
+func (enc *Encoder) SetFloatFormat(format byte) *Encoder {
+	switch format {
+	case 'e', 'f', 'g':
+		enc.floatFormat = format
+	default:
+		enc.floatFormat = 0
+	}
+	return enc
+}
+
+// SetInlineTableMaxLen makes the encoder emit tables, coming from structs or
+// maps, as inline tables when their inline form is at most n bytes long:
+//
+//   point = {x = 1, y = 2}
+//
+// Tables that contain arrays of tables, or sub-tables that are not short
+// enough to be inlined themselves, are always emitted with a header. A value of
+// 0 or less disables the threshold, which is the default.
+func (enc *Encoder) SetInlineTableMaxLen(n int) *Encoder {
+	enc.inlineMaxLen = n
+	return enc
+}
+
+// SetInlineTableThreshold makes the encoder emit tables, coming from structs or
+// maps, as inline tables when they have at most maxKeys keys, whose values are
+// all neither tables nor arrays:
+//
+//   color = {r = 255, g = 128, b = 0}
+//
+// Larger tables, and tables containing tables or arrays, are emitted with a
+// header, and their sub-tables are considered on their own. When
+// SetInlineTableMaxLen is also set, tables must satisfy both limits to be
+// inlined. A value of 0 or less disables the threshold, which is the default.
+func (enc *Encoder) SetInlineTableThreshold(maxKeys int) *Encoder {
+	enc.inlineMaxKeys = maxKeys
+	return enc
+}
+
+// SetMultilineStringThreshold makes the encoder emit the strings that contain
+// a newline, or that are longer than n bytes, as multi-line basic strings:
+//
+//   script = """
+//   echo hello
+//   """
+//
+// The content of the strings is preserved exactly, including their leading and
+// trailing newlines. A value of 0 or less disables the threshold, which is the
+// default: strings are then multi-line only when tagged with the "multiline"
+// option.
+func (enc *Encoder) SetMultilineStringThreshold(n int) *Encoder {
+	enc.multilineMinLen = n
+	return enc
+}
+
+// SetDurationNanoseconds makes the encoder emit time.Duration values as an
+// integer number of nanoseconds, instead of a string like "1h30m0s", which is
+// the default.
+func (enc *Encoder) SetDurationNanoseconds(enabled bool) *Encoder {
+	enc.durationNanos = enabled
+	return enc
+}
+
+// SetBigNumberLiterals makes the encoder emit big.Int and big.Float values as
+// bare TOML numbers, like 123456789012345678901234567890, instead of strings,
+// which is the default. Readers that decode numbers into 64-bit integers and
+// floats may not be able to read them back.
+func (enc *Encoder) SetBigNumberLiterals(enabled bool) *Encoder {
+	enc.bigNumbers = enabled
+	return enc
+}
+
+// SetLineEnding sets the sequence the encoder uses to end lines: "\n", the
+// default, or "\r\n". Encode returns an error for any other value. The
+// newlines that are part of the content of multi-line strings are emitted as
+// they are.
+func (enc *Encoder) SetLineEnding(eol string) *Encoder {
+	enc.lineEnding = eol
+	return enc
+}
+
+// SetOmitEmpty makes the encoder skip empty values, as if all the struct
+// fields were tagged with the "omitempty" option. It also applies to the
+// entries of maps. Fields tagged with the "keepzero" option are still emitted.
+func (enc *Encoder) SetOmitEmpty(enabled bool) *Encoder {
+	enc.omitEmpty = enabled
+	return enc
+}
+
+// SetMaxDepth limits the nesting of the tables and arrays the encoder emits to
+// n levels, the root table being the first one. Encoding a deeper value, like
+// a structure containing itself through a pointer, fails with an error naming
+// the path of the value, in the format described in SetValueInterceptor,
+// instead of exhausting the stack. A value of 0 or less disables the limit,
+// which is the default.
+func (enc *Encoder) SetMaxDepth(n int) *Encoder {
+	enc.maxDepth = n
+	return enc
+}
+
+// SetKeyOrderFunc sets the function used to sort the keys of maps, which are
+// sorted byte-wise by default. less reports whether the key a is emitted before
+// the key b; NaturalOrder sorts item2 before item10:
+//
+//   enc.SetKeyOrderFunc(toml.NaturalOrder)
+//
+// Fields of structs and keys of OrderedMap keep their order.
+func (enc *Encoder) SetKeyOrderFunc(less func(a, b string) bool) *Encoder {
+	enc.keyOrder = less
+	return enc
+}
+
+// SetTableKeyOrder sets a function called with the keys of each table about to
+// be emitted, in the order they would otherwise be emitted, and returning them
+// in the order they should be emitted. It applies to the keys coming from the
+// fields of structs as well as from maps, and to the keys of inline tables. For
+// example, this emits the name and version keys first in every table:
+//
+//   enc.SetTableKeyOrder(func(keys []string) []string {
+//       var first, rest []string
+//       for _, k := range keys {
+//           if k == "name" || k == "version" {
+//               first = append(first, k)
+//           } else {
+//               rest = append(rest, k)
+//           }
+//       }
+//       return append(first, rest...)
+//   })
+//
+// Key-values are still emitted before the sub-tables of a table, as required by
+// TOML. Keys missing from the returned slice are emitted last, in their original
+// order, and unknown keys are ignored.
+func (enc *Encoder) SetTableKeyOrder(order func(keys []string) []string) *Encoder {
+	enc.tableKeyOrder = order
+	return enc
+}
+
+// SetKeyMapper sets the KeyMapper used to compute the key of struct fields that
+// don't have a name in their "toml" struct tag. For example, with KebabCase the
+// field MaxRetries is emitted as max-retries.
+func (enc *Encoder) SetKeyMapper(m KeyMapper) *Encoder {
+	enc.keyMapper = m
+	return enc
+}
+
+// SetIntegerGrouping makes the encoder separate groups of n digits of integers
+// with underscores, starting from the right. For example, with n = 3:
+//
+//   population = 1_000_000
+//
+// Integers are always emitted in decimal notation. A value of 0 or less
+// disables grouping, which is the default.
+func (enc *Encoder) SetIntegerGrouping(n int) *Encoder {
+	enc.integerGrouping = n
+	return enc
+}
+
+// SetFieldComments provides comments to emit before the keys of struct fields,
+// for example generated from the documentation of the Go types. Keys of the map
+// are either the name of the struct type followed by the name of the field:
+//
+//   Config.MaxRetries
+//
+// or the path of the key in the document, in the format described in
+// SetValueInterceptor. Comments given by the "comment" struct tag take
+// precedence. Each line of a comment is emitted as a separate TOML comment.
+func (enc *Encoder) SetFieldComments(comments map[string]string) *Encoder {
+	enc.fieldComments = comments
+	return enc
+}
+
+// EnableBinaryMarshaler makes the encoder emit the values implementing
+// encoding.BinaryMarshaler as TOML strings holding the result of their
+// MarshalBinary method, encoded in standard base64. Types implementing
+// encoding.TextMarshaler or RawMarshaler, time.Time and the other types with a
+// built-in encoding are not affected.
+//
+// It matches Decoder.EnableBinaryMarshaler.
+func (enc *Encoder) EnableBinaryMarshaler() *Encoder {
+	enc.binaryMarshaler = true
+	return enc
+}
+
+// SetCommentsEnabled sets whether the encoder emits the comments given by the
+// "comment" struct tag and by SetFieldComments. Comments are enabled by
+// default.
+func (enc *Encoder) SetCommentsEnabled(enabled bool) *Encoder {
+	enc.noComments = !enabled
+	return enc
+}
+
+// SetValueInterceptor registers a function called for every value before it
+// is emitted. It can be used to replace or drop values without modifying the
+// Go structures being encoded.
+//
+// The path is made of the keys leading to the value joined with dots. Array
+// elements are designated by their index between brackets. For example:
+//
+//   servers[1].password
+func (enc *Encoder) SetValueInterceptor(fn ValueInterceptor) *Encoder {
+	enc.valueInterceptor = fn
+	return enc
+}
+
+// SetValueFormatter registers a function called for the value of every
+// key-value and every array element before it is emitted, to customize how
+// some values are represented without changing their Go types. For example,
+// this emits the enabled flags as strings:
+//
+//   enc.SetValueFormatter(func(path []string, v interface{}) (string, bool) {
+//     b, ok := v.(bool)
+//     if !ok || path[len(path)-1] != "enabled" {
+//       return "", false
+//     }
+//     if b {
+//       return `"yes"`, true
+//     }
+//     return `"no"`, true
+//   })
+//
+// The function is called after the value interceptor. The values it returns
+// are emitted as they are, and it is an error if they are not valid TOML
+// values. Tables emitted with a header are not passed to it, but their
+// key-values are.
+func (enc *Encoder) SetValueFormatter(fn ValueFormatter) *Encoder {
+	enc.valueFormatter = fn
+	return enc
+}
+
+// SetRootKey makes Encode emit v as the value of the key of the document root
+// named key, instead of as the document itself. It allows encoding values that
+// are not tables, like a slice or an integer:
+//
+//   toml.NewEncoder(w).SetRootKey("items").Encode([]string{"a", "b"})
+//
+// writes `items = ['a', 'b']`. Maps and structs are emitted as the table
+// [items], and slices of them as the array of tables [[items]]. The key is a
+// single key, which is not split on dots. A Decoder with the same root key
+// reads the value back. An empty key restores the default.
+func (enc *Encoder) SetRootKey(key string) *Encoder {
+	enc.rootKey = key
+	return enc
+}
+
+// ErrTopLevelNotTable is wrapped by the error Encode returns when v is not a
+// table, like a slice or an integer, and no root key is set with
+// Encoder.SetRootKey.
+var ErrTopLevelNotTable = errors.New("the root of a TOML document must be a table")
+
 // Encode writes a TOML representation of v to the stream.
 //
 // If v cannot be represented to TOML it returns an error.
@@ -97,12 +617,47 @@ func (enc *Encoder) SetIndentTables(indent bool) *Encoder {
 // All slices not matching rule 1 are encoded as [array]. As a result, any map
 // or struct they contain is encoded as an {inline table}.
 //
-// Nil interfaces and nil pointers are not supported.
+// TOML has no null value: struct fields and map entries holding a nil pointer
+// or a nil interface are omitted, with or without the omitempty option. Nil
+// pointer elements of slices are emitted as the zero value of the type they
+// point to, so that they keep their position among the other elements, while
+// nil interface elements are an error.
+//
+// A nil map passed to Encode produces an empty document. Other values than
+// maps and structs passed to Encode, like slices, arrays and integers, are an
+// error wrapping ErrTopLevelNotTable, since the root of a TOML document is a
+// table, unless Encoder.SetRootKey is set.
+//
+// Nullable values and the null types of database/sql, like sql.NullString, are
+// emitted as the value they hold. Keys whose value is not set are omitted. Null
+// values that are not set cannot be emitted in arrays.
+//
+// The sync/atomic types holding a boolean or an integer, like atomic.Int64, are
+// emitted as the value returned by their Load method.
 //
 // Keys in key-values always have one part.
 //
+// Map keys must be strings, or implement encoding.TextMarshaler, in which case
+// the text returned by MarshalText is used as the key, quoted when needed.
+//
+// A url.URL and a net.IPNet are emitted as the string returned by their String
+// method.
+//
+// A big.Int and a big.Float are emitted as the string returned by their
+// MarshalText method, since their value may not fit in a TOML number, unless
+// Encoder.SetBigNumberLiterals is set. They are decoded from a string as well
+// as from a number.
+//
+// Values implementing RawMarshaler are emitted as the bytes returned by their
+// MarshalTOMLRaw method, verbatim. They take precedence over
+// encoding.TextMarshaler. A RawValue is emitted the same way, or as a table
+// when it holds one.
+//
 // Intermediate tables are always printed.
 //
+// A time.Duration is emitted as a string like "1h30m0s", unless
+// Encoder.SetDurationNanoseconds is set.
+//
 // By default, strings are encoded as literal string, unless they contain either
 // a newline character or a single quote. In that case they are emitted as
 // quoted strings.
@@ -122,43 +677,180 @@ func (enc *Encoder) SetIndentTables(indent bool) *Encoder {
 // has no effect on fields that would not be encoded as strings.
 //
 // The "inline" option turns fields that would be emitted as tables into inline
-// tables instead. It has no effect on other fields.
+// tables instead. It has no effect on other fields. To emit the fields of a
+// struct field in the enclosing table, without a header, use the "squash"
+// option described below.
 //
 // The "omitempty" option prevents empty values or groups from being emitted.
+// A struct or a map is empty when none of its fields or entries would be
+// emitted, so a table containing only empty tables is omitted, header
+// included. A table holding zero values that omitempty does not apply to, or
+// fields tagged with "keepzero", is not empty.
+//
+// The "keepzero" option emits the field even when it is empty and omitempty
+// applies to it, through the group it belongs to or Encoder.SetOmitEmpty. It
+// is meant for fields whose zero value is meaningful, like retries = 0 or
+// enabled = false.
+//
+// The "epoch" option emits a time.Time as an integer number of seconds since
+// the Unix epoch. Other units can be selected with "epoch=ms" (milliseconds),
+// "epoch=us" (microseconds), and "epoch=ns" (nanoseconds). The decoder reads
+// such fields from the same integers.
+//
+// The "layout" option emits a time.Time as a string formatted with the layout
+// it is given, as understood by time.Time.Format. The decoder parses such
+// fields from strings with the same layout:
+//
+//   Created time.Time `toml:"created,layout=2006-01-02 15:04"`
+//
+// As options are separated by commas, the layout cannot contain one.
+//
+// The "time-granularity" option changes how a time.Time is emitted: with
+// "time-granularity=date" as a local date (2021-01-02), with
+// "time-granularity=time" as a local time (15:04:05), and with
+// "time-granularity=datetime" as a local date-time. The location of the time is
+// not emitted in all three cases.
+//
+// The "remaining" option emits the entries of an OrderedMap or map field as if
+// they were fields of the enclosing struct, after all the other fields. Entries
+// whose key is already used by another field are skipped.
+//
+// Fields tagged with the "source", "headercomment" or "key" options are not
+// emitted.
+//
+// The exported fields of embedded structs, and of embedded pointers to structs
+// that are not nil, are emitted as fields of the enclosing struct, unless the
+// tag of the embedded field gives it a name, which makes it a table. Like
+// encoding/json, a field hides the fields of the same name embedded deeper,
+// and the fields of the same name at the same depth are all omitted, unless a
+// single one of them is named by its tag.
+//
+// The "squash" option emits the fields of a struct field as if they were
+// fields of the enclosing struct, like the fields of an embedded struct. Two
+// fields using the same key is an error.
+//
+// The "dotted" option emits a field whose name contains dots in nested
+// tables: the fields tagged `toml:"server.http.port,dotted"` and
+// `toml:"server.debug,dotted"` are emitted as the key port of the
+// [server.http] table and the key debug of the [server] table. Tables whose
+// fields are all omitted are not emitted.
 //
 // In addition to the "toml" tag struct tag, a "comment" tag can be used to emit
 // a TOML comment before the value being annotated. Comments are ignored inside
 // inline tables. For array tables, the comment is only present before the first
-// element of the array.
+// element of the array. Comments containing newlines are emitted as several
+// comment lines. Encoder.SetCommentsEnabled(false) disables them.
+//
+// A value of v that cannot be encoded results in an *EncodeError, holding the
+// path of the value in v.
 func (enc *Encoder) Encode(v interface{}) error {
 	var (
-		b   []byte
+		b   = enc.buf[:0]
 		ctx encoderCtx
 	)
 
 	ctx.inline = enc.tablesInline
+	ctx.binary = enc.binaryMarshaler
 
 	if v == nil {
 		return fmt.Errorf("toml: cannot encode a nil interface")
 	}
 
+	if enc.rootKey != "" {
+		var m OrderedMap
+		m.Set(enc.rootKey, v)
+		v = &m
+	} else if !enc.isDocument(reflect.ValueOf(v)) {
+		return fmt.Errorf("toml: cannot encode a %s as a document: %w, use Encoder.SetRootKey to emit it as the value of a key", reflect.TypeOf(v), ErrTopLevelNotTable)
+	}
+
+	switch enc.lineEnding {
+	case "", "\n", "\r\n":
+	default:
+		return fmt.Errorf("toml: invalid line ending %q: must be \"\\n\" or \"\\r\\n\"", enc.lineEnding)
+	}
+
 	b, err := enc.encode(b, ctx, reflect.ValueOf(v))
 	if err != nil {
 		return err
 	}
 
+	if enc.lineEnding == "\r\n" {
+		b = withCRLF(b)
+	}
+
 	_, err = enc.w.Write(b)
 	if err != nil {
 		return fmt.Errorf("toml: cannot write: %w", err)
 	}
 
+	// Large buffers are not kept, to not hold their memory after encoding a
+	// large document.
+	if cap(b) <= maxEncoderBufferSize {
+		enc.buf = b[:0]
+	}
+
 	return nil
 }
 
+// isDocument reports whether v is encoded as a table, and can be the root of a
+// document. Nil pointers are checked against the type they point to.
+func (enc *Encoder) isDocument(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.Zero(v.Type().Elem())
+		} else {
+			v = v.Elem()
+		}
+	}
+	return willConvertToTable(encoderCtx{binary: enc.binaryMarshaler}, v)
+}
+
+// maxEncoderBufferSize is the capacity of the largest buffer an Encoder
+// reuses between calls to Encode.
+const maxEncoderBufferSize = 64 << 10
+
+// withCRLF returns the document b with its newlines replaced by \r\n, except
+// the ones in the content of multi-line strings.
+func withCRLF(b []byte) []byte {
+	out := make([]byte, 0, len(b)+bytes.Count(b, []byte{'\n'}))
+
+	s := unstable.NewScanner(b)
+	end := 0
+	for s.Next() {
+		t := s.Token()
+		raw := t.Raw
+		if t.Kind == unstable.String && bytes.HasPrefix(raw, []byte("\"\"\"\n")) {
+			out = append(out, "\"\"\"\r\n"...)
+			out = append(out, raw[4:]...)
+		} else {
+			out = appendCRLF(out, raw)
+		}
+		end = t.Offset + len(raw)
+	}
+
+	// Invalid RawTOML values stop the scanner.
+	return appendCRLF(out, b[end:])
+}
+
+func appendCRLF(b []byte, s []byte) []byte {
+	for _, c := range s {
+		if c == '\n' {
+			b = append(b, '\r')
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
 type valueOptions struct {
-	multiline bool
-	omitempty bool
-	comment   string
+	multiline       bool
+	omitempty       bool
+	keepzero        bool
+	comment         string
+	timeGranularity string
+	epoch           string
+	layout          string
 }
 
 type encoderCtx struct {
@@ -183,8 +875,30 @@ type encoderCtx struct {
 	// Indentation level
 	indent int
 
+	// Path of the value being encoded, only maintained when
+	// Encoder.tracksPaths returns true.
+	path valuePath
+
+	// Number of tables and arrays containing the value being encoded.
+	depth int
+
+	// Set to true while measuring the single-line form of an array, to emit
+	// all the arrays it contains on a single line.
+	singleLine bool
+
 	// Options coming from struct tags
 	options valueOptions
+
+	// Parts of the dotted key preceding the key of a KV, when encoding a
+	// table defined with dotted keys.
+	dottedKey []string
+
+	// Width the key of a KV is padded to, with SetAlignEquals.
+	alignWidth int
+
+	// Set to true to emit the values implementing encoding.BinaryMarshaler as
+	// base64 strings.
+	binary bool
 }
 
 func (ctx *encoderCtx) shiftKey() {
@@ -209,26 +923,105 @@ func (ctx *encoderCtx) isRoot() bool {
 }
 
 func (enc *Encoder) encode(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, error) {
+	// Pointers to time.Time would otherwise be caught by the TextMarshaler
+	// check below, and emitted as a string.
+	if v.Kind() == reflect.Ptr && v.Type().Elem() == timeType && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if isNullType(v.Type()) {
+		if isNull(v) {
+			return nil, fmt.Errorf("toml: cannot encode a %s that is not valid", v.Type())
+		}
+		return enc.encode(b, ctx, v.Field(0))
+	}
+
+	if isAtomicType(v.Type()) {
+		return enc.encode(b, ctx, loadAtomic(v))
+	}
+
 	i := v.Interface()
 
 	switch x := i.(type) {
 	case time.Time:
-		if x.Nanosecond() > 0 {
-			return x.AppendFormat(b, time.RFC3339Nano), nil
+		if ctx.options.epoch != "" {
+			return encodeEpoch(b, x, ctx.options.epoch)
+		}
+		if ctx.options.layout != "" {
+			return enc.encodeString(b, x.Format(ctx.options.layout), ctx.options), nil
 		}
-		return x.AppendFormat(b, time.RFC3339), nil
+		return encodeTime(b, x, ctx.options.timeGranularity)
 	case LocalTime:
 		return append(b, x.String()...), nil
 	case LocalDate:
 		return append(b, x.String()...), nil
 	case LocalDateTime:
 		return append(b, x.String()...), nil
+	case Number:
+		return append(b, x.Raw()...), nil
+	case time.Duration:
+		if enc.durationNanos {
+			return strconv.AppendInt(b, int64(x), 10), nil
+		}
+		return enc.encodeString(b, x.String(), ctx.options), nil
+	case url.URL:
+		return enc.encodeString(b, x.String(), ctx.options), nil
+	case *url.URL:
+		if x != nil {
+			return enc.encodeString(b, x.String(), ctx.options), nil
+		}
+	case net.IPNet:
+		return enc.encodeString(b, x.String(), ctx.options), nil
+	case *net.IPNet:
+		if x != nil {
+			return enc.encodeString(b, x.String(), ctx.options), nil
+		}
+	case RawValue:
+		return enc.encodeRawValue(b, ctx, x)
+	case big.Int:
+		if enc.bigNumbers {
+			return x.Append(b, 10), nil
+		}
+	case *big.Int:
+		if enc.bigNumbers && x != nil {
+			return x.Append(b, 10), nil
+		}
+	case big.Float:
+		if enc.bigNumbers {
+			return appendBigFloat(b, &x), nil
+		}
+	case *big.Float:
+		if enc.bigNumbers && x != nil {
+			return appendBigFloat(b, x), nil
+		}
+	}
+
+	if hasRawMarshaler(v) {
+		if v.Type().Kind() != reflect.Ptr && !v.Type().Implements(rawMarshalerType) {
+			v = v.Addr()
+		}
+
+		if ctx.isRoot() {
+			return nil, fmt.Errorf("toml: type %s implementing the RawMarshaler interface cannot be a root element", v.Type())
+		}
+
+		raw, err := v.Interface().(RawMarshaler).MarshalTOMLRaw()
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err = checkRawValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("toml: invalid value returned by MarshalTOMLRaw of %s: %w", v.Type(), err)
+		}
+
+		return append(b, raw...), nil
 	}
 
 	hasTextMarshaler := v.Type().Implements(textMarshalerType)
-	if hasTextMarshaler || (v.CanAddr() && reflect.PtrTo(v.Type()).Implements(textMarshalerType)) {
+	if hasTextMarshaler || (v.Kind() != reflect.Ptr && reflect.PtrTo(v.Type()).Implements(textMarshalerType)) {
 		if !hasTextMarshaler {
-			v = v.Addr()
+			v = addressable(v).Addr()
 		}
 
 		if ctx.isRoot() {
@@ -245,11 +1038,41 @@ func (enc *Encoder) encode(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, e
 		return b, nil
 	}
 
+	if hasBinaryMarshaler(ctx, v) {
+		if v.Kind() != reflect.Ptr {
+			v = addressable(v).Addr()
+		}
+
+		if ctx.isRoot() {
+			return nil, fmt.Errorf("toml: type %s implementing the BinaryMarshaler interface cannot be a root element", v.Type())
+		}
+
+		data, err := v.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+
+		return enc.encodeString(b, base64.StdEncoding.EncodeToString(data), ctx.options), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice:
+		if enc.maxDepth > 0 {
+			if ctx.depth >= enc.maxDepth {
+				return nil, fmt.Errorf("toml: maximum depth of %d exceeded", enc.maxDepth)
+			}
+			ctx.depth++
+		}
+	}
+
 	switch v.Kind() {
 	// containers
 	case reflect.Map:
 		return enc.encodeMap(b, ctx, v)
 	case reflect.Struct:
+		if v.Type() == orderedMapType {
+			return enc.encodeOrderedMap(b, ctx, v)
+		}
 		return enc.encodeStruct(b, ctx, v)
 	case reflect.Slice:
 		return enc.encodeSlice(b, ctx, v)
@@ -278,10 +1101,8 @@ func (enc *Encoder) encode(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, e
 			b = append(b, "inf"...)
 		} else if f < -math.MaxFloat32 {
 			b = append(b, "-inf"...)
-		} else if math.Trunc(f) == f {
-			b = strconv.AppendFloat(b, f, 'f', 1, 32)
 		} else {
-			b = strconv.AppendFloat(b, f, 'f', -1, 32)
+			b = enc.appendFloat(b, f, 32)
 		}
 	case reflect.Float64:
 		f := v.Float()
@@ -291,10 +1112,8 @@ func (enc *Encoder) encode(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, e
 			b = append(b, "inf"...)
 		} else if f < -math.MaxFloat64 {
 			b = append(b, "-inf"...)
-		} else if math.Trunc(f) == f {
-			b = strconv.AppendFloat(b, f, 'f', 1, 64)
 		} else {
-			b = strconv.AppendFloat(b, f, 'f', -1, 64)
+			b = enc.appendFloat(b, f, 64)
 		}
 	case reflect.Bool:
 		if v.Bool() {
@@ -303,9 +1122,13 @@ func (enc *Encoder) encode(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, e
 			b = append(b, "false"...)
 		}
 	case reflect.Uint64, reflect.Uint32, reflect.Uint16, reflect.Uint8, reflect.Uint:
+		start := len(b)
 		b = strconv.AppendUint(b, v.Uint(), 10)
+		b = enc.groupDigits(b, start)
 	case reflect.Int64, reflect.Int32, reflect.Int16, reflect.Int8, reflect.Int:
+		start := len(b)
 		b = strconv.AppendInt(b, v.Int(), 10)
+		b = enc.groupDigits(b, start)
 	default:
 		return nil, fmt.Errorf("toml: cannot encode value of type %s", v.Kind())
 	}
@@ -313,19 +1136,157 @@ func (enc *Encoder) encode(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, e
 	return b, nil
 }
 
-func isNil(v reflect.Value) bool {
-	switch v.Kind() {
-	case reflect.Ptr, reflect.Interface, reflect.Map:
-		return v.IsNil()
-	default:
-		return false
+// RawMarshaler is implemented by types that provide the TOML representation of
+// their value themselves. The bytes returned by MarshalTOMLRaw are emitted
+// verbatim as the value of the key, and must form exactly one valid TOML value,
+// which can span several lines, like a multi-line string or array:
+//
+//   func (m Matrix) MarshalTOMLRaw() ([]byte, error) {
+//     return []byte("[\n  [1, 0],\n  [0, 1],\n]"), nil
+//   }
+//
+// Whitespace around the value is ignored. The value cannot be followed by a
+// comment, since it may be emitted inside an inline table or an array.
+type RawMarshaler interface {
+	MarshalTOMLRaw() ([]byte, error)
+}
+
+// hasRawMarshaler reports whether v, or a pointer to v if it is addressable,
+// implements RawMarshaler.
+func hasRawMarshaler(v reflect.Value) bool {
+	if v.Type().Implements(rawMarshalerType) {
+		return v.Kind() != reflect.Ptr || !v.IsNil()
+	}
+	return v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(v.Type()).Implements(rawMarshalerType)
+}
+
+// checkRawValue returns raw without the whitespace around it, or an error if it
+// is not exactly one TOML value.
+func checkRawValue(raw []byte) ([]byte, error) {
+	raw = bytes.TrimSpace(raw)
+	doc := append([]byte("v = "), raw...)
+
+	p := parser{}
+	p.Reset(doc)
+	if !p.NextExpression() {
+		if err := p.Error(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("no value")
+	}
+
+	value := p.Expression().Value()
+	data := value.Data
+	if value.Raw.Length > 0 {
+		data = p.Raw(value.Raw)
+	}
+	end := danger.SubsliceOffset(doc, data) + len(data)
+
+	if p.NextExpression() || end != len(doc) {
+		return nil, fmt.Errorf("unexpected content after the value")
+	}
+	if err := p.Error(); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// appendBigFloat appends f as a TOML float, with as many digits as needed to
+// represent it exactly at its precision.
+func appendBigFloat(b []byte, f *big.Float) []byte {
+	if f.IsInf() {
+		if f.Signbit() {
+			return append(b, "-inf"...)
+		}
+		return append(b, "inf"...)
+	}
+
+	start := len(b)
+	b = f.Append(b, 'g', -1)
+	if bytes.IndexAny(b[start:], ".e") < 0 {
+		b = append(b, ".0"...)
+	}
+	return b
+}
+
+func encodeTime(b []byte, t time.Time, granularity string) ([]byte, error) {
+	date := LocalDate{Year: t.Year(), Month: int(t.Month()), Day: t.Day()}
+	clock := LocalTime{Hour: t.Hour(), Minute: t.Minute(), Second: t.Second(), Nanosecond: t.Nanosecond()}
+
+	switch granularity {
+	case "":
+		if t.Nanosecond() > 0 {
+			return t.AppendFormat(b, time.RFC3339Nano), nil
+		}
+		return t.AppendFormat(b, time.RFC3339), nil
+	case "date":
+		return append(b, date.String()...), nil
+	case "time":
+		return append(b, clock.String()...), nil
+	case "datetime":
+		return append(b, LocalDateTime{date, clock}.String()...), nil
+	default:
+		return nil, fmt.Errorf("toml: unsupported time granularity %q", granularity)
+	}
+}
+
+// groupDigits inserts underscores between the groups of digits of the integer
+// that has been appended to b at offset start, according to the integer
+// grouping of the encoder.
+func (enc *Encoder) groupDigits(b []byte, start int) []byte {
+	n := enc.integerGrouping
+	digits := b[start:]
+	if len(digits) > 0 && digits[0] == '-' {
+		start++
+		digits = digits[1:]
+	}
+
+	if n <= 0 || len(digits) <= n {
+		return b
+	}
+
+	grouped := make([]byte, 0, len(digits)+len(digits)/n)
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%n == 0 {
+			grouped = append(grouped, '_')
+		}
+		grouped = append(grouped, c)
+	}
+
+	return append(b[:start], grouped...)
+}
+
+func encodeEpoch(b []byte, t time.Time, unit string) ([]byte, error) {
+	switch unit {
+	case "s":
+		return strconv.AppendInt(b, t.Unix(), 10), nil
+	case "ms":
+		return strconv.AppendInt(b, t.Unix()*1e3+int64(t.Nanosecond())/int64(time.Millisecond), 10), nil
+	case "us":
+		return strconv.AppendInt(b, t.Unix()*1e6+int64(t.Nanosecond())/int64(time.Microsecond), 10), nil
+	case "ns":
+		return strconv.AppendInt(b, t.UnixNano(), 10), nil
+	default:
+		return nil, fmt.Errorf("toml: unsupported epoch unit %q", unit)
+	}
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map:
+		return v.IsNil()
+	case reflect.Struct:
+		return isNull(v)
+	default:
+		return false
 	}
 }
 
 func (enc *Encoder) encodeKv(b []byte, ctx encoderCtx, options valueOptions, v reflect.Value) ([]byte, error) {
 	var err error
 
-	if (ctx.options.omitempty || options.omitempty) && isEmptyValue(v) {
+	if enc.omitsKv(ctx, options, v) {
 		return b, nil
 	}
 
@@ -333,25 +1294,184 @@ func (enc *Encoder) encodeKv(b []byte, ctx encoderCtx, options valueOptions, v r
 		b = enc.encodeComment(ctx.indent, options.comment, b)
 	}
 
-	b = enc.indent(ctx.indent, b)
-	b = enc.encodeKey(b, ctx.key)
-	b = append(b, " = "...)
+	if m, ok := dottedOrderedMap(v); ok && !ctx.insideKv && !ctx.inline {
+		b, err = enc.encodeDottedKvs(b, ctx, m)
+		if err != nil {
+			return nil, keyEncodeError(err, ctx.key)
+		}
+		return b, nil
+	}
+
+	// The key-values of inline tables are on the line of their key.
+	if !ctx.insideKv {
+		b = enc.indent(ctx.indent, b)
+	}
+	start := len(b)
+	b = enc.encodeKvKey(b, ctx)
+	for i := len(b) - start; i < ctx.alignWidth; i++ {
+		b = append(b, ' ')
+	}
+	if enc.compact {
+		b = append(b, '=')
+	} else {
+		b = append(b, " = "...)
+	}
 
 	// create a copy of the context because the value of a KV shouldn't
 	// modify the global context.
 	subctx := ctx
 	subctx.insideKv = true
+	subctx.path = enc.childPath(ctx.path, ctx.key)
 	subctx.shiftKey()
 	subctx.options = options
+	subctx.dottedKey = nil
+	subctx.alignWidth = 0
 
-	b, err = enc.encode(b, subctx, v)
+	b, err = enc.encodeFormatted(b, subctx, v)
 	if err != nil {
-		return nil, err
+		return nil, keyEncodeError(err, ctx.key)
+	}
+
+	return b, nil
+}
+
+// omitsKv reports whether the KV of value v is omitted because it is empty.
+func (enc *Encoder) omitsKv(ctx encoderCtx, options valueOptions, v reflect.Value) bool {
+	omitempty := enc.omitEmpty || ctx.options.omitempty || options.omitempty
+	return omitempty && !options.keepzero && !enc.skeleton && (isEmptyValue(v) || enc.isEmptyTable(ctx, options, v))
+}
+
+// encodeKvKey encodes the key of the KV at the key of ctx, including the parts
+// of its dotted key.
+func (enc *Encoder) encodeKvKey(b []byte, ctx encoderCtx) []byte {
+	if enc.flatten && !ctx.insideKv && !ctx.inline {
+		for _, k := range ctx.parentKey {
+			b = enc.encodeKey(b, k)
+			b = append(b, '.')
+		}
+	}
+	for _, k := range ctx.dottedKey {
+		b = enc.encodeKey(b, k)
+		b = append(b, '.')
+	}
+	return enc.encodeKey(b, ctx.key)
+}
+
+// alignWidths returns, when SetAlignEquals is used, the width to pad the key
+// of each of the KVs kvs to: the width of the longest key of the group of
+// consecutive KVs it belongs to.
+func (enc *Encoder) alignWidths(ctx encoderCtx, kvs []entry) []int {
+	if !enc.alignEquals || enc.compact || ctx.insideKv || ctx.inline {
+		return nil
+	}
+
+	widths := make([]int, len(kvs))
+	var group []int
+	width := 0
+	endGroup := func() {
+		for _, i := range group {
+			widths[i] = width
+		}
+		group = group[:0]
+		width = 0
+	}
+
+	for i, kv := range kvs {
+		ctx.setKey(kv.Key)
+		if enc.omitsKv(ctx, kv.Options, kv.Value) {
+			continue
+		}
+		if _, ok := dottedOrderedMap(kv.Value); ok {
+			endGroup()
+			continue
+		}
+		if kv.Options.comment != "" {
+			endGroup()
+		}
+
+		group = append(group, i)
+		if n := len(enc.encodeKvKey(nil, ctx)); n > width {
+			width = n
+		}
+	}
+	endGroup()
+
+	return widths
+}
+
+// dottedOrderedMap returns the OrderedMap held by v, if it was defined with
+// dotted keys in the document.
+func dottedOrderedMap(v reflect.Value) (*OrderedMap, bool) {
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	if v.Type() != orderedMapPtrType || v.IsNil() {
+		return nil, false
+	}
+
+	m := v.Interface().(*OrderedMap)
+	return m, m.kind == DottedTable && m.Len() > 0
+}
+
+// encodeDottedKvs encodes the entries of the OrderedMap m, stored at the key of
+// ctx, as key-values with dotted keys.
+func (enc *Encoder) encodeDottedKvs(b []byte, ctx encoderCtx, m *OrderedMap) ([]byte, error) {
+	var err error
+
+	path := enc.childPath(ctx.path, ctx.key)
+	ctx.dottedKey = append(ctx.dottedKey[:len(ctx.dottedKey):len(ctx.dottedKey)], ctx.key)
+
+	first := true
+	for _, k := range m.keys {
+		v := reflect.ValueOf(m.values[k])
+		if !v.IsValid() || isNil(v) {
+			continue
+		}
+
+		v, ok := enc.intercept(enc.childPath(path, k), v)
+		if !ok {
+			continue
+		}
+
+		if !first {
+			b = append(b, '\n')
+		}
+		first = false
+
+		ctx.setKey(k)
+		ctx.path = path
+
+		b, err = enc.encodeKv(b, ctx, valueOptions{}, v)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return b, nil
 }
 
+// isEmptyTable reports whether v, stored at the key of ctx, is a struct or a
+// map that would be encoded as a table without any key-value once its empty
+// values are omitted, including the ones of the tables it contains.
+func (enc *Encoder) isEmptyTable(ctx encoderCtx, options valueOptions, v reflect.Value) bool {
+	if !willConvertToTable(encoderCtx{}, v) {
+		return false
+	}
+
+	// The inline form of the table is {} exactly when the table has no
+	// content.
+	subctx := ctx
+	subctx.insideKv = true
+	subctx.path = enc.childPath(ctx.path, ctx.key)
+	subctx.shiftKey()
+	subctx.options = options
+	subctx.dottedKey = nil
+
+	b, err := enc.encode(nil, subctx, v)
+	return err == nil && string(b) == "{}"
+}
+
 func isEmptyValue(v reflect.Value) bool {
 	switch v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
@@ -366,30 +1486,147 @@ func isEmptyValue(v reflect.Value) bool {
 		return v.Float() == 0
 	case reflect.Interface, reflect.Ptr:
 		return v.IsNil()
+	case reflect.Struct:
+		if isAtomicType(v.Type()) {
+			return isEmptyValue(loadAtomic(v))
+		}
 	}
 	return false
 }
 
+// appendFloat appends the finite float f, of the given bit size, in the
+// style selected by SetFloatExponentStyle, SetFloatPrecision and
+// SetFloatFormat.
+func (enc *Encoder) appendFloat(b []byte, f float64, bitSize int) []byte {
+	start := len(b)
+	b = enc.appendFloatPrec(b, f, bitSize, enc.floatPrecision)
+
+	// Rounding the floats closest to the largest one can overflow it, like
+	// 1.8e+308, which would not be read back.
+	limit := math.MaxFloat64
+	if bitSize == 32 {
+		limit = math.MaxFloat32
+	}
+	if enc.floatPrecision >= 0 && math.Abs(f) > limit/2 {
+		_, err := strconv.ParseFloat(string(b[start:]), bitSize)
+		if err != nil {
+			b = enc.appendFloatPrec(b[:start], f, bitSize, -1)
+		}
+	}
+
+	return b
+}
+
+// appendFloatPrec appends the float f like appendFloat, with the precision
+// prec.
+func (enc *Encoder) appendFloatPrec(b []byte, f float64, bitSize int, prec int) []byte {
+	if enc.floatFormat != 0 {
+		start := len(b)
+		b = strconv.AppendFloat(b, f, enc.floatFormat, prec, bitSize)
+		if bytes.IndexAny(b[start:], ".e") < 0 {
+			b = append(b, ".0"...)
+		}
+		return b
+	}
+
+	if enc.floatStyle == FloatExponentNever {
+		if prec < 0 && math.Trunc(f) == f {
+			return strconv.AppendFloat(b, f, 'f', 1, bitSize)
+		}
+		start := len(b)
+		b = strconv.AppendFloat(b, f, 'f', prec, bitSize)
+		if bytes.IndexByte(b[start:], '.') < 0 {
+			b = append(b, ".0"...)
+		}
+		return b
+	}
+
+	start := len(b)
+	b = strconv.AppendFloat(b, f, 'g', prec, bitSize)
+	s := b[start:]
+
+	e := bytes.IndexByte(s, 'e')
+	if e < 0 {
+		if bytes.IndexByte(s, '.') < 0 {
+			b = append(b, ".0"...)
+		}
+		return b
+	}
+
+	if enc.floatStyle == FloatExponentDecimalPoint && bytes.IndexByte(s[:e], '.') < 0 {
+		exp := string(s[e:])
+		b = append(append(b[:start+e], ".0"...), exp...)
+	}
+
+	return b
+}
+
 const literalQuote = '\''
 
 func (enc *Encoder) encodeString(b []byte, v string, options valueOptions) []byte {
-	if needsQuoting(v) {
+	if enc.multilineMinLen > 0 && (len(v) > enc.multilineMinLen || strings.IndexByte(v, '\n') >= 0) {
+		return enc.encodeQuotedString(true, b, v)
+	}
+
+	if enc.needsQuoting(v) {
 		return enc.encodeQuotedString(options.multiline, b, v)
 	}
 
 	return enc.encodeLiteralString(b, v)
 }
 
-func needsQuoting(v string) bool {
+func (enc *Encoder) needsQuoting(v string) bool {
 	// TODO: vectorize
 	for _, b := range []byte(v) {
 		if b == '\'' || b == '\r' || b == '\n' || invalidAscii(b) {
 			return true
 		}
 	}
+
+	if enc.escapeMode != EscapeMinimal {
+		for _, r := range v {
+			if enc.escapesRune(r) {
+				return true
+			}
+		}
+	}
+
 	return false
 }
 
+// escapesRune reports whether the escape mode requires the rune r, which is
+// not a control character, to be escaped.
+func (enc *Encoder) escapesRune(r rune) bool {
+	switch enc.escapeMode {
+	case EscapeNonASCII:
+		return r > unicode.MaxASCII
+	case EscapeHTML:
+		return r == '<' || r == '>' || r == '&' || r == '\u2028' || r == '\u2029'
+	default:
+		return false
+	}
+}
+
+// appendUnicodeEscape appends the escape sequence of r to b, in its short
+// form when r is in the Basic Multilingual Plane.
+func appendUnicodeEscape(b []byte, r rune) []byte {
+	const hextable = "0123456789ABCDEF"
+
+	digits := 4
+	if r > 0xFFFF {
+		digits = 8
+		b = append(b, `\U`...)
+	} else {
+		b = append(b, `\u`...)
+	}
+
+	for shift := (digits - 1) * 4; shift >= 0; shift -= 4 {
+		b = append(b, hextable[(r>>uint(shift))&0x0f])
+	}
+
+	return b
+}
+
 // caller should have checked that the string does not contain new lines or ' .
 func (enc *Encoder) encodeLiteralString(b []byte, v string) []byte {
 	b = append(b, literalQuote)
@@ -422,7 +1659,8 @@ func (enc *Encoder) encodeQuotedString(multiline bool, b []byte, v string) []byt
 		del = 0x7f
 	)
 
-	for _, r := range []byte(v) {
+	for i := 0; i < len(v); i++ {
+		r := v[i]
 		switch r {
 		case '\\':
 			b = append(b, `\\`...)
@@ -448,8 +1686,16 @@ func (enc *Encoder) encodeQuotedString(multiline bool, b []byte, v string) []byt
 				b = append(b, `\u00`...)
 				b = append(b, hextable[r>>4])
 				b = append(b, hextable[r&0x0f])
-			default:
+			case enc.escapeMode == EscapeMinimal:
 				b = append(b, r)
+			default:
+				c, size := utf8.DecodeRuneInString(v[i:])
+				if enc.escapesRune(c) && (c != utf8.RuneError || size > 1) {
+					b = appendUnicodeEscape(b, c)
+				} else {
+					b = append(b, v[i:i+size]...)
+				}
+				i += size - 1
 			}
 		}
 	}
@@ -469,6 +1715,10 @@ func (enc *Encoder) encodeTableHeader(ctx encoderCtx, b []byte) ([]byte, error)
 		return b, nil
 	}
 
+	if len(ctx.parentKey) == 1 {
+		b = enc.spaceTable(b)
+	}
+
 	b = enc.encodeComment(ctx.indent, ctx.options.comment, b)
 
 	b = enc.indent(ctx.indent, b)
@@ -487,11 +1737,34 @@ func (enc *Encoder) encodeTableHeader(ctx encoderCtx, b []byte) ([]byte, error)
 	return b, nil
 }
 
+// spaceTable appends newlines to b so that it ends with the number of blank
+// lines requested by SetTableSpacing, unless b is empty.
+func (enc *Encoder) spaceTable(b []byte) []byte {
+	if enc.tableSpacing <= 0 || len(b) == 0 {
+		return b
+	}
+
+	blank := -1
+	for i := len(b) - 1; i >= 0 && b[i] == '\n'; i-- {
+		blank++
+	}
+
+	for ; blank < enc.tableSpacing; blank++ {
+		b = append(b, '\n')
+	}
+
+	return b
+}
+
 //nolint:cyclop
 func (enc *Encoder) encodeKey(b []byte, k string) []byte {
 	needsQuotation := false
 	cannotUseLiteral := false
 
+	if enc.quoteAllKeys {
+		return enc.encodeQuotedString(false, b, k)
+	}
+
 	if len(k) == 0 {
 		return append(b, "''"...)
 	}
@@ -508,7 +1781,7 @@ func (enc *Encoder) encodeKey(b []byte, k string) []byte {
 		needsQuotation = true
 	}
 
-	if needsQuotation && needsQuoting(k) {
+	if needsQuotation && enc.needsQuoting(k) {
 		cannotUseLiteral = true
 	}
 
@@ -523,8 +1796,9 @@ func (enc *Encoder) encodeKey(b []byte, k string) []byte {
 }
 
 func (enc *Encoder) encodeMap(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, error) {
-	if v.Type().Key().Kind() != reflect.String {
-		return nil, fmt.Errorf("toml: type %s is not supported as a map key", v.Type().Key().Kind())
+	kt := v.Type().Key()
+	if kt.Kind() != reflect.String && !kt.Implements(textMarshalerType) {
+		return nil, fmt.Errorf("toml: type %s is not supported as a map key", kt.Kind())
 	}
 
 	var (
@@ -534,32 +1808,88 @@ func (enc *Encoder) encodeMap(b []byte, ctx encoderCtx, v reflect.Value) ([]byte
 
 	iter := v.MapRange()
 	for iter.Next() {
-		k := iter.Key().String()
-		v := iter.Value()
-
-		if isNil(v) {
-			continue
+		k, err := mapKeyString(iter.Key())
+		if err != nil {
+			return nil, err
 		}
+		enc.pushMapEntry(ctx, &t, k, iter.Value(), emptyValueOptions)
+	}
 
-		if willConvertToTableOrArrayTable(ctx, v) {
-			t.pushTable(k, v, emptyValueOptions)
-		} else {
-			t.pushKV(k, v, emptyValueOptions)
-		}
+	enc.sortEntriesByKey(t.kvs)
+	enc.sortEntriesByKey(t.tables)
+
+	return enc.encodeTable(b, ctx, t)
+}
+
+// mapKeyString returns the TOML key of the map key k. Keys implementing
+// encoding.TextMarshaler are the text returned by their MarshalText method,
+// and are quoted like other keys when needed.
+func mapKeyString(k reflect.Value) (string, error) {
+	if !k.Type().Implements(textMarshalerType) {
+		return k.String(), nil
+	}
+
+	if k.Kind() == reflect.Ptr && k.IsNil() {
+		return "", fmt.Errorf("toml: cannot encode a nil %s map key", k.Type())
+	}
+
+	text, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+	if err != nil {
+		return "", fmt.Errorf("toml: cannot encode map key of type %s: %w", k.Type(), err)
 	}
+	return string(text), nil
+}
 
-	sortEntriesByKey(t.kvs)
-	sortEntriesByKey(t.tables)
+func (enc *Encoder) encodeOrderedMap(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, error) {
+	var (
+		t                 table
+		emptyValueOptions valueOptions
+	)
+
+	m := v.Interface().(OrderedMap)
+	for _, k := range m.keys {
+		enc.pushMapEntry(ctx, &t, k, reflect.ValueOf(m.values[k]), emptyValueOptions)
+	}
 
 	return enc.encodeTable(b, ctx, t)
 }
 
-func sortEntriesByKey(e []entry) {
+// pushMapEntry adds the map value v stored at key k to the table t.
+func (enc *Encoder) pushMapEntry(ctx encoderCtx, t *table, k string, v reflect.Value, options valueOptions) {
+	if !v.IsValid() || isNil(v) {
+		return
+	}
+
+	v, ok := enc.intercept(enc.childPath(ctx.path, k), v)
+	if !ok {
+		return
+	}
+
+	if n, ok := enc.arrayOfTablesLen(ctx, v); ok {
+		if n > 0 {
+			t.pushTable(k, v, options)
+		}
+	} else if willConvertToTableOrArrayTable(ctx, v) && !enc.fitsInline(ctx, k, v) {
+		t.pushTable(k, v, options)
+	} else {
+		t.pushKV(k, v, options)
+	}
+}
+
+func (enc *Encoder) sortEntriesByKey(e []entry) {
 	sort.Slice(e, func(i, j int) bool {
-		return e[i].Key < e[j].Key
+		return enc.keyLess(e[i].Key, e[j].Key)
 	})
 }
 
+// keyLess reports whether the map key a is emitted before the map key b.
+func (enc *Encoder) keyLess(a, b string) bool {
+	if enc.keyOrder != nil {
+		return enc.keyOrder(a, b)
+	}
+	return a < b
+}
+
 type entry struct {
 	Key     string
 	Value   reflect.Value
@@ -571,6 +1901,59 @@ type table struct {
 	tables []entry
 }
 
+func (t *table) has(k string) bool {
+	for _, e := range t.kvs {
+		if e.Key == k {
+			return true
+		}
+	}
+	for _, e := range t.tables {
+		if e.Key == k {
+			return true
+		}
+	}
+	return false
+}
+
+// orderTable returns t with its entries in the order returned by the function
+// set by SetTableKeyOrder, if any.
+func (enc *Encoder) orderTable(t table) table {
+	if enc.tableKeyOrder == nil || len(t.kvs)+len(t.tables) < 2 {
+		return t
+	}
+
+	keys := make([]string, 0, len(t.kvs)+len(t.tables))
+	for _, e := range t.kvs {
+		keys = append(keys, e.Key)
+	}
+	for _, e := range t.tables {
+		keys = append(keys, e.Key)
+	}
+
+	rank := make(map[string]int, len(keys))
+	for i, k := range enc.tableKeyOrder(keys) {
+		if _, ok := rank[k]; !ok {
+			rank[k] = i
+		}
+	}
+
+	order := func(entries []entry) []entry {
+		sorted := make([]entry, len(entries))
+		copy(sorted, entries)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			ri, ok := rank[sorted[i].Key]
+			if !ok {
+				return false
+			}
+			rj, ok := rank[sorted[j].Key]
+			return !ok || ri < rj
+		})
+		return sorted
+	}
+
+	return table{kvs: order(t.kvs), tables: order(t.tables)}
+}
+
 func (t *table) pushKV(k string, v reflect.Value, options valueOptions) {
 	for _, e := range t.kvs {
 		if e.Key == k {
@@ -590,64 +1973,184 @@ func (t *table) pushTable(k string, v reflect.Value, options valueOptions) {
 	t.tables = append(t.tables, entry{Key: k, Value: v, Options: options})
 }
 
-func walkStruct(ctx encoderCtx, t *table, v reflect.Value) {
-	// TODO: cache this
+func (enc *Encoder) walkStruct(ctx encoderCtx, t *table, v reflect.Value) {
+	var remaining reflect.Value
+	var dotted map[string]bool
+
 	typ := v.Type()
-	for i := 0; i < typ.NumField(); i++ {
-		fieldType := typ.Field(i)
+	for _, idx := range cachedStructInfo(typ).paths {
+		f, ok := fieldByIndex(v, idx)
+		if !ok {
+			// Inside a nil embedded pointer.
+			continue
+		}
+
+		fieldType := typ.FieldByIndex(idx)
+
+		k, opts := parseTag(fieldType.Tag.Get("toml"))
+		if !isValidName(k) {
+			k = ""
+		}
+
+		if opts.source || opts.headerComment || opts.key {
+			continue
+		}
+
+		if opts.remaining {
+			if !remaining.IsValid() {
+				remaining = f
+			}
+			continue
+		}
+
+		if idx := strings.Index(k, "."); opts.dotted && idx > 0 {
+			name := k[:idx]
+			if !dotted[name] {
+				if dotted == nil {
+					dotted = map[string]bool{}
+				}
+				dotted[name] = true
+				enc.pushDotted(ctx, t, v, name)
+			}
+			continue
+		}
+
+		if k == "" {
+			if enc.keyMapper != nil {
+				k = enc.keyMapper.Key(fieldType.Name)
+			} else {
+				k = fieldType.Name
+			}
+		}
 
-		// only consider exported fields
-		if fieldType.PkgPath != "" {
+		if isNil(f) {
 			continue
 		}
 
-		tag := fieldType.Tag.Get("toml")
+		path := enc.childPath(ctx.path, k)
+
+		f, ok = enc.intercept(path, f)
+		if !ok {
+			continue
+		}
+
+		options := valueOptions{
+			multiline:       opts.multiline,
+			omitempty:       opts.omitempty,
+			keepzero:        opts.keepzero,
+			comment:         enc.fieldComment(fieldParentType(typ, idx), fieldType, path),
+			timeGranularity: opts.timeGranularity,
+			epoch:           opts.epoch,
+			layout:          opts.layout,
+		}
+
+		if enc.skeleton {
+			if options.comment != "" {
+				options.comment += "\n"
+			}
+			options.comment += tomlTypeName(fieldType.Type)
+		}
+
+		if n, ok := enc.arrayOfTablesLen(ctx, f); ok && !opts.inline {
+			if n > 0 {
+				t.pushTable(k, f, options)
+			}
+		} else if opts.inline || !willConvertToTableOrArrayTable(ctx, f) || enc.fitsInline(ctx, k, f) {
+			t.pushKV(k, f, options)
+		} else {
+			t.pushTable(k, f, options)
+		}
+	}
+
+	if remaining.IsValid() {
+		enc.pushRemaining(ctx, t, remaining)
+	}
+}
 
-		// special field name to skip field
-		if tag == "-" {
+// pushDotted adds to t the table designated by name, the first segment of the
+// names of the fields of v tagged with the "dotted" option.
+func (enc *Encoder) pushDotted(ctx encoderCtx, t *table, v reflect.Value, name string) {
+	dt := cachedStructInfo(v.Type()).dotted[name]
+
+	// The table is omitted when none of the fields would be encoded.
+	empty := true
+	f := reflect.New(dt.encodeType).Elem()
+	for _, l := range dt.leaves {
+		x, ok := fieldByIndex(v, l.field)
+		if !ok || isNil(x) {
 			continue
 		}
+		f.FieldByIndex(l.view).Set(x)
 
-		k, opts := parseTag(tag)
-		if !isValidName(k) {
-			k = ""
+		_, opts := parseTag(v.Type().FieldByIndex(l.field).Tag.Get("toml"))
+		if !(enc.omitEmpty || opts.omitempty) || opts.keepzero || !isEmptyValue(x) {
+			empty = false
 		}
+	}
+	if empty {
+		return
+	}
 
-		f := v.Field(i)
+	f, ok := enc.intercept(enc.childPath(ctx.path, name), f)
+	if !ok {
+		return
+	}
 
-		if k == "" {
-			if fieldType.Anonymous {
-				if fieldType.Type.Kind() == reflect.Struct {
-					walkStruct(ctx, t, f)
-				}
-				continue
-			} else {
-				k = fieldType.Name
-			}
-		}
+	if !willConvertToTableOrArrayTable(ctx, f) || enc.fitsInline(ctx, name, f) {
+		t.pushKV(name, f, valueOptions{})
+	} else {
+		t.pushTable(name, f, valueOptions{})
+	}
+}
 
-		if isNil(f) {
-			continue
-		}
+// pushRemaining adds the entries of the field tagged with the "remaining"
+// option to t. Keys already provided by other fields of the struct are
+// skipped.
+func (enc *Encoder) pushRemaining(ctx encoderCtx, t *table, v reflect.Value) {
+	var emptyValueOptions valueOptions
 
-		options := valueOptions{
-			multiline: opts.multiline,
-			omitempty: opts.omitempty,
-			comment:   fieldType.Tag.Get("comment"),
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
 		}
+		v = v.Elem()
+	}
 
-		if opts.inline || !willConvertToTableOrArrayTable(ctx, f) {
-			t.pushKV(k, f, options)
-		} else {
-			t.pushTable(k, f, options)
+	switch {
+	case v.Type() == orderedMapType:
+		m := v.Interface().(OrderedMap)
+		for _, k := range m.keys {
+			if !t.has(k) {
+				enc.pushMapEntry(ctx, t, k, reflect.ValueOf(m.values[k]), emptyValueOptions)
+			}
+		}
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		keys := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			keys = append(keys, iter.Key().String())
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return enc.keyLess(keys[i], keys[j])
+		})
+
+		for _, k := range keys {
+			if !t.has(k) {
+				mk := reflect.ValueOf(k).Convert(v.Type().Key())
+				enc.pushMapEntry(ctx, t, k, v.MapIndex(mk), emptyValueOptions)
+			}
 		}
 	}
 }
 
 func (enc *Encoder) encodeStruct(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, error) {
+	if c := cachedStructInfo(v.Type()).conflict; c != "" {
+		return nil, fmt.Errorf("toml: %s", c)
+	}
+
 	var t table
 
-	walkStruct(ctx, &t, v)
+	enc.walkStruct(ctx, &t, v)
 
 	return enc.encodeTable(b, ctx, t)
 }
@@ -689,9 +2192,25 @@ func isValidName(s string) bool {
 }
 
 type tagOptions struct {
-	multiline bool
-	inline    bool
-	omitempty bool
+	multiline  bool
+	inline     bool
+	omitempty  bool
+	keepzero   bool
+	remaining  bool
+	required   bool
+	source     bool
+	deprecated bool
+	squash     bool
+	dotted     bool
+
+	headerComment bool
+	key           bool
+
+	timeGranularity string
+	epoch           string
+	layout          string
+	oneof           []string
+	aliases         []string
 }
 
 func parseTag(tag string) (string, tagOptions) {
@@ -719,6 +2238,38 @@ func parseTag(tag string) (string, tagOptions) {
 			opts.inline = true
 		case "omitempty":
 			opts.omitempty = true
+		case "keepzero":
+			opts.keepzero = true
+		case "remaining", "unknown":
+			opts.remaining = true
+		case "required":
+			opts.required = true
+		case "source":
+			opts.source = true
+		case "deprecated":
+			opts.deprecated = true
+		case "squash":
+			opts.squash = true
+		case "dotted":
+			opts.dotted = true
+		case "headercomment":
+			opts.headerComment = true
+		case "key":
+			opts.key = true
+		case "epoch":
+			opts.epoch = "s"
+		default:
+			if strings.HasPrefix(o, "time-granularity=") {
+				opts.timeGranularity = o[len("time-granularity="):]
+			} else if strings.HasPrefix(o, "epoch=") {
+				opts.epoch = o[len("epoch="):]
+			} else if strings.HasPrefix(o, "layout=") {
+				opts.layout = o[len("layout="):]
+			} else if strings.HasPrefix(o, "oneof=") {
+				opts.oneof = strings.Split(o[len("oneof="):], "|")
+			} else if strings.HasPrefix(o, "alias=") {
+				opts.aliases = strings.Split(o[len("alias="):], "|")
+			}
 		}
 	}
 
@@ -729,11 +2280,16 @@ func (enc *Encoder) encodeTable(b []byte, ctx encoderCtx, t table) ([]byte, erro
 	var err error
 
 	ctx.shiftKey()
+	t = enc.orderTable(t)
 
 	if ctx.insideKv || (ctx.inline && !ctx.isRoot()) {
 		return enc.encodeTableInline(b, ctx, t)
 	}
 
+	if enc.flatten {
+		return enc.encodeTableFlat(b, ctx, t)
+	}
+
 	if !ctx.skipTableHeader {
 		b, err = enc.encodeTableHeader(ctx, b)
 		if err != nil {
@@ -746,25 +2302,42 @@ func (enc *Encoder) encodeTable(b []byte, ctx encoderCtx, t table) ([]byte, erro
 	}
 	ctx.skipTableHeader = false
 
-	for _, kv := range t.kvs {
+	widths := enc.alignWidths(ctx, t.kvs)
+	for i, kv := range t.kvs {
 		ctx.setKey(kv.Key)
+		if widths != nil {
+			ctx.alignWidth = widths[i]
+		}
 
+		n := len(b)
 		b, err = enc.encodeKv(b, ctx, kv.Options, kv.Value)
 		if err != nil {
 			return nil, err
 		}
 
-		b = append(b, '\n')
+		// Omitted empty values don't get a line.
+		if len(b) > n {
+			b = append(b, '\n')
+		}
 	}
+	ctx.alignWidth = 0
+
+	path := ctx.path
+	omitempty := enc.omitEmpty || ctx.options.omitempty
 
 	for _, table := range t.tables {
 		ctx.setKey(table.Key)
 
+		if (omitempty || table.Options.omitempty) && !table.Options.keepzero && !enc.skeleton && enc.isEmptyTable(ctx, table.Options, table.Value) {
+			continue
+		}
+
 		ctx.options = table.Options
+		ctx.path = enc.childPath(path, table.Key)
 
 		b, err = enc.encode(b, ctx, table.Value)
 		if err != nil {
-			return nil, err
+			return nil, keyEncodeError(err, table.Key)
 		}
 
 		b = append(b, '\n')
@@ -773,6 +2346,168 @@ func (enc *Encoder) encodeTable(b []byte, ctx encoderCtx, t table) ([]byte, erro
 	return b, nil
 }
 
+// encodeTableFlat encodes the table t without a header, with the keys of its
+// key-values prefixed by the key of the table.
+func (enc *Encoder) encodeTableFlat(b []byte, ctx encoderCtx, t table) ([]byte, error) {
+	var err error
+
+	if len(t.kvs) == 0 && len(t.tables) == 0 && len(ctx.parentKey) > 0 {
+		ctx.setKey(ctx.parentKey[len(ctx.parentKey)-1])
+		ctx.parentKey = ctx.parentKey[:len(ctx.parentKey)-1]
+
+		b, err = enc.encodeKv(b, ctx, ctx.options, reflect.ValueOf(map[string]interface{}{}))
+		if err != nil {
+			return nil, err
+		}
+
+		return append(b, '\n'), nil
+	}
+
+	b = enc.encodeComment(ctx.indent, ctx.options.comment, b)
+
+	widths := enc.alignWidths(ctx, t.kvs)
+	for i, kv := range t.kvs {
+		ctx.setKey(kv.Key)
+		if widths != nil {
+			ctx.alignWidth = widths[i]
+		}
+
+		n := len(b)
+		b, err = enc.encodeKv(b, ctx, kv.Options, kv.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		// Omitted empty values don't get a line.
+		if len(b) > n {
+			b = append(b, '\n')
+		}
+	}
+	ctx.alignWidth = 0
+
+	path := ctx.path
+	omitempty := enc.omitEmpty || ctx.options.omitempty
+
+	for _, table := range t.tables {
+		ctx.setKey(table.Key)
+
+		if (omitempty || table.Options.omitempty) && !table.Options.keepzero && !enc.skeleton && enc.isEmptyTable(ctx, table.Options, table.Value) {
+			continue
+		}
+
+		ctx.options = table.Options
+		ctx.path = enc.childPath(path, table.Key)
+
+		v := table.Value
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			v = v.Elem()
+		}
+
+		if v.Kind() == reflect.Slice {
+			b, err = enc.encodeKv(b, ctx, table.Options, table.Value)
+			if err != nil {
+				return nil, err
+			}
+			b = append(b, '\n')
+		} else {
+			b, err = enc.encode(b, ctx, table.Value)
+			if err != nil {
+				return nil, keyEncodeError(err, table.Key)
+			}
+		}
+	}
+
+	return b, nil
+}
+
+// fitsInline returns true if the table v, stored at key k, is short enough to
+// be emitted inline according to SetInlineTableMaxLen.
+func (enc *Encoder) fitsInline(ctx encoderCtx, k string, v reflect.Value) bool {
+	if (enc.inlineMaxLen <= 0 && enc.inlineMaxKeys <= 0) || ctx.insideKv || !willConvertToTable(ctx, v) {
+		return false
+	}
+
+	subctx := ctx
+	subctx.setKey(k)
+	subctx.shiftKey()
+	subctx.insideKv = true
+	subctx.path = enc.childPath(ctx.path, k)
+
+	if enc.inlineMaxLen > 0 {
+		b, err := enc.encode(nil, subctx, v)
+		if err != nil || len(b) > enc.inlineMaxLen {
+			return false
+		}
+	}
+
+	// Tables with entries that need a header of their own are expanded.
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+
+	var t table
+	subctx.insideKv = false
+	switch {
+	case v.Type() == orderedMapType:
+		m := v.Interface().(OrderedMap)
+		for _, k := range m.keys {
+			enc.pushMapEntry(subctx, &t, k, reflect.ValueOf(m.values[k]), valueOptions{})
+		}
+	case v.Kind() == reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			// Errors of the keys are reported when encoding the map.
+			k, _ := mapKeyString(iter.Key())
+			enc.pushMapEntry(subctx, &t, k, iter.Value(), valueOptions{})
+		}
+	case v.Kind() == reflect.Struct:
+		enc.walkStruct(subctx, &t, v)
+	}
+
+	if len(t.tables) > 0 {
+		return false
+	}
+
+	if enc.inlineMaxKeys > 0 {
+		if len(t.kvs) > enc.inlineMaxKeys {
+			return false
+		}
+		for _, kv := range t.kvs {
+			if !isScalar(kv.Value) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// isScalar returns true if v is emitted as a TOML value that is neither a table
+// nor an array.
+func isScalar(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true
+		}
+		v = v.Elem()
+	}
+
+	t := v.Type()
+	if t == rawValueType {
+		return !isRawTable(v)
+	}
+	if t == timeType || t == numberType || t == urlType || t == ipNetType || isNullType(t) || isAtomicType(t) || t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) || t.Implements(rawMarshalerType) || reflect.PtrTo(t).Implements(rawMarshalerType) {
+		return true
+	}
+
+	switch v.Kind() {
+	case reflect.Map, reflect.Struct, reflect.Slice, reflect.Array:
+		return false
+	default:
+		return true
+	}
+}
+
 func (enc *Encoder) encodeTableInline(b []byte, ctx encoderCtx, t table) ([]byte, error) {
 	var err error
 
@@ -780,18 +2515,29 @@ func (enc *Encoder) encodeTableInline(b []byte, ctx encoderCtx, t table) ([]byte
 
 	first := true
 	for _, kv := range t.kvs {
-		if first {
-			first = false
-		} else {
-			b = append(b, `, `...)
+		start := len(b)
+		if !first {
+			if enc.compact {
+				b = append(b, ',')
+			} else {
+				b = append(b, `, `...)
+			}
 		}
 
 		ctx.setKey(kv.Key)
 
+		n := len(b)
 		b, err = enc.encodeKv(b, ctx, kv.Options, kv.Value)
 		if err != nil {
 			return nil, err
 		}
+
+		// Omitted empty values don't get a separator.
+		if len(b) == n {
+			b = b[:start]
+			continue
+		}
+		first = false
 	}
 
 	if len(t.tables) > 0 {
@@ -803,11 +2549,44 @@ func (enc *Encoder) encodeTableInline(b []byte, ctx encoderCtx, t table) ([]byte
 	return b, nil
 }
 
+// addressable returns v, or a copy of v when it is not addressable, like map
+// values and fields of structs passed by value, so that methods with a pointer
+// receiver can be called.
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+
+	x := reflect.New(v.Type()).Elem()
+	x.Set(v)
+	return x
+}
+
+// hasBinaryMarshaler reports whether v is emitted with its MarshalBinary
+// method, when Encoder.EnableBinaryMarshaler is used. Nil pointers are emitted
+// as the zero value of their type.
+func hasBinaryMarshaler(ctx encoderCtx, v reflect.Value) bool {
+	if !ctx.binary || v.Type() == timeType {
+		return false
+	}
+	if v.Kind() == reflect.Ptr {
+		return !v.IsNil() && v.Type().Implements(binaryMarshalerType)
+	}
+
+	return v.Type().Implements(binaryMarshalerType) || reflect.PtrTo(v.Type()).Implements(binaryMarshalerType)
+}
+
 func willConvertToTable(ctx encoderCtx, v reflect.Value) bool {
 	if !v.IsValid() {
 		return false
 	}
-	if v.Type() == timeType || v.Type().Implements(textMarshalerType) || (v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(v.Type()).Implements(textMarshalerType)) {
+	if isNullType(v.Type()) {
+		return !isNull(v) && willConvertToTable(ctx, v.Field(0))
+	}
+	if v.Type() == rawValueType {
+		return isRawTable(v) && !ctx.inline
+	}
+	if v.Type() == timeType || v.Type() == numberType || v.Type() == urlType || v.Type() == ipNetType || isAtomicType(v.Type()) || v.Type().Implements(textMarshalerType) || (v.Kind() != reflect.Ptr && reflect.PtrTo(v.Type()).Implements(textMarshalerType)) || hasRawMarshaler(v) || hasBinaryMarshaler(ctx, v) {
 		return false
 	}
 
@@ -818,7 +2597,7 @@ func willConvertToTable(ctx encoderCtx, v reflect.Value) bool {
 	case reflect.Interface:
 		return willConvertToTable(ctx, v.Elem())
 	case reflect.Ptr:
-		if v.IsNil() {
+		if v.IsNil() || keptAsKeyValue(v) {
 			return false
 		}
 
@@ -858,6 +2637,28 @@ func willConvertToTableOrArrayTable(ctx encoderCtx, v reflect.Value) bool {
 	return willConvertToTable(ctx, v)
 }
 
+// arrayOfTablesLen returns the number of elements of v when it is a slice of
+// structs or maps that SetArraysOfTables makes emit as an array of tables.
+func (enc *Encoder) arrayOfTablesLen(ctx encoderCtx, v reflect.Value) (int, bool) {
+	if !enc.arraysOfTables || ctx.insideKv || ctx.inline {
+		return 0, false
+	}
+
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return 0, false
+	}
+
+	t := v.Type().Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return v.Len(), willConvertToTable(ctx, reflect.New(t).Elem())
+}
+
 func (enc *Encoder) encodeSlice(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, error) {
 	if v.Len() == 0 {
 		b = append(b, "[]"...)
@@ -865,7 +2666,11 @@ func (enc *Encoder) encodeSlice(b []byte, ctx encoderCtx, v reflect.Value) ([]by
 		return b, nil
 	}
 
-	if willConvertToTableOrArrayTable(ctx, v) {
+	if enc.arraysSortKey != "" {
+		v = enc.sortSlice(v)
+	}
+
+	if _, ok := enc.arrayOfTablesLen(ctx, v); ok || willConvertToTableOrArrayTable(ctx, v) {
 		return enc.encodeSliceAsArrayTable(b, ctx, v)
 	}
 
@@ -891,15 +2696,35 @@ func (enc *Encoder) encodeSliceAsArrayTable(b []byte, ctx encoderCtx, v reflect.
 	scratch = append(scratch, "]]\n"...)
 	ctx.skipTableHeader = true
 
+	if len(ctx.parentKey) == 1 {
+		b = enc.spaceTable(b)
+	}
+
 	b = enc.encodeComment(ctx.indent, ctx.options.comment, b)
 
+	// The header is skipped when encoding each element, so the indentation of
+	// the content of the elements has to be done here.
+	indent := ctx.indent
+	if enc.indentTables {
+		ctx.indent++
+	}
+
+	path := ctx.path
+
 	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			b = enc.spaceTable(b)
+		}
+
+		b = enc.indent(indent, b)
 		b = append(b, scratch...)
 
+		ctx.path = enc.indexPath(path, i)
+
 		var err error
 		b, err = enc.encode(b, ctx, v.Index(i))
 		if err != nil {
-			return nil, err
+			return nil, indexEncodeError(err, i)
 		}
 	}
 
@@ -908,12 +2733,36 @@ func (enc *Encoder) encodeSliceAsArrayTable(b []byte, ctx encoderCtx, v reflect.
 
 func (enc *Encoder) encodeSliceAsArray(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, error) {
 	multiline := ctx.options.multiline || enc.arraysMultiline
+
+	switch {
+	case ctx.singleLine:
+		multiline = false
+	case enc.arraysMaxWidth > 0 && !ctx.options.multiline:
+		lineCtx := ctx
+		lineCtx.singleLine = true
+		line, err := enc.encodeSliceAsArray(nil, lineCtx, v)
+		if err != nil {
+			return nil, err
+		}
+		if utf8.RuneCount(line) <= enc.arraysMaxWidth {
+			return append(b, line...), nil
+		}
+		multiline = true
+	}
+
 	separator := ", "
+	if enc.compact {
+		separator = ","
+	}
 
 	b = append(b, '[')
 
 	subCtx := ctx
-	subCtx.options = valueOptions{}
+	subCtx.options = valueOptions{
+		timeGranularity: ctx.options.timeGranularity,
+		epoch:           ctx.options.epoch,
+		layout:          ctx.options.layout,
+	}
 
 	if multiline {
 		separator = ",\n"
@@ -937,9 +2786,11 @@ func (enc *Encoder) encodeSliceAsArray(b []byte, ctx encoderCtx, v reflect.Value
 			b = enc.indent(subCtx.indent, b)
 		}
 
-		b, err = enc.encode(b, subCtx, v.Index(i))
+		subCtx.path = enc.indexPath(ctx.path, i)
+
+		b, err = enc.encodeFormatted(b, subCtx, v.Index(i))
 		if err != nil {
-			return nil, err
+			return nil, indexEncodeError(err, i)
 		}
 	}
 
@@ -960,3 +2811,152 @@ func (enc *Encoder) indent(level int, b []byte) []byte {
 
 	return b
 }
+
+// fieldComment returns the comment to emit before the field f of the struct
+// type t, found at path.
+func (enc *Encoder) fieldComment(t reflect.Type, f reflect.StructField, path valuePath) string {
+	if enc.noComments {
+		return ""
+	}
+
+	if c := f.Tag.Get("comment"); c != "" || enc.fieldComments == nil {
+		return c
+	}
+
+	if c, ok := enc.fieldComments[path.s]; ok {
+		return c
+	}
+
+	return enc.fieldComments[t.Name()+"."+f.Name]
+}
+
+// tracksPaths returns true if the paths of the values need to be maintained in
+// encoderCtx.
+func (enc *Encoder) tracksPaths() bool {
+	return enc.valueInterceptor != nil || enc.valueFormatter != nil || enc.fieldComments != nil || enc.maxDepth > 0
+}
+
+// valuePath is the path of a value in the structure being encoded.
+type valuePath struct {
+	// Keys leading to the value joined with dots, with the indexes of array
+	// elements between brackets, like servers[1].password.
+	s string
+
+	// Keys and indexes leading to the value, only maintained for the value
+	// formatter.
+	keys []string
+}
+
+func (enc *Encoder) childPath(parent valuePath, k string) valuePath {
+	if !enc.tracksPaths() {
+		return valuePath{}
+	}
+
+	p := valuePath{s: k}
+	if parent.s != "" {
+		p.s = parent.s + "." + k
+	}
+	if enc.valueFormatter != nil {
+		p.keys = append(parent.keys[:len(parent.keys):len(parent.keys)], k)
+	}
+
+	return p
+}
+
+func (enc *Encoder) indexPath(parent valuePath, i int) valuePath {
+	if !enc.tracksPaths() {
+		return valuePath{}
+	}
+
+	index := strconv.Itoa(i)
+	p := valuePath{s: parent.s + "[" + index + "]"}
+	if enc.valueFormatter != nil {
+		p.keys = append(parent.keys[:len(parent.keys):len(parent.keys)], index)
+	}
+
+	return p
+}
+
+// encodeFormatted encodes the value v of a key-value or an array element,
+// with the value formatter if it handles it.
+func (enc *Encoder) encodeFormatted(b []byte, ctx encoderCtx, v reflect.Value) ([]byte, error) {
+	if enc.valueFormatter == nil {
+		return enc.encode(b, ctx, v)
+	}
+
+	raw, ok := enc.valueFormatter(ctx.path.keys, v.Interface())
+	if !ok {
+		return enc.encode(b, ctx, v)
+	}
+
+	x, err := checkRawValue([]byte(raw))
+	if err != nil {
+		return nil, fmt.Errorf("toml: invalid value %q from the value formatter: %w", raw, err)
+	}
+
+	return append(b, x...), nil
+}
+
+// intercept calls the value interceptor, if any, on the value v found at
+// path. It returns the value that should be encoded instead of v, and false if
+// the value should be skipped.
+//
+// Elements of arrays are intercepted at the same time, so that the array can
+// be correctly classified as an array or an array table.
+func (enc *Encoder) intercept(path valuePath, v reflect.Value) (reflect.Value, bool) {
+	if enc.valueInterceptor == nil {
+		return v, true
+	}
+
+	x, ok := enc.valueInterceptor(path.s, v.Interface())
+	if !ok || x == nil {
+		return reflect.Value{}, false
+	}
+
+	rv := reflect.ValueOf(x)
+	if isNil(rv) {
+		return reflect.Value{}, false
+	}
+
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return rv, true
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return reflect.ValueOf(x), true
+	}
+
+	elemType := rv.Type().Elem()
+	elems := make([]reflect.Value, 0, rv.Len())
+	assignable := true
+
+	for i := 0; i < rv.Len(); i++ {
+		e := rv.Index(i)
+		if isNil(e) {
+			elems = append(elems, e)
+			continue
+		}
+
+		e, ok := enc.intercept(enc.indexPath(path, i), e)
+		if !ok {
+			continue
+		}
+
+		assignable = assignable && e.Type().AssignableTo(elemType)
+		elems = append(elems, e)
+	}
+
+	if !assignable {
+		elemType = reflect.TypeOf((*interface{})(nil)).Elem()
+	}
+
+	s := reflect.MakeSlice(reflect.SliceOf(elemType), len(elems), len(elems))
+	for i, e := range elems {
+		s.Index(i).Set(e)
+	}
+
+	return s, true
+}
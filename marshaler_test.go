@@ -2,10 +2,13 @@ package toml_test
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
+	"net"
 	"strings"
 	"testing"
 	"time"
@@ -697,6 +700,47 @@ nan = nan
 	require.Equal(t, expected, string(actual))
 }
 
+func TestMarshalSpecialFloatsRoundTrip(t *testing.T) {
+	examples := []struct {
+		input    string
+		expected string
+	}{
+		{input: "nan", expected: "nan"},
+		{input: "+nan", expected: "nan"},
+		{input: "-nan", expected: "nan"},
+		{input: "inf", expected: "inf"},
+		{input: "+inf", expected: "inf"},
+		{input: "-inf", expected: "-inf"},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.input, func(t *testing.T) {
+			var v64 struct{ V float64 }
+			err := toml.Unmarshal([]byte("V = "+e.input), &v64)
+			require.NoError(t, err)
+			b, err := toml.Marshal(v64)
+			require.NoError(t, err)
+			require.Equal(t, "V = "+e.expected+"\n", string(b))
+
+			var v32 struct{ V float32 }
+			err = toml.Unmarshal([]byte("V = "+e.input), &v32)
+			require.NoError(t, err)
+			b, err = toml.Marshal(v32)
+			require.NoError(t, err)
+			require.Equal(t, "V = "+e.expected+"\n", string(b))
+		})
+	}
+
+	b, err := toml.Marshal(map[string]float64{"v": math.Copysign(math.NaN(), -1)})
+	require.NoError(t, err)
+	require.Equal(t, "v = nan\n", string(b))
+
+	var v32 struct{ V float32 }
+	err = toml.Unmarshal([]byte("V = -1e39"), &v32)
+	require.Error(t, err)
+}
+
 //nolint:funlen
 func TestMarshalIndentTables(t *testing.T) {
 	examples := []struct {
@@ -802,6 +846,224 @@ func TestMarshalTextMarshaler(t *testing.T) {
 	equalStringsIgnoreNewlines(t, "a = '::2'", string(r))
 }
 
+func TestMarshalTextMarshalerNested(t *testing.T) {
+	type config struct {
+		IP     net.IP
+		IPs    []net.IP
+		ByName map[string]net.IP
+		Inline struct {
+			IP net.IP
+		} `toml:",inline"`
+	}
+
+	c := config{
+		IP:     net.ParseIP("10.0.0.1"),
+		IPs:    []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("::1")},
+		ByName: map[string]net.IP{"gw": net.ParseIP("10.0.0.254")},
+	}
+	c.Inline.IP = net.ParseIP("10.0.0.3")
+
+	b, err := toml.Marshal(c)
+	require.NoError(t, err)
+
+	expected := `IP = '10.0.0.1'
+IPs = ['10.0.0.2', '::1']
+Inline = {IP = '10.0.0.3'}
+[ByName]
+gw = '10.0.0.254'
+
+`
+	require.Equal(t, expected, string(b))
+
+	var decoded config
+	require.NoError(t, toml.Unmarshal(b, &decoded))
+	require.Equal(t, c, decoded)
+
+	// Types implementing TextMarshaler with a pointer receiver are encoded
+	// with it even when they are not addressable.
+	m := map[string]interface{}{
+		"value": customTextMarshaler{value: 2},
+		"map":   map[string]customTextMarshaler{"a": {value: 3}},
+		"slice": []customTextMarshaler{{value: 4}},
+	}
+	b, err = toml.Marshal(m)
+	require.NoError(t, err)
+	require.Equal(t, "slice = ['::4']\nvalue = '::2'\n[map]\na = '::3'\n\n", string(b))
+}
+
+type binaryKey struct {
+	data []byte
+}
+
+func (k binaryKey) MarshalBinary() ([]byte, error) {
+	return k.data, nil
+}
+
+func (k *binaryKey) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("empty key")
+	}
+	k.data = data
+	return nil
+}
+
+func TestEncoderEnableBinaryMarshaler(t *testing.T) {
+	type config struct {
+		Key     binaryKey
+		Backup  *binaryKey
+		History []binaryKey
+		ByName  map[string]binaryKey
+	}
+
+	c := config{
+		Key:     binaryKey{data: []byte("secret")},
+		Backup:  &binaryKey{data: []byte{0xff, 0x00}},
+		History: []binaryKey{{data: []byte("a")}},
+		ByName:  map[string]binaryKey{"x": {data: []byte("b")}},
+	}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).EnableBinaryMarshaler().Encode(c)
+	require.NoError(t, err)
+
+	expected := `Key = 'c2VjcmV0'
+Backup = '/wA='
+History = ['YQ==']
+[ByName]
+x = 'Yg=='
+
+`
+	require.Equal(t, expected, buf.String())
+
+	var decoded config
+	err = toml.NewDecoder(&buf).EnableBinaryMarshaler().Decode(&decoded)
+	require.NoError(t, err)
+	require.Equal(t, c, decoded)
+
+	err = toml.NewDecoder(strings.NewReader("Key = 'not base64!'")).EnableBinaryMarshaler().Decode(&decoded)
+	var derr *toml.DecodeError
+	require.True(t, errors.As(err, &derr))
+	require.Equal(t, "toml: invalid base64 string for toml_test.binaryKey: illegal base64 data at input byte 3", derr.Error())
+
+	err = toml.NewDecoder(strings.NewReader("Key = ''")).EnableBinaryMarshaler().Decode(&decoded)
+	require.EqualError(t, err, "toml: empty key")
+
+	// Without the option, the type is encoded as a struct, and decoding a
+	// string into it is an error.
+	b, err := toml.Marshal(struct{ Key binaryKey }{})
+	require.NoError(t, err)
+	require.Equal(t, "[Key]\n\n", string(b))
+	err = toml.Unmarshal([]byte("Key = 'c2VjcmV0'"), &decoded)
+	require.Error(t, err)
+}
+
+type matrix [][]int
+
+func (m matrix) MarshalTOMLRaw() ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("[\n")
+	for _, row := range m {
+		fmt.Fprintf(&b, "  %v,\n", strings.Replace(fmt.Sprint(row), " ", ", ", -1))
+	}
+	b.WriteString("]")
+	return []byte(b.String()), nil
+}
+
+type rawValue string
+
+func (r *rawValue) MarshalTOMLRaw() ([]byte, error) {
+	if *r == "" {
+		return nil, fmt.Errorf("empty")
+	}
+	return []byte(*r), nil
+}
+
+func TestMarshalRawMarshaler(t *testing.T) {
+	type section struct {
+		Rotation matrix
+	}
+	type doc struct {
+		Identity matrix
+		Hex      rawValue
+		Section  section
+		Inline   section `toml:",inline"`
+	}
+
+	v := doc{
+		Identity: matrix{{1, 0}, {0, 1}},
+		Hex:      "  0xff\n",
+		Section:  section{Rotation: matrix{{0, -1}, {1, 0}}},
+		Inline:   section{Rotation: matrix{{1}}},
+	}
+
+	b, err := toml.Marshal(&v)
+	require.NoError(t, err)
+
+	expected := `Identity = [
+  [1, 0],
+  [0, 1],
+]
+Hex = 0xff
+Inline = {Rotation = [
+  [1],
+]}
+[Section]
+Rotation = [
+  [0, -1],
+  [1, 0],
+]
+
+`
+	require.Equal(t, expected, string(b))
+
+	var decoded struct {
+		Identity [][]int
+		Hex      int
+	}
+	require.NoError(t, toml.Unmarshal(b, &decoded))
+	require.Equal(t, [][]int{{1, 0}, {0, 1}}, decoded.Identity)
+	require.Equal(t, 255, decoded.Hex)
+
+	for _, raw := range []string{"", "1 # comment", "1, 2", "a = 1", "[1"} {
+		r := rawValue(raw)
+		_, err := toml.Marshal(map[string]interface{}{"a": &r})
+		require.Error(t, err, raw)
+	}
+
+	_, err = toml.Marshal(matrix{})
+	require.Error(t, err)
+}
+
+type textMapKey struct {
+	region string
+	id     int
+}
+
+func (k textMapKey) MarshalText() ([]byte, error) {
+	if k.id < 0 {
+		return nil, fmt.Errorf("negative id")
+	}
+	return []byte(fmt.Sprintf("%s/%d", k.region, k.id)), nil
+}
+
+func (k *textMapKey) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(strings.Replace(string(b), "/", " ", 1), "%s %d", &k.region, &k.id)
+	return err
+}
+
+func TestMarshalTextMarshalerMapKey(t *testing.T) {
+	m := map[textMapKey]string{
+		{region: "eu", id: 1}: "a",
+		{region: "us", id: 2}: "b",
+	}
+	r, err := toml.Marshal(m)
+	require.NoError(t, err)
+	equalStringsIgnoreNewlines(t, "'eu/1' = 'a'\n'us/2' = 'b'", string(r))
+
+	_, err = toml.Marshal(map[textMapKey]string{{id: -1}: "a"})
+	require.EqualError(t, err, "toml: cannot encode map key of type toml_test.textMapKey: negative id")
+}
+
 type brokenWriter struct{}
 
 func (b *brokenWriter) Write([]byte) (int, error) {
@@ -828,6 +1090,74 @@ func TestEncoderSetIndentSymbol(t *testing.T) {
 	equalStringsIgnoreNewlines(t, expected, w.String())
 }
 
+func TestEncoderSetIndentTablesNested(t *testing.T) {
+	type c struct {
+		V      int
+		List   []int
+		Inline map[string][]int `toml:",inline"`
+	}
+	type b struct {
+		X int
+		C c
+	}
+	type sub struct {
+		M int
+	}
+	type element struct {
+		N   string
+		Sub []sub
+	}
+	type doc struct {
+		Top int
+		A   struct {
+			Y int
+			B b
+		}
+		Elements []element
+	}
+
+	var v doc
+	v.A.B.C = c{V: 1, List: []int{1, 2}, Inline: map[string][]int{"q": {3}}}
+	v.Elements = []element{{N: "a", Sub: []sub{{M: 1}, {M: 2}}}, {N: "b"}}
+
+	var buf strings.Builder
+	enc := toml.NewEncoder(&buf)
+	enc.SetIndentTables(true).SetIndentSymbol("\t").SetArraysMultiline(true)
+	err := enc.Encode(v)
+	require.NoError(t, err)
+
+	expected := `Top = 0
+[A]
+	Y = 0
+	[A.B]
+		X = 0
+		[A.B.C]
+			V = 1
+			List = [
+				1,
+				2
+			]
+			Inline = {q = [
+				3
+			]}
+
+
+
+[[Elements]]
+	N = 'a'
+	[[Elements.Sub]]
+		M = 1
+	[[Elements.Sub]]
+		M = 2
+
+[[Elements]]
+	N = 'b'
+	Sub = []
+
+`
+	require.Equal(t, expected, buf.String())
+}
+
 func TestEncoderOmitempty(t *testing.T) {
 	type doc struct {
 		String  string            `toml:",omitempty,multiline"`
@@ -856,9 +1186,221 @@ func TestEncoderOmitempty(t *testing.T) {
 	b, err := toml.Marshal(d)
 	require.NoError(t, err)
 
-	expected := `[Struct]`
+	equalStringsIgnoreNewlines(t, "", string(b))
+}
 
-	equalStringsIgnoreNewlines(t, expected, string(b))
+func TestEncoderOmitemptyTables(t *testing.T) {
+	type inner struct {
+		A int    `toml:",omitempty"`
+		B string `toml:",omitempty"`
+	}
+	type zeros struct {
+		A int
+	}
+	type kept struct {
+		A int `toml:",keepzero"`
+	}
+	type nested struct {
+		In    inner
+		Empty map[string]inner `toml:",omitempty"`
+	}
+	type doc struct {
+		X      int
+		In     inner            `toml:",omitempty"`
+		Zeros  zeros            `toml:",omitempty"`
+		Kept   kept             `toml:",omitempty"`
+		Nested nested           `toml:",omitempty"`
+		Map    map[string]inner `toml:",omitempty"`
+		Ptr    *inner           `toml:",omitempty"`
+		Inline inner            `toml:",omitempty,inline"`
+		Full   map[string]zeros `toml:",omitempty"`
+		Plain  inner
+	}
+
+	v := doc{
+		Nested: nested{Empty: map[string]inner{"a": {}}},
+		Map:    map[string]inner{"a": {}, "b": {}},
+		Ptr:    &inner{},
+		Full:   map[string]zeros{"a": {}, "b": {A: 1}},
+	}
+
+	b, err := toml.Marshal(v)
+	require.NoError(t, err)
+
+	expected := `X = 0
+[Kept]
+A = 0
+
+[Full]
+[Full.a]
+A = 0
+
+[Full.b]
+A = 1
+
+
+[Plain]
+
+`
+	require.Equal(t, expected, string(b))
+}
+
+func TestEncoderSetValueInterceptor(t *testing.T) {
+	type server struct {
+		Name     string
+		Password string
+	}
+
+	type doc struct {
+		Token   string
+		Debug   bool
+		Ports   []int
+		Servers []server
+		Meta    map[string]interface{}
+	}
+
+	d := doc{
+		Token:   "secret",
+		Debug:   true,
+		Ports:   []int{80, 443, 8080},
+		Servers: []server{{Name: "a", Password: "pa"}, {Name: "b", Password: "pb"}},
+		Meta:    map[string]interface{}{"owner": "me", "tags": []string{"x", "y"}},
+	}
+
+	var paths []string
+
+	var buf strings.Builder
+	enc := toml.NewEncoder(&buf)
+	enc.SetValueInterceptor(func(path string, v interface{}) (interface{}, bool) {
+		paths = append(paths, path)
+		switch {
+		case path == "Token" || strings.HasSuffix(path, ".Password"):
+			return "***", true
+		case path == "Debug" || path == "Ports[1]" || path == "Meta.tags[0]":
+			return nil, false
+		}
+		return v, true
+	})
+	err := enc.Encode(d)
+	require.NoError(t, err)
+
+	expected := `
+Token = '***'
+Ports = [80, 8080]
+[[Servers]]
+Name = 'a'
+Password = '***'
+[[Servers]]
+Name = 'b'
+Password = '***'
+
+[Meta]
+owner = 'me'
+tags = ['y']
+`
+	equalStringsIgnoreNewlines(t, expected, buf.String())
+
+	for _, p := range []string{
+		"Token", "Debug", "Ports", "Ports[0]", "Ports[1]", "Ports[2]",
+		"Servers", "Servers[0]", "Servers[1]", "Servers[0].Name",
+		"Servers[1].Password", "Meta", "Meta.owner", "Meta.tags", "Meta.tags[1]",
+	} {
+		assert.Contains(t, paths, p)
+	}
+}
+
+func TestEncoderSetValueFormatter(t *testing.T) {
+	type feature struct {
+		Name    string `toml:"name"`
+		Enabled bool   `toml:"enabled"`
+	}
+	type doc struct {
+		Debug    bool               `toml:"debug"`
+		Enabled  bool               `toml:"enabled"`
+		Levels   []int              `toml:"levels"`
+		Features []feature          `toml:"features"`
+		Limits   map[string]float64 `toml:"limits,inline"`
+	}
+
+	d := doc{
+		Enabled:  true,
+		Levels:   []int{1, 2},
+		Features: []feature{{Name: "a", Enabled: false}},
+		Limits:   map[string]float64{"cpu": 1.5},
+	}
+
+	var paths [][]string
+
+	var buf strings.Builder
+	enc := toml.NewEncoder(&buf)
+	enc.SetValueFormatter(func(path []string, v interface{}) (string, bool) {
+		paths = append(paths, path)
+		if b, ok := v.(bool); ok && path[len(path)-1] == "enabled" {
+			if b {
+				return `"yes"`, true
+			}
+			return ` 'no' `, true
+		}
+		if strings.Join(path, ".") == "levels.1" {
+			return "0x2", true
+		}
+		return "", false
+	})
+	err := enc.Encode(d)
+	require.NoError(t, err)
+
+	expected := `debug = false
+enabled = "yes"
+levels = [1, 0x2]
+limits = {cpu = 1.5}
+[[features]]
+name = 'a'
+enabled = 'no'
+
+`
+	require.Equal(t, expected, buf.String())
+
+	assert.Contains(t, paths, []string{"levels"})
+	assert.Contains(t, paths, []string{"levels", "0"})
+	assert.Contains(t, paths, []string{"limits", "cpu"})
+	assert.Contains(t, paths, []string{"features", "0", "name"})
+	assert.NotContains(t, paths, []string{"features"})
+
+	enc.SetValueFormatter(func(path []string, v interface{}) (string, bool) {
+		return "yes", path[0] == "enabled"
+	})
+	err = enc.Encode(d)
+	var eerr *toml.EncodeError
+	require.ErrorAs(t, err, &eerr)
+	assert.Equal(t, []string{"enabled"}, eerr.Path)
+}
+
+func TestEncoderSetValueInterceptorReplaceTable(t *testing.T) {
+	doc := map[string]interface{}{
+		"a": map[string]string{"b": "c"},
+		"d": "e",
+	}
+
+	var buf strings.Builder
+	enc := toml.NewEncoder(&buf)
+	enc.SetValueInterceptor(func(path string, v interface{}) (interface{}, bool) {
+		if path == "a" {
+			return 42, true
+		}
+		if path == "d" {
+			return map[string]int{"x": 1}, true
+		}
+		return v, true
+	})
+	err := enc.Encode(doc)
+	require.NoError(t, err)
+
+	expected := `
+a = 42
+[d]
+x = 1
+`
+	equalStringsIgnoreNewlines(t, expected, buf.String())
 }
 
 func TestEncoderTagFieldName(t *testing.T) {
@@ -1020,6 +1562,36 @@ Name = ''
 	require.Equal(t, expected, string(out))
 }
 
+func TestEncoderSetCommentsEnabled(t *testing.T) {
+	type server struct {
+		Port int `comment:"listening port"`
+	}
+	type cfg struct {
+		Name   string `comment:"name of the\nservice"`
+		Server server `comment:"server settings"`
+	}
+
+	var buf strings.Builder
+	err := toml.NewEncoder(&buf).SetCommentsEnabled(false).SetFieldComments(map[string]string{"cfg.Name": "ignored"}).Encode(cfg{})
+	require.NoError(t, err)
+	require.Equal(t, "Name = ''\n[Server]\nPort = 0\n\n", buf.String())
+
+	buf.Reset()
+	err = toml.NewEncoder(&buf).SetCommentsEnabled(true).Encode(cfg{})
+	require.NoError(t, err)
+
+	expected := `# name of the
+# service
+Name = ''
+# server settings
+[Server]
+# listening port
+Port = 0
+
+`
+	require.Equal(t, expected, buf.String())
+}
+
 func TestMarshalNestedAnonymousStructs(t *testing.T) {
 	type Embedded struct {
 		Value string `toml:"value" json:"value"`
@@ -1084,45 +1656,1559 @@ value = ''
 	require.Equal(t, expected, string(result))
 }
 
-func TestLocalTime(t *testing.T) {
-	v := map[string]toml.LocalTime{
-		"a": toml.LocalTime{
-			Hour:       1,
-			Minute:     2,
-			Second:     3,
-			Nanosecond: 4,
+func TestMarshalArrayTableQuotedKeys(t *testing.T) {
+	examples := []struct {
+		desc     string
+		parent   string
+		key      string
+		expected string
+	}{
+		{
+			desc:     "dots",
+			parent:   "a.b",
+			key:      "c",
+			expected: "[['a.b'.c]]\nx = 1\n",
+		},
+		{
+			desc:     "spaces",
+			parent:   "a",
+			key:      "b c",
+			expected: "[[a.'b c']]\nx = 1\n",
+		},
+		{
+			desc:     "unicode",
+			parent:   "é",
+			key:      "ü.ñ",
+			expected: "[['é'.'ü.ñ']]\nx = 1\n",
+		},
+		{
+			desc:     "quotes",
+			parent:   "a",
+			key:      `b'"c`,
+			expected: "[[a.\"b'\\\"c\"]]\nx = 1\n",
+		},
+		{
+			desc:     "empty",
+			parent:   "a",
+			key:      "",
+			expected: "[[a.'']]\nx = 1\n",
 		},
 	}
 
-	expected := `a = 01:02:03.000000004
-`
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			doc := map[string]interface{}{
+				e.parent: map[string]interface{}{
+					e.key: []interface{}{
+						map[string]interface{}{"x": int64(1)},
+					},
+				},
+			}
 
-	out, err := toml.Marshal(v)
-	require.NoError(t, err)
-	require.Equal(t, expected, string(out))
+			b, err := toml.Marshal(doc)
+			require.NoError(t, err)
+			assert.Contains(t, string(b), e.expected)
+
+			var out map[string]interface{}
+			err = toml.Unmarshal(b, &out)
+			require.NoError(t, err)
+			assert.Equal(t, doc, out)
+		})
+	}
 }
 
-func ExampleMarshal() {
-	type MyConfig struct {
-		Version int
-		Name    string
-		Tags    []string
+func TestMarshalArrayTableIndentTables(t *testing.T) {
+	type elem struct {
+		X int
 	}
 
-	cfg := MyConfig{
-		Version: 2,
-		Name:    "go-toml",
-		Tags:    []string{"go", "toml"},
+	doc := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b.c": []elem{{X: 1}, {X: 2}},
+		},
 	}
 
-	b, err := toml.Marshal(cfg)
-	if err != nil {
-		panic(err)
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetIndentTables(true).Encode(doc)
+	require.NoError(t, err)
+
+	expected := `[a]
+  [[a.'b.c']]
+    X = 1
+  [[a.'b.c']]
+    X = 2
+`
+	equalStringsIgnoreNewlines(t, expected, buf.String())
+}
+
+func TestMarshalEpoch(t *testing.T) {
+	type doc struct {
+		S     time.Time   `toml:"s,epoch"`
+		Ms    time.Time   `toml:"ms,epoch=ms"`
+		Us    *time.Time  `toml:"us,epoch=us"`
+		Ns    time.Time   `toml:"ns,epoch=ns"`
+		List  []time.Time `toml:"list,epoch"`
+		Plain time.Time   `toml:"plain"`
 	}
-	fmt.Println(string(b))
 
-	// Output:
-	// Version = 2
-	// Name = 'go-toml'
-	// Tags = ['go', 'toml']
+	ts := time.Date(2021, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	d := doc{
+		S:     ts,
+		Ms:    ts,
+		Us:    &ts,
+		Ns:    ts,
+		List:  []time.Time{time.Unix(0, 0), time.Unix(-10, 0)},
+		Plain: ts,
+	}
+
+	b, err := toml.Marshal(d)
+	require.NoError(t, err)
+
+	expected := `s = 1609556645
+ms = 1609556645123
+us = 1609556645123456
+ns = 1609556645123456789
+list = [0, -10]
+plain = 2021-01-02T03:04:05.123456789Z
+`
+	require.Equal(t, expected, string(b))
+
+	var d2 doc
+	err = toml.NewDecoder(bytes.NewReader(b)).SetDefaultLocation(time.UTC).Decode(&d2)
+	require.NoError(t, err)
+	assert.Equal(t, ts.Truncate(time.Second), d2.S)
+	assert.Equal(t, ts.Truncate(time.Millisecond), d2.Ms)
+	require.NotNil(t, d2.Us)
+	assert.Equal(t, ts.Truncate(time.Microsecond), *d2.Us)
+	assert.Equal(t, ts, d2.Ns)
+	assert.Equal(t, []time.Time{time.Unix(0, 0).UTC(), time.Unix(-10, 0).UTC()}, d2.List)
+	assert.True(t, ts.Equal(d2.Plain))
+
+	// Integers cannot be decoded into time.Time without the option.
+	err = toml.Unmarshal([]byte("plain = 1"), &d2)
+	require.Error(t, err)
+}
+
+func TestMarshalTimeLayout(t *testing.T) {
+	type doc struct {
+		Created time.Time   `toml:"created,layout=2006-01-02 15:04"`
+		Zoned   *time.Time  `toml:"zoned,layout=02/01/2006 15:04 MST"`
+		List    []time.Time `toml:"list,layout=2006-01-02"`
+		Plain   time.Time   `toml:"plain"`
+	}
+
+	ts := time.Date(2021, 1, 2, 3, 4, 0, 0, time.UTC)
+	d := doc{
+		Created: ts,
+		Zoned:   &ts,
+		List:    []time.Time{ts.Truncate(24 * time.Hour)},
+		Plain:   ts,
+	}
+
+	b, err := toml.Marshal(d)
+	require.NoError(t, err)
+
+	expected := `created = '2021-01-02 03:04'
+zoned = '02/01/2021 03:04 UTC'
+list = ['2021-01-02']
+plain = 2021-01-02T03:04:00Z
+`
+	require.Equal(t, expected, string(b))
+
+	var d2 doc
+	err = toml.NewDecoder(bytes.NewReader(b)).SetDefaultLocation(time.UTC).Decode(&d2)
+	require.NoError(t, err)
+	assert.Equal(t, ts, d2.Created)
+	require.NotNil(t, d2.Zoned)
+	assert.True(t, ts.Equal(*d2.Zoned))
+	assert.Equal(t, d.List, d2.List)
+	assert.True(t, ts.Equal(d2.Plain))
+
+	err = toml.Unmarshal([]byte(`created = "2021-01-02T03:04:00Z"`), &d2)
+	require.Error(t, err)
+	var derr *toml.DecodeError
+	require.ErrorAs(t, err, &derr)
+	assert.Contains(t, derr.Error(), `expected layout "2006-01-02 15:04"`)
+	row, col := derr.Position()
+	assert.Equal(t, 1, row)
+	assert.Equal(t, 11, col)
+
+	// Strings cannot be decoded into time.Time without the option.
+	err = toml.Unmarshal([]byte(`plain = "2021-01-02 03:04"`), &d2)
+	require.Error(t, err)
+}
+
+func TestMarshalTimeGranularity(t *testing.T) {
+	type doc struct {
+		Date     time.Time   `toml:"date,time-granularity=date"`
+		Time     time.Time   `toml:"time,time-granularity=time"`
+		DateTime time.Time   `toml:"datetime,time-granularity=datetime"`
+		Offset   time.Time   `toml:"offset"`
+		Dates    []time.Time `toml:"dates,time-granularity=date"`
+	}
+
+	loc := time.FixedZone("x", 3600)
+	d := doc{
+		Date:     time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC),
+		Time:     time.Date(0, 1, 1, 15, 4, 5, 0, time.UTC),
+		DateTime: time.Date(2021, 1, 2, 15, 4, 5, 500000000, time.UTC),
+		Offset:   time.Date(2021, 1, 2, 15, 4, 5, 0, loc),
+		Dates:    []time.Time{time.Date(2021, 1, 2, 0, 0, 0, 0, time.UTC)},
+	}
+
+	b, err := toml.Marshal(d)
+	require.NoError(t, err)
+
+	expected := `date = 2021-01-02
+time = 15:04:05
+datetime = 2021-01-02T15:04:05.5
+offset = 2021-01-02T15:04:05+01:00
+dates = [2021-01-02]
+`
+	require.Equal(t, expected, string(b))
+
+	var d2 doc
+	err = toml.NewDecoder(bytes.NewReader(b)).SetDefaultLocation(time.UTC).Decode(&d2)
+	require.NoError(t, err)
+	assert.True(t, d.Date.Equal(d2.Date))
+	assert.True(t, d.Time.Equal(d2.Time))
+	assert.True(t, d.DateTime.Equal(d2.DateTime))
+	assert.True(t, d.Offset.Equal(d2.Offset))
+	require.Len(t, d2.Dates, 1)
+	assert.True(t, d.Dates[0].Equal(d2.Dates[0]))
+}
+
+func TestMarshalTimeGranularityInvalid(t *testing.T) {
+	type doc struct {
+		T time.Time `toml:",time-granularity=week"`
+	}
+
+	_, err := toml.Marshal(doc{})
+	require.Error(t, err)
+}
+
+type commentedServer struct {
+	Host string
+	Port int `comment:"from tag"`
+}
+
+type commentedConfig struct {
+	Name    string
+	Server  commentedServer
+	Servers []commentedServer
+}
+
+func TestEncoderSetFieldComments(t *testing.T) {
+	v := commentedConfig{
+		Name:    "app",
+		Server:  commentedServer{Host: "a", Port: 1},
+		Servers: []commentedServer{{Host: "b", Port: 2}},
+	}
+
+	comments := map[string]string{
+		"commentedConfig.Name":    "Name of the application.\nMust be unique.",
+		"commentedServer.Host":    "Host to listen on.",
+		"commentedServer.Port":    "ignored",
+		"Server.Host":             "Host of the main server.",
+		"commentedConfig.Missing": "unused",
+	}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetFieldComments(comments).Encode(v)
+	require.NoError(t, err)
+
+	expected := `# Name of the application.
+# Must be unique.
+Name = 'app'
+[Server]
+# Host of the main server.
+Host = 'a'
+# from tag
+Port = 1
+
+[[Servers]]
+# Host to listen on.
+Host = 'b'
+# from tag
+Port = 2
+
+`
+	require.Equal(t, expected, buf.String())
+}
+
+func TestEncoderSetFlatten(t *testing.T) {
+	type http struct {
+		Port int
+	}
+
+	type server struct {
+		Host string
+		HTTP http `comment:"HTTP settings"`
+	}
+
+	type peer struct {
+		Name string
+	}
+
+	type config struct {
+		Name   string
+		Server server
+		Empty  struct{}
+		Labels map[string]string
+		Peers  []peer
+	}
+
+	v := config{
+		Name:   "app",
+		Server: server{Host: "localhost", HTTP: http{Port: 80}},
+		Labels: map[string]string{"env": "prod", "a b": "c"},
+		Peers:  []peer{{Name: "a"}, {Name: "b"}},
+	}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetFlatten(true).Encode(v)
+	require.NoError(t, err)
+
+	expected := `Name = 'app'
+Server.Host = 'localhost'
+# HTTP settings
+Server.HTTP.Port = 80
+Empty = {}
+Labels.'a b' = 'c'
+Labels.env = 'prod'
+Peers = [{Name = 'a'}, {Name = 'b'}]
+`
+	require.Equal(t, expected, buf.String())
+
+	var out config
+	err = toml.Unmarshal(buf.Bytes(), &out)
+	require.NoError(t, err)
+	require.Equal(t, v, out)
+}
+
+func TestEncoderSetQuoteAllKeys(t *testing.T) {
+	type server struct {
+		IP   string `toml:"ip"`
+		Tags map[string]int
+	}
+
+	type config struct {
+		Name    string            `toml:"name"`
+		Servers map[string]server `toml:"servers"`
+		Peers   []server          `toml:"peers"`
+		Inline  server            `toml:"inline,inline"`
+	}
+
+	v := config{
+		Name: "app",
+		Servers: map[string]server{
+			"alpha": {IP: "10.0.0.1", Tags: map[string]int{`a"b`: 1, "": 2, "é\t": 3}},
+		},
+		Peers:  []server{{IP: "10.0.0.2"}},
+		Inline: server{IP: "10.0.0.3"},
+	}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetQuoteAllKeys(true).Encode(v)
+	require.NoError(t, err)
+
+	expected := `"name" = 'app'
+"inline" = {"ip" = '10.0.0.3'}
+["servers"]
+["servers"."alpha"]
+"ip" = '10.0.0.1'
+["servers"."alpha"."Tags"]
+"" = 2
+"a\"b" = 1
+"é\t" = 3
+
+
+
+[["peers"]]
+"ip" = '10.0.0.2'
+
+`
+	require.Equal(t, expected, buf.String())
+
+	var out config
+	err = toml.Unmarshal(buf.Bytes(), &out)
+	require.NoError(t, err)
+	require.Equal(t, v, out)
+}
+
+func TestMarshalSQLNull(t *testing.T) {
+	type config struct {
+		Name    sql.NullString
+		Count   sql.NullInt64
+		Missing sql.NullString
+		At      *sql.NullTime
+		Values  map[string]sql.NullBool
+	}
+
+	v := config{
+		Name:  sql.NullString{String: "a", Valid: true},
+		Count: sql.NullInt64{Int64: 0, Valid: true},
+		At:    &sql.NullTime{Time: time.Date(2021, 4, 8, 22, 0, 0, 0, time.UTC), Valid: true},
+		Values: map[string]sql.NullBool{
+			"set":   {Bool: true, Valid: true},
+			"unset": {},
+		},
+	}
+
+	b, err := toml.Marshal(v)
+	require.NoError(t, err)
+
+	expected := `Name = 'a'
+Count = 0
+At = 2021-04-08T22:00:00Z
+[Values]
+set = true
+
+`
+	require.Equal(t, expected, string(b))
+
+	_, err = toml.Marshal(map[string]interface{}{"a": []sql.NullString{{}}})
+	require.EqualError(t, err, "toml: cannot encode a sql.NullString that is not valid at a[0]")
+}
+
+func TestMarshalErrorPath(t *testing.T) {
+	type server struct {
+		Name string
+		Tags interface{}
+	}
+	type config struct {
+		Servers []server `toml:"servers"`
+		Labels  map[string]interface{}
+	}
+
+	c := config{
+		Servers: []server{
+			{Name: "a", Tags: []string{"x"}},
+			{Name: "b", Tags: map[int]string{1: "y"}},
+		},
+	}
+
+	_, err := toml.Marshal(c)
+	var eerr *toml.EncodeError
+	require.ErrorAs(t, err, &eerr)
+	assert.Equal(t, []string{"servers", "1", "Tags"}, eerr.Path)
+	assert.Contains(t, err.Error(), " at servers[1].Tags")
+
+	c.Servers = nil
+	c.Labels = map[string]interface{}{"x": map[string]interface{}{"y": []interface{}{1, make(chan int)}}}
+	_, err = toml.Marshal(c)
+	require.ErrorAs(t, err, &eerr)
+	assert.Equal(t, []string{"Labels", "x", "y", "1"}, eerr.Path)
+	assert.Contains(t, err.Error(), " at Labels.x.y[1]")
+}
+
+func TestEncoderSetTableSpacing(t *testing.T) {
+	type server struct {
+		Name string
+	}
+
+	type group struct {
+		Servers []server
+	}
+
+	type config struct {
+		Title   string
+		Servers []server `comment:"servers"`
+		Group   group
+	}
+
+	v := config{
+		Title:   "t",
+		Servers: []server{{Name: "a"}, {Name: "b"}},
+		Group:   group{Servers: []server{{Name: "c"}, {Name: "d"}}},
+	}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetTableSpacing(1).Encode(v)
+	require.NoError(t, err)
+
+	expected := `Title = 't'
+
+# servers
+[[Servers]]
+Name = 'a'
+
+[[Servers]]
+Name = 'b'
+
+[Group]
+[[Group.Servers]]
+Name = 'c'
+
+[[Group.Servers]]
+Name = 'd'
+
+
+`
+	require.Equal(t, expected, buf.String())
+
+	buf.Reset()
+	err = toml.NewEncoder(&buf).SetTableSpacing(2).Encode(map[string]interface{}{
+		"servers": []map[string]interface{}{{"name": "a"}, {"name": "b"}},
+	})
+	require.NoError(t, err)
+
+	expected = `[[servers]]
+name = 'a'
+
+
+[[servers]]
+name = 'b'
+
+`
+	require.Equal(t, expected, buf.String())
+}
+
+func TestEncoderSetIntegerGrouping(t *testing.T) {
+	examples := []struct {
+		desc     string
+		n        int
+		v        interface{}
+		expected string
+	}{
+		{desc: "disabled", n: 0, v: 1000000, expected: "1000000"},
+		{desc: "short", n: 3, v: 100, expected: "100"},
+		{desc: "exact", n: 3, v: 1000, expected: "1_000"},
+		{desc: "million", n: 3, v: int64(1000000), expected: "1_000_000"},
+		{desc: "negative", n: 3, v: -1234567, expected: "-1_234_567"},
+		{desc: "negative short", n: 3, v: int8(-100), expected: "-100"},
+		{desc: "unsigned", n: 4, v: uint64(123456789), expected: "1_2345_6789"},
+		{desc: "array", n: 2, v: []int{100, 12}, expected: "[1_00, 12]"},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			doc := map[string]interface{}{"a": e.v}
+			err := toml.NewEncoder(&buf).SetIntegerGrouping(e.n).Encode(doc)
+			require.NoError(t, err)
+			require.Equal(t, "a = "+e.expected+"\n", buf.String())
+
+			var out map[string]interface{}
+			err = toml.Unmarshal(buf.Bytes(), &out)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestEncoderSetFloatExponentStyle(t *testing.T) {
+	examples := []struct {
+		desc     string
+		style    toml.FloatExponentStyle
+		v        interface{}
+		expected string
+	}{
+		{desc: "never large", style: toml.FloatExponentNever, v: 1e6, expected: "1000000.0"},
+		{desc: "never small", style: toml.FloatExponentNever, v: 1.5e-7, expected: "0.00000015"},
+		{desc: "shortest large", style: toml.FloatExponentShortest, v: 1e6, expected: "1e+06"},
+		{desc: "shortest small", style: toml.FloatExponentShortest, v: 1.5e-7, expected: "1.5e-07"},
+		{desc: "shortest integral", style: toml.FloatExponentShortest, v: 42.0, expected: "42.0"},
+		{desc: "shortest fraction", style: toml.FloatExponentShortest, v: 3.25, expected: "3.25"},
+		{desc: "decimal point large", style: toml.FloatExponentDecimalPoint, v: 1e6, expected: "1.0e+06"},
+		{desc: "decimal point negative", style: toml.FloatExponentDecimalPoint, v: -2e-10, expected: "-2.0e-10"},
+		{desc: "decimal point mantissa", style: toml.FloatExponentDecimalPoint, v: 1.5e20, expected: "1.5e+20"},
+		{desc: "decimal point float32", style: toml.FloatExponentDecimalPoint, v: float32(3e10), expected: "3.0e+10"},
+		{desc: "infinity", style: toml.FloatExponentDecimalPoint, v: math.Inf(1), expected: "inf"},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			doc := map[string]interface{}{"a": e.v}
+			err := toml.NewEncoder(&buf).SetFloatExponentStyle(e.style).Encode(doc)
+			require.NoError(t, err)
+			require.Equal(t, "a = "+e.expected+"\n", buf.String())
+
+			var out map[string]interface{}
+			err = toml.Unmarshal(buf.Bytes(), &out)
+			require.NoError(t, err)
+			require.IsType(t, float64(0), out["a"])
+		})
+	}
+}
+
+func TestEncoderSetFloatPrecision(t *testing.T) {
+	examples := []struct {
+		desc     string
+		prec     int
+		format   byte
+		v        interface{}
+		expected string
+	}{
+		{desc: "default", prec: -1, v: 0.30000000000000004, expected: "0.30000000000000004"},
+		{desc: "precision", prec: 3, v: 0.30000000000000004, expected: "0.300"},
+		{desc: "precision integral", prec: 0, v: 2.0, expected: "2.0"},
+		{desc: "f", prec: 2, format: 'f', v: 3.14159, expected: "3.14"},
+		{desc: "f integral", prec: 0, format: 'f', v: 3.0, expected: "3.0"},
+		{desc: "e", prec: 2, format: 'e', v: 1234.5, expected: "1.23e+03"},
+		{desc: "e integral", prec: 0, format: 'e', v: 5.0, expected: "5e+00"},
+		{desc: "g", prec: 4, format: 'g', v: 0.30000000000000004, expected: "0.3"},
+		{desc: "g integral", prec: 4, format: 'g', v: 42.0, expected: "42.0"},
+		{desc: "g large", prec: 3, format: 'g', v: 1.5e20, expected: "1.5e+20"},
+		{desc: "float32", prec: 2, format: 'f', v: float32(0.1), expected: "0.10"},
+		{desc: "infinity", prec: 2, format: 'f', v: math.Inf(-1), expected: "-inf"},
+		{desc: "nan", prec: 2, format: 'e', v: math.NaN(), expected: "nan"},
+		{desc: "overflow", prec: 2, format: 'g', v: math.MaxFloat64, expected: "1.7976931348623157e+308"},
+		{desc: "overflow e", prec: 0, format: 'e', v: -math.MaxFloat64, expected: "-1.7976931348623157e+308"},
+		{desc: "overflow float32", prec: 3, format: 'e', v: float32(math.MaxFloat32), expected: "3.4028235e+38"},
+		{desc: "large", prec: 3, format: 'g', v: 1.2345e308, expected: "1.23e+308"},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			doc := map[string]interface{}{"a": e.v}
+			err := toml.NewEncoder(&buf).SetFloatPrecision(e.prec).SetFloatFormat(e.format).Encode(doc)
+			require.NoError(t, err)
+			require.Equal(t, "a = "+e.expected+"\n", buf.String())
+
+			var out map[string]interface{}
+			err = toml.Unmarshal(buf.Bytes(), &out)
+			require.NoError(t, err)
+			require.IsType(t, float64(0), out["a"])
+		})
+	}
+}
+
+func TestEncoderSetInlineTableMaxLen(t *testing.T) {
+	type point struct {
+		X int
+		Y int
+	}
+	type shape struct {
+		Name   string
+		Center point
+		Points []point
+	}
+	type doc struct {
+		Origin point
+		Labels map[string]string
+		Shape  shape
+		Sizes  map[string]point
+		Long   map[string]string
+	}
+
+	v := doc{
+		Origin: point{X: 1, Y: 2},
+		Labels: map[string]string{"b": "2", "a": "1"},
+		Shape: shape{
+			Name:   "s",
+			Center: point{X: 3, Y: 4},
+			Points: []point{{X: 5, Y: 6}},
+		},
+		Sizes: map[string]point{"small": {X: 1, Y: 1}},
+		Long:  map[string]string{"key": "a value that does not fit"},
+	}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetInlineTableMaxLen(30).Encode(v)
+	require.NoError(t, err)
+
+	expected := `Origin = {X = 1, Y = 2}
+Labels = {a = '1', b = '2'}
+Sizes = {small = {X = 1, Y = 1}}
+[Shape]
+Name = 's'
+Center = {X = 3, Y = 4}
+[[Shape.Points]]
+X = 5
+Y = 6
+
+
+[Long]
+key = 'a value that does not fit'
+
+`
+	require.Equal(t, expected, buf.String())
+
+	var out doc
+	err = toml.Unmarshal(buf.Bytes(), &out)
+	require.NoError(t, err)
+	require.Equal(t, v, out)
+}
+
+func TestEncoderSetInlineTableThreshold(t *testing.T) {
+	type color struct {
+		R, G, B uint8
+	}
+	type theme struct {
+		Name   string
+		Fg     color
+		Bg     color
+		Accent *color
+	}
+	type doc struct {
+		Color  color
+		Theme  theme
+		Big    map[string]int
+		Tagged map[string][]string
+	}
+
+	v := doc{
+		Color:  color{R: 255},
+		Theme:  theme{Name: "dark", Fg: color{R: 1, G: 2, B: 3}},
+		Big:    map[string]int{"a": 1, "b": 2, "c": 3, "d": 4},
+		Tagged: map[string][]string{"a": {"x"}},
+	}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetInlineTableThreshold(3).Encode(v)
+	require.NoError(t, err)
+
+	expected := `Color = {R = 255, G = 0, B = 0}
+[Theme]
+Name = 'dark'
+Fg = {R = 1, G = 2, B = 3}
+Bg = {R = 0, G = 0, B = 0}
+
+[Big]
+a = 1
+b = 2
+c = 3
+d = 4
+
+[Tagged]
+a = ['x']
+
+`
+	require.Equal(t, expected, buf.String())
+
+	var out doc
+	err = toml.Unmarshal(buf.Bytes(), &out)
+	require.NoError(t, err)
+	require.Equal(t, v, out)
+}
+
+func TestMarshalValue(t *testing.T) {
+	type point struct {
+		X, Y int
+		Tags []string
+		Meta map[string]interface{} `toml:",omitempty"`
+	}
+
+	examples := []struct {
+		desc     string
+		v        interface{}
+		expected string
+		err      bool
+	}{
+		{desc: "integer", v: 42, expected: "42"},
+		{desc: "string", v: "a\nb", expected: `"a\nb"`},
+		{desc: "float", v: 1.5, expected: "1.5"},
+		{desc: "datetime", v: time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC), expected: "2021-01-02T03:04:05Z"},
+		{desc: "local date", v: toml.LocalDate{Year: 2021, Month: 1, Day: 2}, expected: "2021-01-02"},
+		{desc: "array", v: []int{1, 2}, expected: "[1, 2]"},
+		{
+			desc:     "nested map",
+			v:        map[string]interface{}{"a": map[string]int{"b": 1}, "c": []map[string]int{{"d": 2}}},
+			expected: "{a = {b = 1}, c = [{d = 2}]}",
+		},
+		{desc: "struct", v: point{X: 1, Y: 2, Tags: []string{"x"}}, expected: "{X = 1, Y = 2, Tags = ['x']}"},
+		{desc: "text marshaler", v: &customTextMarshaler{value: 7}, expected: "'::7'"},
+		{desc: "big integer", v: big.NewInt(7), expected: "'7'"},
+		{desc: "nil", v: nil, err: true},
+		{desc: "nil element", v: []interface{}{nil}, err: true},
+		{desc: "func", v: func() {}, err: true},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			b, err := toml.MarshalValue(e.v)
+			if e.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, e.expected, string(b))
+		})
+	}
+}
+
+func TestEncoderSetMaxDepth(t *testing.T) {
+	v := map[string]interface{}{
+		"a": map[string]interface{}{
+			"b": []interface{}{
+				map[string]interface{}{"c": 1},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetMaxDepth(4).Encode(v)
+	require.NoError(t, err)
+
+	err = toml.NewEncoder(&buf).SetMaxDepth(3).Encode(v)
+	require.EqualError(t, err, "toml: maximum depth of 3 exceeded at a.b[0]")
+
+	err = toml.NewEncoder(&buf).SetMaxDepth(2).Encode(v)
+	require.EqualError(t, err, "toml: maximum depth of 2 exceeded at a.b")
+
+	type node struct {
+		Name string
+		Next *node
+	}
+	n := &node{Name: "a"}
+	n.Next = n
+
+	err = toml.NewEncoder(&buf).SetMaxDepth(3).Encode(n)
+	require.EqualError(t, err, "toml: maximum depth of 3 exceeded at Next.Next.Next")
+}
+
+func TestEncoderSetOmitEmptyKeepZero(t *testing.T) {
+	type retry struct {
+		Retries int     `toml:"retries,keepzero"`
+		Delay   float64 `toml:"delay,keepzero"`
+		Enabled bool    `toml:"enabled,keepzero"`
+		Name    string  `toml:"name"`
+		Tags    []string
+	}
+	type config struct {
+		Retry  retry          `toml:"retry"`
+		Group  retry          `toml:"group,omitempty"`
+		Limits map[string]int `toml:"limits"`
+		Count  int
+	}
+
+	v := config{Limits: map[string]int{"a": 0, "b": 1}}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetOmitEmpty(true).Encode(v)
+	require.NoError(t, err)
+
+	expected := `[retry]
+retries = 0
+delay = 0.0
+enabled = false
+
+[group]
+retries = 0
+delay = 0.0
+enabled = false
+
+[limits]
+b = 1
+
+`
+	require.Equal(t, expected, buf.String())
+
+	buf.Reset()
+	err = toml.NewEncoder(&buf).Encode(config{Group: retry{Retries: 0}})
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "[retry]\nretries = 0\ndelay = 0.0\nenabled = false\nname = ''\nTags = []\n")
+	require.Contains(t, buf.String(), "[group]\nretries = 0\ndelay = 0.0\nenabled = false\n\n")
+}
+
+func TestEncoderSetMultilineStringThreshold(t *testing.T) {
+	type doc struct {
+		Script string
+		Long   string
+		Short  string
+		Quotes string
+	}
+
+	v := doc{
+		Script: "\nline 1\nline 2\n\n",
+		Long:   "0123456789abc",
+		Short:  "short",
+		Quotes: "a\n\"\"\" \\ b",
+	}
+
+	var buf strings.Builder
+	enc := toml.NewEncoder(&buf)
+	enc.SetMultilineStringThreshold(10)
+	err := enc.Encode(v)
+	require.NoError(t, err)
+
+	expected := `Script = """
+
+line 1
+line 2
+
+"""
+Long = """
+0123456789abc"""
+Short = 'short'
+Quotes = """
+a
+\"\"\" \\ b"""
+`
+	require.Equal(t, expected, buf.String())
+
+	var decoded doc
+	err = toml.Unmarshal([]byte(buf.String()), &decoded)
+	require.NoError(t, err)
+	require.Equal(t, v, decoded)
+}
+
+func TestMarshalBigNumbers(t *testing.T) {
+	type doc struct {
+		Amount *big.Int
+		Nonce  big.Int
+		Ratio  *big.Float
+		Whole  *big.Float
+		Limit  *big.Float
+		Values []*big.Int
+	}
+
+	amount, ok := new(big.Int).SetString("123456789012345678901234567890", 10)
+	require.True(t, ok)
+	ratio, _, err := big.ParseFloat("0.1234567890123456789012345", 10, 100, big.ToNearestEven)
+	require.NoError(t, err)
+
+	v := doc{
+		Amount: amount,
+		Nonce:  *big.NewInt(-1),
+		Ratio:  ratio,
+		Whole:  big.NewFloat(3),
+		Limit:  new(big.Float).SetInf(true),
+		Values: []*big.Int{big.NewInt(1), big.NewInt(2)},
+	}
+
+	b, err := toml.Marshal(v)
+	require.NoError(t, err)
+
+	expected := `Amount = '123456789012345678901234567890'
+Nonce = '-1'
+Ratio = '0.1234567890123456789012345'
+Whole = '3'
+Limit = '-Inf'
+Values = ['1', '2']
+`
+	require.Equal(t, expected, string(b))
+
+	// Strings are decoded by UnmarshalText, which keeps the precision of the
+	// target.
+	decoded := doc{Ratio: new(big.Float).SetPrec(ratio.Prec())}
+	err = toml.Unmarshal(b, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, 0, v.Amount.Cmp(decoded.Amount))
+	require.Equal(t, 0, v.Nonce.Cmp(&decoded.Nonce))
+	require.Equal(t, v.Ratio.Text('g', -1), decoded.Ratio.Text('g', -1))
+	require.Equal(t, 0, v.Whole.Cmp(decoded.Whole))
+	require.True(t, decoded.Limit.IsInf())
+	require.Equal(t, v.Values, decoded.Values)
+
+	t.Run("literals", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := toml.NewEncoder(&buf).SetBigNumberLiterals(true).Encode(v)
+		require.NoError(t, err)
+
+		expected := `Amount = 123456789012345678901234567890
+Nonce = -1
+Ratio = 0.1234567890123456789012345
+Whole = 3.0
+Limit = -inf
+Values = [1, 2]
+`
+		require.Equal(t, expected, buf.String())
+
+		var decoded doc
+		err = toml.Unmarshal(buf.Bytes(), &decoded)
+		require.NoError(t, err)
+		require.Equal(t, 0, v.Amount.Cmp(decoded.Amount))
+		require.Equal(t, 0, v.Nonce.Cmp(&decoded.Nonce))
+		require.Equal(t, v.Ratio.Text('g', -1), decoded.Ratio.Text('g', -1))
+		require.Equal(t, 0, v.Whole.Cmp(decoded.Whole))
+		require.True(t, decoded.Limit.IsInf())
+		require.Equal(t, v.Values, decoded.Values)
+	})
+}
+
+func TestMarshalDuration(t *testing.T) {
+	type doc struct {
+		Timeout  time.Duration
+		Retry    *time.Duration
+		Backoffs []time.Duration
+	}
+
+	retry := 90 * time.Minute
+	v := doc{
+		Timeout:  30 * time.Second,
+		Retry:    &retry,
+		Backoffs: []time.Duration{time.Millisecond, 0},
+	}
+
+	b, err := toml.Marshal(v)
+	require.NoError(t, err)
+	require.Equal(t, "Timeout = '30s'\nRetry = '1h30m0s'\nBackoffs = ['1ms', '0s']\n", string(b))
+
+	var decoded doc
+	err = toml.Unmarshal(b, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, v, decoded)
+
+	var buf strings.Builder
+	enc := toml.NewEncoder(&buf)
+	enc.SetDurationNanoseconds(true)
+	err = enc.Encode(v)
+	require.NoError(t, err)
+	require.Equal(t, "Timeout = 30000000000\nRetry = 5400000000000\nBackoffs = [1000000, 0]\n", buf.String())
+}
+
+func TestEncoderReset(t *testing.T) {
+	var first, second bytes.Buffer
+	enc := toml.NewEncoder(&first).SetIndentSymbol("\t").SetIndentTables(true)
+
+	err := enc.Encode(map[string]map[string]int{"a": {"b": 1}})
+	require.NoError(t, err)
+
+	enc.Reset(&second)
+	err = enc.Encode(map[string]map[string]int{"c": {"d": 2}})
+	require.NoError(t, err)
+
+	require.Equal(t, "[a]\n\tb = 1\n\n", first.String())
+	require.Equal(t, "[c]\n\td = 2\n\n", second.String())
+}
+
+func TestEncoderSetAlignEquals(t *testing.T) {
+	type server struct {
+		Name    string
+		Timeout int `comment:"In seconds."`
+		Port    int
+		Label   string               `toml:"the label"`
+		Limits  struct{ Max, N int } `toml:",inline"`
+	}
+	v := struct {
+		Title   string
+		Version int
+		Unused  string `toml:"unused,omitempty"`
+		Server  server
+	}{Title: "app", Version: 2, Server: server{Name: "a", Port: 80}}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetAlignEquals(true).SetIndentTables(true).Encode(v)
+	require.NoError(t, err)
+
+	expected := `Title   = 'app'
+Version = 2
+[Server]
+  Name = 'a'
+  # In seconds.
+  Timeout     = 0
+  Port        = 80
+  'the label' = ''
+  Limits      = {Max = 0, N = 0}
+
+`
+	require.Equal(t, expected, buf.String())
+
+	buf.Reset()
+	err = toml.NewEncoder(&buf).SetAlignEquals(true).SetFlatten(true).Encode(struct {
+		A   int
+		Sub struct{ B, Key int }
+	}{})
+	require.NoError(t, err)
+	require.Equal(t, "A = 0\nSub.B   = 0\nSub.Key = 0\n", buf.String())
+}
+
+func TestEncoderSetArraysOfTables(t *testing.T) {
+	type server struct {
+		Name string
+		Port int
+	}
+
+	examples := []struct {
+		desc     string
+		v        interface{}
+		expected string
+	}{
+		{
+			desc: "nil pointer element",
+			v: struct{ Servers []*server }{
+				Servers: []*server{{Name: "a", Port: 1}, nil},
+			},
+			expected: "[[Servers]]\nName = 'a'\nPort = 1\n[[Servers]]\nName = ''\nPort = 0\n\n",
+		},
+		{
+			desc: "empty slice",
+			v: struct {
+				Servers []server
+				Version int
+			}{Servers: []server{}, Version: 1},
+			expected: "Version = 1\n",
+		},
+		{
+			desc:     "empty slice in map",
+			v:        map[string]interface{}{"servers": []map[string]int{}, "version": 1},
+			expected: "version = 1\n",
+		},
+		{
+			desc: "inline option",
+			v: struct {
+				Servers []*server `toml:",inline"`
+			}{Servers: []*server{nil}},
+			expected: "Servers = [{Name = '', Port = 0}]\n",
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := toml.NewEncoder(&buf).SetArraysOfTables(true).Encode(e.v)
+			require.NoError(t, err)
+			require.Equal(t, e.expected, buf.String())
+		})
+	}
+
+	b, err := toml.Marshal(struct{ Servers []*server }{Servers: []*server{nil}})
+	require.NoError(t, err)
+	require.Equal(t, "Servers = [{Name = '', Port = 0}]\n", string(b))
+}
+
+func TestEncoderSetArraysMultilineThreshold(t *testing.T) {
+	type doc struct {
+		Short  []int
+		Long   []string
+		Nested [][]int
+		Tagged []int `toml:",multiline"`
+	}
+
+	v := doc{
+		Short:  []int{1, 2, 3},
+		Long:   []string{"alpha", "beta", "gamma", "delta"},
+		Nested: [][]int{{1, 2}, {3, 4, 5, 6, 7, 8, 9, 10, 11, 12}},
+		Tagged: []int{1},
+	}
+
+	var buf strings.Builder
+	enc := toml.NewEncoder(&buf)
+	enc.SetArraysMultiline(true).SetArraysMultilineThreshold(20)
+	err := enc.Encode(v)
+	require.NoError(t, err)
+
+	expected := `Short = [1, 2, 3]
+Long = [
+  'alpha',
+  'beta',
+  'gamma',
+  'delta'
+]
+Nested = [
+  [1, 2],
+  [
+    3,
+    4,
+    5,
+    6,
+    7,
+    8,
+    9,
+    10,
+    11,
+    12
+  ]
+]
+Tagged = [
+  1
+]
+`
+	require.Equal(t, expected, buf.String())
+
+	var decoded doc
+	err = toml.Unmarshal([]byte(buf.String()), &decoded)
+	require.NoError(t, err)
+	require.Equal(t, v, decoded)
+}
+
+func TestEncoderSetSortArraysByField(t *testing.T) {
+	type server struct {
+		Name string `toml:"name"`
+		Port int    `toml:"port"`
+	}
+	type config struct {
+		Servers []server                 `toml:"servers"`
+		Ports   []*server                `toml:"ports,inline"`
+		Maps    []map[string]interface{} `toml:"maps"`
+		Values  []int                    `toml:"values"`
+	}
+
+	c := config{
+		Servers: []server{{Name: "c"}, {Name: "a"}, {Name: "b"}},
+		Ports:   []*server{{Port: 10}, {Port: 2}},
+		Maps: []map[string]interface{}{
+			{"other": 1},
+			{"name": "y"},
+			{"name": 2},
+			{"name": "x"},
+		},
+		Values: []int{3, 1, 2},
+	}
+
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetSortArraysByField("name").Encode(c)
+	require.NoError(t, err)
+
+	expected := `ports = [{name = '', port = 10}, {name = '', port = 2}]
+values = [3, 1, 2]
+[[servers]]
+name = 'a'
+port = 0
+[[servers]]
+name = 'b'
+port = 0
+[[servers]]
+name = 'c'
+port = 0
+
+[[maps]]
+name = 2
+[[maps]]
+name = 'x'
+[[maps]]
+name = 'y'
+[[maps]]
+other = 1
+
+`
+	require.Equal(t, expected, buf.String())
+	assert.Equal(t, "c", c.Servers[0].Name, "the slice must not be modified")
+
+	buf.Reset()
+	err = toml.NewEncoder(&buf).SetSortArraysByField("port").Encode(map[string]interface{}{"ports": c.Ports})
+	require.NoError(t, err)
+	assert.Equal(t, "[[ports]]\nname = ''\nport = 2\n[[ports]]\nname = ''\nport = 10\n\n", buf.String())
+}
+
+func TestEncoderSetLineEnding(t *testing.T) {
+	type server struct {
+		Ports  []int
+		Script string `toml:",multiline"`
+	}
+	type doc struct {
+		Name   string `comment:"line 1\nline 2"`
+		Server server
+	}
+
+	v := doc{
+		Name:   "a",
+		Server: server{Ports: []int{1, 2}, Script: "echo 1\necho 2\r\n"},
+	}
+
+	var buf strings.Builder
+	enc := toml.NewEncoder(&buf)
+	enc.SetLineEnding("\r\n").SetArraysMultiline(true)
+	err := enc.Encode(v)
+	require.NoError(t, err)
+
+	expected := "# line 1\r\n# line 2\r\nName = 'a'\r\n[Server]\r\nPorts = [\r\n  1,\r\n  2\r\n]\r\n" +
+		"Script = \"\"\"\r\necho 1\necho 2\\r\n\"\"\"\r\n\r\n"
+	require.Equal(t, expected, buf.String())
+
+	var decoded doc
+	err = toml.Unmarshal([]byte(buf.String()), &decoded)
+	require.NoError(t, err)
+	require.Equal(t, v, decoded)
+
+	err = toml.NewEncoder(&buf).SetLineEnding("\r").Encode(v)
+	require.EqualError(t, err, `toml: invalid line ending "\r": must be "\n" or "\r\n"`)
+}
+
+func TestMarshalNilRoot(t *testing.T) {
+	var nilMap map[string]int
+	var nilMapPtr *map[string]int
+
+	for _, v := range []interface{}{nilMap, nilMapPtr, map[string]interface{}{}} {
+		b, err := toml.Marshal(v)
+		require.NoError(t, err)
+		require.Empty(t, b)
+	}
+
+	var nilSlice []int
+	var nilSlicePtr *[]map[string]int
+
+	examples := []struct {
+		v   interface{}
+		err string
+	}{
+		{v: nilSlice, err: "toml: cannot encode a []int as a document: the root of a TOML document must be a table, use Encoder.SetRootKey to emit it as the value of a key"},
+		{v: nilSlicePtr, err: "toml: cannot encode a *[]map[string]int as a document: the root of a TOML document must be a table, use Encoder.SetRootKey to emit it as the value of a key"},
+		{v: []map[string]int{{"a": 1}}, err: "toml: cannot encode a []map[string]int as a document: the root of a TOML document must be a table, use Encoder.SetRootKey to emit it as the value of a key"},
+		{v: [1]int{1}, err: "toml: cannot encode a [1]int as a document: the root of a TOML document must be a table, use Encoder.SetRootKey to emit it as the value of a key"},
+	}
+
+	for _, e := range examples {
+		_, err := toml.Marshal(e.v)
+		require.EqualError(t, err, e.err)
+		require.ErrorIs(t, err, toml.ErrTopLevelNotTable)
+	}
+}
+
+func TestMarshalSquash(t *testing.T) {
+	type netConfig struct {
+		Host string
+		Port int
+	}
+	type config struct {
+		Name string
+		Net  netConfig `toml:",squash"`
+		Log  struct {
+			Level string
+		}
+	}
+
+	b, err := toml.Marshal(config{Name: "a", Net: netConfig{Host: "localhost", Port: 80}})
+	require.NoError(t, err)
+
+	expected := `Name = 'a'
+Host = 'localhost'
+Port = 80
+[Log]
+Level = ''
+
+`
+	require.Equal(t, expected, string(b))
+
+	type conflict struct {
+		Port int
+		Net  netConfig `toml:",squash"`
+	}
+	_, err = toml.Marshal(conflict{})
+	require.EqualError(t, err, "toml: fields Port and Net.Port of toml_test.conflict both use the key Port")
+}
+
+func TestLocalTime(t *testing.T) {
+	v := map[string]toml.LocalTime{
+		"a": toml.LocalTime{
+			Hour:       1,
+			Minute:     2,
+			Second:     3,
+			Nanosecond: 4,
+		},
+	}
+
+	expected := `a = 01:02:03.000000004
+`
+
+	out, err := toml.Marshal(v)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(out))
+}
+
+func ExampleMarshal() {
+	type MyConfig struct {
+		Version int
+		Name    string
+		Tags    []string
+	}
+
+	cfg := MyConfig{
+		Version: 2,
+		Name:    "go-toml",
+		Tags:    []string{"go", "toml"},
+	}
+
+	b, err := toml.Marshal(cfg)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(b))
+
+	// Output:
+	// Version = 2
+	// Name = 'go-toml'
+	// Tags = ['go', 'toml']
+}
+
+func ExampleEncoder_SetRootKey() {
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).SetRootKey("items").Encode([]string{"a", "b"})
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(buf.String())
+
+	var items []string
+	err = toml.NewDecoder(&buf).SetRootKey("items").Decode(&items)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(items)
+
+	// Output:
+	// items = ['a', 'b']
+	// [a b]
+}
+
+func TestEncoderOmitemptyInline(t *testing.T) {
+	type point struct {
+		X int `toml:"x,omitempty"`
+		Y int `toml:"y,omitempty"`
+		Z int `toml:"z,omitempty"`
+	}
+	v := struct {
+		P point `toml:"p,inline"`
+	}{P: point{Y: 1}}
+
+	b, err := toml.Marshal(v)
+	require.NoError(t, err)
+	require.Equal(t, "p = {y = 1}\n", string(b))
+}
+
+func TestMarshalNilValues(t *testing.T) {
+	type sub struct {
+		A int
+	}
+
+	examples := []struct {
+		desc     string
+		v        interface{}
+		expected string
+	}{
+		{
+			desc: "nil pointer field",
+			v: struct {
+				S *sub
+				B int
+			}{B: 1},
+			expected: "B = 1\n",
+		},
+		{
+			desc: "nil interface field",
+			v: struct {
+				S interface{}
+				B int
+			}{B: 1},
+			expected: "B = 1\n",
+		},
+		{
+			desc:     "nil interface map value",
+			v:        map[string]interface{}{"a": nil, "b": 1},
+			expected: "b = 1\n",
+		},
+		{
+			desc:     "nil pointer element",
+			v:        map[string]interface{}{"a": []*sub{{A: 1}, nil}},
+			expected: "a = [{A = 1}, {A = 0}]\n",
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			b, err := toml.Marshal(e.v)
+			require.NoError(t, err)
+			require.Equal(t, e.expected, string(b))
+		})
+	}
+
+	t.Run("nil interface element", func(t *testing.T) {
+		values := []interface{}{
+			map[string]interface{}{"a": []interface{}{1, nil, "x"}},
+			map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": 1}, nil}},
+		}
+		for _, v := range values {
+			_, err := toml.Marshal(v)
+			var ee *toml.EncodeError
+			require.ErrorAs(t, err, &ee)
+			require.Equal(t, []string{"a", "1"}, ee.Path)
+		}
+	})
+}
+
+func TestEncoderSetStringEscapeMode(t *testing.T) {
+	v := map[string]interface{}{
+		"clé":   "Zoë 😀 <b>&</b>\x7f",
+		"plain": "a",
+	}
+
+	examples := []struct {
+		desc     string
+		mode     toml.StringEscapeMode
+		expected string
+	}{
+		{
+			desc:     "minimal",
+			mode:     toml.EscapeMinimal,
+			expected: "'clé' = \"Zoë 😀 <b>&</b>\\u007F\"\nplain = 'a'\n",
+		},
+		{
+			desc:     "non-ASCII",
+			mode:     toml.EscapeNonASCII,
+			expected: "\"cl\\u00E9\" = \"Zo\\u00EB \\U0001F600 <b>&</b>\\u007F\"\nplain = 'a'\n",
+		},
+		{
+			desc:     "HTML",
+			mode:     toml.EscapeHTML,
+			expected: "'clé' = \"Zoë 😀 \\u003Cb\\u003E\\u0026\\u003C/b\\u003E\\u007F\"\nplain = 'a'\n",
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := toml.NewEncoder(&buf).SetStringEscapeMode(e.mode).Encode(v)
+			require.NoError(t, err)
+			require.Equal(t, e.expected, buf.String())
+
+			var back map[string]interface{}
+			err = toml.Unmarshal(buf.Bytes(), &back)
+			require.NoError(t, err)
+			require.Equal(t, v, back)
+		})
+	}
+}
+
+func TestEncoderSetRootKey(t *testing.T) {
+	type server struct {
+		Name string
+		Port int
+	}
+
+	examples := []struct {
+		desc     string
+		v        interface{}
+		table    bool
+		expected string
+	}{
+		{
+			desc:     "slice",
+			v:        []string{"a", "b"},
+			expected: "items = ['a', 'b']\n",
+		},
+		{
+			desc:     "integer",
+			v:        42,
+			expected: "items = 42\n",
+		},
+		{
+			desc:     "struct",
+			v:        server{Name: "a", Port: 80},
+			table:    true,
+			expected: "[items]\nName = 'a'\nPort = 80\n\n",
+		},
+		{
+			desc:     "slice of structs",
+			v:        []server{{Name: "a", Port: 80}, {Name: "b", Port: 81}},
+			expected: "[[items]]\nName = 'a'\nPort = 80\n[[items]]\nName = 'b'\nPort = 81\n\n",
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := toml.NewEncoder(&buf).SetRootKey("items").Encode(e.v)
+			require.NoError(t, err)
+			require.Equal(t, e.expected, buf.String())
+
+			_, err = toml.Marshal(e.v)
+			if e.table {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, toml.ErrTopLevelNotTable)
+			}
+		})
+	}
 }
@@ -0,0 +1,117 @@
+package toml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// MergeOptions controls how MergeWithOptions combines two documents.
+type MergeOptions struct {
+	// AppendArrays appends the elements of an array of the override document,
+	// including an array of tables, to the array of the base document at the
+	// same key, instead of replacing it.
+	AppendArrays bool
+}
+
+// Merge returns the TOML document made of the TOML document base with the
+// TOML document override applied on top of it, like a configuration file and
+// its overrides for one environment. It is MergeWithOptions with the zero
+// MergeOptions.
+func Merge(base, override []byte) ([]byte, error) {
+	return MergeWithOptions(base, override, MergeOptions{})
+}
+
+// MergeWithOptions returns the TOML document made of the TOML document base
+// with the TOML document override applied on top of it.
+//
+// Tables are merged key by key, recursively, whether they are defined by
+// headers, dotted keys or inline tables. Keys only present in override are
+// added after the keys of base. When a key holds a value other than a table on
+// both sides, the value of override replaces the one of base, including for
+// arrays unless opts.AppendArrays is set. A key holding a table on one side
+// and another value on the other side is an error, which reports the path of
+// the key.
+//
+// The result is encoded with the default Encoder options, keeping the order
+// of the keys and the kind of each table. Comments are not preserved.
+func MergeWithOptions(base, override []byte, opts MergeOptions) ([]byte, error) {
+	var a, b OrderedMap
+
+	err := Unmarshal(base, &a)
+	if err != nil {
+		return nil, err
+	}
+
+	err = Unmarshal(override, &b)
+	if err != nil {
+		return nil, err
+	}
+
+	err = mergeTables(nil, &a, &b, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return Marshal(&a)
+}
+
+// mergeTables applies the table b on top of the table a, in place. path holds
+// the keys leading to the tables.
+func mergeTables(path []string, a, b *OrderedMap, opts MergeOptions) error {
+	var err error
+
+	b.Range(func(k string, y interface{}) bool {
+		x, ok := a.Get(k)
+		if !ok {
+			a.Set(k, y)
+			return true
+		}
+
+		p := append(path[:len(path):len(path)], k)
+
+		tx, xTable := x.(*OrderedMap)
+		ty, yTable := y.(*OrderedMap)
+
+		switch {
+		case xTable && yTable:
+			err = mergeTables(p, tx, ty, opts)
+			return err == nil
+		case xTable || yTable:
+			err = fmt.Errorf("toml: cannot merge %s: %s in base, %s in override", mergePath(p), mergeTypeName(x), mergeTypeName(y))
+			return false
+		}
+
+		if opts.AppendArrays {
+			ax, xArray := x.([]interface{})
+			ay, yArray := y.([]interface{})
+			if xArray && yArray {
+				a.Set(k, append(ax[:len(ax):len(ax)], ay...))
+				return true
+			}
+		}
+
+		a.Set(k, y)
+		return true
+	})
+
+	return err
+}
+
+// mergePath returns the dotted key made of path, as written in a document.
+func mergePath(path []string) string {
+	var enc Encoder
+	parts := make([]string, len(path))
+	for i, k := range path {
+		parts[i] = string(enc.encodeKey(nil, k))
+	}
+	return strings.Join(parts, ".")
+}
+
+// mergeTypeName returns the name of the TOML type of the decoded value v.
+func mergeTypeName(v interface{}) string {
+	if _, ok := v.([]interface{}); ok {
+		return "array"
+	}
+	return tomlTypeName(reflect.TypeOf(v))
+}
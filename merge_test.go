@@ -0,0 +1,94 @@
+package toml_test
+
+import (
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMerge(t *testing.T) {
+	base := `
+name = "app"
+ports = [80, 443]
+log.level = "info"
+
+[db]
+host = "localhost"
+port = 5432
+options = { timeout = 5, retries = 3 }
+`
+	override := `
+ports = [8080]
+log.format = "json"
+
+[db]
+host = "db.internal"
+options = { timeout = 10 }
+
+[cache]
+size = 100
+`
+
+	b, err := toml.Merge([]byte(base), []byte(override))
+	require.NoError(t, err)
+
+	expected := `name = 'app'
+ports = [8080]
+log.level = 'info'
+log.format = 'json'
+[db]
+host = 'db.internal'
+port = 5432
+options = {timeout = 10, retries = 3}
+
+[cache]
+size = 100
+
+`
+	require.Equal(t, expected, string(b))
+}
+
+func TestMergeAppendArrays(t *testing.T) {
+	base := `
+ports = [80]
+[[servers]]
+name = "a"
+`
+	override := `
+ports = [443]
+[[servers]]
+name = "b"
+`
+
+	b, err := toml.MergeWithOptions([]byte(base), []byte(override), toml.MergeOptions{AppendArrays: true})
+	require.NoError(t, err)
+
+	var c struct {
+		Ports   []int
+		Servers []struct {
+			Name string
+		}
+	}
+	err = toml.Unmarshal(b, &c)
+	require.NoError(t, err)
+	require.Equal(t, []int{80, 443}, c.Ports)
+	require.Len(t, c.Servers, 2)
+	require.Equal(t, "a", c.Servers[0].Name)
+	require.Equal(t, "b", c.Servers[1].Name)
+
+	b, err = toml.Merge([]byte(base), []byte(override))
+	require.NoError(t, err)
+	require.Equal(t, "ports = [443]\n[[servers]]\nname = 'b'\n\n", string(b))
+}
+
+func TestMergeConflict(t *testing.T) {
+	_, err := toml.Merge([]byte("[a.'b c']\nd = 1"), []byte("a.'b c' = 2"))
+	require.EqualError(t, err, `toml: cannot merge a.'b c': table in base, integer in override`)
+
+	_, err = toml.Merge([]byte("a = [1]"), []byte("[a]"))
+	require.EqualError(t, err, "toml: cannot merge a: array in base, table in override")
+
+	_, err = toml.Merge([]byte("a = "), []byte(""))
+	require.Error(t, err)
+}
@@ -0,0 +1,324 @@
+package toml
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+)
+
+// MetaData describes the keys present in a document decoded by
+// Decoder.DecodeWithMeta, regardless of the target they were decoded into. It
+// tells apart a key explicitly set to its zero value, like timeout = 0, from an
+// absent key.
+//
+// Keys are the parts of dotted keys and table headers. The elements of arrays
+// of tables, and the tables and arrays nested in arrays, are designated by
+// their index in decimal: the name key of the second [[servers]] table is
+// servers.1.name.
+type MetaData struct {
+	keys  []Key
+	types map[string]string
+
+	// Number of elements of each array of tables, by joined key.
+	arrayTables map[string]int
+
+	// Key of the table containing the key-values being decoded.
+	current Key
+
+	// Settings of the decoder, to match the keys with struct fields.
+	keyMapper KeyMapper
+	root      Key
+}
+
+// IsDefined returns true if the key is present in the document, either
+// explicitly or as a table implied by a dotted key or a table header.
+func (m MetaData) IsDefined(key ...string) bool {
+	_, ok := m.types[joinKey(key)]
+	return ok
+}
+
+// Type returns the TOML type of the value of the key: "string", "integer",
+// "float", "boolean", "offset date-time", "local date-time", "local date",
+// "local time", "array", "table" or "array of tables". It returns an empty
+// string if the key is not present in the document.
+func (m MetaData) Type(key ...string) string {
+	return m.types[joinKey(key)]
+}
+
+// Keys returns the keys present in the document, in the order they first
+// appear.
+func (m MetaData) Keys() []Key {
+	keys := make([]Key, len(m.keys))
+	copy(keys, m.keys)
+	return keys
+}
+
+// UnsetFields returns the fields of the struct v, or pointed to by v, that did
+// not receive a value from the document, like the fields whose key is absent.
+// A field explicitly set to its zero value in the document is not part of
+// them, which makes it possible to apply defaults only over the keys that are
+// truly absent:
+//
+//   meta, err := dec.DecodeWithMeta(&cfg)
+//   ...
+//   for _, f := range meta.UnsetFields(&cfg) {
+//     ...
+//   }
+//
+// Fields are designated by their path of Go field names, like Server.Port, and
+// the elements of slices and arrays by their index, like Servers[1].Port.
+// Fields of a struct field absent from the document are not listed, only the
+// struct field itself. Maps are not inspected, and neither are the fields
+// tagged with the "remaining", "source", "headercomment" or "key" options.
+// Keys are matched with fields like Decode does, case-insensitively, with the
+// KeyMapper of the Decoder and the aliases of the fields.
+func (m MetaData) UnsetFields(v interface{}) []string {
+	u := unsetFields{
+		present:   make(map[string]struct{}, len(m.keys)),
+		keyMapper: m.keyMapper,
+	}
+	for _, k := range m.keys {
+		u.present[strings.ToLower(joinKey(k))] = struct{}{}
+	}
+
+	var path []string
+	for _, k := range m.root {
+		path = append(path, strings.ToLower(k))
+	}
+	u.walk(path, "", reflect.ValueOf(v))
+
+	return u.fields
+}
+
+// unsetFields collects the fields of a value that are absent from a document.
+type unsetFields struct {
+	// Lowercased keys of the document.
+	present   map[string]struct{}
+	keyMapper KeyMapper
+
+	fields []string
+}
+
+func (u *unsetFields) has(path []string) bool {
+	_, ok := u.present[joinKey(path)]
+	return ok
+}
+
+// walk collects the unset fields of v, found at the key path of the document
+// and named name in Go.
+func (u *unsetFields) walk(path []string, name string, v reflect.Value) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		if !isTableStruct(v.Type()) {
+			return
+		}
+
+		forEachField(v.Type(), func(fieldName string, idx []int, opts tagOptions) {
+			if opts.remaining || opts.source || opts.headerComment || opts.key {
+				return
+			}
+
+			n := goFieldPath(v.Type(), idx)
+			if name != "" {
+				n = name + "." + n
+			}
+
+			p, ok := u.fieldKey(path, fieldName, v.Type().FieldByIndex(idx).Name, opts)
+			if !ok {
+				u.fields = append(u.fields, n)
+				return
+			}
+
+			f, ok := fieldByIndex(v, idx)
+			if ok {
+				u.walk(p, n, f)
+			}
+		})
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			p := append(path[:len(path):len(path)], strconv.Itoa(i))
+			u.walk(p, name+"["+strconv.Itoa(i)+"]", v.Index(i))
+		}
+	}
+}
+
+// fieldKey returns the key of the document, under the key path, of the field
+// named fieldName in its tag and goName in Go, and whether it is present.
+func (u *unsetFields) fieldKey(path []string, fieldName, goName string, opts tagOptions) ([]string, bool) {
+	names := []string{fieldName}
+	if u.keyMapper != nil {
+		names = append(names, u.keyMapper.Key(goName))
+	}
+	names = append(names, opts.aliases...)
+
+	for _, n := range names {
+		p := path[:len(path):len(path)]
+		if opts.dotted {
+			for _, part := range strings.Split(n, ".") {
+				p = append(p, strings.ToLower(part))
+			}
+		} else {
+			p = append(p, strings.ToLower(n))
+		}
+		if u.has(p) {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+// isTableStruct reports whether the struct type t is decoded from a table, as
+// opposed to types like time.Time and the ones decoding themselves.
+func isTableStruct(t reflect.Type) bool {
+	switch t {
+	case urlType, ipNetType, rawValueType:
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	return !isNullType(t) && !isAtomicType(t) && !implementsUnmarshaler(pt) && !pt.Implements(textUnmarshalerType) && !pt.Implements(tableUnmarshalerType)
+}
+
+// goFieldPath returns the Go selector of the field at idx of the struct type
+// t, skipping the embedded structs.
+func goFieldPath(t reflect.Type, idx []int) string {
+	var parts []string
+	for i, x := range idx {
+		f := t.Field(x)
+		if !f.Anonymous || i == len(idx)-1 {
+			parts = append(parts, f.Name)
+		}
+		t = indirectType(f.Type)
+	}
+	return strings.Join(parts, ".")
+}
+
+func joinKey(key []string) string {
+	return strings.Join(key, "\x00")
+}
+
+func (m *MetaData) add(key Key, typ string) {
+	j := joinKey(key)
+	if _, ok := m.types[j]; ok {
+		return
+	}
+	if m.types == nil {
+		m.types = map[string]string{}
+	}
+	m.types[j] = typ
+	m.keys = append(m.keys, append(Key(nil), key...))
+}
+
+// collectMeta records the keys of the expression node in the metadata, if
+// requested.
+func (d *decoder) collectMeta(expr *ast.Node) {
+	m := d.meta
+	if m == nil {
+		return
+	}
+
+	switch expr.Kind {
+	case ast.Table, ast.ArrayTable:
+		m.current = m.addTable(expr)
+	case ast.KeyValue:
+		m.addKeyValue(m.current, expr)
+	}
+}
+
+// addTable records the keys of the table header node, and returns the key of
+// the table it designates.
+func (m *MetaData) addTable(node *ast.Node) Key {
+	var key Key
+
+	it := node.Key()
+	for it.Next() {
+		key = append(key, string(it.Node().Data))
+
+		if it.IsLast() && node.Kind == ast.ArrayTable {
+			m.add(key, "array of tables")
+			if m.arrayTables == nil {
+				m.arrayTables = map[string]int{}
+			}
+			j := joinKey(key)
+			idx := m.arrayTables[j]
+			m.arrayTables[j] = idx + 1
+			key = append(key, strconv.Itoa(idx))
+		}
+
+		m.add(key, "table")
+
+		// Keys under an array of tables are in its last element.
+		if n, ok := m.arrayTables[joinKey(key)]; ok && !it.IsLast() {
+			key = append(key, strconv.Itoa(n-1))
+		}
+	}
+
+	return key
+}
+
+func (m *MetaData) addKeyValue(parent Key, node *ast.Node) {
+	key := append(Key(nil), parent...)
+
+	it := node.Key()
+	for it.Next() {
+		key = append(key, string(it.Node().Data))
+		if !it.IsLast() {
+			m.add(key, "table")
+		}
+	}
+
+	m.addValue(key, node.Value())
+}
+
+func (m *MetaData) addValue(key Key, value *ast.Node) {
+	m.add(key, metaType(value.Kind))
+
+	it := value.Children()
+	switch value.Kind {
+	case ast.InlineTable:
+		for it.Next() {
+			m.addKeyValue(key, it.Node())
+		}
+	case ast.Array:
+		for i := 0; it.Next(); i++ {
+			n := it.Node()
+			if n.Kind == ast.InlineTable || n.Kind == ast.Array {
+				m.addValue(append(key[:len(key):len(key)], strconv.Itoa(i)), n)
+			}
+		}
+	}
+}
+
+func metaType(k ast.Kind) string {
+	switch k {
+	case ast.String:
+		return "string"
+	case ast.Integer:
+		return "integer"
+	case ast.Float:
+		return "float"
+	case ast.Bool:
+		return "boolean"
+	case ast.DateTime:
+		return "offset date-time"
+	case ast.LocalDateTime:
+		return "local date-time"
+	case ast.LocalDate:
+		return "local date"
+	case ast.LocalTime:
+		return "local time"
+	case ast.Array:
+		return "array"
+	default:
+		return "table"
+	}
+}
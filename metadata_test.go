@@ -0,0 +1,139 @@
+package toml_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoderDecodeWithMeta(t *testing.T) {
+	doc := `
+timeout = 0
+db.host = "h"
+point = { x = 1, y = 2020-01-01 }
+
+[[servers]]
+name = "a"
+[servers.tls]
+enabled = true
+
+[[servers]]
+name = "b"
+ports = [80, 443]
+routes = [{ path = "/" }]
+`
+
+	var c struct {
+		Timeout int
+		Retries int
+	}
+	meta, err := toml.NewDecoder(strings.NewReader(doc)).DecodeWithMeta(&c)
+	require.NoError(t, err)
+
+	require.True(t, meta.IsDefined("timeout"))
+	require.False(t, meta.IsDefined("retries"))
+	require.Equal(t, "integer", meta.Type("timeout"))
+	require.Equal(t, "", meta.Type("retries"))
+
+	require.Equal(t, "table", meta.Type("db"))
+	require.Equal(t, "string", meta.Type("db", "host"))
+	require.Equal(t, "local date", meta.Type("point", "y"))
+
+	require.Equal(t, "array of tables", meta.Type("servers"))
+	require.Equal(t, "boolean", meta.Type("servers", "0", "tls", "enabled"))
+	require.False(t, meta.IsDefined("servers", "1", "tls"))
+	require.Equal(t, "array", meta.Type("servers", "1", "ports"))
+	require.Equal(t, "string", meta.Type("servers", "1", "routes", "0", "path"))
+
+	require.Equal(t, []toml.Key{
+		{"timeout"},
+		{"db"},
+		{"db", "host"},
+		{"point"},
+		{"point", "x"},
+		{"point", "y"},
+		{"servers"},
+		{"servers", "0"},
+		{"servers", "0", "name"},
+		{"servers", "0", "tls"},
+		{"servers", "0", "tls", "enabled"},
+		{"servers", "1"},
+		{"servers", "1", "name"},
+		{"servers", "1", "ports"},
+		{"servers", "1", "routes"},
+		{"servers", "1", "routes", "0"},
+		{"servers", "1", "routes", "0", "path"},
+	}, meta.Keys())
+}
+
+func TestDecoderDecodeWithMetaNestedArrayTables(t *testing.T) {
+	doc := "[[a]]\n[[a.b]]\nx = 1\n[[a]]\n[[a.b]]\n[[a.b]]\nx = 2"
+
+	var v map[string]interface{}
+	meta, err := toml.NewDecoder(strings.NewReader(doc)).DecodeWithMeta(&v)
+	require.NoError(t, err)
+
+	require.True(t, meta.IsDefined("a", "0", "b", "0", "x"))
+	require.False(t, meta.IsDefined("a", "0", "b", "1"))
+	require.True(t, meta.IsDefined("a", "1", "b", "0"))
+	require.False(t, meta.IsDefined("a", "1", "b", "0", "x"))
+	require.Equal(t, "integer", meta.Type("a", "1", "b", "1", "x"))
+}
+
+func TestMetaDataUnsetFields(t *testing.T) {
+	doc := `
+timeout = 0
+log-level = "debug"
+db.host = "h"
+
+[[servers]]
+name = "a"
+
+[[servers]]
+name = "b"
+port = 0
+`
+
+	type server struct {
+		Name string
+		Port int
+	}
+	type net struct {
+		Proxy string
+	}
+	var c struct {
+		Timeout  int
+		Retries  int
+		LogLevel string `toml:"level,alias=log-level"`
+		Started  toml.LocalDate
+		DB       struct {
+			Host string
+			User string
+		}
+		Cache struct {
+			Size int
+		}
+		Net     net `toml:",squash"`
+		Servers []server
+		Extra   map[string]interface{} `toml:",remaining"`
+	}
+	meta, err := toml.NewDecoder(strings.NewReader(doc)).DecodeWithMeta(&c)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"Retries",
+		"Started",
+		"DB.User",
+		"Cache",
+		"Net.Proxy",
+		"Servers[0].Port",
+	}, meta.UnsetFields(&c))
+
+	var items []server
+	dec := toml.NewDecoder(strings.NewReader("[[items]]\nname = 'a'\nport = 80\n[[items]]\nport = 81"))
+	meta, err = dec.SetRootKey("items").DecodeWithMeta(&items)
+	require.NoError(t, err)
+	require.Equal(t, []string{"[1].Name"}, meta.UnsetFields(items))
+}
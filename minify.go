@@ -0,0 +1,42 @@
+package toml
+
+import (
+	"bytes"
+)
+
+// Minify returns the shortest form of the TOML document data that the encoder
+// can produce with the same content. Comments and blank lines are removed, all
+// the tables are emitted as inline tables of the root table, arrays fit on a
+// single line, and separators are not surrounded by spaces:
+//
+//   [server]
+//   host = "localhost"   # comment
+//   ports = [ 80, 443 ]
+//
+// becomes:
+//
+//   server={host='localhost',ports=[80,443]}
+//
+// Keys keep the order in which they appear in the document. Values are decoded
+// and encoded again, so their representation may change: for example integers
+// are always written in decimal.
+func Minify(data []byte) ([]byte, error) {
+	var doc OrderedMap
+
+	err := Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetTablesInline(true)
+	enc.compact = true
+
+	err = enc.Encode(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
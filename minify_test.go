@@ -0,0 +1,83 @@
+package toml_test
+
+import (
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinify(t *testing.T) {
+	examples := []struct {
+		desc     string
+		input    string
+		expected string
+	}{
+		{
+			desc:     "empty",
+			input:    "# only a comment\n\n",
+			expected: "",
+		},
+		{
+			desc: "tables",
+			input: `
+# comment
+title = "TOML"   # trailing comment
+
+[server]
+host = "localhost"
+ports = [
+  80,
+  443, # https
+]
+
+[server.tls]
+enabled = true
+`,
+			expected: "title='TOML'\nserver={host='localhost',ports=[80,443],tls={enabled=true}}\n",
+		},
+		{
+			desc: "array tables",
+			input: `
+[[peers]]
+name = "a"
+
+[[peers]]
+name = "b"
+tags = { env = "prod" }
+`,
+			expected: "peers=[{name='a'},{name='b',tags={env='prod'}}]\n",
+		},
+		{
+			desc: "values",
+			input: `
+hex = 0xff
+dotted.key = 1_000
+date = 2021-04-08
+when = 1979-05-27T07:32:00-08:00
+empty = {}
+quote = "it's"
+`,
+			expected: "hex=255\ndotted={key=1000}\ndate=2021-04-08\nwhen=1979-05-27T07:32:00-08:00\nempty={}\nquote=\"it's\"\n",
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			b, err := toml.Minify([]byte(e.input))
+			require.NoError(t, err)
+			require.Equal(t, e.expected, string(b))
+
+			var expected, actual interface{}
+			require.NoError(t, toml.Unmarshal([]byte(e.input), &expected))
+			require.NoError(t, toml.Unmarshal(b, &actual))
+			require.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestMinifyInvalid(t *testing.T) {
+	_, err := toml.Minify([]byte("a = "))
+	require.Error(t, err)
+}
@@ -0,0 +1,24 @@
+//go:build go1.18
+// +build go1.18
+
+package toml
+
+// Nullable holds an optional value of type T. It distinguishes a key that is
+// absent from the document from a key set to the zero value of T, without
+// using a pointer.
+//
+// When encoding, a Nullable whose Set field is false is omitted, and a set
+// Nullable is emitted as its Value, even if it is the zero value. When
+// decoding, a key present in the document is decoded in Value, and Set is
+// changed to true.
+type Nullable[T any] struct {
+	Value T
+	Set   bool
+}
+
+// NewNullable returns a Nullable holding v.
+func NewNullable[T any](v T) Nullable[T] {
+	return Nullable[T]{Value: v, Set: true}
+}
+
+func (Nullable[T]) tomlNullable() {}
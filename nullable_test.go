@@ -0,0 +1,71 @@
+//go:build go1.18
+// +build go1.18
+
+package toml_test
+
+import (
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type nullableServer struct {
+	Host string
+}
+
+type nullableConfig struct {
+	Port    toml.Nullable[int]
+	Name    toml.Nullable[string]
+	Debug   toml.Nullable[bool]
+	Tags    toml.Nullable[[]string]
+	Server  toml.Nullable[nullableServer]
+	Pointer *toml.Nullable[float64]
+}
+
+func TestMarshalNullable(t *testing.T) {
+	v := nullableConfig{
+		Port:   toml.NewNullable(0),
+		Debug:  toml.NewNullable(false),
+		Tags:   toml.NewNullable([]string{"a"}),
+		Server: toml.NewNullable(nullableServer{Host: "h"}),
+	}
+
+	b, err := toml.Marshal(v)
+	require.NoError(t, err)
+	require.Equal(t, "Port = 0\nDebug = false\nTags = ['a']\n[Server]\nHost = 'h'\n\n", string(b))
+
+	_, err = toml.Marshal(map[string]interface{}{
+		"a": []toml.Nullable[int]{{}},
+	})
+	require.Error(t, err)
+}
+
+func TestUnmarshalNullable(t *testing.T) {
+	doc := `
+Port = 0
+Tags = ["a", "b"]
+Pointer = 1.5
+Server = { Host = "h" }
+`
+
+	var c nullableConfig
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+
+	pointer := toml.NewNullable(1.5)
+	require.Equal(t, nullableConfig{
+		Port:    toml.NewNullable(0),
+		Tags:    toml.NewNullable([]string{"a", "b"}),
+		Server:  toml.NewNullable(nullableServer{Host: "h"}),
+		Pointer: &pointer,
+	}, c)
+
+	err = toml.Unmarshal([]byte(`Port = "a"`), &c)
+	require.Error(t, err)
+
+	var out nullableConfig
+	err = toml.Unmarshal([]byte("[Server]\nHost = 'h'"), &out)
+	require.NoError(t, err)
+	require.Equal(t, nullableConfig{Server: toml.NewNullable(nullableServer{Host: "h"})}, out)
+}
@@ -0,0 +1,66 @@
+package toml
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Number is a TOML integer or float that keeps the text it was written with in
+// the document, like 1_000, 0xff or 1e3.
+//
+// Decoders with EnableNumberPreservation produce a Number instead of an int64
+// or a float64 when decoding into an interface{}. Struct fields of type Number
+// receive TOML integers and floats regardless of that option. A Number is
+// encoded as its original text.
+type Number struct {
+	raw   string
+	value interface{} // int64 or float64
+}
+
+var numberType = reflect.TypeOf(Number{})
+
+// Raw returns the number as it was written in the document.
+func (n Number) Raw() string {
+	if n.raw == "" {
+		return "0"
+	}
+	return n.raw
+}
+
+// String returns the number as it was written in the document.
+func (n Number) String() string {
+	return n.Raw()
+}
+
+// IsFloat returns true if the number is a TOML float.
+func (n Number) IsFloat() bool {
+	_, ok := n.value.(float64)
+	return ok
+}
+
+// Int64 returns the value of the number as an int64. It returns an error if the
+// number is a float that cannot be represented exactly as an int64.
+func (n Number) Int64() (int64, error) {
+	switch x := n.value.(type) {
+	case int64:
+		return x, nil
+	case float64:
+		if x != math.Trunc(x) || x < math.MinInt64 || x >= math.MaxInt64 {
+			return 0, fmt.Errorf("toml: number %s is not an int64", n.Raw())
+		}
+		return int64(x), nil
+	}
+	return 0, nil
+}
+
+// Float64 returns the value of the number as a float64.
+func (n Number) Float64() (float64, error) {
+	switch x := n.value.(type) {
+	case int64:
+		return float64(x), nil
+	case float64:
+		return x, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,74 @@
+package toml_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoderEnableNumberPreservation(t *testing.T) {
+	doc := `
+a = 1_000
+b = 0xff
+c = 1e3
+d = 3.0
+e = [1, 2.5]
+`
+
+	var m map[string]interface{}
+	err := toml.NewDecoder(strings.NewReader(doc)).EnableNumberPreservation().Decode(&m)
+	require.NoError(t, err)
+
+	a := m["a"].(toml.Number)
+	require.Equal(t, "1_000", a.Raw())
+	require.False(t, a.IsFloat())
+	i, err := a.Int64()
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), i)
+
+	b := m["b"].(toml.Number)
+	i, err = b.Int64()
+	require.NoError(t, err)
+	require.Equal(t, int64(255), i)
+
+	c := m["c"].(toml.Number)
+	require.Equal(t, "1e3", c.String())
+	require.True(t, c.IsFloat())
+	f, err := c.Float64()
+	require.NoError(t, err)
+	require.Equal(t, 1000.0, f)
+	i, err = c.Int64()
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), i)
+
+	e := m["e"].([]interface{})
+	_, err = e[1].(toml.Number).Int64()
+	require.EqualError(t, err, "toml: number 2.5 is not an int64")
+
+	out, err := toml.Marshal(m)
+	require.NoError(t, err)
+	require.Equal(t, "a = 1_000\nb = 0xff\nc = 1e3\nd = 3.0\ne = [1, 2.5]\n", string(out))
+
+	// Without the option, numbers are decoded as int64 and float64.
+	m = nil
+	err = toml.Unmarshal([]byte(doc), &m)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), m["a"])
+	require.Equal(t, 1000.0, m["c"])
+}
+
+func TestUnmarshalNumberField(t *testing.T) {
+	var s struct {
+		Port  toml.Number
+		Ratio toml.Number
+	}
+	err := toml.Unmarshal([]byte("port = 8_080\nratio = 0.5"), &s)
+	require.NoError(t, err)
+	require.Equal(t, "8_080", s.Port.Raw())
+	require.Equal(t, "0.5", s.Ratio.Raw())
+
+	err = toml.Unmarshal([]byte("port = 'x'"), &s)
+	require.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package toml
+
+import "reflect"
+
+// OrderedMap is a map of string keys to values that remembers the order in
+// which keys have been inserted.
+//
+// When a table is decoded into an OrderedMap, keys are stored in the order they
+// appear in the document, and nested tables are stored as *OrderedMap. When
+// encoding an OrderedMap, keys are emitted in the same order.
+//
+// Nested tables also remember how they were defined in the document, which is
+// reported by Kind, and are encoded the same way. Decoding a document into an
+// OrderedMap and encoding it back keeps the keys of each table in their
+// original order, as long as the key-values of each table come before its
+// sub-tables in the document. It also tells apart empty tables defined by a
+// header, like [x], from empty inline tables, like x = {}, which are both empty
+// maps when decoding into a map.
+//
+// The zero value is an empty map ready to use.
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+	kind   TableKind
+}
+
+// TableKind describes how a table is defined in a document.
+type TableKind int
+
+const (
+	// StandardTable is a table defined by a [table] header, or implicitly by
+	// the header of one of its sub-tables. This is the kind of the root table.
+	StandardTable TableKind = iota
+
+	// InlineTable is a table defined by an inline table: x = {}.
+	InlineTable
+
+	// DottedTable is a table defined by dotted keys: x.y = 1.
+	DottedTable
+)
+
+// Kind returns how the table was defined in the document it was decoded from.
+func (m *OrderedMap) Kind() TableKind {
+	return m.kind
+}
+
+// SetKind sets how the table is emitted by the encoder when it is nested in
+// another OrderedMap. An empty DottedTable is emitted as an empty inline table.
+func (m *OrderedMap) SetKind(kind TableKind) {
+	m.kind = kind
+}
+
+// Get returns the value stored at key, and whether it was present.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Set stores value at key. If key is already present, its value is replaced
+// and its position is left unchanged. Otherwise key is added at the end.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = make(map[string]interface{})
+	}
+
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+
+	m.values[key] = value
+}
+
+// Delete removes key from the map, if present.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+
+	delete(m.values, key)
+
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys of the map in order.
+func (m *OrderedMap) Keys() []string {
+	keys := make([]string, len(m.keys))
+	copy(keys, m.keys)
+	return keys
+}
+
+// Len returns the number of keys in the map.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// Range calls fn for each key and value in order. Iteration stops if fn
+// returns false.
+func (m *OrderedMap) Range(fn func(key string, value interface{}) bool) {
+	for _, k := range m.keys {
+		if !fn(k, m.values[k]) {
+			return
+		}
+	}
+}
+
+// keptAsKeyValue returns true if v is an *OrderedMap that is encoded as a
+// key-value instead of a table, because it was defined as such in the
+// document.
+func keptAsKeyValue(v reflect.Value) bool {
+	if v.Type() != orderedMapPtrType || v.IsNil() {
+		return false
+	}
+
+	m := v.Interface().(*OrderedMap)
+	return m.kind != StandardTable
+}
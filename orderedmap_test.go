@@ -0,0 +1,341 @@
+package toml_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderedMap(t *testing.T) {
+	var m toml.OrderedMap
+
+	_, ok := m.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, m.Len())
+
+	m.Set("b", 1)
+	m.Set("a", 2)
+	m.Set("c", 3)
+	m.Set("b", 4)
+	assert.Equal(t, []string{"b", "a", "c"}, m.Keys())
+
+	v, ok := m.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 4, v)
+
+	m.Delete("a")
+	m.Delete("missing")
+	assert.Equal(t, []string{"b", "c"}, m.Keys())
+	assert.Equal(t, 2, m.Len())
+
+	var keys []string
+	m.Range(func(k string, v interface{}) bool {
+		keys = append(keys, k)
+		return false
+	})
+	assert.Equal(t, []string{"b"}, keys)
+}
+
+func TestOrderedMapRemaining(t *testing.T) {
+	type doc struct {
+		Name  string
+		Extra toml.OrderedMap `toml:",remaining"`
+	}
+
+	input := `zeta = 1
+name = "n"
+alpha = "a"
+
+[server]
+port = 80
+host = "h"
+
+[server.tls]
+enabled = true
+
+[[items]]
+b = 1
+a = 2
+
+[[items]]
+d = 3
+c = 4
+
+[inlined]
+point = { y = 1, x = 2 }
+`
+
+	var d doc
+	err := toml.Unmarshal([]byte(input), &d)
+	require.NoError(t, err)
+
+	assert.Equal(t, "n", d.Name)
+	assert.Equal(t, []string{"zeta", "alpha", "server", "items", "inlined"}, d.Extra.Keys())
+
+	v, _ := d.Extra.Get("server")
+	server, ok := v.(*toml.OrderedMap)
+	require.True(t, ok)
+	assert.Equal(t, []string{"port", "host", "tls"}, server.Keys())
+
+	v, _ = d.Extra.Get("items")
+	items, ok := v.([]interface{})
+	require.True(t, ok)
+	require.Len(t, items, 2)
+	assert.Equal(t, []string{"b", "a"}, items[0].(*toml.OrderedMap).Keys())
+	assert.Equal(t, []string{"d", "c"}, items[1].(*toml.OrderedMap).Keys())
+
+	v, _ = d.Extra.Get("inlined")
+	v, _ = v.(*toml.OrderedMap).Get("point")
+	assert.Equal(t, []string{"y", "x"}, v.(*toml.OrderedMap).Keys())
+
+	b, err := toml.Marshal(d)
+	require.NoError(t, err)
+
+	expected := `Name = 'n'
+zeta = 1
+alpha = 'a'
+[server]
+port = 80
+host = 'h'
+[server.tls]
+enabled = true
+
+
+[[items]]
+b = 1
+a = 2
+[[items]]
+d = 3
+c = 4
+
+[inlined]
+point = {y = 1, x = 2}
+`
+	equalStringsIgnoreNewlines(t, expected, string(b))
+}
+
+func TestOrderedMapRemainingMap(t *testing.T) {
+	type doc struct {
+		Name  string
+		Extra map[string]interface{} `toml:",remaining"`
+	}
+
+	var d doc
+	err := toml.Unmarshal([]byte("name = 'n'\nb = 1\n[a]\nx = 2\n"), &d)
+	require.NoError(t, err)
+
+	assert.Equal(t, "n", d.Name)
+	assert.Equal(t, map[string]interface{}{
+		"b": int64(1),
+		"a": map[string]interface{}{"x": int64(2)},
+	}, d.Extra)
+}
+
+func TestOrderedMapRemainingStrict(t *testing.T) {
+	type doc struct {
+		Extra *toml.OrderedMap `toml:",remaining"`
+	}
+
+	var d doc
+	err := toml.NewDecoder(strings.NewReader("a = 1\n[b]\nc = 2\n")).DisallowUnknownFields().Decode(&d)
+	require.NoError(t, err)
+	require.NotNil(t, d.Extra)
+	assert.Equal(t, []string{"a", "b"}, d.Extra.Keys())
+}
+
+func TestOrderedMapDecodeEncode(t *testing.T) {
+	input := `b = 1
+a = [1, 2]
+
+[z]
+y = 'y'
+x = 'x'
+`
+
+	var m toml.OrderedMap
+	err := toml.Unmarshal([]byte(input), &m)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "a", "z"}, m.Keys())
+
+	b, err := toml.Marshal(&m)
+	require.NoError(t, err)
+
+	expected := `b = 1
+a = [1, 2]
+[z]
+y = 'y'
+x = 'x'
+`
+	equalStringsIgnoreNewlines(t, expected, string(b))
+}
+
+func TestDecoderEnableOrderedMaps(t *testing.T) {
+	input := `b = 1
+a = {d = 1, c = 2}
+
+[z]
+y = 'y'
+x = [{q = 1, p = 2}]
+`
+
+	var v interface{}
+	err := toml.NewDecoder(strings.NewReader(input)).EnableOrderedMaps().Decode(&v)
+	require.NoError(t, err)
+
+	m, ok := v.(*toml.OrderedMap)
+	require.True(t, ok)
+	assert.Equal(t, []string{"b", "a", "z"}, m.Keys())
+
+	a, _ := m.Get("a")
+	assert.Equal(t, []string{"d", "c"}, a.(*toml.OrderedMap).Keys())
+
+	z, _ := m.Get("z")
+	x, _ := z.(*toml.OrderedMap).Get("x")
+	assert.Equal(t, []string{"q", "p"}, x.([]interface{})[0].(*toml.OrderedMap).Keys())
+
+	var s struct {
+		Z interface{}
+	}
+	err = toml.NewDecoder(strings.NewReader(input)).EnableOrderedMaps().Decode(&s)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"y", "x"}, s.Z.(*toml.OrderedMap).Keys())
+
+	b, err := toml.Marshal(v)
+	require.NoError(t, err)
+
+	expected := `b = 1
+a = {d = 1, c = 2}
+[z]
+y = 'y'
+x = [{q = 1, p = 2}]
+`
+	equalStringsIgnoreNewlines(t, expected, string(b))
+}
+
+func TestOrderedMapRoundTrip(t *testing.T) {
+	input := `zeta = 1
+point = {y = 1, x = 2}
+alpha.second = 2
+alpha.first.deep = 1
+points = [{b = 1, a = 2}, {d = 3, c = 4}]
+beta = 'b'
+
+[server]
+port = 80
+host = 'h'
+
+[server.tls]
+key = 'k'
+enabled = true
+
+[[items]]
+b = 1
+a = 2
+
+[[items]]
+d = 3
+c = 4
+
+[after]
+z = 1
+y = 2
+`
+
+	var m toml.OrderedMap
+	err := toml.Unmarshal([]byte(input), &m)
+	require.NoError(t, err)
+
+	b, err := toml.Marshal(&m)
+	require.NoError(t, err)
+
+	expected := `zeta = 1
+point = {y = 1, x = 2}
+alpha.second = 2
+alpha.first.deep = 1
+points = [{b = 1, a = 2}, {d = 3, c = 4}]
+beta = 'b'
+[server]
+port = 80
+host = 'h'
+[server.tls]
+key = 'k'
+enabled = true
+
+
+[[items]]
+b = 1
+a = 2
+[[items]]
+d = 3
+c = 4
+
+[after]
+z = 1
+y = 2
+`
+	equalStringsIgnoreNewlines(t, expected, string(b))
+
+	var again toml.OrderedMap
+	err = toml.Unmarshal(b, &again)
+	require.NoError(t, err)
+	require.Equal(t, m, again)
+}
+
+func TestOrderedMapKind(t *testing.T) {
+	input := `inline = {}
+dotted.a = 1
+
+[header]
+
+[implicit.sub]
+`
+
+	var m toml.OrderedMap
+	err := toml.Unmarshal([]byte(input), &m)
+	require.NoError(t, err)
+	assert.Equal(t, toml.StandardTable, m.Kind())
+
+	kinds := map[string]toml.TableKind{}
+	m.Range(func(k string, v interface{}) bool {
+		kinds[k] = v.(*toml.OrderedMap).Kind()
+		return true
+	})
+	assert.Equal(t, map[string]toml.TableKind{
+		"inline":   toml.InlineTable,
+		"dotted":   toml.DottedTable,
+		"header":   toml.StandardTable,
+		"implicit": toml.StandardTable,
+	}, kinds)
+
+	b, err := toml.Marshal(&m)
+	require.NoError(t, err)
+
+	expected := `inline = {}
+dotted.a = 1
+[header]
+
+[implicit]
+[implicit.sub]
+
+
+`
+	assert.Equal(t, expected, string(b))
+
+	var plain map[string]interface{}
+	err = toml.Unmarshal([]byte(input), &plain)
+	require.NoError(t, err)
+	assert.Equal(t, plain["inline"], plain["header"])
+
+	built := &toml.OrderedMap{}
+	built.Set("a", 1)
+	built.SetKind(toml.InlineTable)
+	var root toml.OrderedMap
+	root.Set("t", built)
+	root.Set("b", 2)
+	b, err = toml.Marshal(&root)
+	require.NoError(t, err)
+	assert.Equal(t, "t = {a = 1}\nb = 2\n", string(b))
+}
@@ -15,6 +15,38 @@ type parser struct {
 	left    []byte
 	err     error
 	first   bool
+
+	// Comment lines directly preceding the current expression.
+	comments [][]byte
+
+	// Maximum nesting of arrays and inline tables, defaultMaxDepth when 0,
+	// and nesting of the value being parsed.
+	maxDepth int
+	depth    int
+}
+
+// defaultMaxDepth is the maximum nesting of arrays and inline tables of a
+// document, unless set otherwise with Decoder.SetMaxDepth.
+const defaultMaxDepth = 1000
+
+// enter increases the nesting depth when parsing the array or inline table
+// starting at b, and returns an error when it exceeds the maximum.
+func (p *parser) enter(b []byte) error {
+	p.depth++
+
+	max := p.maxDepth
+	if max <= 0 {
+		max = defaultMaxDepth
+	}
+	if p.depth > max {
+		return newDecodeError(b[:1], "maximum nesting depth of %d exceeded", max)
+	}
+
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
 }
 
 func (p *parser) Range(b []byte) ast.Range {
@@ -35,6 +67,7 @@ func (p *parser) Reset(b []byte) {
 	p.left = b
 	p.err = nil
 	p.first = true
+	p.depth = 0
 }
 
 //nolint:cyclop
@@ -45,6 +78,7 @@ func (p *parser) NextExpression() bool {
 
 	p.builder.Reset()
 	p.ref = ast.InvalidReference
+	p.comments = p.comments[:0]
 
 	for {
 		if len(p.left) == 0 || p.err != nil {
@@ -77,6 +111,12 @@ func (p *parser) Expression() *ast.Node {
 	return p.builder.NodeAt(p.ref)
 }
 
+// Comments returns the comment lines that are directly above the current
+// expression, without blank lines between them and the expression.
+func (p *parser) Comments() [][]byte {
+	return p.comments
+}
+
 func (p *parser) Error() error {
 	return p.err
 }
@@ -107,11 +147,13 @@ func (p *parser) parseExpression(b []byte) (ast.Reference, []byte, error) {
 	}
 
 	if b[0] == '#' {
-		_, rest, err := scanComment(b)
+		c, rest, err := scanComment(b)
+		p.comments = append(p.comments, c)
 		return ref, rest, err
 	}
 
 	if b[0] == '\n' || b[0] == '\r' {
+		p.comments = p.comments[:0]
 		return ref, b, nil
 	}
 
@@ -339,10 +381,16 @@ func (p *parser) parseInlineTable(b []byte) (ast.Reference, []byte, error) {
 	// inline-table-close = ws %x7D     ; }
 	// inline-table-sep   = ws %x2C ws  ; , Comma
 	// inline-table-keyvals = keyval [ inline-table-sep inline-table-keyvals ]
+	start := b
 	parent := p.builder.Push(ast.Node{
 		Kind: ast.InlineTable,
 	})
 
+	defer p.leave()
+	if err := p.enter(b); err != nil {
+		return parent, nil, err
+	}
+
 	first := true
 
 	var child ast.Reference
@@ -389,6 +437,9 @@ func (p *parser) parseInlineTable(b []byte) (ast.Reference, []byte, error) {
 	}
 
 	rest, err := expect('}', b)
+	if err == nil {
+		p.builder.NodeAt(parent).Raw = p.Range(start[:len(start)-len(rest)])
+	}
 
 	return parent, rest, err
 }
@@ -409,6 +460,11 @@ func (p *parser) parseValArray(b []byte) (ast.Reference, []byte, error) {
 		Kind: ast.Array,
 	})
 
+	defer p.leave()
+	if err := p.enter(arrayStart); err != nil {
+		return parent, nil, err
+	}
+
 	first := true
 
 	var lastChild ast.Reference
@@ -468,6 +524,9 @@ func (p *parser) parseValArray(b []byte) (ast.Reference, []byte, error) {
 	}
 
 	rest, err := expect(']', b)
+	if err == nil {
+		p.builder.NodeAt(parent).Raw = p.Range(arrayStart[:len(arrayStart)-len(rest)])
+	}
 
 	return parent, rest, err
 }
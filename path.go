@@ -0,0 +1,71 @@
+package toml
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrPathNotFound is returned by Decoder.DecodePath when the document does not
+// contain the requested path.
+var ErrPathNotFound = errors.New("toml: path not found")
+
+// DecodePath decodes only the table or the value at path in the document into
+// v, ignoring the rest of the document. path is a TOML key, whose parts are the
+// keys leading to the table or the value:
+//
+//   var ip string
+//   err := dec.DecodePath("servers.alpha.ip", &ip)
+//
+// decodes the ip key of the [servers.alpha] table, however it is written in the
+// document. Parts containing dots or spaces are quoted, like in the document:
+// `hosts."example.com".port`. The parts are matched exactly. Paths cannot
+// designate the elements of arrays of tables. An empty path designates the
+// whole document.
+//
+// If the document does not contain the path, the returned error wraps
+// ErrPathNotFound. With DisallowUnknownFields, only the keys under the path are
+// reported.
+func (d *Decoder) DecodePath(path string, v interface{}) error {
+	if path == "" {
+		return d.Decode(v)
+	}
+
+	keys, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	found, err := d.decodeRoot(v, nil, keys, true)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", ErrPathNotFound, path)
+	}
+
+	return nil
+}
+
+// parsePath returns the parts of the TOML key path.
+func parsePath(path string) ([]string, error) {
+	p := parser{}
+	p.Reset([]byte(path))
+
+	ref, rest, err := p.parseKey(p.data)
+	if err == nil && len(p.parseWhitespace(rest)) > 0 {
+		err = newDecodeError(rest, "expected end of key")
+	}
+	if err != nil {
+		var e *decodeError
+		if errors.As(err, &e) {
+			err = wrapDecodeError(p.data, e)
+		}
+		return nil, fmt.Errorf("toml: invalid path %q: %w", path, err)
+	}
+
+	var keys []string
+	for n := p.builder.NodeAt(ref); n.Valid(); n = n.Next() {
+		keys = append(keys, string(n.Data))
+	}
+	return keys, nil
+}
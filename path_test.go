@@ -0,0 +1,134 @@
+package toml_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+const pathDoc = `
+title = "example"
+
+[servers.alpha]
+ip = "10.0.0.1"
+dc = "eqdc10"
+
+[servers.beta]
+ip = "10.0.0.2"
+ports = [80, 443]
+
+[database]
+enabled = true
+`
+
+func TestDecodePath(t *testing.T) {
+	type server struct {
+		IP    string
+		DC    string
+		Ports []int
+	}
+
+	t.Run("table", func(t *testing.T) {
+		var s server
+		err := toml.NewDecoder(strings.NewReader(pathDoc)).DecodePath("servers.beta", &s)
+		require.NoError(t, err)
+		require.Equal(t, server{IP: "10.0.0.2", Ports: []int{80, 443}}, s)
+	})
+
+	t.Run("value", func(t *testing.T) {
+		var ip string
+		err := toml.NewDecoder(strings.NewReader(pathDoc)).DecodePath("servers.alpha.ip", &ip)
+		require.NoError(t, err)
+		require.Equal(t, "10.0.0.1", ip)
+	})
+
+	t.Run("dotted keys", func(t *testing.T) {
+		var ip string
+		doc := "servers.alpha.ip = '10.0.0.3'"
+		err := toml.NewDecoder(strings.NewReader(doc)).DecodePath("servers.alpha.ip", &ip)
+		require.NoError(t, err)
+		require.Equal(t, "10.0.0.3", ip)
+	})
+
+	t.Run("map", func(t *testing.T) {
+		var m map[string]interface{}
+		err := toml.NewDecoder(strings.NewReader(pathDoc)).DecodePath("servers", &m)
+		require.NoError(t, err)
+		require.Equal(t, map[string]interface{}{
+			"alpha": map[string]interface{}{"ip": "10.0.0.1", "dc": "eqdc10"},
+			"beta":  map[string]interface{}{"ip": "10.0.0.2", "ports": []interface{}{int64(80), int64(443)}},
+		}, m)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		var s server
+		err := toml.NewDecoder(strings.NewReader(pathDoc)).DecodePath("servers.gamma", &s)
+		require.True(t, errors.Is(err, toml.ErrPathNotFound))
+		require.EqualError(t, err, "toml: path not found: servers.gamma")
+	})
+
+	t.Run("quoted key", func(t *testing.T) {
+		var port int
+		doc := "[hosts.\"example.com\"]\nport = 80\n"
+		err := toml.NewDecoder(strings.NewReader(doc)).DecodePath(`hosts."example.com".port`, &port)
+		require.NoError(t, err)
+		require.Equal(t, 80, port)
+	})
+
+	t.Run("inline tables", func(t *testing.T) {
+		var ip string
+		doc := "[servers]\nalpha = { ip = '10.0.0.4', dc = 'eqdc10' }\n"
+		err := toml.NewDecoder(strings.NewReader(doc)).DecodePath("servers.alpha.ip", &ip)
+		require.NoError(t, err)
+		require.Equal(t, "10.0.0.4", ip)
+
+		err = toml.NewDecoder(strings.NewReader("servers.alpha = 1")).DecodePath("servers.alpha.ip", &ip)
+		require.True(t, errors.Is(err, toml.ErrPathNotFound))
+	})
+
+	t.Run("array tables", func(t *testing.T) {
+		doc := "[[arr]]\na = 1\n[[arr]]\na = 2\n"
+
+		var s []map[string]int
+		err := toml.NewDecoder(strings.NewReader(doc)).DecodePath("arr", &s)
+		require.NoError(t, err)
+		require.Equal(t, []map[string]int{{"a": 1}, {"a": 2}}, s)
+
+		var m map[string]interface{}
+		err = toml.NewDecoder(strings.NewReader(doc)).DecodePath("arr", &m)
+		var derr *toml.DecodeError
+		require.ErrorAs(t, err, &derr)
+		require.Contains(t, derr.Error(), "cannot decode array table arr into a Go value of type map[string]interface {}, use a slice")
+
+		var a int
+		err = toml.NewDecoder(strings.NewReader(doc)).DecodePath("arr.a", &a)
+		require.True(t, errors.Is(err, toml.ErrPathNotFound))
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		var ip string
+		err := toml.NewDecoder(strings.NewReader(pathDoc)).DecodePath("servers.", &ip)
+		require.Error(t, err)
+		require.False(t, errors.Is(err, toml.ErrPathNotFound))
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		var s struct{ IP string }
+		dec := toml.NewDecoder(strings.NewReader(pathDoc))
+		dec.DisallowUnknownFields()
+		err := dec.DecodePath("servers.alpha", &s)
+
+		var serr *toml.StrictMissingError
+		require.True(t, errors.As(err, &serr))
+		require.Len(t, serr.Errors, 1)
+		require.Equal(t, toml.Key{"servers", "alpha", "dc"}, serr.Errors[0].Key())
+
+		dec = toml.NewDecoder(strings.NewReader(pathDoc))
+		dec.DisallowUnknownFields()
+		err = dec.DecodePath("database", &struct{ Enabled bool }{})
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,272 @@
+package toml
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+)
+
+// RawValue holds a part of a TOML document that is kept undecoded, to be
+// decoded later with UnmarshalValue. It is useful when the type of a value
+// depends on another key of the document:
+//
+//   type Plugin struct {
+//     Kind   string
+//     Config toml.RawValue
+//   }
+//
+// Once Kind is known, Config is decoded into the right type.
+//
+// A RawValue decoded from a value, like a string, an array or an inline
+// table, holds the value as it appears in the document. A RawValue decoded
+// from a table defined by headers or dotted keys holds a TOML document with
+// the key-values and the sub-tables of the table, and Table is true. Comments
+// and the layout of the key-values are not preserved.
+//
+// A map[string]RawValue holds one entry for each key of a table, which makes
+// it possible to decode each sub-table into a different type depending on its
+// name, like the entries of a registry of plugins:
+//
+//   var c struct {
+//     Plugins map[string]toml.RawValue
+//   }
+//   err := toml.Unmarshal(doc, &c)
+//   ...
+//   for name, raw := range c.Plugins {
+//     err := toml.UnmarshalValue(raw, plugins[name].Config())
+//     ...
+//   }
+//
+// When encoding, a RawValue is emitted as it is, or as a table when Table is
+// true.
+type RawValue struct {
+	// TOML representation of the value, or document of the table.
+	Raw []byte
+
+	// Table reports whether Raw is a document holding a table, rather than a
+	// value.
+	Table bool
+
+	// Length of the beginning of Raw holding the key-values of the table
+	// itself, before the headers of its sub-tables.
+	rootLen int
+}
+
+// UnmarshalValue decodes the value held by raw into v, following the rules of
+// Unmarshal. An empty RawValue, like the one of a key missing from a
+// map[string]RawValue, leaves v untouched.
+func UnmarshalValue(raw RawValue, v interface{}) error {
+	if raw.Table {
+		return Unmarshal(raw.Raw, v)
+	}
+	if len(raw.Raw) == 0 {
+		return nil
+	}
+
+	r := reflect.ValueOf(v)
+	if r.Kind() != reflect.Ptr {
+		return fmt.Errorf("toml: decoding can only be performed into a pointer, not %s", r.Kind())
+	}
+
+	if r.IsNil() {
+		return fmt.Errorf("toml: decoding pointer target cannot be nil")
+	}
+
+	doc := make([]byte, 0, len(rawValuePrefix)+len(raw.Raw))
+	doc = append(doc, rawValuePrefix...)
+	doc = append(doc, raw.Raw...)
+
+	p := parser{}
+	p.Reset(doc)
+	d := decoder{p: &p}
+
+	if !p.NextExpression() {
+		if err := p.Error(); err != nil {
+			return d.wrapError(err)
+		}
+		return fmt.Errorf("toml: RawValue holds no value")
+	}
+
+	err := d.handleValue(p.Expression().Value(), r.Elem())
+	if err != nil {
+		return d.wrapError(err)
+	}
+
+	if p.NextExpression() {
+		return fmt.Errorf("toml: RawValue holds more than one value")
+	}
+	if err := p.Error(); err != nil {
+		return d.wrapError(err)
+	}
+
+	return afterDecode(r.Elem())
+}
+
+// rawValuePrefix turns the bytes of a value into a key-value that can be
+// parsed.
+const rawValuePrefix = "v = "
+
+// rawValueTarget returns the RawValue v holds, allocating it when v is a nil
+// pointer to a RawValue.
+func rawValueTarget(v reflect.Value) (reflect.Value, bool) {
+	if v.Kind() == reflect.Ptr && v.Type().Elem() == rawValueType {
+		if v.IsNil() {
+			v.Set(reflect.New(rawValueType))
+		}
+		return v.Elem(), true
+	}
+	return v, v.Type() == rawValueType
+}
+
+// unmarshalRawValue stores the bytes of the value node into the RawValue v.
+func (d *decoder) unmarshalRawValue(value *ast.Node, v reflect.Value) error {
+	raw := d.rawValue(value)
+	v.Set(reflect.ValueOf(RawValue{Raw: append([]byte(nil), raw...)}))
+	return nil
+}
+
+// handleRawTable adds the table or array table expression being decoded to
+// the RawValue v, given the remaining parts of its key, and consumes the
+// key-values of the table.
+func (d *decoder) handleRawTable(key ast.Iterator, v reflect.Value, arrayTable bool) (reflect.Value, error) {
+	r := v.Interface().(RawValue)
+	r.Table = true
+
+	parts := rawKeyParts(key)
+	if len(parts) > 0 {
+		if len(r.Raw) > 0 {
+			r.Raw = append(r.Raw, '\n')
+		}
+		if arrayTable {
+			r.Raw = append(r.Raw, "[["...)
+			r.Raw = appendRawKey(r.Raw, parts)
+			r.Raw = append(r.Raw, "]]\n"...)
+		} else {
+			r.Raw = append(r.Raw, '[')
+			r.Raw = appendRawKey(r.Raw, parts)
+			r.Raw = append(r.Raw, "]\n"...)
+		}
+	}
+
+	for d.nextExpr() {
+		expr := d.expr()
+		if expr.Kind != ast.KeyValue {
+			d.stashExpr()
+			break
+		}
+
+		err := d.countKeys(expr)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		err = d.seen.CheckExpression(expr)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		d.required.KeyValue(expr)
+
+		kv := appendRawKeyValue(nil, rawKeyParts(expr.Key()), d.rawValue(expr.Value()))
+		if len(parts) > 0 {
+			r.Raw = append(r.Raw, kv...)
+		} else {
+			r.insertKeyValue(kv)
+		}
+	}
+
+	v.Set(reflect.ValueOf(r))
+
+	return reflect.Value{}, nil
+}
+
+// handleRawKeyValue decodes the value of a key-value into the RawValue v,
+// given the remaining parts of its key. A dotted key going past v adds a
+// key-value to the table v holds.
+func (d *decoder) handleRawKeyValue(key ast.Iterator, value *ast.Node, v reflect.Value) (reflect.Value, error) {
+	parts := rawKeyParts(key)
+	if len(parts) == 0 {
+		return reflect.Value{}, d.unmarshalRawValue(value, v)
+	}
+
+	r := v.Interface().(RawValue)
+	r.Table = true
+	r.insertKeyValue(appendRawKeyValue(nil, parts, d.rawValue(value)))
+	v.Set(reflect.ValueOf(r))
+
+	return reflect.Value{}, nil
+}
+
+// insertKeyValue adds the key-value kv to the table r holds, after its other
+// key-values and before its sub-tables.
+func (r *RawValue) insertKeyValue(kv []byte) {
+	if r.rootLen > len(r.Raw) {
+		r.rootLen = len(r.Raw)
+	}
+	n := len(kv)
+	if r.rootLen == 0 && len(r.Raw) > 0 {
+		// Separate the key-values from the sub-tables that follow them.
+		kv = append(kv, '\n')
+	}
+	r.Raw = append(r.Raw, kv...)
+	copy(r.Raw[r.rootLen+len(kv):], r.Raw[r.rootLen:])
+	copy(r.Raw[r.rootLen:], kv)
+	r.rootLen += n
+}
+
+// rawKeyParts returns the parts of key that have not been visited yet.
+func rawKeyParts(key ast.Iterator) [][]byte {
+	var parts [][]byte
+	for key.Next() {
+		parts = append(parts, key.Node().Data)
+	}
+	return parts
+}
+
+func appendRawKey(b []byte, parts [][]byte) []byte {
+	var enc Encoder
+	for i, p := range parts {
+		if i > 0 {
+			b = append(b, '.')
+		}
+		b = enc.encodeKey(b, string(p))
+	}
+	return b
+}
+
+func appendRawKeyValue(b []byte, parts [][]byte, raw []byte) []byte {
+	b = appendRawKey(b, parts)
+	b = append(b, " = "...)
+	b = append(b, raw...)
+	return append(b, '\n')
+}
+
+// encodeRawValue emits the RawValue v: its bytes for a value, or the table it
+// holds.
+func (enc *Encoder) encodeRawValue(b []byte, ctx encoderCtx, v RawValue) ([]byte, error) {
+	if v.Table {
+		var m OrderedMap
+		err := Unmarshal(v.Raw, &m)
+		if err != nil {
+			return nil, fmt.Errorf("toml: invalid RawValue: %w", err)
+		}
+		return enc.encode(b, ctx, reflect.ValueOf(m))
+	}
+
+	if ctx.isRoot() {
+		return nil, fmt.Errorf("toml: a RawValue holding a value cannot be a root element")
+	}
+
+	raw, err := checkRawValue(v.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("toml: invalid RawValue: %w", err)
+	}
+
+	return append(b, raw...), nil
+}
+
+// isRawTable reports whether v is a RawValue holding a table.
+func isRawTable(v reflect.Value) bool {
+	return v.Field(1).Bool()
+}
@@ -0,0 +1,218 @@
+package toml_test
+
+import (
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalRawValue(t *testing.T) {
+	type plugin struct {
+		Kind   string
+		Config toml.RawValue
+	}
+	type config struct {
+		Name    string
+		Values  []toml.RawValue
+		Inline  *toml.RawValue
+		Dotted  toml.RawValue
+		Plugins map[string]plugin
+	}
+
+	doc := `
+name = "a" # comment
+values = [1, 'two', { three = 3 }]
+inline = { port = 80 }
+dotted.a = 1
+dotted.b.c = 2
+
+[plugins.http]
+kind = "http"
+[plugins.http.config.tls]
+cert = "c.pem"
+[plugins.http.config]
+port = 80
+'listen address' = "::"
+[[plugins.http.config.routes]]
+path = "/"
+[[plugins.http.config.routes]]
+path = "/admin"
+`
+
+	var c config
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+
+	require.Equal(t, "a", c.Name)
+	require.Len(t, c.Values, 3)
+	require.Equal(t, "1", string(c.Values[0].Raw))
+	require.Equal(t, "'two'", string(c.Values[1].Raw))
+	require.Equal(t, "{ three = 3 }", string(c.Values[2].Raw))
+	require.False(t, c.Values[2].Table)
+	require.Equal(t, "{ port = 80 }", string(c.Inline.Raw))
+	require.Equal(t, "a = 1\nb.c = 2\n", string(c.Dotted.Raw))
+	require.True(t, c.Dotted.Table)
+
+	raw := c.Plugins["http"].Config
+	require.True(t, raw.Table)
+	expected := `port = 80
+'listen address' = "::"
+
+[tls]
+cert = "c.pem"
+
+[[routes]]
+path = "/"
+
+[[routes]]
+path = "/admin"
+`
+	require.Equal(t, expected, string(raw.Raw))
+
+	type route struct {
+		Path string
+	}
+	type httpConfig struct {
+		Port   int
+		Listen string `toml:"listen address"`
+		TLS    struct {
+			Cert string
+		}
+		Routes []route
+	}
+
+	var h httpConfig
+	err = toml.UnmarshalValue(raw, &h)
+	require.NoError(t, err)
+	require.Equal(t, 80, h.Port)
+	require.Equal(t, "::", h.Listen)
+	require.Equal(t, "c.pem", h.TLS.Cert)
+	require.Equal(t, []route{{Path: "/"}, {Path: "/admin"}}, h.Routes)
+
+	var s string
+	err = toml.UnmarshalValue(c.Values[1], &s)
+	require.NoError(t, err)
+	require.Equal(t, "two", s)
+
+	var m map[string]int
+	err = toml.UnmarshalValue(c.Values[2], &m)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"three": 3}, m)
+
+	var i int
+	err = toml.UnmarshalValue(c.Values[1], &i)
+	require.Error(t, err)
+}
+
+func TestUnmarshalRawValueArrayTables(t *testing.T) {
+	doc := `
+[[items]]
+x = 1
+[items.sub]
+y = 2
+[[items]]
+x = 3
+`
+
+	var c struct {
+		Items []toml.RawValue
+	}
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+	require.Len(t, c.Items, 2)
+	require.Equal(t, "x = 1\n\n[sub]\ny = 2\n", string(c.Items[0].Raw))
+	require.Equal(t, "x = 3\n", string(c.Items[1].Raw))
+
+	var single struct {
+		Items toml.RawValue
+	}
+	err = toml.Unmarshal([]byte(doc), &single)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "use a []RawValue")
+}
+
+func TestUnmarshalValueInvalid(t *testing.T) {
+	var v interface{}
+
+	err := toml.UnmarshalValue(toml.RawValue{Raw: []byte("[1,")}, &v)
+	require.Error(t, err)
+
+	err = toml.UnmarshalValue(toml.RawValue{Raw: []byte("1\nw = 2")}, &v)
+	require.Error(t, err)
+
+	err = toml.UnmarshalValue(toml.RawValue{Raw: []byte("1")}, v)
+	require.Error(t, err)
+}
+
+func TestMarshalRawValue(t *testing.T) {
+	type config struct {
+		Value toml.RawValue
+		Table toml.RawValue
+	}
+
+	c := config{
+		Value: toml.RawValue{Raw: []byte("[1, 0x2]")},
+		Table: toml.RawValue{Raw: []byte("a = 1\n[b]\nc = 'd'\n"), Table: true},
+	}
+
+	b, err := toml.Marshal(c)
+	require.NoError(t, err)
+
+	expected := "Value = [1, 0x2]\n[Table]\na = 1\n[Table.b]\nc = 'd'\n\n\n"
+	require.Equal(t, expected, string(b))
+
+	_, err = toml.Marshal(config{Value: toml.RawValue{Raw: []byte("1 2")}})
+	require.Error(t, err)
+}
+
+func TestUnmarshalRawValueMap(t *testing.T) {
+	doc := `
+[plugins]
+cache.size = 10
+
+[plugins.http]
+port = 80
+[plugins.http.tls]
+cert = "c.pem"
+
+[plugins.log]
+[[plugins.log.outputs]]
+path = "/var/log/a"
+`
+
+	var c struct {
+		Plugins map[string]toml.RawValue
+	}
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+	require.Len(t, c.Plugins, 3)
+
+	var cache struct {
+		Size int
+	}
+	err = toml.UnmarshalValue(c.Plugins["cache"], &cache)
+	require.NoError(t, err)
+	require.Equal(t, 10, cache.Size)
+
+	var http struct {
+		Port int
+		TLS  struct {
+			Cert string
+		}
+	}
+	err = toml.UnmarshalValue(c.Plugins["http"], &http)
+	require.NoError(t, err)
+	require.Equal(t, 80, http.Port)
+	require.Equal(t, "c.pem", http.TLS.Cert)
+
+	var log struct {
+		Outputs []struct {
+			Path string
+		}
+	}
+	err = toml.UnmarshalValue(c.Plugins["log"], &log)
+	require.NoError(t, err)
+	require.Len(t, log.Outputs, 1)
+	require.Equal(t, "/var/log/a", log.Outputs[0].Path)
+}
@@ -0,0 +1,283 @@
+package toml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+)
+
+// required records the keys present in the document, so that struct fields
+// tagged with the "required" option can be verified once the document has been
+// decoded.
+//
+// Keys are stored lowercased with their parents, and elements of arrays are
+// identified by their index.
+type required struct {
+	Enabled bool
+
+	// Set of the keys present in the document.
+	present map[string]struct{}
+
+	// Number of elements of each array table.
+	arrays map[string]int
+
+	// Path to the current table.
+	table []string
+
+	// Used to find the keys of fields that don't have an exact match.
+	keyMapper KeyMapper
+
+	// Keys of the document leading to the decoded value, set by
+	// Decoder.SetRootKey and Decoder.DecodePath.
+	rootPath []string
+}
+
+const requiredPathSeparator = "\x00"
+
+func requiredIndex(i int) string {
+	return "#" + strconv.Itoa(i)
+}
+
+func (r *required) mark(path []string) {
+	if r.present == nil {
+		r.present = map[string]struct{}{}
+	}
+	r.present[strings.Join(path, requiredPathSeparator)] = struct{}{}
+}
+
+func (r *required) has(path []string) bool {
+	_, ok := r.present[strings.Join(path, requiredPathSeparator)]
+	return ok
+}
+
+func (r *required) EnterTable(node *ast.Node) {
+	if !r.Enabled {
+		return
+	}
+
+	r.table = r.resolve(node.Key(), false)
+}
+
+func (r *required) EnterArrayTable(node *ast.Node) {
+	if !r.Enabled {
+		return
+	}
+
+	r.table = r.resolve(node.Key(), true)
+}
+
+// resolve returns the path of the table designated by key. Array tables are
+// followed by the index of their last element.
+func (r *required) resolve(key ast.Iterator, arrayTable bool) []string {
+	if r.arrays == nil {
+		r.arrays = map[string]int{}
+	}
+
+	var path []string
+	for key.Next() {
+		path = append(path, strings.ToLower(string(key.Node().Data)))
+
+		r.mark(path)
+
+		p := strings.Join(path, requiredPathSeparator)
+		if arrayTable && key.IsLast() {
+			r.arrays[p]++
+		}
+		if n, ok := r.arrays[p]; ok {
+			path = append(path, requiredIndex(n-1))
+			r.mark(path)
+		}
+	}
+
+	return path
+}
+
+func (r *required) KeyValue(node *ast.Node) {
+	if !r.Enabled {
+		return
+	}
+
+	r.keyValue(r.table, node)
+}
+
+func (r *required) keyValue(parent []string, node *ast.Node) {
+	path := parent[:len(parent):len(parent)]
+
+	it := node.Key()
+	for it.Next() {
+		path = append(path, strings.ToLower(string(it.Node().Data)))
+		r.mark(path)
+	}
+
+	r.value(path, node.Value())
+}
+
+func (r *required) value(path []string, node *ast.Node) {
+	switch node.Kind {
+	case ast.InlineTable:
+		it := node.Children()
+		for it.Next() {
+			r.keyValue(path, it.Node())
+		}
+	case ast.Array:
+		it := node.Children()
+		for i := 0; it.Next(); i++ {
+			p := append(path[:len(path):len(path)], requiredIndex(i))
+			r.mark(p)
+			r.value(p, it.Node())
+		}
+	}
+}
+
+// Check returns an error if the decoded value v contains a struct with a
+// required field whose key was absent from the document. Fields of structs
+// that are themselves absent from the document are not checked.
+func (r *required) Check(v reflect.Value) error {
+	if !r.Enabled {
+		return nil
+	}
+
+	var path []string
+	for _, k := range r.rootPath {
+		path = append(path, strings.ToLower(k))
+	}
+	if len(path) > 0 && !r.has(path) {
+		return nil
+	}
+
+	return r.check(path, "", v)
+}
+
+// fieldKey returns the path of the key of the document decoded into the field
+// named fieldName, and whether it is present. The names of fields tagged with
+// the "dotted" option stand for several keys.
+func (r *required) fieldKey(path []string, fieldName string, opts tagOptions) ([]string, bool) {
+	names := []string{fieldName}
+	if r.keyMapper != nil {
+		names = append(names, r.keyMapper.Key(fieldName))
+	}
+	names = append(names, opts.aliases...)
+
+	for _, n := range names {
+		p := path[:len(path):len(path)]
+		if opts.dotted {
+			for _, part := range strings.Split(n, ".") {
+				p = append(p, strings.ToLower(part))
+			}
+		} else {
+			p = append(p, strings.ToLower(n))
+		}
+		if r.has(p) {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+func (r *required) check(path []string, name string, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		var err error
+
+		forEachField(v.Type(), func(fieldName string, idx []int, opts tagOptions) {
+			if err != nil || opts.remaining || opts.source || opts.headerComment {
+				return
+			}
+
+			n := fieldName
+			if name != "" {
+				n = name + "." + fieldName
+			}
+
+			p, ok := r.fieldKey(path, fieldName, opts)
+			if !ok {
+				if opts.required {
+					err = requiredFieldError(n, v.Type().FieldByIndex(idx).Type)
+				}
+				return
+			}
+
+			f, ok := fieldByIndex(v, idx)
+			if ok {
+				err = r.check(p, n, f)
+			}
+		})
+
+		return err
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Array:
+		default:
+			return nil
+		}
+
+		for i := 0; i < v.Len(); i++ {
+			p := append(path[:len(path):len(path)], requiredIndex(i))
+			err := r.check(p, name+"["+strconv.Itoa(i)+"]", v.Index(i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func requiredFieldError(name string, t reflect.Type) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	isTable := t.Kind() == reflect.Map ||
+		(t.Kind() == reflect.Struct && !reflect.PtrTo(t).Implements(textUnmarshalerType))
+	if isTable {
+		return fmt.Errorf("toml: missing section `%s`", name)
+	}
+
+	return fmt.Errorf("toml: missing key `%s`", name)
+}
+
+// allocFieldByIndex is like reflect.Value.FieldByIndex, but allocates the nil
+// embedded pointers it traverses. It returns false when one of them cannot be
+// set, like a pointer to an unexported struct type.
+func allocFieldByIndex(v reflect.Value, idx []int) (reflect.Value, bool) {
+	for i, x := range idx {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but returns false instead of
+// panicking when traversing a nil embedded pointer.
+func fieldByIndex(v reflect.Value, idx []int) (reflect.Value, bool) {
+	for i, x := range idx {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
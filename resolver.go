@@ -0,0 +1,80 @@
+package toml
+
+import (
+	"reflect"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+)
+
+// FieldResolver is implemented by types that are decoded through functions
+// setting their fields, instead of through their exported struct fields. It is
+// meant for generated types that only provide getters and setters, like
+// protocol buffer messages.
+//
+// When decoding a table into a FieldResolver, ResolveTOMLField is called with
+// each key of the table. It returns the function that sets the corresponding
+// field, or false if the key does not correspond to any field. Keys without a
+// field are handled like unknown struct fields.
+//
+// The value provided to the set function has the type it would have when
+// decoding into an interface{}. When the value of a key is a table, the set
+// function receives a map[string]interface{}. Tables and arrays of tables can
+// span multiple expressions of the document: in that case, the set function is
+// called again with the whole value of the key every time it is extended.
+//
+// FieldResolver must be implemented with a pointer receiver.
+type FieldResolver interface {
+	ResolveTOMLField(key string) (set func(value interface{}) error, ok bool)
+}
+
+var fieldResolverType = reflect.TypeOf(new(FieldResolver)).Elem()
+
+// fieldResolver returns the FieldResolver implemented by the address of v, if
+// any.
+func fieldResolver(v reflect.Value) (FieldResolver, bool) {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface || !v.CanAddr() {
+		return nil, false
+	}
+
+	p := v.Addr()
+	if !p.Type().Implements(fieldResolverType) {
+		return nil, false
+	}
+
+	return p.Interface().(FieldResolver), true
+}
+
+// handleResolverPart decodes the key of a FieldResolver. The values of the
+// keys of r are accumulated in a map, decoded with next, and the set function
+// of the key is called with the result.
+func (d *decoder) handleResolverPart(key ast.Iterator, r FieldResolver, next func(v reflect.Value) (reflect.Value, error)) (reflect.Value, error) {
+	k := string(key.Node().Data)
+
+	set, ok := r.ResolveTOMLField(k)
+	if !ok {
+		d.skipUntilTable = true
+		return reflect.Value{}, nil
+	}
+
+	if d.resolved == nil {
+		d.resolved = map[FieldResolver]map[string]interface{}{}
+	}
+
+	values, ok := d.resolved[r]
+	if !ok {
+		values = map[string]interface{}{}
+		d.resolved[r] = values
+	}
+
+	_, err := next(reflect.ValueOf(values))
+	if err != nil || d.skipUntilTable {
+		return reflect.Value{}, err
+	}
+
+	err = set(values[k])
+	if err != nil {
+		return reflect.Value{}, newDecodeError(key.Node().Data, "cannot set field %s: %w", k, err)
+	}
+
+	return reflect.Value{}, nil
+}
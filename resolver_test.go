@@ -0,0 +1,121 @@
+package toml_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+// message mimics a generated type that is only accessible through setters.
+type message struct {
+	name   string
+	ports  []int64
+	labels map[string]interface{}
+	peers  []interface{}
+}
+
+func (m *message) ResolveTOMLField(key string) (func(interface{}) error, bool) {
+	switch key {
+	case "name":
+		return func(v interface{}) error {
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("expected a string, not %T", v)
+			}
+			m.name = s
+			return nil
+		}, true
+	case "ports":
+		return func(v interface{}) error {
+			m.ports = nil
+			for _, p := range v.([]interface{}) {
+				m.ports = append(m.ports, p.(int64))
+			}
+			return nil
+		}, true
+	case "labels":
+		return func(v interface{}) error {
+			m.labels = v.(map[string]interface{})
+			return nil
+		}, true
+	case "peers":
+		return func(v interface{}) error {
+			m.peers = v.([]interface{})
+			return nil
+		}, true
+	}
+	return nil, false
+}
+
+func TestUnmarshalFieldResolver(t *testing.T) {
+	doc := `
+name = "a"
+ports = [1, 2]
+unknown = true
+labels.env = "prod"
+
+[labels.team]
+owner = "x"
+
+[[peers]]
+name = "b"
+
+[[peers]]
+name = "c"
+`
+
+	var m message
+	err := toml.Unmarshal([]byte(doc), &m)
+	require.NoError(t, err)
+	require.Equal(t, "a", m.name)
+	require.Equal(t, []int64{1, 2}, m.ports)
+	require.Equal(t, map[string]interface{}{
+		"env":  "prod",
+		"team": map[string]interface{}{"owner": "x"},
+	}, m.labels)
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"name": "b"},
+		map[string]interface{}{"name": "c"},
+	}, m.peers)
+}
+
+func TestUnmarshalFieldResolverNested(t *testing.T) {
+	type config struct {
+		Main     message
+		Messages []*message
+	}
+
+	doc := `
+main = { name = "a", ports = [1] }
+
+[[messages]]
+name = "b"
+
+[[messages]]
+name = "c"
+`
+
+	var c config
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+	require.Equal(t, "a", c.Main.name)
+	require.Equal(t, []int64{1}, c.Main.ports)
+	require.Len(t, c.Messages, 2)
+	require.Equal(t, "b", c.Messages[0].name)
+	require.Equal(t, "c", c.Messages[1].name)
+}
+
+func TestUnmarshalFieldResolverErrors(t *testing.T) {
+	err := toml.Unmarshal([]byte(`name = 1`), &message{})
+	var de *toml.DecodeError
+	require.True(t, errors.As(err, &de))
+	require.Equal(t, "toml: cannot set field name: expected a string, not int64", de.Error())
+
+	err = toml.NewDecoder(strings.NewReader(`unknown = 1`)).DisallowUnknownFields().Decode(&message{})
+	var se *toml.StrictMissingError
+	require.True(t, errors.As(err, &se))
+}
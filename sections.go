@@ -0,0 +1,106 @@
+package toml
+
+import (
+	"strconv"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+)
+
+// sections keeps track of the table containing the expression being decoded,
+// to provide context in errors.
+//
+// Only the key of the current table is recorded while decoding: its
+// description is built when an error needs it.
+type sections struct {
+	// Kind of the header of the current table, ast.Invalid at the root of
+	// the document.
+	kind ast.Kind
+
+	// Parts of the key of the current table, separated by
+	// requiredPathSeparator, and the offset in key of the end of each part.
+	key  []byte
+	ends []int
+
+	// Element of the current array table, starting at 1.
+	element int
+
+	// Number of elements of each array table of the current branch of the
+	// document, by key.
+	counts map[string]*int
+}
+
+// Enter records node as the current table when it is a table header.
+func (s *sections) Enter(node *ast.Node) {
+	if node.Kind != ast.Table && node.Kind != ast.ArrayTable {
+		return
+	}
+
+	s.kind = node.Kind
+	s.key = s.key[:0]
+	s.ends = s.ends[:0]
+
+	it := node.Key()
+	for it.Next() {
+		if len(s.key) > 0 {
+			s.key = append(s.key, requiredPathSeparator...)
+		}
+		s.key = append(s.key, it.Node().Data...)
+		s.ends = append(s.ends, len(s.key))
+	}
+
+	if node.Kind != ast.ArrayTable {
+		return
+	}
+
+	if s.counts == nil {
+		s.counts = map[string]*int{}
+	}
+
+	// A new element restarts the count of the array tables it contains.
+	k := s.key
+	for x, n := range s.counts {
+		if len(x) > len(k) && x[len(k)] == requiredPathSeparator[0] && x[:len(k)] == string(k) {
+			*n = 0
+		}
+	}
+
+	n := s.counts[string(k)]
+	if n == nil {
+		n = new(int)
+		s.counts[string(k)] = n
+	}
+	*n++
+	s.element = *n
+}
+
+// String returns the description of the current table, like "[owner]" or
+// "[[servers]] element 2", or an empty string at the root of the document.
+// Parts of the key are quoted like the Encoder does.
+func (s *sections) String() string {
+	if s.kind == ast.Invalid {
+		return ""
+	}
+
+	var enc Encoder
+	b := []byte{'['}
+	if s.kind == ast.ArrayTable {
+		b = append(b, '[')
+	}
+
+	start := 0
+	for i, end := range s.ends {
+		if i > 0 {
+			b = append(b, '.')
+		}
+		b = enc.encodeKey(b, string(s.key[start:end]))
+		start = end + len(requiredPathSeparator)
+	}
+
+	b = append(b, ']')
+	if s.kind == ast.ArrayTable {
+		b = append(b, "] element "...)
+		b = strconv.AppendInt(b, int64(s.element), 10)
+	}
+
+	return string(b)
+}
@@ -0,0 +1,153 @@
+package toml_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeErrorTable(t *testing.T) {
+	type port struct {
+		Number int
+	}
+
+	type server struct {
+		Name  string
+		Ports []port
+	}
+
+	type config struct {
+		Title   string
+		Owner   struct{ Name string }
+		Servers []server
+	}
+
+	examples := []struct {
+		desc  string
+		doc   string
+		table string
+	}{
+		{
+			desc:  "root",
+			doc:   `title = 1`,
+			table: "",
+		},
+		{
+			desc:  "table",
+			doc:   "title = 't'\n[owner]\nname = 1",
+			table: "[owner]",
+		},
+		{
+			desc: "array table",
+			doc: `[[servers]]
+name = "a"
+
+[[servers]]
+name = 2`,
+			table: "[[servers]] element 2",
+		},
+		{
+			desc: "nested array table",
+			doc: `[[servers]]
+[[servers.ports]]
+number = 1
+[[servers.ports]]
+number = 2
+
+[[servers]]
+[[servers.ports]]
+number = 1e3`,
+			table: "[[servers.ports]] element 1",
+		},
+		{
+			desc:  "syntax error",
+			doc:   "[owner]\nname = ",
+			table: "[owner]",
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var c config
+			err := toml.Unmarshal([]byte(e.doc), &c)
+			require.Error(t, err)
+
+			if e.table == "" {
+				require.NotContains(t, err.Error(), "(in ")
+			} else {
+				require.True(t, strings.HasSuffix(err.Error(), " (in "+e.table+")"), err.Error())
+			}
+
+			var de *toml.DecodeError
+			if errors.As(err, &de) {
+				require.Equal(t, e.table, de.Table())
+			}
+		})
+	}
+}
+
+func TestDecodeErrorTableString(t *testing.T) {
+	doc := "[[servers]]\n[[servers]]\nname = 1__0"
+
+	err := toml.Unmarshal([]byte(doc), &map[string]interface{}{})
+
+	var de *toml.DecodeError
+	require.True(t, errors.As(err, &de))
+	require.Equal(t, "[[servers]] element 2", de.Table())
+	require.Equal(t, "toml: number must have at least one digit between underscores (in [[servers]] element 2)", de.Error())
+
+	expected := `1| [[servers]]
+2| [[servers]]
+3| name = 1__0
+ |         ~~ number must have at least one digit between underscores (in [[servers]] element 2)`
+	require.Equal(t, expected, de.String())
+}
+
+func TestDecodeErrorTableHeader(t *testing.T) {
+	examples := []struct {
+		desc  string
+		doc   string
+		table string
+	}{
+		{
+			desc:  "redefined table",
+			doc:   "[x]\nz = 2\n[x.y]\n[x]",
+			table: "[x]",
+		},
+		{
+			desc:  "table redefining an array table",
+			doc:   "[[a]]\n[a]",
+			table: "[a]",
+		},
+		{
+			desc:  "array table redefining a table",
+			doc:   "[a]\n[b]\n[[a]]",
+			table: "[[a]] element 1",
+		},
+		{
+			desc:  "quoted key",
+			doc:   "[a.\"b.c\"]\nd = 1__0",
+			table: "[a.'b.c']",
+		},
+		{
+			desc:  "nested array table",
+			doc:   "[[a]]\n[[a.b]]\n[[a]]\n[[a.b]]\n[[a.b]]\nc = 1__0",
+			table: "[[a.b]] element 2",
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			err := toml.Unmarshal([]byte(e.doc), &map[string]interface{}{})
+
+			var de *toml.DecodeError
+			require.True(t, errors.As(err, &de), "%v", err)
+			require.Equal(t, e.table, de.Table())
+		})
+	}
+}
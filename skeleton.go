@@ -0,0 +1,195 @@
+package toml
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// Skeleton returns a TOML document showing every key that v can contain. It is
+// meant to help writing configuration files for the type of v.
+//
+// Each key is preceded by a comment with its TOML type, after the comment from
+// its "comment" struct tag, if any. Values come from v: they are the defaults
+// of the document. Nil pointers are replaced by pointers to zero values, and
+// empty slices and maps by one example element. Fields tagged with omitempty
+// are emitted anyway.
+//
+// Recursive types are only expanded once.
+func Skeleton(v interface{}) ([]byte, error) {
+	sample := skeletonValue(reflect.ValueOf(v), map[reflect.Type]bool{})
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.skeleton = true
+
+	err := enc.Encode(sample.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// skeletonValue returns a copy of v where nil pointers, empty slices, and empty
+// maps are filled with an example value. Types in seen are currently being
+// expanded, and are not expanded again.
+func skeletonValue(v reflect.Value, seen map[reflect.Type]bool) reflect.Value {
+	t := v.Type()
+
+	if isNullType(t) {
+		x := reflect.New(t).Elem()
+		x.Set(v)
+		x.Field(1).SetBool(true)
+		return x
+	}
+
+	if isSkeletonLeaf(t) {
+		return v
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		if seen[t] {
+			return v
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		elem := reflect.New(t.Elem()).Elem()
+		if !v.IsNil() {
+			elem = v.Elem()
+		}
+
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(skeletonValue(elem, seen))
+		return ptr
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+
+		x := reflect.New(t).Elem()
+		x.Set(skeletonValue(v.Elem(), seen))
+		return x
+	case reflect.Struct:
+		if seen[t] {
+			return v
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		x := reflect.New(t).Elem()
+		x.Set(v)
+		for i := 0; i < t.NumField(); i++ {
+			f := x.Field(i)
+			if f.CanSet() {
+				f.Set(skeletonValue(f, seen))
+			}
+		}
+		return x
+	case reflect.Slice:
+		if seen[t.Elem()] {
+			return v
+		}
+
+		elem := reflect.New(t.Elem()).Elem()
+		if v.Len() > 0 {
+			elem = v.Index(0)
+		}
+
+		x := reflect.MakeSlice(t, 1, 1)
+		x.Index(0).Set(skeletonValue(elem, seen))
+		return x
+	case reflect.Array:
+		x := reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			x.Index(i).Set(skeletonValue(v.Index(i), seen))
+		}
+		return x
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || seen[t.Elem()] {
+			return v
+		}
+
+		x := reflect.MakeMap(t)
+		if v.Len() == 0 {
+			k := reflect.ValueOf("key").Convert(t.Key())
+			x.SetMapIndex(k, skeletonValue(reflect.New(t.Elem()).Elem(), seen))
+			return x
+		}
+
+		iter := v.MapRange()
+		for iter.Next() {
+			x.SetMapIndex(iter.Key(), skeletonValue(iter.Value(), seen))
+		}
+		return x
+	default:
+		return v
+	}
+}
+
+// isSkeletonLeaf returns true if values of type t are emitted as a single
+// TOML value.
+func isSkeletonLeaf(t reflect.Type) bool {
+	switch t {
+	case timeType, localDateType, localTimeType, localDateTimeType:
+		return true
+	}
+	if isAtomicType(t) {
+		return true
+	}
+
+	return t.Kind() != reflect.Ptr && (t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType))
+}
+
+// tomlTypeName returns the name of the TOML type used to represent values of
+// type t.
+func tomlTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if isNullType(t) {
+		return tomlTypeName(t.Field(0).Type)
+	}
+	if isAtomicType(t) {
+		return tomlTypeName(atomicTypes[t].value)
+	}
+
+	switch t {
+	case timeType:
+		return "offset date-time"
+	case localDateType:
+		return "local date"
+	case localTimeType:
+		return "local time"
+	case localDateTimeType:
+		return "local date-time"
+	}
+
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Slice, reflect.Array:
+		elem := tomlTypeName(t.Elem())
+		if elem == "table" {
+			return "array of tables"
+		}
+		return "array of " + elem
+	case reflect.Map, reflect.Struct:
+		return "table"
+	default:
+		return "any"
+	}
+}
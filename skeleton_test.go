@@ -0,0 +1,83 @@
+package toml_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkeleton(t *testing.T) {
+	type server struct {
+		Host string `toml:"host" comment:"Address to bind"`
+		Port int    `toml:"port"`
+	}
+
+	type node struct {
+		Name     string  `toml:"name"`
+		Children []*node `toml:"children"`
+	}
+
+	type config struct {
+		Title   string            `toml:"title"`
+		Debug   bool              `toml:"debug,omitempty"`
+		Ratio   float64           `toml:"ratio"`
+		Tags    []string          `toml:"tags"`
+		Created time.Time         `toml:"created"`
+		Server  *server           `toml:"server"`
+		Labels  map[string]string `toml:"labels"`
+		Backups []server          `toml:"backups"`
+		Tree    node              `toml:"tree"`
+	}
+
+	b, err := toml.Skeleton(config{Title: "my app"})
+	require.NoError(t, err)
+
+	expected := `# string
+title = 'my app'
+# boolean
+debug = false
+# float
+ratio = 0.0
+# array of string
+tags = ['']
+# offset date-time
+created = 0001-01-01T00:00:00Z
+# table
+[server]
+# Address to bind
+# string
+host = ''
+# integer
+port = 0
+
+# table
+[labels]
+key = ''
+
+# array of tables
+[[backups]]
+# Address to bind
+# string
+host = ''
+# integer
+port = 0
+
+# table
+[tree]
+# string
+name = ''
+# array of tables
+[[tree.children]]
+# string
+name = ''
+# array of tables
+children = []
+`
+	equalStringsIgnoreNewlines(t, expected, string(b))
+
+	var c config
+	err = toml.Unmarshal(b, &c)
+	require.NoError(t, err)
+}
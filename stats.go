@@ -0,0 +1,100 @@
+package toml
+
+import (
+	"github.com/pelletier/go-toml/v2/internal/ast"
+)
+
+// DecodeStats are counters collected by a Decoder with EnableStats, to help
+// understanding why a document is slow to decode or large.
+type DecodeStats struct {
+	// Number of tokens parsed: each part of a key, value, array and inline
+	// table, as well as each key-value and table header.
+	Tokens int
+
+	// Number of table and array table headers.
+	Tables int
+
+	// Number of key-values, including the ones inside inline tables.
+	Keys int
+
+	// Maximum nesting of tables, inline tables and arrays reached by a value,
+	// not counting the root table. For example, the value of a.b = [1] has a
+	// depth of 2.
+	MaxDepth int
+
+	// Size of the document, in bytes.
+	Bytes int
+}
+
+// collectStats records the expression node in the stats, if enabled.
+func (d *decoder) collectStats(expr *ast.Node) {
+	s := d.stats
+	if s == nil {
+		return
+	}
+
+	s.Tokens += countNodes(expr)
+
+	switch expr.Kind {
+	case ast.Table, ast.ArrayTable:
+		s.Tables++
+		d.statsDepth = countKeyParts(expr)
+		if d.statsDepth > s.MaxDepth {
+			s.MaxDepth = d.statsDepth
+		}
+	case ast.KeyValue:
+		depth := d.statsDepth + s.keyValueDepth(expr)
+		if depth > s.MaxDepth {
+			s.MaxDepth = depth
+		}
+	}
+}
+
+// keyValueDepth counts the key-values of the node, and returns the depth of
+// its value relative to the table containing it.
+func (s *DecodeStats) keyValueDepth(node *ast.Node) int {
+	s.Keys++
+	return countKeyParts(node) - 1 + s.valueDepth(node.Value())
+}
+
+func (s *DecodeStats) valueDepth(value *ast.Node) int {
+	max := 0
+	it := value.Children()
+
+	switch value.Kind {
+	case ast.InlineTable:
+		for it.Next() {
+			if d := s.keyValueDepth(it.Node()); d > max {
+				max = d
+			}
+		}
+	case ast.Array:
+		for it.Next() {
+			if d := s.valueDepth(it.Node()); d > max {
+				max = d
+			}
+		}
+	default:
+		return 0
+	}
+
+	return max + 1
+}
+
+func countKeyParts(node *ast.Node) int {
+	n := 0
+	it := node.Key()
+	for it.Next() {
+		n++
+	}
+	return n
+}
+
+func countNodes(node *ast.Node) int {
+	n := 1
+	it := node.Children()
+	for it.Next() {
+		n += countNodes(it.Node())
+	}
+	return n
+}
@@ -0,0 +1,39 @@
+package toml_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoderStats(t *testing.T) {
+	doc := `title = "x"
+a.b = [1, [2]]
+
+[server.http]
+port = 80
+tls = { cert = "c", key = "k" }
+
+[[workers]]
+name = "w"
+`
+
+	var v map[string]interface{}
+	d := toml.NewDecoder(strings.NewReader(doc)).EnableStats(true)
+	err := d.Decode(&v)
+	require.NoError(t, err)
+
+	s := d.Stats()
+	require.Equal(t, 2, s.Tables)
+	require.Equal(t, 7, s.Keys)
+	require.Equal(t, 3, s.MaxDepth)
+	require.Equal(t, len(doc), s.Bytes)
+	require.Equal(t, 30, s.Tokens)
+
+	d = toml.NewDecoder(strings.NewReader(doc))
+	err = d.Decode(&v)
+	require.NoError(t, err)
+	require.Equal(t, toml.DecodeStats{}, d.Stats())
+}
@@ -0,0 +1,78 @@
+package toml
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// DocumentStream reads a sequence of independent TOML documents from an input
+// stream. Documents are separated by lines containing only the separator:
+//
+//   id = 1
+//   ---
+//   id = 2
+//
+// Only one document is held in memory at a time, which makes it suitable for
+// append-only logs of TOML documents.
+type DocumentStream struct {
+	r   *bufio.Reader
+	sep []byte
+
+	// Set once the end of the input has been reached.
+	done bool
+}
+
+// NewDocumentStream returns a DocumentStream reading from r the documents
+// separated by lines equal to sep.
+func NewDocumentStream(r io.Reader, sep []byte) *DocumentStream {
+	return &DocumentStream{
+		r:   bufio.NewReader(r),
+		sep: sep,
+	}
+}
+
+// Next decodes the next document of the stream into v, like Unmarshal.
+// Documents are parsed and validated independently: positions of errors are
+// relative to the beginning of the document. An empty document between two
+// separators is decoded as an empty table. Next returns io.EOF when there are no
+// more documents.
+func (s *DocumentStream) Next(v interface{}) error {
+	doc, err := s.readDocument()
+	if err != nil {
+		return err
+	}
+
+	return Unmarshal(doc, v)
+}
+
+// readDocument returns the lines until the next separator or the end of the
+// input.
+func (s *DocumentStream) readDocument() ([]byte, error) {
+	if s.done {
+		return nil, io.EOF
+	}
+
+	var doc []byte
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("toml: %w", err)
+		}
+
+		if bytes.Equal(bytes.TrimRight(line, "\r\n"), s.sep) {
+			return doc, nil
+		}
+
+		doc = append(doc, line...)
+
+		if err == io.EOF {
+			s.done = true
+			if len(doc) == 0 {
+				return nil, io.EOF
+			}
+			return doc, nil
+		}
+	}
+}
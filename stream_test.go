@@ -0,0 +1,63 @@
+package toml_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentStream(t *testing.T) {
+	type event struct {
+		ID   int
+		Name string
+	}
+
+	input := "id = 1\nname = 'a'\n---\r\n---\nid = 3\n\n---\n"
+
+	s := toml.NewDocumentStream(strings.NewReader(input), []byte("---"))
+
+	var events []event
+	for {
+		var e event
+		err := s.Next(&e)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		events = append(events, e)
+	}
+
+	require.Equal(t, []event{{ID: 1, Name: "a"}, {}, {ID: 3}}, events)
+	require.Equal(t, io.EOF, s.Next(&event{}))
+}
+
+func TestDocumentStreamErrors(t *testing.T) {
+	input := "a = 1\n--\nb = \nc = 2\n--\na = 1\nb = ["
+
+	s := toml.NewDocumentStream(strings.NewReader(input), []byte("--"))
+
+	var m map[string]interface{}
+	require.NoError(t, s.Next(&m))
+
+	err := s.Next(&m)
+	var de *toml.DecodeError
+	require.True(t, errors.As(err, &de))
+	row, _ := de.Position()
+	require.Equal(t, 1, row)
+
+	err = s.Next(&map[string]interface{}{})
+	require.True(t, errors.As(err, &de))
+	row, _ = de.Position()
+	require.Equal(t, 2, row)
+
+	require.Equal(t, io.EOF, s.Next(&m))
+}
+
+func TestDocumentStreamEmpty(t *testing.T) {
+	s := toml.NewDocumentStream(strings.NewReader(""), []byte("---"))
+	require.Equal(t, io.EOF, s.Next(&map[string]interface{}{}))
+}
@@ -9,6 +9,10 @@ import (
 type strict struct {
 	Enabled bool
 
+	// Called with the key of each missing field or table, and the node of the
+	// key-value or table. A non-nil error stops decoding.
+	Unknown func(key Key, node *ast.Node) error
+
 	// Tracks the current key being processed.
 	key tracker.KeyTracker
 
@@ -16,7 +20,7 @@ type strict struct {
 }
 
 func (s *strict) EnterTable(node *ast.Node) {
-	if !s.Enabled {
+	if !s.tracking() {
 		return
 	}
 
@@ -24,7 +28,7 @@ func (s *strict) EnterTable(node *ast.Node) {
 }
 
 func (s *strict) EnterArrayTable(node *ast.Node) {
-	if !s.Enabled {
+	if !s.tracking() {
 		return
 	}
 
@@ -32,7 +36,7 @@ func (s *strict) EnterArrayTable(node *ast.Node) {
 }
 
 func (s *strict) EnterKeyValue(node *ast.Node) {
-	if !s.Enabled {
+	if !s.tracking() {
 		return
 	}
 
@@ -40,35 +44,84 @@ func (s *strict) EnterKeyValue(node *ast.Node) {
 }
 
 func (s *strict) ExitKeyValue(node *ast.Node) {
-	if !s.Enabled {
+	if !s.tracking() {
 		return
 	}
 
 	s.key.Pop(node)
 }
 
-func (s *strict) MissingTable(node *ast.Node) {
-	if !s.Enabled {
-		return
+// tracking reports whether the keys need to be tracked, either to report the
+// missing fields or to call Unknown.
+func (s *strict) tracking() bool {
+	return s.Enabled || s.Unknown != nil
+}
+
+func (s *strict) MissingTable(node *ast.Node) error {
+	return s.addMissing(node, "missing table")
+}
+
+func (s *strict) MissingField(node *ast.Node) error {
+	return s.addMissing(node, "missing field")
+}
+
+func (s *strict) addMissing(node *ast.Node, message string) error {
+	if !s.tracking() {
+		return nil
 	}
 
-	s.missing = append(s.missing, decodeError{
-		highlight: keyLocation(node),
-		message:   "missing table",
-		key:       s.key.Key(),
-	})
+	if s.Unknown != nil {
+		err := s.Unknown(s.key.Key(), node)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.Enabled {
+		s.missing = append(s.missing, decodeError{
+			highlight: keyLocation(node),
+			message:   message,
+			key:       s.key.Key(),
+		})
+	}
+
+	return nil
 }
 
-func (s *strict) MissingField(node *ast.Node) {
+// Known removes the missing fields that are under one of the given keys of
+// the table at parent.
+func (s *strict) Known(parent []string, keys []string) {
 	if !s.Enabled {
 		return
 	}
 
-	s.missing = append(s.missing, decodeError{
-		highlight: keyLocation(node),
-		message:   "missing field",
-		key:       s.key.Key(),
-	})
+	missing := s.missing[:0]
+	for _, e := range s.missing {
+		if !isUnderKeys(e.key, parent, keys) {
+			missing = append(missing, e)
+		}
+	}
+	s.missing = missing
+}
+
+func isUnderKeys(key Key, parent []string, keys []string) bool {
+	if len(key) <= len(parent) {
+		return false
+	}
+
+	for i, k := range parent {
+		if key[i] != k {
+			return false
+		}
+	}
+
+	for _, k := range keys {
+		if key[len(parent)] == k {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (s *strict) Error(doc []byte) error {
@@ -0,0 +1,222 @@
+package toml
+
+import (
+	"bytes"
+	"reflect"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+)
+
+// TableUnmarshaler is implemented by types that decode a TOML table
+// themselves, once all its keys are known. UnmarshalTOMLTable receives the
+// keys of the table with their undecoded values, regardless of the order in
+// which they appear in the document, so that a key like `type` can decide how
+// the others are decoded:
+//
+//   func (s *Shape) UnmarshalTOMLTable(table map[string]toml.RawValue) error {
+//     var kind string
+//     err := toml.UnmarshalValue(table["type"], &kind)
+//     if err != nil {
+//       return err
+//     }
+//     switch kind {
+//     case "circle":
+//       s.Value = &Circle{}
+//     case "square":
+//       s.Value = &Square{}
+//     default:
+//       return fmt.Errorf("unknown shape %q", kind)
+//     }
+//     delete(table, "type")
+//     return toml.UnmarshalTable(table, s.Value)
+//   }
+//
+// Sub-tables and arrays of tables are passed as a RawValue holding a table.
+// UnmarshalTOMLTable is called once for an inline table, and once for a table
+// header followed by the headers of its sub-tables, or for consecutive dotted
+// keys. The keys of a table spread over several places of the document lead to
+// one call for each of them.
+//
+// TableUnmarshaler takes precedence over the struct fields and map entries of
+// the type. Unmarshaler takes precedence over TableUnmarshaler for inline
+// tables. TableUnmarshaler must be implemented with a pointer receiver.
+type TableUnmarshaler interface {
+	UnmarshalTOMLTable(table map[string]RawValue) error
+}
+
+// UnmarshalTable decodes the table made of the entries of table into v,
+// following the rules of Unmarshal.
+func UnmarshalTable(table map[string]RawValue, v interface{}) error {
+	b, err := Marshal(table)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(b, v)
+}
+
+// tableUnmarshaler returns the TableUnmarshaler implemented by v, allocating
+// it when v is a nil pointer.
+func tableUnmarshaler(v reflect.Value) (TableUnmarshaler, bool) {
+	flags := cachedTypeFlags(v.Type())
+	if v.Kind() == reflect.Ptr && flags&typeTableUnmarshaler != 0 {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return v.Interface().(TableUnmarshaler), true
+	}
+	if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface && v.CanAddr() && flags&typePtrTableUnmarshaler != 0 {
+		return v.Addr().Interface().(TableUnmarshaler), true
+	}
+	return nil, false
+}
+
+// handleTableUnmarshaler decodes the table or array table expression being
+// decoded, its key-values and the headers of its sub-tables that follow it,
+// into the TableUnmarshaler u.
+func (d *decoder) handleTableUnmarshaler(key ast.Iterator, u TableUnmarshaler, arrayTable bool) error {
+	// The expression node is reused by the parser for the expressions that
+	// follow.
+	highlight := keyLocation(d.expr())
+	all := rawKeyParts(d.expr().Key())
+	prefix := all[:len(all)-len(rawKeyParts(key))]
+
+	raw := reflect.New(rawValueType).Elem()
+	_, err := d.handleRawTable(key, raw, arrayTable)
+	if err != nil {
+		return err
+	}
+
+	for d.nextExpr() {
+		expr := d.expr()
+		if expr.Kind == ast.KeyValue || !isUnderKey(rawKeyParts(expr.Key()), prefix) {
+			d.stashExpr()
+			break
+		}
+
+		d.sections.Enter(expr)
+
+		err := d.countKeys(expr)
+		if err != nil {
+			return err
+		}
+
+		err = d.seen.CheckExpression(expr)
+		if err != nil {
+			return err
+		}
+
+		it := expr.Key()
+		for range prefix {
+			it.Next()
+		}
+
+		_, err = d.handleRawTable(it, raw, expr.Kind == ast.ArrayTable)
+		if err != nil {
+			return err
+		}
+	}
+
+	return d.callTableUnmarshaler(highlight, raw.Interface().(RawValue), u)
+}
+
+// handleTableUnmarshalerKeyValue decodes the key-value being decoded, given
+// the parts of its key after the TableUnmarshaler u, and the key-values with
+// the same prefix that follow it, into u.
+func (d *decoder) handleTableUnmarshalerKeyValue(parts [][]byte, value *ast.Node, u TableUnmarshaler) error {
+	highlight := d.rawValue(value)
+
+	var raw RawValue
+	raw.Table = true
+	raw.insertKeyValue(appendRawKeyValue(nil, parts, highlight))
+
+	// Only the key-values of the document can be grouped, not the ones of an
+	// inline table.
+	first := d.expr()
+	if first.Kind == ast.KeyValue && first.Value() == value {
+		all := rawKeyParts(first.Key())
+		prefix := all[:len(all)-len(parts)]
+
+		for d.nextExpr() {
+			expr := d.expr()
+			if expr.Kind != ast.KeyValue {
+				d.stashExpr()
+				break
+			}
+			keyParts := rawKeyParts(expr.Key())
+			if !isUnderKey(keyParts, prefix) {
+				d.stashExpr()
+				break
+			}
+
+			err := d.countKeys(expr)
+			if err != nil {
+				return err
+			}
+
+			err = d.seen.CheckExpression(expr)
+			if err != nil {
+				return err
+			}
+
+			d.required.KeyValue(expr)
+
+			raw.insertKeyValue(appendRawKeyValue(nil, keyParts[len(prefix):], d.rawValue(expr.Value())))
+		}
+	}
+
+	return d.callTableUnmarshaler(highlight, raw, u)
+}
+
+// unmarshalTableUnmarshaler decodes the inline table itable into the
+// TableUnmarshaler u.
+func (d *decoder) unmarshalTableUnmarshaler(itable *ast.Node, u TableUnmarshaler) error {
+	m := map[string]RawValue{}
+	err := d.unmarshalInlineTable(itable, reflect.ValueOf(m))
+	if err != nil {
+		return err
+	}
+
+	err = u.UnmarshalTOMLTable(m)
+	if err != nil {
+		return d.nodeError(d.rawValue(itable), err)
+	}
+
+	return nil
+}
+
+// callTableUnmarshaler passes the table held by raw to u. Errors are reported
+// at highlight.
+func (d *decoder) callTableUnmarshaler(highlight []byte, raw RawValue, u TableUnmarshaler) error {
+	m := map[string]RawValue{}
+	err := Unmarshal(raw.Raw, &m)
+	if err != nil {
+		return newDecodeError(highlight, "%w", err)
+	}
+
+	err = u.UnmarshalTOMLTable(m)
+	if err != nil {
+		return d.nodeError(highlight, err)
+	}
+
+	return nil
+}
+
+// isUnderKey reports whether the key made of parts starts with prefix, and
+// is longer than it.
+func isUnderKey(parts, prefix [][]byte) bool {
+	if len(parts) <= len(prefix) {
+		return false
+	}
+	for i, p := range prefix {
+		if !bytes.Equal(parts[i], p) {
+			return false
+		}
+	}
+	return true
+}
+
+// tableUnmarshalerArrayError reports an array table decoded into a
+// TableUnmarshaler that is not an element of a slice.
+func tableUnmarshalerArrayError(key []byte, v reflect.Value) error {
+	return newDecodeError(key, "cannot store an array table in %s, use a slice", v.Type())
+}
@@ -0,0 +1,114 @@
+package toml_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/require"
+)
+
+type testCircle struct {
+	Radius int
+}
+
+type testSquare struct {
+	Side  int
+	Color struct {
+		Name string
+	}
+}
+
+type testShape struct {
+	Value interface{}
+}
+
+func (s *testShape) UnmarshalTOMLTable(table map[string]toml.RawValue) error {
+	var kind string
+	err := toml.UnmarshalValue(table["type"], &kind)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case "circle":
+		s.Value = &testCircle{}
+	case "square":
+		s.Value = &testSquare{}
+	default:
+		return fmt.Errorf("unknown shape %q", kind)
+	}
+	delete(table, "type")
+	return toml.UnmarshalTable(table, s.Value)
+}
+
+func TestUnmarshalTableUnmarshaler(t *testing.T) {
+	type config struct {
+		Inline  testShape
+		Dotted  testShape
+		Table   *testShape
+		Shapes  []testShape
+		ByName  map[string]testShape
+		Unknown string
+	}
+
+	doc := `
+inline = { radius = 1, type = "circle" }
+dotted.side = 2
+dotted.type = "square"
+unknown = "x"
+
+[table]
+side = 3
+type = "square"
+[table.color]
+name = "red"
+
+[[shapes]]
+radius = 4
+type = "circle"
+[[shapes]]
+type = "square"
+[shapes.color]
+name = "blue"
+
+[byName.c]
+type = "circle"
+radius = 5
+`
+
+	var c config
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+
+	require.Equal(t, &testCircle{Radius: 1}, c.Inline.Value)
+	require.Equal(t, &testSquare{Side: 2}, c.Dotted.Value)
+	square := &testSquare{Side: 3}
+	square.Color.Name = "red"
+	require.Equal(t, square, c.Table.Value)
+	require.Len(t, c.Shapes, 2)
+	require.Equal(t, &testCircle{Radius: 4}, c.Shapes[0].Value)
+	square = &testSquare{}
+	square.Color.Name = "blue"
+	require.Equal(t, square, c.Shapes[1].Value)
+	require.Equal(t, &testCircle{Radius: 5}, c.ByName["c"].Value)
+	require.Equal(t, "x", c.Unknown)
+}
+
+func TestUnmarshalTableUnmarshalerError(t *testing.T) {
+	var c struct {
+		Shape testShape
+	}
+
+	err := toml.Unmarshal([]byte("[shape]\ntype = 'triangle'\n"), &c)
+	require.Error(t, err)
+
+	var de *toml.DecodeError
+	require.ErrorAs(t, err, &de)
+	require.Equal(t, `toml: unknown shape "triangle" (in [shape])`, de.Error())
+	row, col := de.Position()
+	require.Equal(t, 1, row)
+	require.Equal(t, 2, col)
+
+	err = toml.Unmarshal([]byte("[[shape]]\ntype = 'circle'\n"), &c)
+	require.Error(t, err)
+}
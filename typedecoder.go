@@ -0,0 +1,67 @@
+package toml
+
+import (
+	"reflect"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// TypeDecoderFunc builds the Go value of a TOML value, described by node. It
+// can return an invalid reflect.Value to leave the target untouched.
+type TypeDecoderFunc func(node *unstable.Node) (reflect.Value, error)
+
+// RegisterTypeDecoder makes the decoder build the values of type t with fn,
+// instead of decoding them by reflection or through the interfaces they
+// implement. It provides a single place to decode and validate named types:
+//
+//   dec.RegisterTypeDecoder(reflect.TypeOf(Port(0)), func(node *unstable.Node) (reflect.Value, error) {
+//     n, err := strconv.ParseUint(string(node.Raw), 10, 16)
+//     if err != nil || n == 0 {
+//       return reflect.Value{}, node.Errorf("invalid port %s", node.Raw)
+//     }
+//     return reflect.ValueOf(Port(n)), nil
+//   })
+//
+// The value returned by fn must be assignable or convertible to t. Errors
+// created with Node.Errorf are reported at the position of their node. Pointers
+// to t are allocated as usual. Only values are given to fn: tables defined
+// with a header or with dotted keys are decoded into t by reflection.
+func (d *Decoder) RegisterTypeDecoder(t reflect.Type, fn TypeDecoderFunc) *Decoder {
+	if d.typeDecoders == nil {
+		d.typeDecoders = map[reflect.Type]TypeDecoderFunc{}
+	}
+	d.typeDecoders[t] = fn
+	return d
+}
+
+func (d *decoder) tryTypeDecoder(node *ast.Node, v reflect.Value) (bool, error) {
+	fn := d.typeDecoders[v.Type()]
+	if fn == nil {
+		return false, nil
+	}
+
+	raw := d.rawValue(node)
+	n := d.unstableNode(node)
+
+	x, err := fn(&n)
+	if err != nil {
+		return false, d.nodeError(raw, err)
+	}
+
+	if !x.IsValid() {
+		return true, nil
+	}
+
+	t := v.Type()
+	switch {
+	case x.Type().AssignableTo(t):
+		v.Set(x)
+	case x.Type().ConvertibleTo(t):
+		v.Set(x.Convert(t))
+	default:
+		return false, newDecodeError(raw, "type decoder of %s returned a %s", t, x.Type())
+	}
+
+	return true, nil
+}
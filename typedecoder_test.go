@@ -0,0 +1,107 @@
+package toml_test
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"github.com/stretchr/testify/require"
+)
+
+type port uint16
+
+type percent float64
+
+func newTypeDecoder(doc string) *toml.Decoder {
+	dec := toml.NewDecoder(strings.NewReader(doc))
+	dec.RegisterTypeDecoder(reflect.TypeOf(port(0)), func(node *unstable.Node) (reflect.Value, error) {
+		n, err := strconv.ParseUint(string(node.Raw), 10, 16)
+		if err != nil || n == 0 {
+			return reflect.Value{}, node.Errorf("invalid port %s", node.Raw)
+		}
+		return reflect.ValueOf(port(n)), nil
+	})
+	dec.RegisterTypeDecoder(reflect.TypeOf(percent(0)), func(node *unstable.Node) (reflect.Value, error) {
+		s := strings.TrimSuffix(strings.Trim(string(node.Raw), `"'`), "%")
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(f / 100), nil
+	})
+	return dec
+}
+
+func TestDecoderRegisterTypeDecoder(t *testing.T) {
+	type config struct {
+		Port    port
+		Ports   []port
+		Backup  *port
+		Load    percent
+		Unknown percent
+	}
+
+	doc := `
+port = 80
+ports = [81, 82]
+backup = 8080
+load = "50%"
+`
+
+	var c config
+	err := newTypeDecoder(doc).Decode(&c)
+	require.NoError(t, err)
+
+	backup := port(8080)
+	require.Equal(t, config{Port: 80, Ports: []port{81, 82}, Backup: &backup, Load: 0.5}, c)
+
+	examples := []struct {
+		desc   string
+		doc    string
+		err    string
+		line   int
+		column int
+	}{
+		{
+			desc:   "positioned error",
+			doc:    "load = '1%'\nport = 99999",
+			err:    "toml: invalid port 99999",
+			line:   2,
+			column: 8,
+		},
+		{
+			desc:   "other error",
+			doc:    "load = 'x'",
+			err:    `toml: strconv.ParseFloat: parsing "x": invalid syntax`,
+			line:   1,
+			column: 8,
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			err := newTypeDecoder(e.doc).Decode(&config{})
+			require.EqualError(t, err, e.err)
+
+			var derr *toml.DecodeError
+			require.True(t, errors.As(err, &derr))
+			line, column := derr.Position()
+			require.Equal(t, e.line, line)
+			require.Equal(t, e.column, column)
+		})
+	}
+
+	t.Run("wrong type", func(t *testing.T) {
+		dec := toml.NewDecoder(strings.NewReader("port = 1"))
+		dec.RegisterTypeDecoder(reflect.TypeOf(port(0)), func(node *unstable.Node) (reflect.Value, error) {
+			return reflect.ValueOf("1"), nil
+		})
+		err := dec.Decode(&config{})
+		require.EqualError(t, err, "toml: type decoder of toml_test.port returned a string")
+	})
+}
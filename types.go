@@ -1,14 +1,163 @@
 package toml
 
 import (
+	"database/sql"
 	"encoding"
+	"math/big"
+	"net"
+	"net/url"
 	"reflect"
+	"sync/atomic"
 	"time"
 )
 
 var timeType = reflect.TypeOf(time.Time{})
 var textMarshalerType = reflect.TypeOf(new(encoding.TextMarshaler)).Elem()
 var textUnmarshalerType = reflect.TypeOf(new(encoding.TextUnmarshaler)).Elem()
+var rawMarshalerType = reflect.TypeOf(new(RawMarshaler)).Elem()
+var binaryMarshalerType = reflect.TypeOf(new(encoding.BinaryMarshaler)).Elem()
+var binaryUnmarshalerType = reflect.TypeOf(new(encoding.BinaryUnmarshaler)).Elem()
+var bigIntType = reflect.TypeOf(big.Int{})
+var bigFloatType = reflect.TypeOf(big.Float{})
+var urlType = reflect.TypeOf(url.URL{})
+var ipNetType = reflect.TypeOf(net.IPNet{})
 var mapStringInterfaceType = reflect.TypeOf(map[string]interface{}{})
+var mapStringStringType = reflect.TypeOf(map[string]string{})
+var mapStringInt64Type = reflect.TypeOf(map[string]int64{})
+var mapStringIntType = reflect.TypeOf(map[string]int{})
+var mapStringFloat64Type = reflect.TypeOf(map[string]float64{})
+var mapStringBoolType = reflect.TypeOf(map[string]bool{})
 var sliceInterfaceType = reflect.TypeOf([]interface{}{})
 var stringType = reflect.TypeOf("")
+var durationType = reflect.TypeOf(time.Duration(0))
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+var orderedMapType = reflect.TypeOf(OrderedMap{})
+var orderedMapPtrType = reflect.TypeOf(&OrderedMap{})
+var rawValueType = reflect.TypeOf(RawValue{})
+var localDateType = reflect.TypeOf(LocalDate{})
+var localTimeType = reflect.TypeOf(LocalTime{})
+var localDateTimeType = reflect.TypeOf(LocalDateTime{})
+var stringSetterType = reflect.TypeOf(new(stringSetter)).Elem()
+var unmarshalerType = reflect.TypeOf(new(Unmarshaler)).Elem()
+var contextUnmarshalerType = reflect.TypeOf(new(ContextUnmarshaler)).Elem()
+var keySetterType = reflect.TypeOf(new(KeySetter)).Elem()
+var tableUnmarshalerType = reflect.TypeOf(new(TableUnmarshaler)).Elem()
+
+// sqlNullTypes are the database/sql types representing nullable values.
+var sqlNullTypes = map[reflect.Type]bool{
+	reflect.TypeOf(sql.NullBool{}):    true,
+	reflect.TypeOf(sql.NullFloat64{}): true,
+	reflect.TypeOf(sql.NullInt32{}):   true,
+	reflect.TypeOf(sql.NullInt64{}):   true,
+	reflect.TypeOf(sql.NullString{}):  true,
+	reflect.TypeOf(sql.NullTime{}):    true,
+}
+
+// nullable is implemented by the Nullable types.
+type nullable interface {
+	tomlNullable()
+}
+
+var nullableType = reflect.TypeOf(new(nullable)).Elem()
+
+// isNullType returns true if t is one of the database/sql null types or a
+// Nullable. They all have the value as their first field, and a boolean
+// indicating whether the value is present as their second field.
+func isNullType(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && cachedTypeFlags(t)&typeNull != 0
+}
+
+// typeFlags are properties of a type that are checked for most of the values
+// being encoded or decoded, computed once per type.
+type typeFlags uint8
+
+const (
+	// The type is one of the null types, see isNullType.
+	typeNull typeFlags = 1 << iota
+	// The type, or a pointer to it, implements Unmarshaler or
+	// ContextUnmarshaler.
+	typeUnmarshaler
+	typePtrUnmarshaler
+	// The type, or a pointer to it, implements TableUnmarshaler.
+	typeTableUnmarshaler
+	typePtrTableUnmarshaler
+)
+
+var typeFlagsCache atomic.Value // map[reflect.Type]typeFlags
+
+// cachedTypeFlags returns the typeFlags of t. They are computed the first time
+// t is seen.
+func cachedTypeFlags(t reflect.Type) typeFlags {
+	cache, _ := typeFlagsCache.Load().(map[reflect.Type]typeFlags)
+	flags, ok := cache[t]
+	if ok {
+		return flags
+	}
+
+	if sqlNullTypes[t] || (t.Kind() == reflect.Struct && t.Implements(nullableType)) {
+		flags |= typeNull
+	}
+	pt := reflect.PtrTo(t)
+	if t.Implements(unmarshalerType) || t.Implements(contextUnmarshalerType) {
+		flags |= typeUnmarshaler
+	}
+	if pt.Implements(unmarshalerType) || pt.Implements(contextUnmarshalerType) {
+		flags |= typePtrUnmarshaler
+	}
+	if t.Implements(tableUnmarshalerType) {
+		flags |= typeTableUnmarshaler
+	}
+	if pt.Implements(tableUnmarshalerType) {
+		flags |= typePtrTableUnmarshaler
+	}
+
+	newCache := make(map[reflect.Type]typeFlags, len(cache)+1)
+	newCache[t] = flags
+	for k, v := range cache {
+		newCache[k] = v
+	}
+	typeFlagsCache.Store(newCache)
+
+	return flags
+}
+
+// isNull returns true if v is a null type that doesn't hold a value.
+func isNull(v reflect.Value) bool {
+	return isNullType(v.Type()) && !v.Field(1).Bool()
+}
+
+// atomicType describes a sync/atomic type holding a value, like atomic.Int64.
+type atomicType struct {
+	// Type of the value held.
+	value reflect.Type
+
+	// Call the Load and Store methods of the addressable value v.
+	load  func(v reflect.Value) reflect.Value
+	store func(v reflect.Value, x reflect.Value)
+}
+
+// atomicTypes maps the sync/atomic types holding a value to their
+// description. It is filled on the Go versions providing them.
+var atomicTypes = map[reflect.Type]atomicType{}
+
+// isAtomicType returns true if t is one of the sync/atomic types holding a
+// value.
+func isAtomicType(t reflect.Type) bool {
+	_, ok := atomicTypes[t]
+	return ok
+}
+
+// loadAtomic returns the value held by the sync/atomic value v.
+func loadAtomic(v reflect.Value) reflect.Value {
+	return atomicTypes[v.Type()].load(addressable(v))
+}
+
+// stringSetter is implemented by types that can be set from a string, like
+// flag.Value.
+type stringSetter interface {
+	Set(string) error
+}
+
+// RawTOML holds the bytes of a TOML document. A struct field of this type
+// tagged with the "source" option receives the document it was decoded from.
+type RawTOML []byte
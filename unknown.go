@@ -0,0 +1,46 @@
+package toml
+
+import (
+	"github.com/pelletier/go-toml/v2/internal/ast"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// SetUnknownFieldHook makes the decoder call fn for each key of the document
+// that does not match a field of the target struct, instead of ignoring it.
+// key is the full key, starting from the root of the document. node describes
+// the value of the key-value, and is nil for a table header: the keys of an
+// unknown table are not visited.
+//
+// Returning an error stops decoding, and the error is reported at the position
+// of the key, or of the node for errors created with Node.Errorf. Returning nil
+// goes on with the next key. Keys stored in the field tagged with the
+// "remaining" option and sources of composite fields are not unknown. With
+// DisallowUnknownFields, the keys for which fn returned nil are still reported
+// in the StrictMissingError.
+func (d *Decoder) SetUnknownFieldHook(fn func(key []string, node *unstable.Node) error) *Decoder {
+	d.unknownFieldHook = fn
+	return d
+}
+
+// unknownField calls the hook set with SetUnknownFieldHook for the key-value or
+// table expr, which has no matching field.
+func (d *decoder) unknownField(key Key, expr *ast.Node) error {
+	for _, c := range d.composites {
+		if isUnderKeys(key, c.path[:len(c.path)-1], c.sources) {
+			return nil
+		}
+	}
+
+	var node *unstable.Node
+	if expr.Kind == ast.KeyValue {
+		n := d.unstableNode(expr.Value())
+		node = &n
+	}
+
+	err := d.unknownFieldHook(key, node)
+	if err != nil {
+		return d.nodeError(keyLocation(expr), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package toml_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecoderSetUnknownFieldHook(t *testing.T) {
+	type config struct {
+		Name   string
+		Server struct {
+			Port int
+		}
+	}
+
+	doc := `
+name = 'a'
+color = 'blue'
+[server]
+port = 80
+debug.level = 2
+[cache]
+size = 10
+`
+
+	var unknown []string
+	var c config
+	err := toml.NewDecoder(strings.NewReader(doc)).
+		SetUnknownFieldHook(func(key []string, node *unstable.Node) error {
+			s := strings.Join(key, ".")
+			if node != nil {
+				s += " = " + string(node.Raw)
+			}
+			unknown = append(unknown, s)
+			return nil
+		}).
+		Decode(&c)
+	require.NoError(t, err)
+	require.Equal(t, "a", c.Name)
+	require.Equal(t, 80, c.Server.Port)
+	require.Equal(t, []string{"color = 'blue'", "server.debug.level = 2", "cache"}, unknown)
+
+	err = toml.NewDecoder(strings.NewReader(doc)).
+		SetUnknownFieldHook(func(key []string, node *unstable.Node) error {
+			if key[0] == "cache" {
+				return errors.New("unknown table")
+			}
+			return nil
+		}).
+		Decode(&c)
+	var derr *toml.DecodeError
+	require.True(t, errors.As(err, &derr))
+	require.Equal(t, "toml: unknown table (in [cache])", derr.Error())
+	row, col := derr.Position()
+	require.Equal(t, 7, row)
+	require.Equal(t, 2, col)
+
+	err = toml.NewDecoder(strings.NewReader(doc)).
+		DisallowUnknownFields().
+		SetUnknownFieldHook(func(key []string, node *unstable.Node) error {
+			return nil
+		}).
+		Decode(&c)
+	var serr *toml.StrictMissingError
+	require.True(t, errors.As(err, &serr))
+	require.Len(t, serr.Errors, 3)
+}
+
+func TestUnmarshalUnknownOption(t *testing.T) {
+	var c struct {
+		Name  string
+		Extra map[string]interface{} `toml:",unknown"`
+	}
+	err := toml.Unmarshal([]byte("name = 'a'\ncolor = 'blue'"), &c)
+	require.NoError(t, err)
+	require.Equal(t, "a", c.Name)
+	require.Equal(t, map[string]interface{}{"color": "blue"}, c.Extra)
+}
@@ -2,12 +2,18 @@ package toml
 
 import (
 	"encoding"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
+	"math/big"
+	"net"
+	"net/url"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"time"
@@ -15,6 +21,7 @@ import (
 	"github.com/pelletier/go-toml/v2/internal/ast"
 	"github.com/pelletier/go-toml/v2/internal/danger"
 	"github.com/pelletier/go-toml/v2/internal/tracker"
+	"github.com/pelletier/go-toml/v2/unstable"
 )
 
 // Unmarshal deserializes a TOML document into a Go value.
@@ -34,7 +41,40 @@ type Decoder struct {
 	r io.Reader
 
 	// global settings
-	strict bool
+	strict             bool
+	required           bool
+	strictFloat32      bool
+	strictDateTimes    bool
+	parseQuotedNumbers bool
+	thousandsSep       bool
+	defaultLocation    *time.Location
+	keyMapper          KeyMapper
+	repeatedKeyAsArray bool
+	setMethods         bool
+	maxKeys            int
+	maxDepth           int
+	compatMode         CompatMode
+	disallowMixed      bool
+	disallowDuplicates bool
+	preserveNumbers    bool
+	orderedMaps        bool
+	collectStats       bool
+	collectErrors      bool
+	noCaseConflicts    bool
+	binaryUnmarshaler  bool
+	rootKey            string
+
+	// Warnings of the last call to Decode.
+	warnings []*DecodeError
+
+	// Stats of the last call to Decode, when enabled.
+	stats DecodeStats
+
+	// hooks
+	composites       []composite
+	typeDecoders     map[reflect.Type]TypeDecoderFunc
+	enums            map[reflect.Type][]string
+	unknownFieldHook func(key []string, node *unstable.Node) error
 }
 
 // NewDecoder creates a new Decoder that will read from r.
@@ -42,6 +82,15 @@ func NewDecoder(r io.Reader) *Decoder {
 	return &Decoder{r: r}
 }
 
+// Reset makes the decoder read from r, keeping all its settings, and clears the
+// warnings and statistics of the previous call to Decode. It allows to reuse
+// decoders, for example with a sync.Pool.
+func (d *Decoder) Reset(r io.Reader) {
+	d.r = r
+	d.warnings = nil
+	d.stats = DecodeStats{}
+}
+
 // DisallowUnknownFields causes the Decoder to return an error when the
 // destination is a struct and the input contains a key that does not match a
 // non-ignored field.
@@ -54,21 +103,402 @@ func (d *Decoder) DisallowUnknownFields() *Decoder {
 	return d
 }
 
+// CollectErrors causes the Decoder to go on decoding the document after an
+// error, and to return all the errors it found at once in a DecodeErrors,
+// instead of stopping at the first one. Values that cannot be decoded, for
+// example because their type does not match the one of their target, are
+// skipped, and so are the key-values of a table that cannot be decoded. With
+// DisallowUnknownFields, the keys missing in the target are reported in the
+// DecodeErrors too, instead of a StrictMissingError.
+//
+// Errors in the syntax of the document, duplicate keys and documents over the
+// limit of SetMaxKeys still stop decoding, and are returned alone.
+func (d *Decoder) CollectErrors() *Decoder {
+	d.collectErrors = true
+	return d
+}
+
+// DisallowMixedTables causes the Decoder to return an error when a table is
+// defined both by a table header and by dotted keys. For example, both of these
+// documents are valid TOML, but are rejected by this option:
+//
+//   [fruit.apple.texture]
+//   [fruit]
+//   apple.color = "red"
+//
+//   [product]
+//   type.name = "Nail"
+//   [product.type.size]
+//
+// Regardless of this option, the Decoder rejects the combinations forbidden
+// by the TOML specification, like defining a table with a header after it was
+// created by dotted keys.
+func (d *Decoder) DisallowMixedTables() *Decoder {
+	d.disallowMixed = true
+	return d
+}
+
+// DisallowDuplicateKeys makes the errors about a key defined more than once in
+// the same table, whether by key-values, dotted keys, inline tables or table
+// headers, report both where the key is defined again and where it was first
+// defined:
+//
+//   toml: key port is already defined at line 4, column 1, first defined at line 2, column 1
+//
+// The returned DecodeError points at the second definition. This option takes
+// precedence over SetRepeatedKeyAsArray: repeated keys are always an error.
+func (d *Decoder) DisallowDuplicateKeys() *Decoder {
+	d.disallowDuplicates = true
+	return d
+}
+
+// DisallowCaseConflicts makes it an error to designate the same struct field
+// with keys that only differ by case, like MaxConns and maxconns, in the same
+// table. The returned DecodeError points at the second key.
+//
+// Keys that do not match the name of any struct field exactly are always
+// matched with the fields case-insensitively, but when several of them are
+// used, the last one silently overrides the others. This option reports the
+// ambiguity instead. It does not change how keys are matched: to decode keys
+// spelled like max_conns into the field MaxConns, use SetKeyMapper(SnakeCase).
+func (d *Decoder) DisallowCaseConflicts() *Decoder {
+	d.noCaseConflicts = true
+	return d
+}
+
+// EnableBinaryMarshaler makes the decoder decode TOML strings into the types
+// implementing encoding.BinaryUnmarshaler, by giving the bytes of the string,
+// decoded as standard base64, to UnmarshalBinary. A string that is not valid
+// base64 is an error. Types implementing encoding.TextUnmarshaler, time.Time
+// and the other types with a built-in decoding are not affected.
+//
+// It matches Encoder.EnableBinaryMarshaler.
+func (d *Decoder) EnableBinaryMarshaler() *Decoder {
+	d.binaryUnmarshaler = true
+	return d
+}
+
+// SetKeyMapper sets the KeyMapper used to find the struct field corresponding
+// to a key of the document, when no field has that exact name. For example,
+// with KebabCase the key max-retries is decoded into the field MaxRetries.
+func (d *Decoder) SetKeyMapper(m KeyMapper) *Decoder {
+	d.keyMapper = m
+	return d
+}
+
+// UseKebabCase is a shortcut for SetKeyMapper(KebabCase).
+func (d *Decoder) UseKebabCase() *Decoder {
+	return d.SetKeyMapper(KebabCase)
+}
+
+// SetRepeatedKeyAsArray allows a key with a scalar value to be defined multiple
+// times in the same table. Its values are collected into a slice, in the order
+// they appear in the document. For example:
+//
+//   tag = "a"
+//   tag = "b"
+//
+// decodes into a []string field as ["a", "b"], and into an interface{} as
+// []interface{}{"a", "b"}. When decoded into a non-slice value, the last value
+// wins.
+//
+// This is a deviation from the TOML specification, which forbids defining a
+// key multiple times, meant to read documents produced by non-compliant tools.
+// It is disabled by default.
+func (d *Decoder) SetRepeatedKeyAsArray(enabled bool) *Decoder {
+	d.repeatedKeyAsArray = enabled
+	return d
+}
+
+// SetUseSetMethods allows the Decoder to decode TOML strings into values whose
+// pointer has a Set(string) error method, like the types implementing
+// flag.Value. The method is called with the content of the string.
+//
+// Types implementing encoding.TextUnmarshaler still use UnmarshalText.
+func (d *Decoder) SetUseSetMethods(enabled bool) *Decoder {
+	d.setMethods = enabled
+	return d
+}
+
+// EnforceRequiredFields causes the Decoder to return an error when a struct
+// field tagged with the "required" option has no corresponding key in the
+// document. For example, decoding a document without a [server] table into:
+//
+//   type Config struct {
+//     Server Server `toml:"server,required"`
+//   }
+//
+// fails with the error "missing section `server`". Required fields of a table
+// that is absent from the document are not checked, unless that table is
+// required itself.
+func (d *Decoder) EnforceRequiredFields() *Decoder {
+	d.required = true
+	return d
+}
+
+// SetStrictFloat32 causes the Decoder to return an error when a TOML float is
+// decoded into a float32 but cannot be represented exactly by it.
+//
+// By default the value is converted following Go's conversion semantics, which
+// may silently lose precision.
+func (d *Decoder) SetStrictFloat32(strict bool) *Decoder {
+	d.strictFloat32 = strict
+	return d
+}
+
+// SetStrictDateTimes causes the Decoder to only accept offset date-times and
+// local date-times in the format of RFC 3339 as written in its grammar: the
+// date and the time must be separated by an uppercase T, and the UTC offset
+// must be an uppercase Z. Date-times like 1979-05-27 07:32:00Z or
+// 1979-05-27t07:32:00z, which TOML allows, are then an error.
+func (d *Decoder) SetStrictDateTimes(strict bool) *Decoder {
+	d.strictDateTimes = strict
+	return d
+}
+
+// SetParseQuotedNumbers allows the Decoder to decode TOML strings into numeric
+// Go types, as long as the content of the string can be parsed as a decimal
+// number that fits in the target type. For example:
+//
+//   port = "8080"
+//
+// can be decoded into an int field. Strings that are not numbers still return
+// an error.
+func (d *Decoder) SetParseQuotedNumbers(enabled bool) *Decoder {
+	d.parseQuotedNumbers = enabled
+	return d
+}
+
+// SetParseThousandsSeparators allows the Decoder to decode TOML strings
+// containing numbers whose integer part is grouped by thousands with commas
+// into numeric Go types. For example:
+//
+//   budget = "1,000,000"
+//
+// can be decoded into an int field as 1000000. It implies
+// SetParseQuotedNumbers. TOML numbers are not affected.
+func (d *Decoder) SetParseThousandsSeparators(enabled bool) *Decoder {
+	d.thousandsSep = enabled
+	return d
+}
+
+// EnableNumberPreservation causes the Decoder to decode TOML integers and
+// floats into interface{} values as a Number, which keeps the text of the
+// number as written in the document, instead of an int64 or a float64. For
+// example, 1_000 and 1e3 can be emitted again as they were written.
+func (d *Decoder) EnableNumberPreservation() *Decoder {
+	d.preserveNumbers = true
+	return d
+}
+
+// EnableOrderedMaps causes the Decoder to store the tables decoded into
+// interface{} values as *OrderedMap instead of map[string]interface{}, so that
+// their keys keep the order they have in the document. This includes the root
+// table when decoding into a nil interface{}. Encoding the result emits the keys
+// in the same order.
+func (d *Decoder) EnableOrderedMaps() *Decoder {
+	d.orderedMaps = true
+	return d
+}
+
+// EnableStats causes the Decoder to count the tokens, tables and keys of the
+// documents it decodes, returned by Stats. The counters are cheap to collect,
+// but disabled by default.
+func (d *Decoder) EnableStats(enabled bool) *Decoder {
+	d.collectStats = enabled
+	return d
+}
+
+// SetMaxKeys limits the number of keys the document can contain to n. Every
+// key-value, including the ones inside inline tables, and every table header
+// counts as one key. Decoding fails with a DecodeError pointing at the first key
+// over the limit.
+//
+// This protects from documents crafted to consume large amounts of memory when
+// decoding untrusted input, in particular into maps. A value of 0 or less
+// disables the limit, which is the default.
+func (d *Decoder) SetMaxKeys(n int) *Decoder {
+	d.maxKeys = n
+	return d
+}
+
+// SetMaxDepth limits the nesting of arrays and inline tables, in any
+// combination, to n levels: `a = [[1]]` has two levels. Decoding a document
+// nested deeper fails with a DecodeError pointing at the first array or inline
+// table over the limit, instead of exhausting the stack.
+//
+// This protects from documents crafted to crash the decoding of untrusted
+// input. A value of 0 or less restores the default of 1000 levels.
+func (d *Decoder) SetMaxDepth(n int) *Decoder {
+	d.maxDepth = n
+	return d
+}
+
+// SetDefaultLocation sets the location in which TOML local date-times and
+// local dates are interpreted when they are decoded into a time.Time. Defaults
+// to time.Local.
+//
+// Targets of type LocalDateTime or LocalDate are not affected.
+func (d *Decoder) SetDefaultLocation(loc *time.Location) *Decoder {
+	d.defaultLocation = loc
+	return d
+}
+
+// SetRootKey makes the Decoder decode the value of the key of the document
+// root named key into v, instead of the whole document. It reads the documents
+// written by an Encoder with the same root key, including the ones holding a
+// value that is not a table, like a slice or an integer:
+//
+//   var items []string
+//   err := toml.NewDecoder(r).SetRootKey("items").Decode(&items)
+//
+// reads `items = ["a", "b"]`. The key is a single key, which is not split on
+// dots, and is matched exactly. The other keys of the document are ignored,
+// or reported by DisallowUnknownFields, and v is left untouched if the
+// document does not have the key. An empty key restores the default.
+func (d *Decoder) SetRootKey(key string) *Decoder {
+	d.rootKey = key
+	return d
+}
+
 // Decode the whole content of r into v.
 //
 // By default, values in the document that don't exist in the target Go value
 // are ignored. See Decoder.DisallowUnknownFields() to change this behavior.
 //
 // When a TOML local date, time, or date-time is decoded into a time.Time, its
-// value is represented in time.Local timezone. Otherwise the approriate Local*
-// structure is used. For time values, precision up to the nanosecond is
-// supported by truncating extra digits.
+// value is represented in time.Local timezone, unless a different location has
+// been provided with Decoder.SetDefaultLocation(). Otherwise the approriate
+// Local* structure is used. A local time decoded into a time.Time is placed on
+// January 1st of year 0, like time.Parse does. For time values, precision up to
+// the nanosecond is supported by truncating extra digits.
+//
+// A time.Duration is decoded from a string in the format of time.ParseDuration,
+// like "1h30m", or from an integer number of nanoseconds.
 //
 // Empty tables decoded in an interface{} create an empty initialized
 // map[string]interface{}.
 //
-// Types implementing the encoding.TextUnmarshaler interface are decoded from a
-// TOML string.
+// TOML arrays can be decoded into Go arrays of any size. Elements the TOML
+// array does not provide are set to their zero value, and a TOML array with
+// more elements than the Go array is an error.
+//
+// Keys are matched with the fields of structs case-insensitively, but the
+// keys stored in maps, including maps that are fields of structs, keep the
+// case they have in the document.
+//
+// The fields of embedded structs and pointers to structs are decoded from the
+// table of the enclosing struct, following the same rules as Encode when
+// several of them have the same name. Nil embedded pointers are allocated when
+// one of their fields is decoded, unless their type is unexported.
+//
+// Unexported fields of structs are ignored. Channels, functions, complex
+// numbers and unsafe pointers cannot hold TOML values: decoding a key into a
+// field or element of such a type is a DecodeError, unless the type implements
+// one of the unmarshaler interfaces. A field of such a type is left untouched
+// when the document does not have its key.
+//
+// Maps with integer keys accept keys that are TOML integers, in any notation.
+// Two different keys of a table that are the same integer, like 1 and "+1" or
+// 16 and 0x10, are an error.
+//
+// Keys of a table that do not match any field of the target struct are stored
+// in the field tagged with the "remaining" option, if any:
+//
+//   Extra toml.OrderedMap `toml:",remaining"`
+//
+// The field can be an OrderedMap, which keeps the keys in the order they
+// appear in the document, or a map with string keys. The "unknown" option is a
+// synonym of "remaining". See Decoder.SetUnknownFieldHook to handle the
+// unmatched keys with a function instead.
+//
+// If the target is a struct, a copy of the whole document is stored in its
+// field tagged with the "source" option, if any. The field must be a []byte or
+// a RawTOML:
+//
+//   Source toml.RawTOML `toml:",source"`
+//
+// The "alias" option lists other keys accepted for a field, separated by '|'.
+// It helps renaming keys without breaking existing documents:
+//
+//   Servers []Server `toml:"server,alias=servers"`
+//
+// accepts both [[server]] and [[servers]]. Using several names of the same field
+// in the same table is an error.
+//
+// The "squash" option decodes the keys of the fields of a struct field from
+// the table of the enclosing struct, like the fields of an embedded struct:
+//
+//   Net NetConfig `toml:",squash"`
+//
+// decodes the key host into Net.Host, when NetConfig has a Host field. Two
+// fields using the same key is an error.
+//
+// The "dotted" option makes the dots of the name of a field separate keys
+// instead of being part of the key. This decodes nested tables into a flat
+// struct:
+//
+//   Port int `toml:"server.http.port,dotted"`
+//
+// receives the port key of the [server.http] table, however it is written in
+// the document. Two fields using the same key, or a field using a key that
+// another one uses as a table, is an error.
+//
+// The "headercomment" option, on a field of a struct decoded from a table
+// header, receives the comment lines directly above the header. The lines must
+// be contiguous, and the comment lines separated from the header by a blank
+// line are not included:
+//
+//   # Database settings.
+//   # Used by all the workers.
+//   [database]
+//
+// sets the field tagged with `toml:",headercomment"` of the struct decoded from
+// the [database] table to "Database settings.\nUsed by all the workers.". Each
+// line is stripped of its # and of the space following it. The field can also
+// be a []string, receiving one element per line.
+//
+// The "key" option, on a string field of a struct decoded as the value of a
+// map, receives the key of the value in the map:
+//
+//   Plugins map[string]Plugin
+//
+// sets the field tagged with `toml:",key"` of the Plugin decoded from the
+// [plugins.lint] table to "lint". The field is not decoded from the keys of the
+// document.
+//
+// The "deprecated" option marks keys that are still decoded, but whose use is
+// reported by Decoder.Warnings:
+//
+//   Hostname string `toml:"hostname,deprecated"`
+//
+// The "oneof" option restricts the values that can be decoded into a string
+// field, or into the elements of a slice of strings field, to the ones it
+// lists:
+//
+//   Level string `toml:"level,oneof=debug|info|warn|error"`
+//
+// Types implementing the Unmarshaler or ContextUnmarshaler interfaces decode
+// values of any TOML type themselves, and so do the functions registered with
+// Decoder.RegisterTypeDecoder. Values of maps implementing the KeySetter
+// interface are given their key. Types implementing the
+// encoding.TextUnmarshaler interface are decoded from a TOML string, and map
+// keys of such types from the TOML key. Types implementing the FieldResolver
+// interface are decoded through the functions it returns instead of their
+// struct fields. Types implementing the TableUnmarshaler interface decode
+// tables themselves, from all their keys at once. Once the document is
+// decoded, the values implementing the AfterDecoder interface are given a
+// chance to validate themselves.
+//
+// Nullable values and the null types of database/sql, like sql.NullString, are
+// decoded from the value they hold, and marked as valid. They are left
+// untouched when their key is absent from the document.
+//
+// The sync/atomic types holding a boolean or an integer, like atomic.Int64, are
+// decoded from the value they hold, which is set with their Store method.
+//
+// Any value or table can be decoded into a RawValue, which keeps it undecoded
+// until UnmarshalValue is called.
 //
 // When decoding a number, go-toml will return an error if the number is out of
 // bounds for the target type (which includes negative numbers when decoding
@@ -77,15 +507,18 @@ func (d *Decoder) DisallowUnknownFields() *Decoder {
 // If an error occurs while decoding the content of the document, this function
 // returns a toml.DecodeError, providing context about the issue. When using
 // strict mode and a field is missing, a `toml.StrictMissingError` is
-// returned. In any other case, this function returns a standard Go error.
+// returned. When errors are collected, a `toml.DecodeErrors` is returned
+// instead. In any other case, this function returns a standard Go error.
+// The messages of errors that happen inside a table end with a description of
+// that table, like "(in [[servers]] element 2)"; see DecodeError.Table.
 //
 // Type mapping
 //
 // List of supported TOML types and their associated accepted Go types:
 //
-//   String           -> string
-//   Integer          -> uint*, int*, depending on size
-//   Float            -> float*, depending on size
+//   String           -> string, url.URL, net.IPNet
+//   Integer          -> uint*, int*, depending on size, big.Int, big.Float
+//   Float            -> float*, depending on size, big.Float
 //   Boolean          -> bool
 //   Offset Date-Time -> time.Time
 //   Local Date-time  -> LocalDateTime, time.Time
@@ -96,21 +529,117 @@ func (d *Decoder) DisallowUnknownFields() *Decoder {
 //   Inline Table     -> same as Table
 //   Array of Tables  -> same as Array and Table
 func (d *Decoder) Decode(v interface{}) error {
+	return d.decode(v, nil)
+}
+
+// DecodeWithMeta is like Decode, but also returns the description of the keys
+// present in the document, up to the first error if any. MetaData.UnsetFields
+// then lists the fields of v that did not receive a value.
+func (d *Decoder) DecodeWithMeta(v interface{}) (MetaData, error) {
+	var meta MetaData
+	err := d.decode(v, &meta)
+	return meta, err
+}
+
+func (d *Decoder) decode(v interface{}, meta *MetaData) error {
+	var root []string
+	if d.rootKey != "" {
+		root = []string{d.rootKey}
+	}
+	_, err := d.decodeRoot(v, meta, root, false)
+	return err
+}
+
+// decodeRoot decodes the value at the keys root of the document into v. When
+// partial is set, the keys of the document outside of root are not reported by
+// DisallowUnknownFields. It returns true if the document contains root.
+func (d *Decoder) decodeRoot(v interface{}, meta *MetaData, root []string, partial bool) (bool, error) {
 	b, err := ioutil.ReadAll(d.r)
 	if err != nil {
-		return fmt.Errorf("toml: %w", err)
+		return false, fmt.Errorf("toml: %w", err)
 	}
 
-	p := parser{}
+	p := parser{maxDepth: d.maxDepth}
 	p.Reset(b)
 	dec := decoder{
 		p: &p,
 		strict: strict{
 			Enabled: d.strict,
 		},
+		required: required{
+			Enabled:   d.required,
+			keyMapper: d.keyMapper,
+			rootPath:  root,
+		},
+		rootPath:           root,
+		tableRest:          root,
+		partial:            partial,
+		strictFloat32:      d.strictFloat32,
+		strictDateTimes:    d.strictDateTimes,
+		parseQuotedNumbers: d.parseQuotedNumbers,
+		thousandsSep:       d.thousandsSep,
+		defaultLocation:    d.defaultLocation,
+		keyMapper:          d.keyMapper,
+		setMethods:         d.setMethods,
+		maxKeys:            d.maxKeys,
+		compatMode:         d.compatMode,
+		preserveNumbers:    d.preserveNumbers,
+		ordered:            d.orderedMaps,
+		composites:         d.composites,
+		typeDecoders:       d.typeDecoders,
+		enums:              d.enums,
+		unknownFieldHook:   d.unknownFieldHook,
+		collectErrors:      d.collectErrors,
+		noCaseConflicts:    d.noCaseConflicts,
+		binaryUnmarshaler:  d.binaryUnmarshaler,
+		meta:               meta,
+		seen: tracker.SeenTracker{
+			AllowRepeatedScalars:  d.repeatedKeyAsArray,
+			DisallowMixedTables:   d.disallowMixed,
+			DisallowDuplicateKeys: d.disallowDuplicates,
+		},
+	}
+
+	if d.unknownFieldHook != nil {
+		dec.strict.Unknown = dec.unknownField
+	}
+
+	if meta != nil {
+		meta.keyMapper = d.keyMapper
+		if len(root) > 0 {
+			meta.root = Key(root)
+		}
+	}
+
+	d.stats = DecodeStats{}
+	if d.collectStats {
+		dec.stats = &d.stats
+		d.stats.Bytes = len(b)
+	}
+
+	err = dec.FromParser(v)
+
+	d.warnings = make([]*DecodeError, 0, len(dec.warnings))
+	for _, w := range dec.warnings {
+		w := w
+		d.warnings = append(d.warnings, wrapDecodeError(b, &w))
 	}
 
-	return dec.FromParser(v)
+	return dec.rootFound, err
+}
+
+// Warnings returns the warnings recorded by the last call to Decode, in the
+// order they were found in the document. Warnings do not prevent decoding:
+// they report the use of keys of struct fields tagged with the "deprecated"
+// option, once per field.
+func (d *Decoder) Warnings() []*DecodeError {
+	return d.warnings
+}
+
+// Stats returns the counters collected by the last call to Decode, up to the
+// first error if any. They are all zero unless EnableStats is set.
+func (d *Decoder) Stats() DecodeStats {
+	return d.stats
 }
 
 type decoder struct {
@@ -139,8 +668,114 @@ type decoder struct {
 	// Strict mode
 	strict strict
 
+	// Keys present in the document, to verify required fields
+	required required
+
+	// Error when a float cannot be exactly represented as a float32.
+	strictFloat32 bool
+
+	// Error when a date-time uses a lowercase or space separator, or a
+	// lowercase z.
+	strictDateTimes bool
+
+	// Accept strings containing numbers for numeric types.
+	parseQuotedNumbers bool
+
+	// Accept commas grouping thousands in strings containing numbers.
+	thousandsSep bool
+
+	// Location used for local date-times decoded into time.Time. Nil means
+	// time.Local.
+	defaultLocation *time.Location
+
+	// Converts keys to field names when there is no exact match.
+	keyMapper KeyMapper
+
+	// Decode strings into types with a Set(string) error method.
+	setMethods bool
+
+	// Decode numbers into interface{} values as Number.
+	preserveNumbers bool
+
+	// Counters of the document, nil when disabled, and depth of the current
+	// table for them.
+	stats      *DecodeStats
+	statsDepth int
+
+	// Keys of the document, nil when not requested.
+	meta *MetaData
+
+	// Maximum number of keys of the document, and number of keys seen so far.
+	maxKeys int
+	keys    int
+
+	// Selects the types of values decoded into interface{}.
+	compatMode CompatMode
+
+	// Fields built from multiple keys, and the values of their source keys
+	// found in the document.
+	composites []composite
+	sources    compositeSources
+
+	// Functions decoding the values of specific types.
+	typeDecoders map[reflect.Type]TypeDecoderFunc
+
+	// Strings accepted by the string types registered with RegisterEnum.
+	enums map[reflect.Type][]string
+
+	// Called for the keys that do not match any field.
+	unknownFieldHook func(key []string, node *unstable.Node) error
+
 	// Current context for the error.
 	errorContext *errorContext
+
+	// Set to true while decoding inside an OrderedMap, so that tables decoded
+	// into interface{} values are stored as *OrderedMap.
+	ordered bool
+
+	// Values of the keys decoded into each FieldResolver.
+	resolved map[FieldResolver]map[string]interface{}
+
+	// Names used to decode the fields that have aliases.
+	aliases map[fieldKey]string
+
+	// Report fields decoded from keys that only differ by case, and the keys
+	// used to decode each field.
+	noCaseConflicts bool
+	fieldKeys       map[fieldKey]string
+
+	// Decode strings into encoding.BinaryUnmarshaler values from base64.
+	binaryUnmarshaler bool
+
+	// Keys of the document leading to the value decoded into the target, set
+	// by Decoder.SetRootKey and Decoder.DecodePath.
+	rootPath []string
+
+	// Parts of rootPath after the key of the current table, when this key is
+	// a prefix of rootPath.
+	tableRest []string
+
+	// Ignore the keys outside of rootPath in strict mode.
+	partial bool
+
+	// Whether the document contains rootPath.
+	rootFound bool
+
+	// Document keys of the integer keys of maps that have been decoded.
+	integerKeys map[integerKey]string
+
+	// Fields tagged with the "deprecated" option that have been decoded.
+	deprecated map[fieldKey]bool
+
+	// Warnings recorded while decoding.
+	warnings []decodeError
+
+	// Go on after recoverable errors, and the errors recorded so far.
+	collectErrors bool
+	errs          []*DecodeError
+
+	// Table containing the expression being decoded, for errors.
+	sections sections
 }
 
 type errorContext struct {
@@ -149,12 +784,57 @@ type errorContext struct {
 }
 
 func (d *decoder) typeMismatchError(toml string, target reflect.Type) error {
+	return fmt.Errorf("toml: %s", d.typeMismatchMessage(toml, target))
+}
+
+func (d *decoder) typeMismatchMessage(toml string, target reflect.Type) string {
 	if d.errorContext != nil && d.errorContext.Struct != nil {
 		ctx := d.errorContext
 		f := ctx.Struct.FieldByIndex(ctx.Field)
-		return fmt.Errorf("toml: cannot decode TOML %s into struct field %s.%s of type %s", toml, ctx.Struct, f.Name, f.Type)
+		return fmt.Sprintf("cannot decode TOML %s into struct field %s.%s of type %s", toml, ctx.Struct, f.Name, f.Type)
+	}
+	return fmt.Sprintf("cannot decode TOML %s into a Go value of type %s", toml, target)
+}
+
+// isUnsupportedType reports whether values of type t, or pointed to by t,
+// cannot hold TOML values, like channels and functions, unless they decode
+// themselves.
+func (d *decoder) isUnsupportedType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		if d.typeDecoders[t] != nil {
+			return false
+		}
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.UnsafePointer:
+	default:
+		return false
+	}
+
+	pt := reflect.PtrTo(t)
+	return d.typeDecoders[t] == nil && !implementsUnmarshaler(pt) && !pt.Implements(textUnmarshalerType) && !pt.Implements(tableUnmarshalerType)
+}
+
+// unsupportedFieldError returns the error for the key decoded into the field
+// at path of the struct type t, whose type cannot hold TOML values.
+func (d *decoder) unsupportedFieldError(key *ast.Node, t reflect.Type, path []int) error {
+	f := t.FieldByIndex(path)
+	ft := f.Type
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	return newDecodeError(d.p.Raw(key.Raw), "cannot decode key %s into struct field %s.%s of type %s: %s values are not supported", key.Data, t, f.Name, f.Type, ft.Kind())
+}
+
+// fieldOptions returns the options of the tag of the struct field being
+// decoded that change how its value is decoded, if any.
+func (d *decoder) fieldOptions() tagOptions {
+	if d.errorContext == nil || d.errorContext.Struct == nil {
+		return tagOptions{}
 	}
-	return fmt.Errorf("toml: cannot decode TOML %s into a Go value of type %s", toml, target)
+	return cachedStructInfo(d.errorContext.Struct).fieldOptions(d.errorContext.Field)
 }
 
 func (d *decoder) expr() *ast.Node {
@@ -166,7 +846,13 @@ func (d *decoder) nextExpr() bool {
 		d.stashedExpr = false
 		return true
 	}
-	return d.p.NextExpression()
+	if !d.p.NextExpression() {
+		return false
+	}
+	d.collectStats(d.p.Expression())
+	d.collectMeta(d.p.Expression())
+	d.collectComposites(d.p.Expression())
+	return true
 }
 
 func (d *decoder) stashExpr() {
@@ -201,24 +887,129 @@ func (d *decoder) FromParser(v interface{}) error {
 	}
 
 	r = r.Elem()
-	if r.Kind() == reflect.Interface && r.IsNil() {
-		newMap := map[string]interface{}{}
-		r.Set(reflect.ValueOf(newMap))
+	if r.Kind() == reflect.Interface && r.IsNil() && len(d.rootPath) == 0 {
+		r.Set(d.makeTable())
 	}
 
 	err := d.fromParser(r)
+	if err == nil && d.collectErrors {
+		err = d.collectedErrors()
+		if err != nil {
+			return err
+		}
+	}
 	if err == nil {
-		return d.strict.Error(d.p.data)
+		err = d.setSource(r)
+		if err != nil {
+			return err
+		}
+		err = d.applyComposites(r)
+		if err != nil {
+			return err
+		}
+		err = d.required.Check(r)
+		if err != nil {
+			return err
+		}
+		err = d.strict.Error(d.p.data)
+		if err != nil {
+			return err
+		}
+		return afterDecode(r)
+	}
+
+	return d.wrapError(err)
+}
+
+// wrapError returns err as a DecodeError when it refers to a part of the
+// document.
+func (d *decoder) wrapError(err error) error {
+	var ke *tracker.KeyError
+	if errors.As(err, &ke) {
+		msg := ke.Message
+		if ke.First != nil {
+			row, column := positionAtEnd(d.p.data[:ke.Raw.Offset])
+			firstRow, firstColumn := positionAtEnd(d.p.data[:ke.First.Offset])
+			msg = fmt.Sprintf("%s at line %d, column %d, first defined at line %d, column %d", msg, row, column, firstRow, firstColumn)
+		}
+		err = newDecodeError(d.p.Raw(ke.Raw), "%s", msg)
 	}
 
 	var e *decodeError
 	if errors.As(err, &e) {
+		e.table = d.sections.String()
 		return wrapDecodeError(d.p.data, e)
 	}
 
+	if table := d.sections.String(); table != "" {
+		return fmt.Errorf("%w (in %s)", err, table)
+	}
+
 	return err
 }
 
+// collectError records err and returns nil when errors are collected and
+// decoding can go on after err, which happened while decoding expr. Otherwise
+// it returns err.
+func (d *decoder) collectError(expr *ast.Node, err error) error {
+	if !d.collectErrors || !d.recoverable(err) {
+		return err
+	}
+
+	var e *decodeError
+	if !errors.As(err, &e) {
+		highlight := keyLocation(expr)
+		if expr.Kind == ast.KeyValue {
+			highlight = d.rawValue(expr.Value())
+		}
+		e = &decodeError{
+			highlight: highlight,
+			message:   strings.TrimPrefix(err.Error(), "toml: "),
+			cause:     err,
+		}
+	}
+	e.table = d.sections.String()
+	d.errs = append(d.errs, wrapDecodeError(d.p.data, e))
+
+	return nil
+}
+
+// recoverable reports whether decoding can go on after err. Errors about the
+// keys of the document, like a duplicate key, are not recoverable, since the
+// keys that follow would be checked against an inconsistent state.
+func (d *decoder) recoverable(err error) bool {
+	var ke *tracker.KeyError
+	if errors.As(err, &ke) {
+		return false
+	}
+	return d.maxKeys <= 0 || d.keys <= d.maxKeys
+}
+
+// collectedErrors returns the errors collected while decoding, followed by the
+// keys missing in the target in strict mode, as a DecodeErrors sorted by
+// position. It returns nil if there are none.
+func (d *decoder) collectedErrors() error {
+	var serr *StrictMissingError
+	if errors.As(d.strict.Error(d.p.data), &serr) {
+		for i := range serr.Errors {
+			d.errs = append(d.errs, &serr.Errors[i])
+		}
+	}
+	d.strict.Enabled = false
+
+	if len(d.errs) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(d.errs, func(i, j int) bool {
+		li, ci := d.errs[i].Position()
+		lj, cj := d.errs[j].Position()
+		return li < lj || (li == lj && ci < cj)
+	})
+
+	return &DecodeErrors{Errors: d.errs}
+}
+
 func (d *decoder) fromParser(root reflect.Value) error {
 	for d.nextExpr() {
 		err := d.handleRootExpression(d.expr(), root)
@@ -243,6 +1034,13 @@ func (d *decoder) handleRootExpression(expr *ast.Node, v reflect.Value) error {
 	var x reflect.Value
 	var err error
 
+	d.sections.Enter(expr)
+
+	err = d.countKeys(expr)
+	if err != nil {
+		return err
+	}
+
 	if !(d.skipUntilTable && expr.Kind == ast.KeyValue) {
 		err = d.seen.CheckExpression(expr)
 		if err != nil {
@@ -250,39 +1048,245 @@ func (d *decoder) handleRootExpression(expr *ast.Node, v reflect.Value) error {
 		}
 	}
 
+	key, rest, inRoot := d.rootKeyIterator(expr)
+	if inRoot && !(d.skipUntilTable && expr.Kind == ast.KeyValue) {
+		d.rootFound = true
+	}
+
 	switch expr.Kind {
 	case ast.KeyValue:
+		d.required.KeyValue(expr)
 		if d.skipUntilTable {
 			return nil
 		}
-		x, err = d.handleKeyValue(expr, v)
+		switch {
+		case inRoot:
+			x, err = d.handleKeyValue(expr, key, v)
+		case rest != nil:
+			x, err = d.handleRootInlineTable(expr, rest, v)
+		default:
+			err = d.missingRootKeyValue(expr)
+		}
 	case ast.Table:
 		d.skipUntilTable = false
+		d.tableRest = rest
 		d.strict.EnterTable(expr)
-		x, err = d.handleTable(expr.Key(), v)
+		d.required.EnterTable(expr)
+		if inRoot {
+			x, err = d.handleTable(key, v)
+		} else if rest == nil {
+			d.skipUntilTable = true
+		}
 	case ast.ArrayTable:
 		d.skipUntilTable = false
+		d.tableRest = nil
 		d.strict.EnterArrayTable(expr)
-		x, err = d.handleArrayTable(expr.Key(), v)
+		d.required.EnterArrayTable(expr)
+		switch {
+		case !inRoot:
+			// Paths cannot lead through the elements of an array of tables.
+			d.skipUntilTable = true
+		case len(d.rootPath) > 0:
+			// The key is positioned on the last part of the root path, which
+			// designates the collection v.
+			err = rootArrayTableError(key, v)
+			if err == nil {
+				x, err = d.handleArrayTableCollection(key, v)
+			}
+		default:
+			x, err = d.handleArrayTable(key, v)
+		}
 	default:
 		panic(fmt.Errorf("parser should not permit expression of kind %s at document root", expr.Kind))
 	}
 
 	if d.skipUntilTable {
-		if expr.Kind == ast.Table || expr.Kind == ast.ArrayTable {
-			d.strict.MissingTable(expr)
+		if (expr.Kind == ast.Table || expr.Kind == ast.ArrayTable) && (inRoot || !d.partial) {
+			if merr := d.strict.MissingTable(expr); err == nil {
+				err = merr
+			}
 		}
 	} else if err == nil && x.IsValid() {
 		v.Set(x)
 	}
 
-	return err
-}
-
+	if err != nil {
+		err = d.collectError(expr, err)
+		if err == nil && expr.Kind != ast.KeyValue {
+			// The key-values of the table cannot be decoded either.
+			d.skipUntilTable = true
+		}
+	}
+
+	return err
+}
+
+// rootKeyIterator returns the iterator over the key of the expression expr of
+// the document root. When the decoder has a root path, the iterator is
+// positioned on the last part of the root path, and false is returned if the
+// key does not start with the root path. The key of a key-value continues the
+// key of the table containing it. When the key is a prefix of the root path,
+// rest holds the parts of the root path that follow it.
+func (d *decoder) rootKeyIterator(expr *ast.Node) (key ast.Iterator, rest []string, inRoot bool) {
+	key = expr.Key()
+	path := d.rootPath
+	if expr.Kind == ast.KeyValue {
+		path = d.tableRest
+	}
+	if len(path) == 0 {
+		return key, nil, true
+	}
+	n, ok := matchKeyPath(&key, path)
+	if !ok {
+		return key, nil, false
+	}
+	if n < len(path) {
+		return key, path[n:], false
+	}
+	return key, nil, true
+}
+
+// matchKeyPath advances key over the parts of path, and returns the number of
+// parts of key matching them. It returns false if a part of key differs from
+// path.
+func matchKeyPath(key *ast.Iterator, path []string) (int, bool) {
+	n := 0
+	for n < len(path) && key.Next() {
+		if string(key.Node().Data) != path[n] {
+			return n, false
+		}
+		n++
+	}
+	return n, true
+}
+
+// handleRootInlineTable decodes into v the parts of the value of the key-value
+// expr that are at the parts rest of the root path, when it is an inline
+// table. Other values are outside of the root path.
+func (d *decoder) handleRootInlineTable(expr *ast.Node, rest []string, v reflect.Value) (reflect.Value, error) {
+	value := expr.Value()
+	if value.Kind != ast.InlineTable {
+		return reflect.Value{}, d.missingRootKeyValue(expr)
+	}
+
+	d.strict.EnterKeyValue(expr)
+	defer d.strict.ExitKeyValue(expr)
+
+	it := value.Children()
+	for it.Next() {
+		kv := it.Node()
+		key := kv.Key()
+		n, ok := matchKeyPath(&key, rest)
+
+		var x reflect.Value
+		var err error
+		switch {
+		case !ok:
+			err = d.missingRootKeyValue(kv)
+		case n < len(rest):
+			x, err = d.handleRootInlineTable(kv, rest[n:], v)
+		default:
+			d.rootFound = true
+			x, err = d.handleKeyValue(kv, key, v)
+		}
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if x.IsValid() {
+			v.Set(x)
+		}
+	}
+
+	return reflect.Value{}, nil
+}
+
+// rootArrayTableError returns an error if the array table designated by the
+// root path, whose key is positioned on its last part, cannot be decoded into
+// v because v does not hold a collection.
+func rootArrayTableError(key ast.Iterator, v reflect.Value) error {
+	if !key.IsLast() {
+		return nil
+	}
+	t := indirectType(v.Type())
+	if (t.Kind() == reflect.Map || t.Kind() == reflect.Struct) && t != rawValueType && !reflect.PtrTo(t).Implements(tableUnmarshalerType) {
+		return newDecodeError(key.Node().Data, "cannot decode array table %s into a Go value of type %s, use a slice", key.Node().Data, v.Type())
+	}
+	return nil
+}
+
+// missingRootKeyValue handles the key-value expr of the document root, whose
+// key is not the root key.
+func (d *decoder) missingRootKeyValue(expr *ast.Node) error {
+	if d.partial {
+		return nil
+	}
+	d.strict.EnterKeyValue(expr)
+	err := d.strict.MissingField(expr)
+	d.strict.ExitKeyValue(expr)
+	return err
+}
+
+// countKeys adds the keys of the expression node to the number of keys of the
+// document, and returns an error if it goes over the limit.
+func (d *decoder) countKeys(node *ast.Node) error {
+	if d.maxKeys <= 0 {
+		return nil
+	}
+
+	d.keys++
+	if d.keys > d.maxKeys {
+		it := node.Key()
+		it.Next()
+		return newDecodeError(it.Node().Data, "document contains more than %d keys", d.maxKeys)
+	}
+
+	if node.Kind == ast.KeyValue {
+		return d.countValueKeys(node.Value())
+	}
+
+	return nil
+}
+
+func (d *decoder) countValueKeys(value *ast.Node) error {
+	it := value.Children()
+
+	switch value.Kind {
+	case ast.InlineTable:
+		for it.Next() {
+			err := d.countKeys(it.Node())
+			if err != nil {
+				return err
+			}
+		}
+	case ast.Array:
+		for it.Next() {
+			err := d.countValueKeys(it.Node())
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func (d *decoder) handleArrayTable(key ast.Iterator, v reflect.Value) (reflect.Value, error) {
+	if raw, ok := rawValueTarget(v); ok {
+		return d.handleRawTable(key, raw, true)
+	}
+	if u, ok := tableUnmarshaler(v); ok {
+		return reflect.Value{}, d.handleTableUnmarshaler(key, u, true)
+	}
 	if key.Next() {
 		return d.handleArrayTablePart(key, v)
 	}
+	if isUnmarshaler(v) {
+		return reflect.Value{}, unmarshalerTableError(lastKey(d.expr()), v)
+	}
+	err := d.setHeaderComment(v)
+	if err != nil {
+		return reflect.Value{}, err
+	}
 	return d.handleKeyValues(v)
 }
 
@@ -317,14 +1321,16 @@ func (d *decoder) handleArrayTableCollectionLast(key ast.Iterator, v reflect.Val
 		if err != nil {
 			return reflect.Value{}, err
 		}
-		v.Elem().Set(elem)
+		if elem.IsValid() {
+			v.Elem().Set(elem)
+		}
 
 		return v, nil
 	case reflect.Slice:
 		elemType := v.Type().Elem()
 		var elem reflect.Value
 		if elemType.Kind() == reflect.Interface {
-			elem = makeMapStringInterface()
+			elem = d.makeTable()
 		} else {
 			elem = reflect.New(elemType).Elem()
 		}
@@ -346,6 +1352,16 @@ func (d *decoder) handleArrayTableCollectionLast(key ast.Iterator, v reflect.Val
 		return v, err
 	}
 
+	if _, ok := rawValueTarget(v); ok {
+		return reflect.Value{}, newDecodeError(key.Node().Data, "cannot store an array table in a RawValue, use a []RawValue")
+	}
+	if _, ok := tableUnmarshaler(v); ok {
+		return reflect.Value{}, tableUnmarshalerArrayError(key.Node().Data, v)
+	}
+	if d.isUnsupportedType(v.Type()) {
+		return reflect.Value{}, newDecodeError(key.Node().Data, "cannot decode array table %s into a Go value of type %s: %s values are not supported", key.Node().Data, v.Type(), v.Kind())
+	}
+
 	return d.handleArrayTable(key, v)
 }
 
@@ -403,6 +1419,26 @@ func (d *decoder) handleArrayTableCollection(key ast.Iterator, v reflect.Value)
 func (d *decoder) handleKeyPart(key ast.Iterator, v reflect.Value, nextFn handlerFn, makeFn valueMakerFn) (reflect.Value, error) {
 	var rv reflect.Value
 
+	if v.Type() == orderedMapType {
+		return d.handleOrderedMapKeyPart(key, v, nextFn, makeFn)
+	}
+
+	if r, ok := fieldResolver(v); ok {
+		return d.handleResolverPart(key, r, func(m reflect.Value) (reflect.Value, error) {
+			return d.handleKeyPart(key, m, nextFn, makeFn)
+		})
+	}
+
+	if isNullType(v.Type()) {
+		return d.handleNullPart(v, func(f reflect.Value) (reflect.Value, error) {
+			return d.handleKeyPart(key, f, nextFn, makeFn)
+		})
+	}
+
+	if isUnmarshaler(v) {
+		return reflect.Value{}, unmarshalerTableError(key.Node().Data, v)
+	}
+
 	// First, dispatch over v to make sure it is a valid object.
 	// There is no guarantee over what it could be.
 	switch v.Kind() {
@@ -412,12 +1448,23 @@ func (d *decoder) handleKeyPart(key ast.Iterator, v reflect.Value, nextFn handle
 			v.Set(reflect.New(v.Type().Elem()))
 		}
 		elem = v.Elem()
-		return d.handleKeyPart(key, elem, nextFn, makeFn)
+		x, err := d.handleKeyPart(key, elem, nextFn, makeFn)
+		if err != nil || d.skipUntilTable {
+			return reflect.Value{}, err
+		}
+		if x.IsValid() {
+			elem.Set(x)
+		}
+		return reflect.Value{}, nil
 	case reflect.Map:
 		vt := v.Type()
 
 		// Create the key for the map element. Convert to key type.
-		mk := reflect.ValueOf(string(key.Node().Data)).Convert(vt.Key())
+		k := string(key.Node().Data)
+		mk, err := d.mapKey(vt, key.Node())
+		if err != nil {
+			return reflect.Value{}, err
+		}
 
 		// If the map does not exist, create it.
 		if v.IsNil() {
@@ -426,6 +1473,11 @@ func (d *decoder) handleKeyPart(key ast.Iterator, v reflect.Value, nextFn handle
 			rv = v
 		}
 
+		err = d.checkIntegerKey(v, mk, key.Node())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
 		mv := v.MapIndex(mk)
 		set := false
 		if !mv.IsValid() {
@@ -466,12 +1518,57 @@ func (d *decoder) handleKeyPart(key ast.Iterator, v reflect.Value, nextFn handle
 			set = true
 		}
 
+		setTOMLKey(mv, k)
+
 		if set {
 			v.SetMapIndex(mk, mv)
 		}
 	case reflect.Struct:
-		path, found := structFieldPath(v, string(key.Node().Data))
+		if c := cachedStructInfo(v.Type()).conflict; c != "" {
+			return reflect.Value{}, newDecodeError(key.Node().Data, "%s", c)
+		}
+
+		path, found := d.keyFieldPath(v, key.Node().Data)
 		if !found {
+			if f, ok := dottedView(v, string(key.Node().Data)); ok {
+				x, err := nextFn(key, f)
+				if err != nil || d.skipUntilTable {
+					return reflect.Value{}, err
+				}
+				if x.IsValid() {
+					f.Set(x)
+				}
+				return reflect.Value{}, nil
+			}
+
+			if f, ok := structRemainingField(v); ok {
+				x, err := d.handleKeyPart(key, f, nextFn, makeFn)
+				if err != nil || d.skipUntilTable {
+					return reflect.Value{}, err
+				}
+				if x.IsValid() {
+					f.Set(x)
+				}
+				return reflect.Value{}, nil
+			}
+
+			d.skipUntilTable = true
+			return reflect.Value{}, nil
+		}
+
+		if d.isUnsupportedType(v.Type().FieldByIndex(path).Type) {
+			return reflect.Value{}, d.unsupportedFieldError(key.Node(), v.Type(), path)
+		}
+
+		err := d.checkAlias(v, path, key.Node().Data)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		d.checkDeprecated(v, path, key.Node())
+
+		f, ok := allocFieldByIndex(v, path)
+		if !ok {
 			d.skipUntilTable = true
 			return reflect.Value{}, nil
 		}
@@ -483,7 +1580,6 @@ func (d *decoder) handleKeyPart(key ast.Iterator, v reflect.Value, nextFn handle
 		d.errorContext.Struct = t
 		d.errorContext.Field = path
 
-		f := v.FieldByIndex(path)
 		x, err := nextFn(key, f)
 		if err != nil || d.skipUntilTable {
 			return reflect.Value{}, err
@@ -497,7 +1593,7 @@ func (d *decoder) handleKeyPart(key ast.Iterator, v reflect.Value, nextFn handle
 		if v.Elem().IsValid() {
 			v = v.Elem()
 		} else {
-			v = makeMapStringInterface()
+			v = d.makeTable()
 		}
 
 		x, err := d.handleKeyPart(key, v, nextFn, makeFn)
@@ -509,8 +1605,47 @@ func (d *decoder) handleKeyPart(key ast.Iterator, v reflect.Value, nextFn handle
 		}
 		rv = v
 	default:
-		panic(fmt.Errorf("unhandled part: %s", v.Kind()))
+		return reflect.Value{}, newDecodeError(d.p.Raw(key.Node().Raw), "cannot decode key %s into a Go value of type %s", key.Node().Data, v.Type())
+	}
+
+	return rv, nil
+}
+
+// handleOrderedMapKeyPart is the equivalent of the map case of handleKeyPart
+// for OrderedMap. Values created inside of it are OrderedMaps as well.
+func (d *decoder) handleOrderedMapKeyPart(key ast.Iterator, v reflect.Value, nextFn handlerFn, makeFn valueMakerFn) (reflect.Value, error) {
+	var rv reflect.Value
+
+	if !v.CanAddr() {
+		nv := reflect.New(orderedMapType).Elem()
+		nv.Set(v)
+		v = nv
+		rv = v
+	}
+
+	om := v.Addr().Interface().(*OrderedMap)
+	k := string(key.Node().Data)
+
+	ordered := d.ordered
+	d.ordered = true
+	defer func() { d.ordered = ordered }()
+
+	var mv reflect.Value
+	if x, ok := om.Get(k); ok && x != nil {
+		mv = reflect.ValueOf(x)
+	} else {
+		mv = makeFn()
+	}
+
+	x, err := nextFn(key, mv)
+	if err != nil || d.skipUntilTable {
+		return reflect.Value{}, err
 	}
+	if x.IsValid() {
+		mv = x
+	}
+
+	om.Set(k, mv.Interface())
 
 	return rv, nil
 }
@@ -523,7 +1658,7 @@ func (d *decoder) handleArrayTablePart(key ast.Iterator, v reflect.Value) (refle
 	if key.IsLast() {
 		makeFn = makeSliceInterface
 	} else {
-		makeFn = makeMapStringInterface
+		makeFn = d.makeTable
 	}
 	return d.handleKeyPart(key, v, d.handleArrayTableCollection, makeFn)
 }
@@ -545,12 +1680,25 @@ func (d *decoder) handleTable(key ast.Iterator, v reflect.Value) (reflect.Value,
 		}
 		return reflect.Value{}, nil
 	}
+	if raw, ok := rawValueTarget(v); ok {
+		return d.handleRawTable(key, raw, false)
+	}
+	if u, ok := tableUnmarshaler(v); ok {
+		return reflect.Value{}, d.handleTableUnmarshaler(key, u, false)
+	}
 	if key.Next() {
 		// Still scoping the key
 		return d.handleTablePart(key, v)
 	}
 	// Done scoping the key.
 	// Now handle all the key-value expressions in this table.
+	if isUnmarshaler(v) {
+		return reflect.Value{}, unmarshalerTableError(lastKey(d.expr()), v)
+	}
+	err := d.setHeaderComment(v)
+	if err != nil {
+		return reflect.Value{}, err
+	}
 	return d.handleKeyValues(v)
 }
 
@@ -569,15 +1717,26 @@ func (d *decoder) handleKeyValues(v reflect.Value) (reflect.Value, error) {
 			break
 		}
 
-		err := d.seen.CheckExpression(expr)
+		err := d.countKeys(expr)
 		if err != nil {
 			return reflect.Value{}, err
 		}
 
-		x, err := d.handleKeyValue(expr, v)
+		err = d.seen.CheckExpression(expr)
 		if err != nil {
 			return reflect.Value{}, err
 		}
+
+		d.required.KeyValue(expr)
+
+		x, err := d.handleKeyValue(expr, expr.Key(), v)
+		if err != nil {
+			err = d.collectError(expr, err)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			continue
+		}
 		if x.IsValid() {
 			v = x
 			rv = x
@@ -599,69 +1758,416 @@ func makeSliceInterface() reflect.Value {
 	return reflect.MakeSlice(sliceInterfaceType, 0, 16)
 }
 
+// makeTable creates the value used to store a table in an interface{}: a
+// map[string]interface{}, or an *OrderedMap when decoding inside an
+// OrderedMap.
+func (d *decoder) makeTable() reflect.Value {
+	if d.ordered {
+		return reflect.ValueOf(&OrderedMap{})
+	}
+	return makeMapStringInterface()
+}
+
+// tableType is the type of the values created by makeTable.
+func (d *decoder) tableType() reflect.Type {
+	if d.ordered {
+		return orderedMapPtrType
+	}
+	return mapStringInterfaceType
+}
+
 func (d *decoder) handleTablePart(key ast.Iterator, v reflect.Value) (reflect.Value, error) {
-	return d.handleKeyPart(key, v, d.handleTable, makeMapStringInterface)
+	return d.handleKeyPart(key, v, d.handleTable, d.makeTable)
 }
 
-func (d *decoder) tryTextUnmarshaler(node *ast.Node, v reflect.Value) (bool, error) {
-	// Special case for time, because we allow to unmarshal to it from
-	// different kind of AST nodes.
-	if v.Type() == timeType {
+// Unmarshaler is implemented by types that decode a TOML value themselves.
+//
+// UnmarshalTOML receives the bytes of the value as they appear in the
+// document: strings keep their quotes, and inline tables and arrays their
+// delimiters. A field accepting either `timeout = 30` or
+// `timeout = { value = 30, unit = "s" }` can branch on the first byte, and
+// decode an inline table with Unmarshal.
+//
+// Unmarshaler takes precedence over encoding.TextUnmarshaler. Only values are
+// passed to UnmarshalTOML: decoding a table header or a dotted key into an
+// Unmarshaler is an error.
+//
+// Unmarshaler must be implemented with a pointer receiver.
+type Unmarshaler interface {
+	UnmarshalTOML(data []byte) error
+}
+
+// ContextUnmarshaler is implemented by types that decode a TOML value
+// themselves, from the value as described by the unstable package. The node
+// gives the position of the value and of its elements in the document:
+// errors created with Node.Errorf are reported at the position of the node
+// they were created from.
+//
+//   func (p *Port) UnmarshalTOMLContext(node *unstable.Node) error {
+//     n, err := strconv.Atoi(string(node.Raw))
+//     if err != nil || n < 1 || n > 65535 {
+//       return node.Errorf("invalid port %s", node.Raw)
+//     }
+//     *p = Port(n)
+//     return nil
+//   }
+//
+// ContextUnmarshaler takes precedence over Unmarshaler, and follows the same
+// rules.
+type ContextUnmarshaler interface {
+	UnmarshalTOMLContext(node *unstable.Node) error
+}
+
+// KeySetter is implemented by types that need to know their key when they are
+// decoded as the value of a map. SetTOMLKey is called with the key every time
+// a value is decoded into the map element: for example, a
+// map[string]Server can default the name of each server to its key.
+//
+// A table spanning several expressions of the document, like a table and its
+// sub-tables, leads to several calls with the same key.
+//
+// KeySetter must be implemented with a pointer receiver.
+type KeySetter interface {
+	SetTOMLKey(key string)
+}
+
+// setTOMLKey calls SetTOMLKey on the map element mv, if it implements
+// KeySetter.
+func setTOMLKey(mv reflect.Value, key string) {
+	if mv.Kind() == reflect.Ptr {
+		if mv.IsNil() {
+			return
+		}
+		mv = mv.Elem()
+	}
+
+	if mv.Kind() == reflect.Struct {
+		if path := cachedStructInfo(mv.Type()).key; path != nil {
+			if f, ok := fieldByIndex(mv, path); ok && f.CanSet() {
+				f.SetString(key)
+			}
+		}
+	}
+
+	if mv.CanAddr() && mv.Addr().Type().Implements(keySetterType) {
+		mv.Addr().Interface().(KeySetter).SetTOMLKey(key)
+	}
+}
+
+// isUnmarshaler returns true if v implements Unmarshaler, even through
+// pointers.
+func isUnmarshaler(v reflect.Value) bool {
+	t := v.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t.Kind() != reflect.Interface && cachedTypeFlags(t)&typePtrUnmarshaler != 0
+}
+
+func implementsUnmarshaler(t reflect.Type) bool {
+	return cachedTypeFlags(t)&typeUnmarshaler != 0
+}
+
+// unmarshalerTableError reports a table or a dotted key decoded into an
+// Unmarshaler. key is the last key part that was resolved.
+func unmarshalerTableError(key []byte, v reflect.Value) error {
+	return newDecodeError(key, "cannot decode a table into %s, which implements toml.Unmarshaler: use an inline table", v.Type())
+}
+
+// rawValue returns the bytes of the value node as they appear in the
+// document.
+func (d *decoder) rawValue(node *ast.Node) []byte {
+	if node.Raw.Length > 0 {
+		return d.p.Raw(node.Raw)
+	}
+
+	return node.Data
+}
+
+// lastKey returns the last part of the key of a table header.
+func lastKey(expr *ast.Node) []byte {
+	var data []byte
+	it := expr.Key()
+	for it.Next() {
+		data = it.Node().Data
+	}
+	return data
+}
+
+func (d *decoder) tryUnmarshaler(node *ast.Node, v reflect.Value) (bool, error) {
+	if !v.CanAddr() || cachedTypeFlags(v.Type())&typePtrUnmarshaler == 0 {
 		return false, nil
 	}
 
-	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
-		err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(node.Data)
+	raw := d.rawValue(node)
+
+	if u, ok := v.Addr().Interface().(ContextUnmarshaler); ok {
+		n := d.unstableNode(node)
+		err := u.UnmarshalTOMLContext(&n)
 		if err != nil {
-			return false, newDecodeError(d.p.Raw(node.Raw), "%w", err)
+			return false, d.nodeError(raw, err)
 		}
-
 		return true, nil
 	}
 
-	return false, nil
+	err := v.Addr().Interface().(Unmarshaler).UnmarshalTOML(raw)
+	if err != nil {
+		return false, newDecodeError(raw, "%w", err)
+	}
+
+	return true, nil
 }
 
-func (d *decoder) handleValue(value *ast.Node, v reflect.Value) error {
-	for v.Kind() == reflect.Ptr {
-		v = initAndDereferencePointer(v)
+// unstableNode describes the value node for ContextUnmarshaler.
+func (d *decoder) unstableNode(node *ast.Node) unstable.Node {
+	raw := d.rawValue(node)
+	offset := danger.SubsliceOffset(d.p.data, raw)
+	line, column := positionAtEnd(d.p.data[:offset])
+
+	n := unstable.Node{
+		Raw:    raw,
+		Offset: offset,
+		Line:   line,
+		Column: column,
 	}
 
-	ok, err := d.tryTextUnmarshaler(value, v)
-	if ok || err != nil {
-		return err
+	it := node.Children()
+	switch node.Kind {
+	case ast.Array:
+		n.Kind = unstable.ArrayOpen
+		for it.Next() {
+			n.Children = append(n.Children, d.unstableNode(it.Node()))
+		}
+	case ast.InlineTable:
+		n.Kind = unstable.InlineTableOpen
+		for it.Next() {
+			kv := it.Node()
+			c := d.unstableNode(kv.Value())
+			k := kv.Key()
+			for k.Next() {
+				c.Key = append(c.Key, string(k.Node().Data))
+			}
+			n.Children = append(n.Children, c)
+		}
+	default:
+		n.Kind = unstableKind(node.Kind)
 	}
 
-	switch value.Kind {
+	return n
+}
+
+func unstableKind(k ast.Kind) unstable.Kind {
+	switch k {
 	case ast.String:
-		return d.unmarshalString(value, v)
-	case ast.Integer:
-		return d.unmarshalInteger(value, v)
-	case ast.Float:
-		return d.unmarshalFloat(value, v)
+		return unstable.String
 	case ast.Bool:
-		return d.unmarshalBool(value, v)
-	case ast.DateTime:
-		return d.unmarshalDateTime(value, v)
+		return unstable.Bool
+	case ast.Float:
+		return unstable.Float
+	case ast.Integer:
+		return unstable.Integer
 	case ast.LocalDate:
-		return d.unmarshalLocalDate(value, v)
+		return unstable.LocalDate
 	case ast.LocalTime:
-		return d.unmarshalLocalTime(value, v)
+		return unstable.LocalTime
 	case ast.LocalDateTime:
-		return d.unmarshalLocalDateTime(value, v)
-	case ast.InlineTable:
-		return d.unmarshalInlineTable(value, v)
-	case ast.Array:
-		return d.unmarshalArray(value, v)
-	default:
-		panic(fmt.Errorf("handleValue not implemented for %s", value.Kind))
+		return unstable.LocalDateTime
+	case ast.DateTime:
+		return unstable.DateTime
 	}
+	return unstable.Invalid
 }
 
-func (d *decoder) unmarshalArray(array *ast.Node, v reflect.Value) error {
-	switch v.Kind() {
-	case reflect.Slice:
-		if v.IsNil() {
+// nodeError positions the error returned by UnmarshalTOMLContext or a
+// TypeDecoderFunc for the value raw. Errors created with Node.Errorf are
+// reported at the position of their node.
+func (d *decoder) nodeError(raw []byte, err error) error {
+	var ue *unstable.Error
+	if errors.As(err, &ue) && ue.Offset >= 0 && ue.Length > 0 && ue.Offset+ue.Length <= len(d.p.data) {
+		return &decodeError{
+			highlight: d.p.data[ue.Offset : ue.Offset+ue.Length],
+			message:   ue.Message,
+			cause:     err,
+		}
+	}
+
+	return newDecodeError(raw, "%w", err)
+}
+
+func (d *decoder) tryTextUnmarshaler(node *ast.Node, v reflect.Value) (bool, error) {
+	// Special case for time, because we allow to unmarshal to it from
+	// different kind of AST nodes.
+	if v.Type() == timeType {
+		return false, nil
+	}
+
+	// Local date and time types and big numbers are decoded from the
+	// corresponding TOML types, but also from strings using their UnmarshalText
+	// method.
+	switch v.Type() {
+	case localDateType, localTimeType, localDateTimeType, bigIntType, bigFloatType:
+		if node.Kind != ast.String {
+			return false, nil
+		}
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText(node.Data)
+		if err != nil {
+			return false, newDecodeError(d.p.Raw(node.Raw), "%w", err)
+		}
+
+		return true, nil
+	}
+
+	if d.setMethods && node.Kind == ast.String && v.CanAddr() && v.Addr().Type().Implements(stringSetterType) {
+		err := v.Addr().Interface().(stringSetter).Set(string(node.Data))
+		if err != nil {
+			return false, newDecodeError(d.p.Raw(node.Raw), "%w", err)
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (d *decoder) handleValue(value *ast.Node, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr {
+		v = initAndDereferencePointer(v)
+	}
+
+	if v.Type() == rawValueType {
+		return d.unmarshalRawValue(value, v)
+	}
+
+	if d.isUnsupportedType(v.Type()) {
+		return newDecodeError(d.rawValue(value), "%s: %s values are not supported", d.typeMismatchMessage(metaType(value.Kind), v.Type()), v.Kind())
+	}
+
+	ok, err := d.tryTypeDecoder(value, v)
+	if ok || err != nil {
+		return err
+	}
+
+	ok, err = d.tryUnmarshaler(value, v)
+	if ok || err != nil {
+		return err
+	}
+
+	ok, err = d.tryTextUnmarshaler(value, v)
+	if ok || err != nil {
+		return err
+	}
+
+	if isNullType(v.Type()) {
+		return d.unmarshalNull(value, v)
+	}
+
+	if isAtomicType(v.Type()) {
+		return d.unmarshalAtomic(value, v)
+	}
+
+	switch value.Kind {
+	case ast.String:
+		switch v.Type() {
+		case durationType:
+			return d.unmarshalDuration(value, v)
+		case urlType:
+			return d.unmarshalURL(value, v)
+		case ipNetType:
+			return d.unmarshalIPNet(value, v)
+		case timeType:
+			if d.fieldOptions().layout != "" {
+				return d.unmarshalTimeLayout(value, v)
+			}
+		}
+		if d.binaryUnmarshaler && v.Type() != timeType && v.CanAddr() && v.Addr().Type().Implements(binaryUnmarshalerType) {
+			return d.unmarshalBinary(value, v)
+		}
+		return d.unmarshalString(value, v)
+	case ast.Integer:
+		switch v.Type() {
+		case timeType:
+			return d.unmarshalEpoch(value, v)
+		case bigIntType, bigFloatType:
+			return d.unmarshalBigNumber(value, v)
+		}
+		return d.unmarshalInteger(value, v)
+	case ast.Float:
+		if v.Type() == bigIntType || v.Type() == bigFloatType {
+			return d.unmarshalBigNumber(value, v)
+		}
+		return d.unmarshalFloat(value, v)
+	case ast.Bool:
+		return d.unmarshalBool(value, v)
+	case ast.DateTime:
+		return d.unmarshalDateTime(value, v)
+	case ast.LocalDate:
+		return d.unmarshalLocalDate(value, v)
+	case ast.LocalTime:
+		return d.unmarshalLocalTime(value, v)
+	case ast.LocalDateTime:
+		return d.unmarshalLocalDateTime(value, v)
+	case ast.InlineTable:
+		return d.unmarshalInlineTable(value, v)
+	case ast.Array:
+		return d.unmarshalArray(value, v)
+	default:
+		panic(fmt.Errorf("handleValue not implemented for %s", value.Kind))
+	}
+}
+
+// handleNullPart marks the null type v as valid, and continues decoding the
+// table it holds with next.
+func (d *decoder) handleNullPart(v reflect.Value, next func(f reflect.Value) (reflect.Value, error)) (reflect.Value, error) {
+	v.Field(1).SetBool(true)
+
+	f := v.Field(0)
+	x, err := next(f)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if x.IsValid() {
+		f.Set(x)
+	}
+
+	return reflect.Value{}, nil
+}
+
+// unmarshalAtomic decodes value into the sync/atomic value v, and stores it
+// with its Store method.
+func (d *decoder) unmarshalAtomic(value *ast.Node, v reflect.Value) error {
+	if !v.CanAddr() {
+		return newDecodeError(d.rawValue(value), "cannot store a value in a %s that is not addressable", v.Type())
+	}
+
+	at := atomicTypes[v.Type()]
+	x := reflect.New(at.value).Elem()
+	err := d.handleValue(value, x)
+	if err != nil {
+		return err
+	}
+	at.store(v, x)
+
+	return nil
+}
+
+// unmarshalNull decodes value into the null type v, and marks it as valid.
+func (d *decoder) unmarshalNull(value *ast.Node, v reflect.Value) error {
+	err := d.handleValue(value, v.Field(0))
+	if err != nil {
+		return err
+	}
+	v.Field(1).SetBool(true)
+	return nil
+}
+
+func (d *decoder) unmarshalArray(array *ast.Node, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
 			v.Set(reflect.MakeSlice(v.Type(), 0, 16))
 		} else {
 			v.SetLen(0)
@@ -709,27 +2215,71 @@ func (d *decoder) unmarshalArray(array *ast.Node, v reflect.Value) error {
 
 			err := d.handleValue(n, elem)
 			if err != nil {
-				return err
+				return arrayElementError(idx, err)
 			}
 
 			v.Set(reflect.Append(v, elem))
 		} else { // array
 			if idx >= v.Len() {
-				return nil
+				return d.arrayLengthError(array, v)
 			}
 			elem := v.Index(idx)
 			err := d.handleValue(n, elem)
 			if err != nil {
-				return err
+				return arrayElementError(idx, err)
 			}
-			idx++
+		}
+		idx++
+	}
+
+	// Elements of Go arrays that the TOML array does not provide are reset.
+	if v.Kind() == reflect.Array {
+		for ; idx < v.Len(); idx++ {
+			v.Index(idx).Set(reflect.Zero(elemType))
 		}
 	}
 
 	return nil
 }
 
+// arrayLengthError returns an error about the TOML array having more elements
+// than the Go array v can hold.
+func (d *decoder) arrayLengthError(array *ast.Node, v reflect.Value) error {
+	n := 0
+	it := array.Children()
+	for it.Next() {
+		n++
+	}
+
+	// Only the first line of multi-line arrays is highlighted.
+	raw := d.p.Raw(array.Raw)
+	if i := strings.IndexByte(string(raw), '\n'); i >= 0 {
+		raw = raw[:i]
+	}
+	return newDecodeError(raw, "array of %d elements cannot be decoded into a %s", n, v.Type())
+}
+
+// arrayElementError adds the index of the array element whose decoding caused
+// err to its message, when err points to a location in the document.
+func arrayElementError(idx int, err error) error {
+	var de *decodeError
+	if !errors.As(err, &de) {
+		return err
+	}
+
+	return &decodeError{
+		highlight: de.highlight,
+		message:   fmt.Sprintf("array element %d: %s", idx, de.message),
+		key:       de.key,
+		cause:     de.cause,
+	}
+}
+
 func (d *decoder) unmarshalInlineTable(itable *ast.Node, v reflect.Value) error {
+	if u, ok := tableUnmarshaler(v); ok {
+		return d.unmarshalTableUnmarshaler(itable, u)
+	}
+
 	// Make sure v is an initialized object.
 	switch v.Kind() {
 	case reflect.Map:
@@ -741,10 +2291,15 @@ func (d *decoder) unmarshalInlineTable(itable *ast.Node, v reflect.Value) error
 	case reflect.Interface:
 		elem := v.Elem()
 		if !elem.IsValid() {
-			elem = makeMapStringInterface()
+			elem = d.makeTable()
+			if m, ok := elem.Interface().(*OrderedMap); ok {
+				m.kind = InlineTable
+			}
 			v.Set(elem)
 		}
 		return d.unmarshalInlineTable(itable, elem)
+	case reflect.Ptr:
+		return d.unmarshalInlineTable(itable, initAndDereferencePointer(v))
 	default:
 		return newDecodeError(itable.Data, "cannot store inline table in Go type %s", v.Kind())
 	}
@@ -753,7 +2308,7 @@ func (d *decoder) unmarshalInlineTable(itable *ast.Node, v reflect.Value) error
 	for it.Next() {
 		n := it.Node()
 
-		x, err := d.handleKeyValue(n, v)
+		x, err := d.handleKeyValue(n, n.Key(), v)
 		if err != nil {
 			return err
 		}
@@ -766,15 +2321,61 @@ func (d *decoder) unmarshalInlineTable(itable *ast.Node, v reflect.Value) error
 }
 
 func (d *decoder) unmarshalDateTime(value *ast.Node, v reflect.Value) error {
+	err := d.checkStrictDateTime(value)
+	if err != nil {
+		return err
+	}
+
 	dt, err := parseDateTime(value.Data)
 	if err != nil {
 		return err
 	}
 
-	v.Set(reflect.ValueOf(dt))
+	return d.setDateTime(value, "offset date-time", v, reflect.ValueOf(dt))
+}
+
+// checkStrictDateTime returns an error if strict date-times are enabled and the
+// date-time value does not use an uppercase T and Z.
+func (d *decoder) checkStrictDateTime(value *ast.Node) error {
+	if !d.strictDateTimes {
+		return nil
+	}
+
+	b := value.Data
+	if len(b) > 10 && b[10] != 'T' {
+		return newDecodeError(b[10:11], "date-time separator must be an uppercase T")
+	}
+	if b[len(b)-1] == 'z' {
+		return newDecodeError(b[len(b)-1:], "UTC offset must be an uppercase Z")
+	}
+
 	return nil
 }
 
+// setDateTime stores the date or time x decoded from value in v, or returns an
+// error if v cannot hold it.
+func (d *decoder) setDateTime(value *ast.Node, toml string, v reflect.Value, x reflect.Value) error {
+	if c, ok := d.compatLocal(v, x.Interface()); ok {
+		x = c
+	}
+
+	if !x.Type().AssignableTo(v.Type()) {
+		return newDecodeError(value.Data, "cannot store TOML %s into a Go %s", toml, v.Type())
+	}
+
+	v.Set(x)
+	return nil
+}
+
+// location returns the location to use for local date and date-times decoded
+// into a time.Time.
+func (d *decoder) location() *time.Location {
+	if d.defaultLocation != nil {
+		return d.defaultLocation
+	}
+	return time.Local
+}
+
 func (d *decoder) unmarshalLocalDate(value *ast.Node, v reflect.Value) error {
 	ld, err := parseLocalDate(value.Data)
 	if err != nil {
@@ -782,14 +2383,12 @@ func (d *decoder) unmarshalLocalDate(value *ast.Node, v reflect.Value) error {
 	}
 
 	if v.Type() == timeType {
-		cast := ld.AsTime(time.Local)
+		cast := ld.AsTime(d.location())
 		v.Set(reflect.ValueOf(cast))
 		return nil
 	}
 
-	v.Set(reflect.ValueOf(ld))
-
-	return nil
+	return d.setDateTime(value, "local date", v, reflect.ValueOf(ld))
 }
 
 func (d *decoder) unmarshalLocalTime(value *ast.Node, v reflect.Value) error {
@@ -802,11 +2401,21 @@ func (d *decoder) unmarshalLocalTime(value *ast.Node, v reflect.Value) error {
 		return newDecodeError(rest, "extra characters at the end of a local time")
 	}
 
-	v.Set(reflect.ValueOf(lt))
-	return nil
+	if v.Type() == timeType {
+		cast := time.Date(0, time.January, 1, lt.Hour, lt.Minute, lt.Second, lt.Nanosecond, d.location())
+		v.Set(reflect.ValueOf(cast))
+		return nil
+	}
+
+	return d.setDateTime(value, "local time", v, reflect.ValueOf(lt))
 }
 
 func (d *decoder) unmarshalLocalDateTime(value *ast.Node, v reflect.Value) error {
+	err := d.checkStrictDateTime(value)
+	if err != nil {
+		return err
+	}
+
 	ldt, rest, err := parseLocalDateTime(value.Data)
 	if err != nil {
 		return err
@@ -817,15 +2426,13 @@ func (d *decoder) unmarshalLocalDateTime(value *ast.Node, v reflect.Value) error
 	}
 
 	if v.Type() == timeType {
-		cast := ldt.AsTime(time.Local)
+		cast := ldt.AsTime(d.location())
 
 		v.Set(reflect.ValueOf(cast))
 		return nil
 	}
 
-	v.Set(reflect.ValueOf(ldt))
-
-	return nil
+	return d.setDateTime(value, "local date-time", v, reflect.ValueOf(ldt))
 }
 
 func (d *decoder) unmarshalBool(value *ast.Node, v reflect.Value) error {
@@ -849,13 +2456,21 @@ func (d *decoder) unmarshalFloat(value *ast.Node, v reflect.Value) error {
 		return err
 	}
 
+	if d.isNumber(v) {
+		v.Set(reflect.ValueOf(Number{raw: string(value.Data), value: f}))
+		return nil
+	}
+
 	switch v.Kind() {
 	case reflect.Float64:
 		v.SetFloat(f)
 	case reflect.Float32:
-		if f > math.MaxFloat32 {
+		if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
 			return newDecodeError(value.Data, "number %f does not fit in a float32", f)
 		}
+		if d.strictFloat32 && !math.IsNaN(f) && float64(float32(f)) != f {
+			return newDecodeError(value.Data, "number %s cannot be represented exactly as a float32", value.Data)
+		}
 		v.SetFloat(f)
 	case reflect.Interface:
 		v.Set(reflect.ValueOf(f))
@@ -866,6 +2481,54 @@ func (d *decoder) unmarshalFloat(value *ast.Node, v reflect.Value) error {
 	return nil
 }
 
+// unmarshalEpoch decodes an integer into a time.Time, for struct fields with
+// the epoch option.
+func (d *decoder) unmarshalEpoch(value *ast.Node, v reflect.Value) error {
+	unit := d.fieldOptions().epoch
+	if unit == "" {
+		return d.typeMismatchError("integer", v.Type())
+	}
+
+	i, err := parseInteger(value.Data)
+	if err != nil {
+		return err
+	}
+
+	var t time.Time
+	switch unit {
+	case "s":
+		t = time.Unix(i, 0)
+	case "ms":
+		t = time.Unix(i/1e3, (i%1e3)*int64(time.Millisecond))
+	case "us":
+		t = time.Unix(i/1e6, (i%1e6)*int64(time.Microsecond))
+	case "ns":
+		t = time.Unix(0, i)
+	default:
+		return fmt.Errorf("toml: unsupported epoch unit %q", unit)
+	}
+
+	v.Set(reflect.ValueOf(t.In(d.location())))
+
+	return nil
+}
+
+// unmarshalTimeLayout decodes a string into a time.Time, for struct fields
+// with the layout option. Times without an offset are in the location of the
+// decoder.
+func (d *decoder) unmarshalTimeLayout(value *ast.Node, v reflect.Value) error {
+	layout := d.fieldOptions().layout
+
+	t, err := time.ParseInLocation(layout, string(value.Data), d.location())
+	if err != nil {
+		return newDecodeError(d.p.Raw(value.Raw), "invalid time %q, expected layout %q", value.Data, layout)
+	}
+
+	v.Set(reflect.ValueOf(t))
+
+	return nil
+}
+
 const (
 	maxInt = int64(^uint(0) >> 1)
 	minInt = -maxInt - 1
@@ -886,12 +2549,45 @@ func init() {
 	}
 }
 
+// unmarshalBigNumber decodes the TOML integer or float value into the
+// big.Int or big.Float v.
+func (d *decoder) unmarshalBigNumber(value *ast.Node, v reflect.Value) error {
+	var x interface{}
+	var err error
+
+	switch {
+	case value.Kind == ast.Float && v.Type() == bigIntType:
+		return newDecodeError(value.Data, "float cannot be assigned to %s", v.Type())
+	case value.Kind == ast.Float:
+		x, err = parseBigFloat(value.Data)
+	case v.Type() == bigFloatType:
+		var i *big.Int
+		i, err = parseBigInt(value.Data)
+		if err == nil {
+			x = new(big.Float).SetInt(i)
+		}
+	default:
+		x, err = parseBigInt(value.Data)
+	}
+	if err != nil {
+		return err
+	}
+
+	v.Set(reflect.ValueOf(x).Elem())
+	return nil
+}
+
 func (d *decoder) unmarshalInteger(value *ast.Node, v reflect.Value) error {
 	i, err := parseInteger(value.Data)
 	if err != nil {
 		return err
 	}
 
+	if d.isNumber(v) {
+		v.Set(reflect.ValueOf(Number{raw: string(value.Data), value: i}))
+		return nil
+	}
+
 	var r reflect.Value
 
 	switch v.Kind() {
@@ -900,55 +2596,55 @@ func (d *decoder) unmarshalInteger(value *ast.Node, v reflect.Value) error {
 		return nil
 	case reflect.Int32:
 		if i < math.MinInt32 || i > math.MaxInt32 {
-			return fmt.Errorf("toml: number %d does not fit in an int32", i)
+			return intRangeError(value, i, v.Kind())
 		}
 
 		r = reflect.ValueOf(int32(i))
 	case reflect.Int16:
 		if i < math.MinInt16 || i > math.MaxInt16 {
-			return fmt.Errorf("toml: number %d does not fit in an int16", i)
+			return intRangeError(value, i, v.Kind())
 		}
 
 		r = reflect.ValueOf(int16(i))
 	case reflect.Int8:
 		if i < math.MinInt8 || i > math.MaxInt8 {
-			return fmt.Errorf("toml: number %d does not fit in an int8", i)
+			return intRangeError(value, i, v.Kind())
 		}
 
 		r = reflect.ValueOf(int8(i))
 	case reflect.Int:
 		if i < minInt || i > maxInt {
-			return fmt.Errorf("toml: number %d does not fit in an int", i)
+			return intRangeError(value, i, v.Kind())
 		}
 
 		r = reflect.ValueOf(int(i))
 	case reflect.Uint64:
 		if i < 0 {
-			return fmt.Errorf("toml: negative number %d does not fit in an uint64", i)
+			return intRangeError(value, i, v.Kind())
 		}
 
 		r = reflect.ValueOf(uint64(i))
 	case reflect.Uint32:
 		if i < 0 || i > math.MaxUint32 {
-			return fmt.Errorf("toml: negative number %d does not fit in an uint32", i)
+			return intRangeError(value, i, v.Kind())
 		}
 
 		r = reflect.ValueOf(uint32(i))
 	case reflect.Uint16:
 		if i < 0 || i > math.MaxUint16 {
-			return fmt.Errorf("toml: negative number %d does not fit in an uint16", i)
+			return intRangeError(value, i, v.Kind())
 		}
 
 		r = reflect.ValueOf(uint16(i))
 	case reflect.Uint8:
 		if i < 0 || i > math.MaxUint8 {
-			return fmt.Errorf("toml: negative number %d does not fit in an uint8", i)
+			return intRangeError(value, i, v.Kind())
 		}
 
 		r = reflect.ValueOf(uint8(i))
 	case reflect.Uint:
 		if i < 0 || i > maxUint {
-			return fmt.Errorf("toml: negative number %d does not fit in an uint", i)
+			return intRangeError(value, i, v.Kind())
 		}
 
 		r = reflect.ValueOf(uint(i))
@@ -967,63 +2663,336 @@ func (d *decoder) unmarshalInteger(value *ast.Node, v reflect.Value) error {
 	return nil
 }
 
+// intRangeError reports the integer i of the node value, that does not fit in
+// a Go integer of kind k.
+func intRangeError(value *ast.Node, i int64, k reflect.Kind) error {
+	switch k {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i < 0 {
+			return newDecodeError(value.Data, "negative number %d does not fit in an %s", i, k)
+		}
+	}
+	return newDecodeError(value.Data, "number %d does not fit in an %s", i, k)
+}
+
+// isNumber returns true if TOML numbers are decoded into v as a Number.
+func (d *decoder) isNumber(v reflect.Value) bool {
+	if v.Type() == numberType {
+		return true
+	}
+	return d.preserveNumbers && v.Kind() == reflect.Interface && numberType.AssignableTo(v.Type())
+}
+
 func (d *decoder) unmarshalString(value *ast.Node, v reflect.Value) error {
 	switch v.Kind() {
 	case reflect.String:
+		err := d.checkOneOf(value)
+		if err != nil {
+			return err
+		}
+		err = d.checkEnum(value, v)
+		if err != nil {
+			return err
+		}
 		v.SetString(string(value.Data))
 	case reflect.Interface:
 		v.Set(reflect.ValueOf(string(value.Data)))
 	default:
+		if (d.parseQuotedNumbers || d.thousandsSep) && isNumericKind(v.Kind()) {
+			return d.unmarshalQuotedNumber(value, v)
+		}
 		return newDecodeError(d.p.Raw(value.Raw), "cannot store TOML string into a Go %s", v.Kind())
 	}
 
 	return nil
 }
 
-func (d *decoder) handleKeyValue(expr *ast.Node, v reflect.Value) (reflect.Value, error) {
-	d.strict.EnterKeyValue(expr)
-
-	v, err := d.handleKeyValueInner(expr.Key(), expr.Value(), v)
-	if d.skipUntilTable {
-		d.strict.MissingField(expr)
-		d.skipUntilTable = false
+// unmarshalDuration decodes a string like "1h30m" into the time.Duration v.
+func (d *decoder) unmarshalDuration(value *ast.Node, v reflect.Value) error {
+	x, err := time.ParseDuration(string(value.Data))
+	if err != nil {
+		return newDecodeError(d.p.Raw(value.Raw), "invalid duration %q", value.Data)
 	}
 
-	d.strict.ExitKeyValue(expr)
+	v.SetInt(int64(x))
 
-	return v, err
+	return nil
 }
 
-func (d *decoder) handleKeyValueInner(key ast.Iterator, value *ast.Node, v reflect.Value) (reflect.Value, error) {
-	if key.Next() {
-		// Still scoping the key
-		return d.handleKeyValuePart(key, value, v)
+func (d *decoder) unmarshalURL(value *ast.Node, v reflect.Value) error {
+	u, err := url.Parse(string(value.Data))
+	if err != nil {
+		return newDecodeError(d.p.Raw(value.Raw), "invalid URL: %w", err)
 	}
-	// Done scoping the key.
-	// v is whatever Go value we need to fill.
-	return reflect.Value{}, d.handleValue(value, v)
-}
 
-func (d *decoder) handleKeyValuePart(key ast.Iterator, value *ast.Node, v reflect.Value) (reflect.Value, error) {
-	// contains the replacement for v
-	var rv reflect.Value
+	v.Set(reflect.ValueOf(*u))
+
+	return nil
+}
+
+// unmarshalBinary decodes the base64 string value with the UnmarshalBinary
+// method of v.
+func (d *decoder) unmarshalBinary(value *ast.Node, v reflect.Value) error {
+	data, err := base64.StdEncoding.DecodeString(string(value.Data))
+	if err != nil {
+		return newDecodeError(d.p.Raw(value.Raw), "invalid base64 string for %s: %w", v.Type(), err)
+	}
+
+	err = v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+	if err != nil {
+		return newDecodeError(d.p.Raw(value.Raw), "%w", err)
+	}
+
+	return nil
+}
+
+func (d *decoder) unmarshalIPNet(value *ast.Node, v reflect.Value) error {
+	_, n, err := net.ParseCIDR(string(value.Data))
+	if err != nil {
+		return newDecodeError(d.p.Raw(value.Raw), "%w", err)
+	}
+
+	v.Set(reflect.ValueOf(*n))
+
+	return nil
+}
+
+// checkOneOf returns an error if the struct field being decoded has the oneof
+// option, and the string value is not part of its allowed values.
+func (d *decoder) checkOneOf(value *ast.Node) error {
+	allowed := d.fieldOptions().oneof
+	if allowed == nil {
+		return nil
+	}
+
+	s := string(value.Data)
+	for _, a := range allowed {
+		if s == a {
+			return nil
+		}
+	}
+
+	return newDecodeError(d.p.Raw(value.Raw), "value %q is not one of %s", s, strings.Join(allowed, ", "))
+}
+
+func isNumericKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// unmarshalQuotedNumber decodes a TOML string containing a number into the
+// numeric value v.
+func (d *decoder) unmarshalQuotedNumber(value *ast.Node, v reflect.Value) error {
+	raw := d.p.Raw(value.Raw)
+	s := string(value.Data)
+
+	if d.thousandsSep && strings.Contains(s, ",") {
+		var ok bool
+		s, ok = stripThousandsSeparators(s)
+		if !ok {
+			return newDecodeError(raw, "invalid thousands separators in number %q", value.Data)
+		}
+	}
 
-	// First, dispatch over v to make sure it is a valid object.
-	// There is no guarantee over what it could be.
 	switch v.Kind() {
-	case reflect.Map:
-		vt := v.Type()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return newDecodeError(raw, "cannot parse string as integer: %w", err)
+		}
+		if v.OverflowInt(i) {
+			return newDecodeError(raw, "number %d does not fit in a %s", i, v.Kind())
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return newDecodeError(raw, "cannot parse string as unsigned integer: %w", err)
+		}
+		if v.OverflowUint(u) {
+			return newDecodeError(raw, "number %d does not fit in a %s", u, v.Kind())
+		}
+		v.SetUint(u)
+	default: // float
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return newDecodeError(raw, "cannot parse string as float: %w", err)
+		}
+		if v.OverflowFloat(f) {
+			return newDecodeError(raw, "number %f does not fit in a %s", f, v.Kind())
+		}
+		v.SetFloat(f)
+	}
+
+	return nil
+}
+
+// stripThousandsSeparators removes the commas grouping the digits of the
+// integer part of the number s by thousands. It returns false if the commas
+// are not placed every three digits.
+func stripThousandsSeparators(s string) (string, bool) {
+	sign := ""
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		sign, s = s[:1], s[1:]
+	}
+
+	end := strings.IndexAny(s, ".eE")
+	if end < 0 {
+		end = len(s)
+	}
+	integer, rest := s[:end], s[end:]
+
+	if strings.Contains(rest, ",") {
+		return "", false
+	}
+
+	groups := strings.Split(integer, ",")
+	for i, g := range groups {
+		if len(g) > 3 || g == "" || (i > 0 && len(g) != 3) {
+			return "", false
+		}
+	}
+
+	return sign + strings.Join(groups, "") + rest, true
+}
+
+func (d *decoder) handleKeyValue(expr *ast.Node, key ast.Iterator, v reflect.Value) (reflect.Value, error) {
+	d.strict.EnterKeyValue(expr)
+
+	v, err := d.handleKeyValueInner(key, expr.Value(), v)
+	if d.skipUntilTable {
+		if merr := d.strict.MissingField(expr); err == nil {
+			err = merr
+		}
+		d.skipUntilTable = false
+	}
+
+	d.strict.ExitKeyValue(expr)
+
+	return v, err
+}
+
+func (d *decoder) handleKeyValueInner(key ast.Iterator, value *ast.Node, v reflect.Value) (reflect.Value, error) {
+	if raw, ok := rawValueTarget(v); ok {
+		return d.handleRawKeyValue(key, value, raw)
+	}
+	if u, ok := tableUnmarshaler(v); ok {
+		if parts := rawKeyParts(key); len(parts) > 0 {
+			return reflect.Value{}, d.handleTableUnmarshalerKeyValue(parts, value, u)
+		}
+	}
+	if key.Next() {
+		// Still scoping the key
+		return d.handleKeyValuePart(key, value, v)
+	}
+	// Done scoping the key.
+	// v is whatever Go value we need to fill.
+	if d.seen.AllowRepeatedScalars && value.Kind.IsScalar() {
+		return reflect.Value{}, d.handleRepeatableValue(value, v)
+	}
+	return reflect.Value{}, d.handleValue(value, v)
+}
+
+// handleRepeatableValue decodes the scalar value of a key that may be repeated.
+// Values are appended to slices, and accumulated in a []interface{} when
+// stored in an interface{}.
+func (d *decoder) handleRepeatableValue(value *ast.Node, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr {
+		v = initAndDereferencePointer(v)
+	}
+
+	if v.CanAddr() && (v.Addr().Type().Implements(textUnmarshalerType) || implementsUnmarshaler(v.Addr().Type())) {
+		return d.handleValue(value, v)
+	}
+
+	if d.typeDecoders[v.Type()] != nil {
+		return d.handleValue(value, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		if !d.seen.Repeated() && !v.IsNil() {
+			v.SetLen(0)
+		}
+
+		elem := reflect.New(v.Type().Elem()).Elem()
+		err := d.handleValue(value, elem)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.Append(v, elem))
+
+		return nil
+	case reflect.Interface:
+		if !d.seen.Repeated() || !v.Elem().IsValid() {
+			return d.handleValue(value, v)
+		}
+
+		var x interface{}
+		err := d.handleValue(value, reflect.ValueOf(&x).Elem())
+		if err != nil {
+			return err
+		}
+
+		values, ok := v.Elem().Interface().([]interface{})
+		if !ok {
+			values = []interface{}{v.Elem().Interface()}
+		}
+		v.Set(reflect.ValueOf(append(values, x)))
+
+		return nil
+	default:
+		return d.handleValue(value, v)
+	}
+}
 
-		mk := reflect.ValueOf(string(key.Node().Data))
-		mkt := stringType
+func (d *decoder) handleKeyValuePart(key ast.Iterator, value *ast.Node, v reflect.Value) (reflect.Value, error) {
+	// contains the replacement for v
+	var rv reflect.Value
+
+	if v.Type() == orderedMapType {
+		return d.handleOrderedMapKeyValuePart(key, value, v)
+	}
+
+	if r, ok := fieldResolver(v); ok {
+		return d.handleResolverPart(key, r, func(m reflect.Value) (reflect.Value, error) {
+			return d.handleKeyValuePart(key, value, m)
+		})
+	}
+
+	if isNullType(v.Type()) {
+		return d.handleNullPart(v, func(f reflect.Value) (reflect.Value, error) {
+			return d.handleKeyValuePart(key, value, f)
+		})
+	}
 
-		keyType := vt.Key()
-		if !mkt.AssignableTo(keyType) {
-			if !mkt.ConvertibleTo(keyType) {
-				return reflect.Value{}, fmt.Errorf("toml: cannot convert map key of type %s to expected type %s", mkt, keyType)
+	if isUnmarshaler(v) {
+		return reflect.Value{}, unmarshalerTableError(key.Node().Data, v)
+	}
+
+	// First, dispatch over v to make sure it is a valid object.
+	// There is no guarantee over what it could be.
+	switch v.Kind() {
+	case reflect.Map:
+		if key.IsLast() {
+			nv, ok, err := d.fastMapKeyValue(key.Node(), value, v)
+			if ok {
+				return nv, err
 			}
+		}
 
-			mk = mk.Convert(keyType)
+		vt := v.Type()
+
+		k := string(key.Node().Data)
+		mk, err := d.mapKey(vt, key.Node())
+		if err != nil {
+			return reflect.Value{}, err
 		}
 
 		// If the map does not exist, create it.
@@ -1032,6 +3001,11 @@ func (d *decoder) handleKeyValuePart(key ast.Iterator, value *ast.Node, v reflec
 			rv = v
 		}
 
+		err = d.checkIntegerKey(v, mk, key.Node())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
 		mv := v.MapIndex(mk)
 		set := false
 		if !mv.IsValid() {
@@ -1039,8 +3013,19 @@ func (d *decoder) handleKeyValuePart(key ast.Iterator, value *ast.Node, v reflec
 			mv = reflect.New(v.Type().Elem()).Elem()
 		} else {
 			if key.IsLast() {
-				var x interface{}
-				mv = reflect.ValueOf(&x).Elem()
+				old := mv
+				mv = reflect.New(v.Type().Elem()).Elem()
+				if d.seen.Repeated() {
+					// keep the previous values of the repeated key.
+					mv.Set(old)
+				}
+				set = true
+			} else if !mv.CanAddr() {
+				// the value may have been created by a table header, so
+				// copy it to be able to add the dotted key to it.
+				old := mv
+				mv = reflect.New(v.Type().Elem()).Elem()
+				mv.Set(old)
 				set = true
 			}
 		}
@@ -1054,12 +3039,57 @@ func (d *decoder) handleKeyValuePart(key ast.Iterator, value *ast.Node, v reflec
 			set = true
 		}
 
+		setTOMLKey(mv, k)
+
 		if set {
 			v.SetMapIndex(mk, mv)
 		}
 	case reflect.Struct:
-		path, found := structFieldPath(v, string(key.Node().Data))
+		if c := cachedStructInfo(v.Type()).conflict; c != "" {
+			return reflect.Value{}, newDecodeError(key.Node().Data, "%s", c)
+		}
+
+		path, found := d.keyFieldPath(v, key.Node().Data)
 		if !found {
+			if f, ok := dottedView(v, string(key.Node().Data)); ok {
+				x, err := d.handleKeyValueInner(key, value, f)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				if x.IsValid() {
+					f.Set(x)
+				}
+				break
+			}
+
+			if f, ok := structRemainingField(v); ok {
+				x, err := d.handleKeyValuePart(key, value, f)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				if x.IsValid() {
+					f.Set(x)
+				}
+				break
+			}
+
+			d.skipUntilTable = true
+			break
+		}
+
+		if d.isUnsupportedType(v.Type().FieldByIndex(path).Type) {
+			return reflect.Value{}, d.unsupportedFieldError(key.Node(), v.Type(), path)
+		}
+
+		err := d.checkAlias(v, path, key.Node().Data)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		d.checkDeprecated(v, path, key.Node())
+
+		f, ok := allocFieldByIndex(v, path)
+		if !ok {
 			d.skipUntilTable = true
 			break
 		}
@@ -1071,7 +3101,6 @@ func (d *decoder) handleKeyValuePart(key ast.Iterator, value *ast.Node, v reflec
 		d.errorContext.Struct = t
 		d.errorContext.Field = path
 
-		f := v.FieldByIndex(path)
 		x, err := d.handleKeyValueInner(key, value, f)
 		if err != nil {
 			return reflect.Value{}, err
@@ -1089,8 +3118,8 @@ func (d *decoder) handleKeyValuePart(key ast.Iterator, value *ast.Node, v reflec
 		// interface{}, it needs to always hold a
 		// map[string]interface{}. This is for the types to be
 		// consistent whether a previous value was set or not.
-		if !v.IsValid() || v.Type() != mapStringInterfaceType {
-			v = makeMapStringInterface()
+		if !v.IsValid() || v.Type() != d.tableType() {
+			v = d.makeTable()
 		}
 
 		x, err := d.handleKeyValuePart(key, value, v)
@@ -1119,12 +3148,57 @@ func (d *decoder) handleKeyValuePart(key ast.Iterator, value *ast.Node, v reflec
 		}
 		v.Elem().Set(elem)
 	default:
-		return reflect.Value{}, fmt.Errorf("unhandled kv part: %s", v.Kind())
+		return reflect.Value{}, newDecodeError(d.p.Raw(key.Node().Raw), "cannot decode key %s into a Go value of type %s", key.Node().Data, v.Type())
 	}
 
 	return rv, nil
 }
 
+// handleOrderedMapKeyValuePart is the equivalent of the map case of
+// handleKeyValuePart for OrderedMap.
+func (d *decoder) handleOrderedMapKeyValuePart(key ast.Iterator, value *ast.Node, v reflect.Value) (reflect.Value, error) {
+	var rv reflect.Value
+
+	if !v.CanAddr() {
+		nv := reflect.New(orderedMapType).Elem()
+		nv.Set(v)
+		v = nv
+		rv = v
+	}
+
+	om := v.Addr().Interface().(*OrderedMap)
+	k := string(key.Node().Data)
+
+	ordered := d.ordered
+	d.ordered = true
+	defer func() { d.ordered = ordered }()
+
+	mv := reflect.New(interfaceType).Elem()
+	dotted := !key.IsLast()
+	if dotted {
+		if x, ok := om.Get(k); ok && x != nil {
+			mv.Set(reflect.ValueOf(x))
+			dotted = false
+		}
+	}
+
+	x, err := d.handleKeyValueInner(key, value, mv)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if x.IsValid() {
+		mv = x
+	}
+
+	if m, ok := mv.Interface().(*OrderedMap); ok && dotted {
+		m.kind = DottedTable
+	}
+
+	om.Set(k, mv.Interface())
+
+	return rv, nil
+}
+
 func initAndDereferencePointer(v reflect.Value) reflect.Value {
 	var elem reflect.Value
 	if v.IsNil() {
@@ -1137,31 +3211,235 @@ func initAndDereferencePointer(v reflect.Value) reflect.Value {
 
 type fieldPathsMap = map[string][]int
 
-var globalFieldPathsCache atomic.Value // map[danger.TypeID]fieldPathsMap
+// structInfo holds the information about a struct type needed to decode into
+// it.
+type structInfo struct {
+	fields fieldPathsMap
 
-func structFieldPath(v reflect.Value, name string) ([]int, bool) {
-	t := v.Type()
+	// All the fields with a key, and their paths, in the order given by
+	// forEachField.
+	keyed []structField
+	paths [][]int
 
-	cache, _ := globalFieldPathsCache.Load().(map[danger.TypeID]fieldPathsMap)
-	fieldPaths, ok := cache[danger.MakeTypeID(t)]
+	// Path to the field tagged with the "remaining" option, nil if absent.
+	remaining []int
 
-	if !ok {
-		fieldPaths = map[string][]int{}
+	// Path to the field tagged with the "source" option, nil if absent.
+	source []int
 
-		forEachField(t, nil, func(name string, path []int) {
-			fieldPaths[name] = path
+	// Path to the field tagged with the "headercomment" option, nil if
+	// absent.
+	headerComment []int
+
+	// Path to the string field tagged with the "key" option, nil if absent.
+	key []int
+
+	// Lowercased names of the fields that have aliases, including the aliases.
+	aliased map[string]bool
+
+	// Options of the fields whose tag changes how their value is decoded,
+	// like "layout", "oneof" or "deprecated", by the key of their path
+	// built by appendFieldPathKey. nil if there is none.
+	options map[string]tagOptions
+
+	// Tables designated by the first segment of the names of the fields
+	// tagged with the "dotted" option, by name and lowercased name.
+	dotted map[string]*dottedTable
+
+	// Description of the first key used by two fields, one of them being
+	// in a field tagged with the "squash" or the "dotted" option. Empty if
+	// there is none.
+	conflict string
+}
+
+var globalStructInfoCache atomic.Value // map[danger.TypeID]*structInfo
+
+func cachedStructInfo(t reflect.Type) *structInfo {
+	cache, _ := globalStructInfoCache.Load().(map[danger.TypeID]*structInfo)
+	info, ok := cache[danger.MakeTypeID(t)]
+
+	if !ok {
+		info = &structInfo{fields: map[string][]int{}}
+		var dotted []dottedField
+
+		info.keyed = typeFields(t)
+		for _, f := range info.keyed {
+			name, path, opts := f.name, f.path, f.opts
+			info.paths = append(info.paths, path)
+			if opts.layout != "" || opts.epoch != "" || opts.oneof != nil || opts.deprecated {
+				if info.options == nil {
+					info.options = map[string]tagOptions{}
+				}
+				info.options[string(appendFieldPathKey(nil, path))] = opts
+			}
+			if opts.source {
+				if info.source == nil {
+					info.source = path
+				}
+				continue
+			}
+			if opts.headerComment {
+				if info.headerComment == nil {
+					info.headerComment = path
+				}
+				continue
+			}
+			if opts.key {
+				if info.key == nil && t.FieldByIndex(path).Type.Kind() == reflect.String {
+					info.key = path
+				}
+				continue
+			}
+			if opts.remaining {
+				if info.remaining == nil {
+					info.remaining = path
+				}
+				continue
+			}
+			if opts.dotted && strings.Contains(name, ".") {
+				dotted = append(dotted, dottedField{segments: strings.Split(name, "."), path: path})
+				continue
+			}
+			if info.conflict == "" {
+				info.conflict = squashConflict(t, info.fields, name, path)
+			}
+			info.fields[name] = path
 			// extra copy for the case-insensitive match
-			fieldPaths[strings.ToLower(name)] = path
-		})
+			info.fields[strings.ToLower(name)] = path
+
+			if len(opts.aliases) == 0 {
+				continue
+			}
+			if info.aliased == nil {
+				info.aliased = map[string]bool{}
+			}
+			info.aliased[strings.ToLower(name)] = true
+			for _, a := range opts.aliases {
+				if _, ok := info.fields[a]; !ok {
+					info.fields[a] = path
+				}
+				if _, ok := info.fields[strings.ToLower(a)]; !ok {
+					info.fields[strings.ToLower(a)] = path
+				}
+				info.aliased[strings.ToLower(a)] = true
+			}
+		}
+
+		if len(dotted) > 0 {
+			var c string
+			info.dotted, c = newDottedTables(t, dotted)
+			if info.conflict == "" {
+				info.conflict = c
+			}
+			for _, f := range dotted {
+				other, ok := info.fields[f.segments[0]]
+				if ok && info.conflict == "" {
+					info.conflict = fmt.Sprintf("fields %s and %s of %s both use the key %s", fieldPathName(t, other), fieldPathName(t, f.path), t, f.segments[0])
+				}
+			}
+		}
 
-		newCache := make(map[danger.TypeID]fieldPathsMap, len(cache)+1)
-		newCache[danger.MakeTypeID(t)] = fieldPaths
+		newCache := make(map[danger.TypeID]*structInfo, len(cache)+1)
+		newCache[danger.MakeTypeID(t)] = info
 		for k, v := range cache {
 			newCache[k] = v
 		}
-		globalFieldPathsCache.Store(newCache)
+		globalStructInfoCache.Store(newCache)
 	}
 
+	return info
+}
+
+// fieldOptions returns the options of the tag of the field at path that change
+// how its value is decoded.
+func (info *structInfo) fieldOptions(path []int) tagOptions {
+	if info.options == nil {
+		return tagOptions{}
+	}
+	var buf [32]byte
+	return info.options[string(appendFieldPathKey(buf[:0], path))]
+}
+
+// appendFieldPathKey appends to b the key identifying the field path in
+// structInfo.options.
+func appendFieldPathKey(b []byte, path []int) []byte {
+	for _, i := range path {
+		b = strconv.AppendInt(b, int64(i), 10)
+		b = append(b, '.')
+	}
+	return b
+}
+
+// squashConflict describes the conflict between the field at path of the
+// struct type t, named name, and the fields already collected in fields, when
+// one of them is in a field tagged with the "squash" option.
+func squashConflict(t reflect.Type, fields fieldPathsMap, name string, path []int) string {
+	for _, n := range []string{name, strings.ToLower(name)} {
+		other, ok := fields[n]
+		if !ok || !(isSquashed(t, other) || isSquashed(t, path)) {
+			continue
+		}
+		return fmt.Sprintf("fields %s and %s of %s both use the key %s", fieldPathName(t, other), fieldPathName(t, path), t, name)
+	}
+	return ""
+}
+
+// isSquashed returns true if the field at path of the struct type t is inside
+// a field tagged with the "squash" option.
+func isSquashed(t reflect.Type, path []int) bool {
+	for _, i := range path[:len(path)-1] {
+		f := t.Field(i)
+		if _, opts := parseTag(f.Tag.Get("toml")); opts.squash {
+			return true
+		}
+		t = indirectType(f.Type)
+	}
+	return false
+}
+
+// fieldParentType returns the struct type declaring the field at path of the
+// struct type t.
+func fieldParentType(t reflect.Type, path []int) reflect.Type {
+	for _, i := range path[:len(path)-1] {
+		t = indirectType(t.Field(i).Type)
+	}
+	return t
+}
+
+// indirectType returns the type pointed to by t, or t if it is not a pointer.
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// fieldPathName returns the dotted Go name of the field at path of the struct
+// type t.
+func fieldPathName(t reflect.Type, path []int) string {
+	names := make([]string, 0, len(path))
+	for _, i := range path {
+		f := t.Field(i)
+		names = append(names, f.Name)
+		t = indirectType(f.Type)
+	}
+	return strings.Join(names, ".")
+}
+
+// keyFieldPath returns the path of the field of the struct v designated by the
+// key named name, like structFieldPath, falling back to the field name given by
+// the key mapper, if any. The name is only copied for the key mapper.
+func (d *decoder) keyFieldPath(v reflect.Value, name []byte) ([]int, bool) {
+	path, ok := structFieldPath(v, string(name))
+	if !ok && d.keyMapper != nil {
+		path, ok = structFieldPath(v, d.keyMapper.FieldName(string(name)))
+	}
+	return path, ok
+}
+
+func structFieldPath(v reflect.Value, name string) ([]int, bool) {
+	fieldPaths := cachedStructInfo(v.Type()).fields
+
 	path, ok := fieldPaths[name]
 	if !ok {
 		path, ok = fieldPaths[strings.ToLower(name)]
@@ -1169,7 +3447,338 @@ func structFieldPath(v reflect.Value, name string) ([]int, bool) {
 	return path, ok
 }
 
-func forEachField(t reflect.Type, path []int, do func(name string, path []int)) {
+// mapKey returns the key of the map type t designated by the TOML key. Keys
+// implementing encoding.TextUnmarshaler are decoded by their UnmarshalText
+// method. Maps with integer keys accept the keys that are valid TOML integers,
+// in any base.
+func (d *decoder) mapKey(t reflect.Type, key *ast.Node) (reflect.Value, error) {
+	kt := t.Key()
+
+	if reflect.PtrTo(kt).Implements(textUnmarshalerType) {
+		mk := reflect.New(kt)
+		err := mk.Interface().(encoding.TextUnmarshaler).UnmarshalText(key.Data)
+		if err != nil {
+			return reflect.Value{}, newDecodeError(d.p.Raw(key.Raw), "cannot use key %s as a map key of type %s: %s", key.Data, kt, err)
+		}
+		return mk.Elem(), nil
+	}
+
+	switch kt.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		mk := reflect.New(kt).Elem()
+		err := d.unmarshalInteger(&ast.Node{Kind: ast.Integer, Data: key.Data}, mk)
+		if err != nil {
+			msg := strings.TrimPrefix(err.Error(), "toml: ")
+			return reflect.Value{}, newDecodeError(d.p.Raw(key.Raw), "cannot use key %s as a map key of type %s: %s", key.Data, kt, msg)
+		}
+		return mk, nil
+	}
+
+	mk := reflect.ValueOf(string(key.Data))
+	if !stringType.AssignableTo(kt) {
+		if !stringType.ConvertibleTo(kt) {
+			return reflect.Value{}, fmt.Errorf("toml: cannot convert map key of type %s to expected type %s", stringType, kt)
+		}
+
+		mk = mk.Convert(kt)
+	}
+
+	return mk, nil
+}
+
+type integerKey struct {
+	m   uintptr
+	key string
+}
+
+// checkIntegerKey returns an error if the integer key mk of the map v has
+// already been decoded from a different key of the document, like 1 and "+1".
+func (d *decoder) checkIntegerKey(v reflect.Value, mk reflect.Value, key *ast.Node) error {
+	if mk.Kind() == reflect.String || reflect.PtrTo(mk.Type()).Implements(textUnmarshalerType) {
+		return nil
+	}
+
+	if d.integerKeys == nil {
+		d.integerKeys = map[integerKey]string{}
+	}
+
+	k := integerKey{m: v.Pointer(), key: fmt.Sprint(mk.Interface())}
+	used, ok := d.integerKeys[k]
+	if !ok {
+		d.integerKeys[k] = string(key.Data)
+		return nil
+	}
+
+	if used != string(key.Data) {
+		return newDecodeError(d.p.Raw(key.Raw), "key %s is already defined as %s: both are the integer %s", key.Data, used, k.key)
+	}
+
+	return nil
+}
+
+// fieldKey identifies a field of a struct value.
+type fieldKey struct {
+	typ   reflect.Type
+	ptr   uintptr
+	field string
+}
+
+// checkAlias returns an error if the field at path of the struct v, designated
+// by key, has already been decoded from one of its other names, or from the
+// same name spelled with a different case when noCaseConflicts is set.
+func (d *decoder) checkAlias(v reflect.Value, path []int, key []byte) error {
+	err := d.checkKeyCase(v, path, key)
+	if err != nil {
+		return err
+	}
+
+	aliased := cachedStructInfo(v.Type()).aliased
+	if aliased == nil || !v.CanAddr() {
+		return nil
+	}
+
+	name := strings.ToLower(string(key))
+	if !aliased[name] {
+		return nil
+	}
+
+	if d.aliases == nil {
+		d.aliases = map[fieldKey]string{}
+	}
+
+	k := fieldKey{typ: v.Type(), ptr: v.Addr().Pointer(), field: fmt.Sprint(path)}
+	used, ok := d.aliases[k]
+	if !ok {
+		d.aliases[k] = name
+		return nil
+	}
+
+	if used != name {
+		f := v.Type().FieldByIndex(path)
+		return newDecodeError(key, "%s and %s cannot both be used for field %s", used, string(key), f.Name)
+	}
+
+	return nil
+}
+
+func (d *decoder) checkKeyCase(v reflect.Value, path []int, key []byte) error {
+	if !d.noCaseConflicts || !v.CanAddr() {
+		return nil
+	}
+
+	if d.fieldKeys == nil {
+		d.fieldKeys = map[fieldKey]string{}
+	}
+
+	k := fieldKey{typ: v.Type(), ptr: v.Addr().Pointer(), field: fmt.Sprint(path)}
+	used, ok := d.fieldKeys[k]
+	if !ok {
+		d.fieldKeys[k] = string(key)
+		return nil
+	}
+
+	if used != string(key) && strings.EqualFold(used, string(key)) {
+		f := v.Type().FieldByIndex(path)
+		return newDecodeError(key, "keys %s and %s only differ by case and cannot both be used for field %s", used, string(key), f.Name)
+	}
+
+	return nil
+}
+
+// checkDeprecated records a warning the first time the field at path of the
+// struct v, tagged with the "deprecated" option, is decoded from key.
+func (d *decoder) checkDeprecated(v reflect.Value, path []int, key *ast.Node) {
+	if !cachedStructInfo(v.Type()).fieldOptions(path).deprecated {
+		return
+	}
+
+	if v.CanAddr() {
+		if d.deprecated == nil {
+			d.deprecated = map[fieldKey]bool{}
+		}
+
+		k := fieldKey{typ: v.Type(), ptr: v.Addr().Pointer(), field: fmt.Sprint(path)}
+		if d.deprecated[k] {
+			return
+		}
+		d.deprecated[k] = true
+	}
+
+	d.warnings = append(d.warnings, decodeError{
+		highlight: d.p.Raw(key.Raw),
+		message:   fmt.Sprintf("key %s is deprecated", string(key.Data)),
+		table:     d.sections.String(),
+	})
+}
+
+// dottedView returns a value standing for the table designated by name
+// in the struct v, when it is the first segment of the names of fields tagged
+// with the "dotted" option. Decoding into the value sets those fields.
+func dottedView(v reflect.Value, name string) (reflect.Value, bool) {
+	dotted := cachedStructInfo(v.Type()).dotted
+	dt, ok := dotted[name]
+	if !ok {
+		dt, ok = dotted[strings.ToLower(name)]
+	}
+	if !ok || !v.CanAddr() {
+		return reflect.Value{}, false
+	}
+
+	view := reflect.New(dt.decodeType).Elem()
+	for _, l := range dt.leaves {
+		f, ok := fieldByIndex(v, l.field)
+		if ok {
+			view.FieldByIndex(l.view).Set(f.Addr())
+		}
+	}
+	return view, true
+}
+
+// structRemainingField returns the field of the struct v tagged with the
+// "remaining" option, if any.
+func structRemainingField(v reflect.Value) (reflect.Value, bool) {
+	path := cachedStructInfo(v.Type()).remaining
+	if path == nil {
+		return reflect.Value{}, false
+	}
+	return allocFieldByIndex(v, path)
+}
+
+// setHeaderComment stores the comment lines above the table header being
+// decoded into the field of v tagged with the "headercomment" option, if any.
+func (d *decoder) setHeaderComment(v reflect.Value) error {
+	comments := d.p.Comments()
+	if len(comments) == 0 {
+		return nil
+	}
+
+	t := v.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	path := cachedStructInfo(t).headerComment
+	if path == nil {
+		return nil
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			if !v.CanSet() {
+				return nil
+			}
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	f, ok := fieldByIndex(v, path)
+	if !ok {
+		return nil
+	}
+
+	lines := make([]string, len(comments))
+	for i, c := range comments {
+		l := strings.TrimSuffix(string(c[1:]), "\r")
+		lines[i] = strings.TrimPrefix(l, " ")
+	}
+
+	switch {
+	case f.Kind() == reflect.String:
+		f.SetString(strings.Join(lines, "\n"))
+	case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String:
+		s := reflect.MakeSlice(f.Type(), len(lines), len(lines))
+		for i, l := range lines {
+			s.Index(i).SetString(l)
+		}
+		f.Set(s)
+	default:
+		return fmt.Errorf("toml: field tagged with the headercomment option must be a string or a []string, not %s", f.Type())
+	}
+
+	return nil
+}
+
+// setSource stores a copy of the document in the field of v tagged with the
+// "source" option, if v is a struct that has one.
+func (d *decoder) setSource(v reflect.Value) error {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	path := cachedStructInfo(v.Type()).source
+	if path == nil {
+		return nil
+	}
+
+	f, ok := fieldByIndex(v, path)
+	if !ok {
+		return nil
+	}
+
+	if f.Kind() != reflect.Slice || f.Type().Elem().Kind() != reflect.Uint8 {
+		return fmt.Errorf("toml: field tagged with the source option must be a []byte, not %s", f.Type())
+	}
+
+	data := make([]byte, len(d.p.data))
+	copy(data, d.p.data)
+	f.Set(reflect.ValueOf(data).Convert(f.Type()))
+
+	return nil
+}
+
+// forEachField calls do for each field of the struct type t that has a key,
+// including the fields promoted from embedded structs and pointers to
+// structs, and the fields of struct fields tagged with the "squash" option.
+// path is prepended to the paths of the fields.
+//
+// Like encoding/json, a promoted field is hidden by a field of the same name
+// closer to t. Among several fields of the same name at the shallowest depth,
+// the one with a name given by its tag wins, and none of them is used if there
+// is no such single field. Fields inside squashed struct fields are not
+// hidden, and conflicts with them are reported by cachedStructInfo instead.
+//
+// The fields are computed once per type, by typeFields.
+func forEachField(t reflect.Type, do func(name string, path []int, opts tagOptions)) {
+	for _, f := range cachedStructInfo(t).keyed {
+		do(f.name, f.path, f.opts)
+	}
+}
+
+// typeFields returns the fields of the struct type t visited by forEachField.
+func typeFields(t reflect.Type) []structField {
+	var fields []structField
+	collectFields(t, nil, false, map[reflect.Type]bool{t: true}, &fields)
+	return dominantFields(fields)
+}
+
+// structField is a field of a struct type found by collectFields.
+type structField struct {
+	name string
+	path []int
+	opts tagOptions
+
+	// The name was given by the tag of the field.
+	tagged bool
+
+	// The field is inside a struct field tagged with the "squash" option.
+	squashed bool
+}
+
+// collectFields adds the fields of the struct type t to fields, in the order of
+// their declaration. visiting holds the embedded types being traversed, which
+// are not traversed again.
+func collectFields(t reflect.Type, path []int, squashed bool, visiting map[reflect.Type]bool, fields *[]structField) {
 	n := t.NumField()
 	for i := 0; i < n; i++ {
 		f := t.Field(i)
@@ -1182,24 +3791,108 @@ func forEachField(t reflect.Type, path []int, do func(name string, path []int))
 		fieldPath := append(path, i)
 		fieldPath = fieldPath[:len(fieldPath):len(fieldPath)]
 
-		name := f.Tag.Get("toml")
-		if name == "-" {
+		tag := f.Tag.Get("toml")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := parseTag(tag)
+
+		if (f.Anonymous && name == "") || opts.squash {
+			ft := f.Type
+			if f.Anonymous && ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if !visiting[ft] {
+					visiting[ft] = true
+					collectFields(ft, fieldPath, squashed || opts.squash, visiting, fields)
+					delete(visiting, ft)
+				}
+				continue
+			}
+			if f.PkgPath != "" {
+				// embedded values that are not structs are only
+				// considered when exported.
+				continue
+			}
+		}
+
+		field := structField{name: name, path: fieldPath, opts: opts, tagged: name != "", squashed: squashed}
+		if name == "" {
+			field.name = f.Name
+		}
+		*fields = append(*fields, field)
+	}
+}
+
+// dominantFields returns fields without the fields hidden by another field of
+// the same name, as described by forEachField.
+func dominantFields(fields []structField) []structField {
+	groups := map[string][]int{}
+	for i, f := range fields {
+		if f.opts.remaining || f.opts.source || f.opts.headerComment || f.opts.key || f.opts.dotted {
 			continue
 		}
+		groups[f.name] = append(groups[f.name], i)
+	}
 
-		if i := strings.IndexByte(name, ','); i >= 0 {
-			name = name[:i]
+	var hidden map[int]bool
+	for _, g := range groups {
+		if len(g) < 2 {
+			continue
 		}
 
-		if f.Anonymous && name == "" {
-			forEachField(f.Type, fieldPath, do)
+		depth := len(fields[g[0]].path)
+		squashed := false
+		for _, i := range g {
+			squashed = squashed || fields[i].squashed
+			if len(fields[i].path) < depth {
+				depth = len(fields[i].path)
+			}
+		}
+		if squashed {
 			continue
 		}
 
-		if name == "" {
-			name = f.Name
+		dominant, tagged, count := -1, -1, 0
+		for _, i := range g {
+			if len(fields[i].path) != depth {
+				continue
+			}
+			count++
+			dominant = i
+			if fields[i].tagged {
+				if tagged == -1 {
+					tagged = i
+				} else {
+					tagged = -2
+				}
+			}
+		}
+		if count > 1 {
+			dominant = tagged
 		}
 
-		do(name, fieldPath)
+		if hidden == nil {
+			hidden = map[int]bool{}
+		}
+		for _, i := range g {
+			if i != dominant {
+				hidden[i] = true
+			}
+		}
+	}
+
+	if len(hidden) == 0 {
+		return fields
+	}
+
+	kept := make([]structField, 0, len(fields)-len(hidden))
+	for i, f := range fields {
+		if !hidden[i] {
+			kept = append(kept, f)
+		}
 	}
+	return kept
 }
@@ -2,16 +2,21 @@ package toml_test
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"math/big"
+	"net"
+	"net/url"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -1426,8 +1431,8 @@ B = "data"`,
 			input: `A = [1,2,3,4,5]`,
 			gen: func() test {
 				return test{
-					target:   &map[string][3]int{},
-					expected: &map[string][3]int{"A": {1, 2, 3}},
+					target: &map[string][3]int{},
+					err:    true,
 				}
 			},
 		},
@@ -1861,605 +1866,1800 @@ func TestUnmarshalFloat32(t *testing.T) {
 	})
 }
 
-func TestDecoderStrict(t *testing.T) {
+func TestDecoderStrictFloat32(t *testing.T) {
+	examples := []struct {
+		desc string
+		doc  string
+		err  bool
+	}{
+		{desc: "exact", doc: "A = 1.5"},
+		{desc: "exact exponent", doc: "A = 2e10"},
+		{desc: "nan", doc: "A = nan"},
+		{desc: "precision loss", doc: "A = 1.23456789012345", err: true},
+		{desc: "not representable", doc: "A = 0.1", err: true},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			m := map[string]float32{}
+			err := toml.NewDecoder(strings.NewReader(e.doc)).SetStrictFloat32(true).Decode(&m)
+			if e.err {
+				var derr *toml.DecodeError
+				require.ErrorAs(t, err, &derr)
+				row, col := derr.Position()
+				require.Equal(t, 1, row)
+				require.Equal(t, 5, col)
+			} else {
+				require.NoError(t, err)
+			}
+
+			err = toml.NewDecoder(strings.NewReader(e.doc)).Decode(&map[string]float32{})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestDecoderSetStrictDateTimes(t *testing.T) {
+	examples := []struct {
+		desc string
+		doc  string
+		err  string
+	}{
+		{desc: "offset", doc: "A = 1979-05-27T07:32:00-08:00"},
+		{desc: "utc", doc: "A = 1979-05-27T07:32:00Z"},
+		{desc: "local", doc: "A = 1979-05-27T07:32:00"},
+		{desc: "local date", doc: "A = 1979-05-27"},
+		{desc: "space", doc: "A = 1979-05-27 07:32:00Z", err: "toml: date-time separator must be an uppercase T"},
+		{desc: "lowercase t", doc: "A = 1979-05-27t07:32:00", err: "toml: date-time separator must be an uppercase T"},
+		{desc: "lowercase z", doc: "A = 1979-05-27T07:32:00z", err: "toml: UTC offset must be an uppercase Z"},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var m map[string]interface{}
+			err := toml.NewDecoder(strings.NewReader(e.doc)).SetStrictDateTimes(true).Decode(&m)
+			if e.err != "" {
+				require.EqualError(t, err, e.err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			err = toml.NewDecoder(strings.NewReader(e.doc)).Decode(&m)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestUnmarshalDateTimeOffsets(t *testing.T) {
+	examples := []struct {
+		doc    string
+		offset int
+	}{
+		{doc: "1979-05-27T07:32:00-08:00", offset: -8 * 3600},
+		{doc: "1979-05-27T07:32:00+05:30", offset: 5*3600 + 30*60},
+		{doc: "1979-05-27T07:32:00.5Z", offset: 0},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.doc, func(t *testing.T) {
+			var x struct{ T time.Time }
+			err := toml.Unmarshal([]byte("T = "+e.doc), &x)
+			require.NoError(t, err)
+
+			_, offset := x.T.Zone()
+			require.Equal(t, e.offset, offset)
+			require.Equal(t, 7, x.T.Hour())
+
+			b, err := toml.Marshal(x)
+			require.NoError(t, err)
+			require.Equal(t, "T = "+e.doc+"\n", string(b))
+		})
+	}
+}
+
+func TestDecoderSetParseQuotedNumbers(t *testing.T) {
+	type doc struct {
+		Port  int
+		Small uint8
+		Ratio float64
+		Name  string
+	}
+
 	examples := []struct {
 		desc     string
 		input    string
-		expected string
-		target   interface{}
+		expected doc
+		err      bool
 	}{
 		{
-			desc: "multiple missing root keys",
-			input: `
-key1 = "value1"
-key2 = "missing2"
-key3 = "missing3"
-key4 = "value4"
-`,
-			expected: `
-2| key1 = "value1"
-3| key2 = "missing2"
- | ~~~~ missing field
-4| key3 = "missing3"
-5| key4 = "value4"
----
-2| key1 = "value1"
-3| key2 = "missing2"
-4| key3 = "missing3"
- | ~~~~ missing field
-5| key4 = "value4"
-`,
-			target: &struct {
-				Key1 string
-				Key4 string
-			}{},
+			desc:     "quoted numbers",
+			input:    `port = "8080"` + "\n" + `small = "255"` + "\n" + `ratio = "0.5"` + "\n" + `name = "42"`,
+			expected: doc{Port: 8080, Small: 255, Ratio: 0.5, Name: "42"},
 		},
 		{
-			desc:  "multi-part key",
-			input: `a.short.key="foo"`,
-			expected: `
-1| a.short.key="foo"
- | ~~~~~~~~~~~ missing field
-`,
+			desc:     "negative integer",
+			input:    `port = "-1"`,
+			expected: doc{Port: -1},
 		},
 		{
-			desc: "missing table",
-			input: `
-[foo]
-bar = 42
-`,
-			expected: `
-2| [foo]
- |  ~~~ missing table
-3| bar = 42
-`,
+			desc:  "not a number",
+			input: `port = "http"`,
+			err:   true,
 		},
-
 		{
-			desc: "missing array table",
-			input: `
-[[foo]]
-bar = 42
-`,
-			expected: `
-2| [[foo]]
- |   ~~~ missing table
-3| bar = 42
-`,
+			desc:  "overflow",
+			input: `small = "256"`,
+			err:   true,
+		},
+		{
+			desc:  "negative unsigned",
+			input: `small = "-1"`,
+			err:   true,
 		},
 	}
 
 	for _, e := range examples {
 		e := e
 		t.Run(e.desc, func(t *testing.T) {
-			t.Run("strict", func(t *testing.T) {
-				r := strings.NewReader(e.input)
-				d := toml.NewDecoder(r)
-				d.DisallowUnknownFields()
-				x := e.target
-				if x == nil {
-					x = &struct{}{}
-				}
-				err := d.Decode(x)
+			var d doc
+			err := toml.NewDecoder(strings.NewReader(e.input)).SetParseQuotedNumbers(true).Decode(&d)
+			if e.err {
+				var derr *toml.DecodeError
+				require.ErrorAs(t, err, &derr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, e.expected, d)
+		})
+	}
 
-				var tsm *toml.StrictMissingError
-				if errors.As(err, &tsm) {
-					equalStringsIgnoreNewlines(t, e.expected, tsm.String())
-				} else {
-					t.Fatalf("err should have been a *toml.StrictMissingError, but got %s (%T)", err, err)
-				}
-			})
+	t.Run("disabled by default", func(t *testing.T) {
+		var d doc
+		err := toml.Unmarshal([]byte(`port = "8080"`), &d)
+		require.Error(t, err)
+	})
+}
 
-			t.Run("default", func(t *testing.T) {
-				r := strings.NewReader(e.input)
-				d := toml.NewDecoder(r)
-				x := e.target
-				if x == nil {
-					x = &struct{}{}
-				}
-				err := d.Decode(x)
-				require.NoError(t, err)
-			})
+func TestDecoderSetParseThousandsSeparators(t *testing.T) {
+	type doc struct {
+		Budget int64
+		Count  uint32
+		Ratio  float64
+		Name   string
+	}
+
+	examples := []struct {
+		desc     string
+		input    string
+		expected doc
+		err      string
+	}{
+		{
+			desc:     "separators",
+			input:    `budget = "-1,000,000"` + "\n" + `count = "12,345"` + "\n" + `ratio = "1,234.5e1"` + "\n" + `name = "1,000"`,
+			expected: doc{Budget: -1000000, Count: 12345, Ratio: 12345, Name: "1,000"},
+		},
+		{
+			desc:     "no separators",
+			input:    `budget = "1000"` + "\n" + `ratio = "0.5"`,
+			expected: doc{Budget: 1000, Ratio: 0.5},
+		},
+		{
+			desc:     "integers are not affected",
+			input:    `budget = 1_000`,
+			expected: doc{Budget: 1000},
+		},
+		{
+			desc:  "short group",
+			input: `budget = "1,00"`,
+			err:   `toml: invalid thousands separators in number "1,00"`,
+		},
+		{
+			desc:  "long group",
+			input: `budget = "1000,000"`,
+			err:   `toml: invalid thousands separators in number "1000,000"`,
+		},
+		{
+			desc:  "separator in the fraction",
+			input: `ratio = "1.000,5"`,
+			err:   `toml: invalid thousands separators in number "1.000,5"`,
+		},
+		{
+			desc:  "leading separator",
+			input: `budget = ",100"`,
+			err:   `toml: invalid thousands separators in number ",100"`,
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var d doc
+			err := toml.NewDecoder(strings.NewReader(e.input)).SetParseThousandsSeparators(true).Decode(&d)
+			if e.err != "" {
+				var derr *toml.DecodeError
+				require.ErrorAs(t, err, &derr)
+				require.Equal(t, e.err, derr.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, e.expected, d)
 		})
 	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		var d doc
+		err := toml.NewDecoder(strings.NewReader(`budget = "1,000"`)).SetParseQuotedNumbers(true).Decode(&d)
+		require.Error(t, err)
+	})
 }
 
-func TestIssue252(t *testing.T) {
-	type config struct {
-		Val1 string `toml:"val1"`
-		Val2 string `toml:"val2"`
-	}
+func TestDecoderSetDefaultLocation(t *testing.T) {
+	loc := time.FixedZone("test", 3*3600)
 
-	configFile := []byte(
-		`
-val1 = "test1"
-`)
+	doc := `
+ldt = 2021-04-05T10:11:12
+ld = 2021-04-05
+odt = 2021-04-05T10:11:12Z
+raw = 2021-04-05T10:11:12
+`
 
-	cfg := &config{
-		Val2: "test2",
+	type target struct {
+		Ldt time.Time
+		Ld  time.Time
+		Odt time.Time
+		Raw toml.LocalDateTime
 	}
 
-	err := toml.Unmarshal(configFile, cfg)
+	var x target
+	err := toml.NewDecoder(strings.NewReader(doc)).SetDefaultLocation(loc).Decode(&x)
 	require.NoError(t, err)
-	require.Equal(t, "test2", cfg.Val2)
-}
 
-func TestIssue287(t *testing.T) {
-	b := `y=[[{}]]`
-	v := map[string]interface{}{}
-	err := toml.Unmarshal([]byte(b), &v)
+	require.Equal(t, time.Date(2021, 4, 5, 10, 11, 12, 0, loc), x.Ldt)
+	require.Equal(t, loc, x.Ldt.Location())
+	require.Equal(t, time.Date(2021, 4, 5, 0, 0, 0, 0, loc), x.Ld)
+	require.Equal(t, loc, x.Ld.Location())
+	require.Equal(t, time.UTC, x.Odt.Location())
+	require.Equal(t, toml.LocalDateTime{
+		LocalDate: toml.LocalDate{Year: 2021, Month: 4, Day: 5},
+		LocalTime: toml.LocalTime{Hour: 10, Minute: 11, Second: 12},
+	}, x.Raw)
+
+	var y target
+	err = toml.NewDecoder(strings.NewReader(doc)).Decode(&y)
 	require.NoError(t, err)
+	require.Equal(t, time.Local, y.Ldt.Location())
+}
 
-	expected := map[string]interface{}{
-		"y": []interface{}{
-			[]interface{}{
-				map[string]interface{}{},
-			},
-		},
+func TestDecoderSetRepeatedKeyAsArray(t *testing.T) {
+	doc := `tag = "a"
+tag = "b"
+name = "x"
+name = "y"
+
+[table]
+tag = 1
+tag = 2
+tag = 3
+`
+
+	type table struct {
+		Tag []int
 	}
-	require.Equal(t, expected, v)
-}
 
-type (
-	Map458   map[string]interface{}
-	Slice458 []interface{}
-)
+	var s struct {
+		Tag   []string
+		Name  string
+		Table table
+	}
+	s.Tag = []string{"default"}
 
-func (m Map458) A(s string) Slice458 {
-	return m[s].([]interface{})
-}
+	err := toml.NewDecoder(strings.NewReader(doc)).SetRepeatedKeyAsArray(true).Decode(&s)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, s.Tag)
+	assert.Equal(t, "y", s.Name)
+	assert.Equal(t, []int{1, 2, 3}, s.Table.Tag)
 
-func TestIssue458(t *testing.T) {
-	s := []byte(`[[package]]
-dependencies = ["regex"]
-name = "decode"
-version = "0.1.0"`)
-	m := Map458{}
-	err := toml.Unmarshal(s, &m)
+	var m map[string]interface{}
+	err = toml.NewDecoder(strings.NewReader(doc)).SetRepeatedKeyAsArray(true).Decode(&m)
 	require.NoError(t, err)
-	a := m.A("package")
-	expected := Slice458{
-		map[string]interface{}{
-			"dependencies": []interface{}{"regex"},
-			"name":         "decode",
-			"version":      "0.1.0",
+	assert.Equal(t, map[string]interface{}{
+		"tag":  []interface{}{"a", "b"},
+		"name": []interface{}{"x", "y"},
+		"table": map[string]interface{}{
+			"tag": []interface{}{int64(1), int64(2), int64(3)},
 		},
-	}
-	assert.Equal(t, expected, a)
-}
+	}, m)
 
-type Integer484 struct {
-	Value int
+	err = toml.Unmarshal([]byte(doc), &map[string]interface{}{})
+	require.Error(t, err)
+
+	// Only scalar values can be repeated.
+	err = toml.NewDecoder(strings.NewReader("a = 1\na = [2]")).SetRepeatedKeyAsArray(true).Decode(&map[string]interface{}{})
+	require.Error(t, err)
+	err = toml.NewDecoder(strings.NewReader("a.b = 1\na = 2")).SetRepeatedKeyAsArray(true).Decode(&map[string]interface{}{})
+	require.Error(t, err)
 }
 
-func (i Integer484) MarshalText() ([]byte, error) {
-	return []byte(strconv.Itoa(i.Value)), nil
+func TestUnmarshalTypedMapExistingKey(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 2}
+	err := toml.Unmarshal([]byte("a = 3"), &m)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 3, "b": 2}, m)
 }
 
-func (i *Integer484) UnmarshalText(data []byte) error {
-	conv, err := strconv.Atoi(string(data))
-	if err != nil {
-		return fmt.Errorf("UnmarshalText: %w", err)
-	}
-	i.Value = conv
+type flagLevel int
 
+func (l *flagLevel) Set(s string) error {
+	switch s {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("invalid level %q", s)
+	}
 	return nil
 }
 
-type Config484 struct {
-	Integers []Integer484 `toml:"integers"`
+func (l *flagLevel) String() string {
+	return strconv.Itoa(int(*l))
 }
 
-func TestIssue484(t *testing.T) {
-	raw := []byte(`integers = ["1","2","3","100"]`)
+func TestDecoderSetUseSetMethods(t *testing.T) {
+	type doc struct {
+		Level  flagLevel
+		Levels []flagLevel
+		Ptr    *flagLevel
+	}
 
-	var cfg Config484
-	err := toml.Unmarshal(raw, &cfg)
+	var d doc
+	err := toml.NewDecoder(strings.NewReader("Level = 'high'\nLevels = ['low', 'high']\nPtr = 'low'")).SetUseSetMethods(true).Decode(&d)
 	require.NoError(t, err)
-	assert.Equal(t, Config484{
-		Integers: []Integer484{{1}, {2}, {3}, {100}},
-	}, cfg)
-}
+	assert.Equal(t, flagLevel(2), d.Level)
+	assert.Equal(t, []flagLevel{1, 2}, d.Levels)
+	require.NotNil(t, d.Ptr)
+	assert.Equal(t, flagLevel(1), *d.Ptr)
 
-func TestIssue494(t *testing.T) {
-	data := `
-foo = 2021-04-08
-bar = 2021-04-08
-`
+	err = toml.NewDecoder(strings.NewReader("Level = 'medium'")).SetUseSetMethods(true).Decode(&d)
+	require.Error(t, err)
+	var de *toml.DecodeError
+	require.True(t, errors.As(err, &de))
+	assert.Contains(t, err.Error(), `invalid level "medium"`)
 
-	type s struct {
-		Foo time.Time `toml:"foo"`
-		Bar time.Time `toml:"bar"`
-	}
-	ss := new(s)
-	err := toml.Unmarshal([]byte(data), ss)
+	// Integers are still decoded directly.
+	err = toml.NewDecoder(strings.NewReader("Level = 1")).SetUseSetMethods(true).Decode(&d)
 	require.NoError(t, err)
+	assert.Equal(t, flagLevel(1), d.Level)
+
+	// Disabled by default.
+	err = toml.Unmarshal([]byte("Level = 'high'"), &d)
+	require.Error(t, err)
 }
 
-func TestIssue508(t *testing.T) {
-	type head struct {
-		Title string `toml:"title"`
+func TestDecoderEnforceRequiredFields(t *testing.T) {
+	type server struct {
+		Host string `toml:"host,required"`
+		Port int    `toml:"port"`
 	}
 
-	type text struct {
-		head
+	type replica struct {
+		Name string `toml:"name,required"`
 	}
 
-	b := []byte(`title = "This is a title"`)
+	type config struct {
+		Title    string    `toml:"title,required"`
+		Server   server    `toml:"server,required"`
+		Backup   server    `toml:"backup"`
+		Replicas []replica `toml:"replicas"`
+	}
 
-	t1 := text{}
-	err := toml.Unmarshal(b, &t1)
-	require.NoError(t, err)
-	require.Equal(t, "This is a title", t1.head.Title)
-}
+	examples := []struct {
+		desc string
+		doc  string
+		err  string
+	}{
+		{
+			desc: "all present",
+			doc: `title = "t"
+[server]
+host = "h"
+[[replicas]]
+name = "a"
+[[replicas]]
+name = "b"`,
+		},
+		{
+			desc: "missing section",
+			doc:  `title = "t"`,
+			err:  "toml: missing section `server`",
+		},
+		{
+			desc: "missing key in section",
+			doc: `title = "t"
+[server]
+port = 80`,
+			err: "toml: missing key `server.host`",
+		},
+		{
+			desc: "missing root key",
+			doc: `[server]
+host = "h"`,
+			err: "toml: missing key `title`",
+		},
+		{
+			desc: "dotted keys and inline tables",
+			doc: `title = "t"
+server.host = "h"
+backup = { host = "b" }`,
+		},
+		{
+			desc: "optional section with missing key",
+			doc: `title = "t"
+server.host = "h"
+[backup]
+port = 1`,
+			err: "toml: missing key `backup.host`",
+		},
+		{
+			desc: "missing key in array table",
+			doc: `title = "t"
+server.host = "h"
+[[replicas]]
+name = "a"
+[[replicas]]`,
+			err: "toml: missing key `replicas[1].name`",
+		},
+	}
 
-func TestIssue507(t *testing.T) {
-	data := []byte{'0', '=', '\n', '0', 'a', 'm', 'e'}
-	m := map[string]interface{}{}
-	err := toml.Unmarshal(data, &m)
-	require.Error(t, err)
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var c config
+			err := toml.NewDecoder(strings.NewReader(e.doc)).EnforceRequiredFields().Decode(&c)
+			if e.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, e.err)
+			}
+
+			// Required fields are not enforced by default.
+			err = toml.Unmarshal([]byte(e.doc), &config{})
+			require.NoError(t, err)
+		})
+	}
 }
 
-type uuid [16]byte
+func TestDecodeSource(t *testing.T) {
+	doc := `# provenance
+name = "app"
 
-func (u *uuid) UnmarshalText(text []byte) (err error) {
-	// Note: the original reported issue had a more complex implementation
-	// of this function. But the important part is to verify that a
-	// non-struct type implementing UnmarshalText works with the unmarshal
-	// process.
-	placeholder := bytes.Repeat([]byte{0xAA}, 16)
-	copy(u[:], placeholder)
-	return nil
-}
+[server]
+port = 80
+`
 
-func TestIssue564(t *testing.T) {
-	type Config struct {
-		ID uuid
+	type server struct {
+		Port int
 	}
 
-	var config Config
+	type config struct {
+		Name   string
+		Server server
+		Raw    toml.RawTOML `toml:",source"`
+		Bytes  []byte       `toml:",source"`
+	}
 
-	err := toml.Unmarshal([]byte(`id = "0818a52b97b94768941ba1172c76cf6c"`), &config)
+	var c config
+	err := toml.NewDecoder(strings.NewReader(doc)).DisallowUnknownFields().EnforceRequiredFields().Decode(&c)
 	require.NoError(t, err)
-	require.Equal(t, uuid{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA}, config.ID)
-}
+	require.Equal(t, "app", c.Name)
+	require.Equal(t, 80, c.Server.Port)
+	require.Equal(t, toml.RawTOML(doc), c.Raw)
+	// Only the first field tagged with source is set.
+	require.Nil(t, c.Bytes)
+
+	type bytesConfig struct {
+		Source []byte `toml:",source"`
+	}
 
-func TestIssue575(t *testing.T) {
-	b := []byte(`
-[pkg.cargo]
-version = "0.55.0 (5ae8d74b3 2021-06-22)"
-git_commit_hash = "a178d0322ce20e33eac124758e837cbd80a6f633"
-[pkg.cargo.target.aarch64-apple-darwin]
-available = true
-url = "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-apple-darwin.tar.gz"
-hash = "7bac3901d8eb6a4191ffeebe75b29c78bcb270158ec901addb31f588d965d35d"
-xz_url = "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-apple-darwin.tar.xz"
-xz_hash = "5207644fd6379f3e5b8ae60016b854efa55a381b0c363bff7f9b2f25bfccc430"
+	data := []byte(`source = 1`)
+	var b bytesConfig
+	err = toml.Unmarshal(data, &b)
+	require.NoError(t, err)
+	require.Equal(t, data, b.Source)
+	data[0] = 'S'
+	require.Equal(t, []byte(`source = 1`), b.Source)
 
-[pkg.cargo.target.aarch64-pc-windows-msvc]
-available = true
-url = "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-pc-windows-msvc.tar.gz"
-hash = "eb8ccd9b1f6312b06dc749c17896fa4e9c163661c273dcb61cd7a48376227f6d"
-xz_url = "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-pc-windows-msvc.tar.xz"
-xz_hash = "1a48f723fea1f17d786ce6eadd9d00914d38062d28fd9c455ed3c3801905b388"
-`)
+	out, err := toml.Marshal(c)
+	require.NoError(t, err)
+	require.NotContains(t, string(out), "Raw")
+	require.NotContains(t, string(out), "Bytes")
 
-	type target struct {
-		XZ_URL string
+	type invalid struct {
+		Source string `toml:",source"`
 	}
 
-	type pkg struct {
-		Target map[string]target
-	}
+	err = toml.Unmarshal([]byte(`a = 1`), &invalid{})
+	require.EqualError(t, err, "toml: field tagged with the source option must be a []byte, not string")
+}
 
-	type doc struct {
-		Pkg map[string]pkg
+func TestDecodeOneOf(t *testing.T) {
+	type item struct {
+		Kind string `toml:"kind,oneof=a|b"`
 	}
 
-	var dist doc
-	err := toml.Unmarshal(b, &dist)
-	require.NoError(t, err)
+	type config struct {
+		Level  string   `toml:"level,oneof=debug|info|warn|error"`
+		Levels []string `toml:"levels,oneof=debug|info"`
+		Name   string   `toml:"name"`
+		Items  []item   `toml:"items"`
+	}
 
-	expected := doc{
-		Pkg: map[string]pkg{
-			"cargo": {
-				Target: map[string]target{
-					"aarch64-apple-darwin": {
-						XZ_URL: "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-apple-darwin.tar.xz",
-					},
-					"aarch64-pc-windows-msvc": {
-						XZ_URL: "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-pc-windows-msvc.tar.xz",
-					},
-				},
-			},
+	examples := []struct {
+		desc string
+		doc  string
+		err  string
+	}{
+		{
+			desc: "allowed values",
+			doc: `level = "warn"
+levels = ["debug", "info"]
+name = "anything"
+items = [{kind = "a"}, {kind = "b"}]`,
+		},
+		{
+			desc: "string field",
+			doc:  `level = "trace"`,
+			err:  `toml: value "trace" is not one of debug, info, warn, error`,
+		},
+		{
+			desc: "slice element",
+			doc:  `levels = ["debug", "warn"]`,
+			err:  `toml: array element 1: value "warn" is not one of debug, info`,
+		},
+		{
+			desc: "array table",
+			doc: `[[items]]
+kind = "a"
+[[items]]
+kind = "c"`,
+			err: `toml: value "c" is not one of a, b (in [[items]] element 2)`,
 		},
 	}
 
-	require.Equal(t, expected, dist)
-}
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var c config
+			err := toml.Unmarshal([]byte(e.doc), &c)
+			if e.err == "" {
+				require.NoError(t, err)
+				return
+			}
 
-func TestIssue579(t *testing.T) {
-	var v interface{}
-	err := toml.Unmarshal([]byte(`[foo`), &v)
-	require.Error(t, err)
+			var de *toml.DecodeError
+			require.ErrorAs(t, err, &de)
+			require.Equal(t, e.err, de.Error())
+			row, _ := de.Position()
+			require.Equal(t, strings.Count(e.doc, "\n")+1, row)
+		})
+	}
 }
 
-func TestIssue581(t *testing.T) {
-	var v interface{}
-	err := toml.Unmarshal([]byte(`P=[#`), &v)
-	require.Error(t, err)
-}
+func TestUnmarshalSQLNull(t *testing.T) {
+	type config struct {
+		Name    sql.NullString
+		Count   sql.NullInt64
+		Small   sql.NullInt32
+		Ratio   sql.NullFloat64
+		Enabled sql.NullBool
+		At      sql.NullTime
+		Missing sql.NullString
+		Tags    []sql.NullString
+	}
 
-func TestIssue585(t *testing.T) {
-	var v interface{}
-	err := toml.Unmarshal([]byte(`a=1979-05127T 0`), &v)
-	require.Error(t, err)
-}
+	doc := `
+Name = ""
+Count = 42
+Small = 7
+Ratio = 0.5
+Enabled = false
+At = 2021-04-08T22:00:00Z
+Tags = ["a"]
+`
 
-func TestIssue586(t *testing.T) {
-	var v interface{}
-	err := toml.Unmarshal([]byte(`a={ `), &v)
+	var c config
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+	require.Equal(t, config{
+		Name:    sql.NullString{String: "", Valid: true},
+		Count:   sql.NullInt64{Int64: 42, Valid: true},
+		Small:   sql.NullInt32{Int32: 7, Valid: true},
+		Ratio:   sql.NullFloat64{Float64: 0.5, Valid: true},
+		Enabled: sql.NullBool{Bool: false, Valid: true},
+		At:      sql.NullTime{Time: time.Date(2021, 4, 8, 22, 0, 0, 0, time.UTC), Valid: true},
+		Tags:    []sql.NullString{{String: "a", Valid: true}},
+	}, c)
+
+	err = toml.Unmarshal([]byte(`Small = 3000000000`), &c)
 	require.Error(t, err)
-}
 
-func TestIssue588(t *testing.T) {
-	var v interface{}
-	err := toml.Unmarshal([]byte(`a=[1#`), &v)
+	err = toml.Unmarshal([]byte(`Count = "a"`), &c)
 	require.Error(t, err)
 }
 
-// Support lowercase 'T' and 'Z'
-func TestIssue600(t *testing.T) {
-	var v interface{}
-	err := toml.Unmarshal([]byte(`a=1979-05-27t00:32:00z`), &v)
-	require.NoError(t, err)
-}
+func TestDecoderSetMaxKeys(t *testing.T) {
+	examples := []struct {
+		desc string
+		doc  string
+		max  int
+		err  string
+		row  int
+	}{
+		{
+			desc: "under the limit",
+			doc:  "a = 1\n[b]\nc = 2",
+			max:  3,
+		},
+		{
+			desc: "key-values",
+			doc:  "a = 1\nb = 2\nc = 3",
+			max:  2,
+			err:  "toml: document contains more than 2 keys",
+			row:  3,
+		},
+		{
+			desc: "table headers",
+			doc:  "a = 1\n[b]\n[[c]]\n[[c]]",
+			max:  3,
+			err:  "toml: document contains more than 3 keys (in [[c]] element 2)",
+			row:  4,
+		},
+		{
+			desc: "inline tables in arrays",
+			doc:  "a = [{b = 1}, {c = 2, d = 3}]",
+			max:  3,
+			err:  "toml: document contains more than 3 keys",
+			row:  1,
+		},
+		{
+			desc: "disabled",
+			doc:  "a = 1\nb = 2\nc = 3",
+			max:  0,
+		},
+	}
 
-func TestIssue596(t *testing.T) {
-	var v interface{}
-	err := toml.Unmarshal([]byte(`a=1979-05-27T90:+2:99`), &v)
-	require.Error(t, err)
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var v map[string]interface{}
+			err := toml.NewDecoder(strings.NewReader(e.doc)).SetMaxKeys(e.max).Decode(&v)
+			if e.err == "" {
+				require.NoError(t, err)
+				return
+			}
+
+			var de *toml.DecodeError
+			require.ErrorAs(t, err, &de)
+			require.Equal(t, e.err, de.Error())
+			row, _ := de.Position()
+			require.Equal(t, e.row, row)
+		})
+	}
 }
 
-func TestIssue602(t *testing.T) {
-	var v interface{}
-	err := toml.Unmarshal([]byte(""), &v)
-	require.NoError(t, err)
+func TestDecoderSetMaxDepth(t *testing.T) {
+	examples := []struct {
+		desc   string
+		doc    string
+		max    int
+		err    string
+		column int
+	}{
+		{
+			desc: "under the limit",
+			doc:  "a = [[1], {b = [2]}]",
+			max:  3,
+		},
+		{
+			desc:   "arrays",
+			doc:    "a = [[[1]]]",
+			max:    2,
+			err:    "toml: maximum nesting depth of 2 exceeded",
+			column: 7,
+		},
+		{
+			desc:   "inline tables",
+			doc:    "a = {b = {c = {d = 1}}}",
+			max:    2,
+			err:    "toml: maximum nesting depth of 2 exceeded",
+			column: 15,
+		},
+		{
+			desc:   "arrays and inline tables",
+			doc:    "a = [{b = [{c = 1}]}]",
+			max:    3,
+			err:    "toml: maximum nesting depth of 3 exceeded",
+			column: 12,
+		},
+		{
+			desc: "siblings",
+			doc:  "a = [[1], [2], [3]]",
+			max:  2,
+		},
+	}
 
-	var expected interface{} = map[string]interface{}{}
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var v map[string]interface{}
+			err := toml.NewDecoder(strings.NewReader(e.doc)).SetMaxDepth(e.max).Decode(&v)
+			if e.err == "" {
+				require.NoError(t, err)
+				return
+			}
 
-	require.Equal(t, expected, v)
+			var de *toml.DecodeError
+			require.ErrorAs(t, err, &de)
+			require.Equal(t, e.err, de.Error())
+			_, column := de.Position()
+			require.Equal(t, e.column, column)
+		})
+	}
 }
 
-func TestIssue623(t *testing.T) {
-	definition := struct {
-		Things []string
-	}{}
-
-	values := `[things]
-foo = "bar"`
+func TestUnmarshalDeeplyNested(t *testing.T) {
+	const n = 100000
 
-	err := toml.Unmarshal([]byte(values), &definition)
-	require.Error(t, err)
-}
+	docs := []string{
+		"a = " + strings.Repeat("[", n) + strings.Repeat("]", n),
+		"a = " + strings.Repeat("{b = ", n) + "1" + strings.Repeat("}", n),
+		"a = " + strings.Repeat("[{b = ", n/2) + "1" + strings.Repeat("}]", n/2),
+		"a = " + strings.Repeat("[", n),
+	}
 
-func TestIssue631(t *testing.T) {
-	v := map[string]interface{}{}
-	err := toml.Unmarshal([]byte("\"\\b\u007f\"= 2"), &v)
-	require.Error(t, err)
+	for _, doc := range docs {
+		var v interface{}
+		err := toml.Unmarshal([]byte(doc), &v)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "maximum nesting depth of 1000 exceeded")
+	}
 }
 
-func TestIssue658(t *testing.T) {
-	var v map[string]interface{}
-	err := toml.Unmarshal([]byte("e={b=1,b=4}"), &v)
-	require.Error(t, err)
-}
+func TestDecoderReset(t *testing.T) {
+	dec := toml.NewDecoder(strings.NewReader("a = 1")).DisallowUnknownFields()
 
-func TestIssue662(t *testing.T) {
-	var v map[string]interface{}
-	err := toml.Unmarshal([]byte("a=[{b=1,b=2}]"), &v)
-	require.Error(t, err)
-}
+	var v struct{ A int }
+	err := dec.Decode(&v)
+	require.NoError(t, err)
+	require.Equal(t, 1, v.A)
 
-func TestIssue666(t *testing.T) {
-	var v map[string]interface{}
-	err := toml.Unmarshal([]byte("a={}\na={}"), &v)
+	dec.Reset(strings.NewReader("a = 2\nb = 3"))
+	err = dec.Decode(&v)
 	require.Error(t, err)
+	require.Equal(t, 2, v.A)
 }
 
-func TestIssue677(t *testing.T) {
+func TestDecoderCollectErrors(t *testing.T) {
+	type server struct {
+		Host string
+		Port int
+	}
+	type config struct {
+		Name    string
+		Retries int
+		Server  server
+		Tags    []string
+	}
+
 	doc := `
-[Build]
-Name = "publication build"
+name = 1
+retries = 3
+unknown = true
 
-[[Build.Dependencies]]
-Name = "command"
-Program = "hugo"
+[server]
+host = "localhost"
+port = "80"
+extra = 1
+
+[tags]
+a = 1
 `
 
-	type _tomlJob struct {
-		Dependencies []map[string]interface{}
-	}
+	var c config
+	err := toml.NewDecoder(strings.NewReader(doc)).DisallowUnknownFields().CollectErrors().Decode(&c)
+	require.Error(t, err)
 
-	type tomlParser struct {
-		Build *_tomlJob
+	var derrs *toml.DecodeErrors
+	require.True(t, errors.As(err, &derrs))
+
+	type position struct{ line, column int }
+	var positions []position
+	for _, e := range derrs.Errors {
+		line, column := e.Position()
+		positions = append(positions, position{line, column})
 	}
+	require.Equal(t, []position{{2, 8}, {4, 1}, {8, 8}, {9, 1}, {11, 2}}, positions)
+	require.Equal(t, "toml: cannot decode TOML integer into struct field toml_test.config.Name of type string (and 4 more errors)", err.Error())
+	require.Equal(t, []string{"server", "extra"}, []string(derrs.Errors[3].Key()))
+	require.Equal(t, config{Retries: 3, Server: server{Host: "localhost"}}, c)
 
-	p := tomlParser{}
+	err = toml.NewDecoder(strings.NewReader("a = 1\nb = [")).CollectErrors().Decode(&c)
+	var derr *toml.DecodeError
+	require.True(t, errors.As(err, &derr))
+	require.False(t, errors.As(err, &derrs))
 
-	err := toml.Unmarshal([]byte(doc), &p)
-	require.NoError(t, err)
+	err = toml.NewDecoder(strings.NewReader("name = 1\nname = 2")).CollectErrors().Decode(&c)
+	require.False(t, errors.As(err, &derrs))
 
-	expected := tomlParser{
-		Build: &_tomlJob{
-			Dependencies: []map[string]interface{}{
-				{
-					"Name":    "command",
-					"Program": "hugo",
-				},
-			},
-		},
-	}
-	require.Equal(t, expected, p)
+	err = toml.NewDecoder(strings.NewReader("name = 'a'")).CollectErrors().Decode(&c)
+	require.NoError(t, err)
 }
 
-func TestIssue701(t *testing.T) {
-	// Expected behavior:
-	// Return an error since a cannot be modified. From the TOML spec:
-	//
-	// > Inline tables are fully self-contained and define all
-	// keys and sub-tables within them. Keys and sub-tables cannot
-	// be added outside the braces.
-
-	docs := []string{
-		`
-a={}
-[a.b]
-z=0
-`,
-		`
-a={}
-[[a.b]]
-z=0
-`,
+func TestUnmarshalArrayTableKeyMismatch(t *testing.T) {
+	type server struct {
+		Name string
 	}
 
-	for _, doc := range docs {
-		var v interface{}
-		err := toml.Unmarshal([]byte(doc), &v)
-		assert.Error(t, err)
+	type config struct {
+		Servers []server `toml:"server"`
 	}
-}
-
-func TestIssue703(t *testing.T) {
-	var v interface{}
-	err := toml.Unmarshal([]byte("[a]\nx.y=0\n[a.x]"), &v)
-	require.Error(t, err)
-}
 
-func TestIssue708(t *testing.T) {
-	v := map[string]string{}
-	err := toml.Unmarshal([]byte("0=\"\"\"\\\r\n\"\"\""), &v)
+	var c config
+	err := toml.Unmarshal([]byte("[[server]]\nname = 'a'\n[[server]]\nname = 'b'"), &c)
 	require.NoError(t, err)
-	require.Equal(t, map[string]string{"0": ""}, v)
-}
+	require.Equal(t, []server{{Name: "a"}, {Name: "b"}}, c.Servers)
 
-func TestIssue710(t *testing.T) {
-	v := map[string]toml.LocalTime{}
-	err := toml.Unmarshal([]byte(`0=00:00:00.0000000000`), &v)
+	// The field name is not accepted when the tag provides a name.
+	c = config{}
+	err = toml.Unmarshal([]byte("[[Servers]]\nname = 'a'"), &c)
 	require.NoError(t, err)
-	require.Equal(t, map[string]toml.LocalTime{"0": {Precision: 9}}, v)
-	v1 := map[string]toml.LocalTime{}
-	err = toml.Unmarshal([]byte(`0=00:00:00.0000000001`), &v1)
-	require.NoError(t, err)
-	require.Equal(t, map[string]toml.LocalTime{"0": {Precision: 9}}, v1)
-	v2 := map[string]toml.LocalTime{}
-	err = toml.Unmarshal([]byte(`0=00:00:00.1111111119`), &v2)
-	require.NoError(t, err)
-	require.Equal(t, map[string]toml.LocalTime{"0": {Nanosecond: 111111111, Precision: 9}}, v2)
+	require.Empty(t, c.Servers)
 }
 
-func TestIssue715(t *testing.T) {
-	var v interface{}
-	err := toml.Unmarshal([]byte("0=+"), &v)
-	require.Error(t, err)
+func TestUnmarshalAlias(t *testing.T) {
+	type server struct {
+		Name string `toml:"name,alias=hostname|host"`
+	}
 
-	err = toml.Unmarshal([]byte("0=-"), &v)
-	require.Error(t, err)
+	type config struct {
+		Servers []server `toml:"servers,alias=server,required"`
+	}
 
-	err = toml.Unmarshal([]byte("0=+A"), &v)
-	require.Error(t, err)
-}
+	examples := []struct {
+		desc     string
+		doc      string
+		expected []server
+		err      string
+	}{
+		{
+			desc:     "primary name",
+			doc:      "[[servers]]\nname = 'a'",
+			expected: []server{{Name: "a"}},
+		},
+		{
+			desc:     "aliases",
+			doc:      "[[server]]\nhost = 'a'\n[[server]]\nhostname = 'b'",
+			expected: []server{{Name: "a"}, {Name: "b"}},
+		},
+		{
+			desc:     "case insensitive",
+			doc:      "[[Server]]\nHOST = 'a'",
+			expected: []server{{Name: "a"}},
+		},
+		{
+			desc:     "inline",
+			doc:      "server = [{host = 'a'}]",
+			expected: []server{{Name: "a"}},
+		},
+		{
+			desc: "conflicting array tables",
+			doc:  "[[server]]\nname = 'a'\n[[servers]]\nname = 'b'",
+			err:  "toml: server and servers cannot both be used for field Servers (in [[servers]] element 1)",
+		},
+		{
+			desc: "conflicting keys",
+			doc:  "[[servers]]\nname = 'a'\nhost = 'b'",
+			err:  "toml: name and host cannot both be used for field Name (in [[servers]] element 1)",
+		},
+		{
+			desc:     "different tables",
+			doc:      "[[servers]]\nname = 'a'\n[[servers]]\nhost = 'b'",
+			expected: []server{{Name: "a"}, {Name: "b"}},
+		},
+	}
 
-func TestIssue714(t *testing.T) {
-	var v interface{}
-	err := toml.Unmarshal([]byte("0."), &v)
-	require.Error(t, err)
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var c config
+			err := toml.NewDecoder(strings.NewReader(e.doc)).EnforceRequiredFields().Decode(&c)
+			if e.err != "" {
+				var de *toml.DecodeError
+				require.ErrorAs(t, err, &de)
+				require.Equal(t, e.err, de.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, e.expected, c.Servers)
+		})
+	}
 
-	err = toml.Unmarshal([]byte("0={0=0,"), &v)
-	require.Error(t, err)
+	b, err := toml.Marshal(config{Servers: []server{{Name: "a"}}})
+	require.NoError(t, err)
+	require.Equal(t, "[[servers]]\nname = 'a'\n\n", string(b))
 }
 
-func TestIssue772(t *testing.T) {
-	type FileHandling struct {
-		FilePattern string `toml:"pattern"`
+func TestUnmarshalBigNumbers(t *testing.T) {
+	type doc struct {
+		I *big.Int
+		F *big.Float
 	}
 
-	type Config struct {
-		FileHandling `toml:"filehandling"`
+	examples := []struct {
+		desc string
+		doc  string
+		i    string
+		f    string
+		err  string
+	}{
+		{desc: "large integer", doc: "i = 123456789012345678901234567890", i: "123456789012345678901234567890"},
+		{desc: "underscores", doc: "i = -1_000_000_000_000_000_000_000", i: "-1000000000000000000000"},
+		{desc: "hexadecimal", doc: "i = 0xffff_ffff_ffff_ffff_ffff", i: "1208925819614629174706175"},
+		{desc: "octal", doc: "i = 0o777", i: "511"},
+		{desc: "binary", doc: "i = 0b1_0000_0000", i: "256"},
+		{desc: "string", doc: "i = '42'", i: "42"},
+		{desc: "precise float", doc: "f = 3.141_592_653_589_793_238_462_643_383_279", f: "3.141592653589793238462643383279"},
+		{desc: "large exponent", doc: "f = 1e400", f: "1e+400"},
+		{desc: "integer float", doc: "f = 12345678901234567890123", f: "1.2345678901234567890123e+22"},
+		{desc: "infinity", doc: "f = -inf", f: "-Inf"},
+		{desc: "float into integer", doc: "i = 1.5", err: "toml: float cannot be assigned to big.Int"},
+		{desc: "nan", doc: "f = nan", err: "toml: nan cannot be represented by a big.Float"},
+		{desc: "underscore after prefix", doc: "i = 0x_ff", err: "toml: number cannot start with underscore"},
 	}
 
-	var defaultConfigFile = []byte(`
-		[filehandling]
-		pattern = "reach-masterdev-"`)
-
-	config := Config{}
-	err := toml.Unmarshal(defaultConfigFile, &config)
-	require.NoError(t, err)
-	require.Equal(t, "reach-masterdev-", config.FileHandling.FilePattern)
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var d doc
+			err := toml.Unmarshal([]byte(e.doc), &d)
+			if e.err != "" {
+				require.EqualError(t, err, e.err)
+				return
+			}
+			require.NoError(t, err)
+			if e.i != "" {
+				require.Equal(t, e.i, d.I.String())
+			}
+			if e.f != "" {
+				require.Equal(t, e.f, d.F.Text('g', -1))
+			}
+		})
+	}
 }
 
-func TestIssue774(t *testing.T) {
-	type ScpData struct {
-		Host string `json:"host"`
+func TestDecoderDisallowCaseConflicts(t *testing.T) {
+	type pool struct {
+		MaxConns int
+		Name     string `toml:"pool_name"`
 	}
-
-	type GenConfig struct {
-		SCP []ScpData `toml:"scp" comment:"Array of Secure Copy Configurations"`
+	type config struct {
+		Pool  pool
+		Pools []pool
 	}
 
-	c := &GenConfig{}
-	c.SCP = []ScpData{{Host: "main.domain.com"}}
+	doc := `
+[pool]
+maxconns = 10
+POOL_NAME = "main"
 
-	b, err := toml.Marshal(c)
+[[pools]]
+MaxConns = 1
+
+[[pools]]
+maxConns = 2
+`
+
+	var c config
+	err := toml.NewDecoder(strings.NewReader(doc)).DisallowCaseConflicts().Decode(&c)
 	require.NoError(t, err)
+	require.Equal(t, config{
+		Pool:  pool{MaxConns: 10, Name: "main"},
+		Pools: []pool{{MaxConns: 1}, {MaxConns: 2}},
+	}, c)
 
-	expected := `# Array of Secure Copy Configurations
-[[scp]]
-Host = 'main.domain.com'
+	doc = "[pool]\nMaxConns = 1\nmaxconns = 2"
 
-`
+	err = toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+	require.Equal(t, 2, c.Pool.MaxConns)
 
-	require.Equal(t, expected, string(b))
+	err = toml.NewDecoder(strings.NewReader(doc)).DisallowCaseConflicts().Decode(&c)
+	require.EqualError(t, err, "toml: keys MaxConns and maxconns only differ by case and cannot both be used for field MaxConns (in [pool])")
+
+	var derr *toml.DecodeError
+	require.True(t, errors.As(err, &derr))
+	line, column := derr.Position()
+	require.Equal(t, 3, line)
+	require.Equal(t, 1, column)
 }
 
-func TestUnmarshalDecodeErrors(t *testing.T) {
-	examples := []struct {
-		desc string
-		data string
-		msg  string
+func TestUnmarshalDateTimeArrays(t *testing.T) {
+	type config struct {
+		Events     []time.Time
+		Fixed      [2]time.Time
+		Dates      []toml.LocalDate
+		Times      []toml.LocalTime
+		DateTimes  []toml.LocalDateTime
+		Pointers   []*time.Time
+		Interfaces []interface{}
+	}
+
+	doc := `
+Events = [2021-01-01T00:00:00Z, 2021-01-02T00:00:00+01:00]
+Fixed = [2021-01-01T00:00:00Z, 2021-01-02T00:00:00Z]
+Dates = [2021-01-01, 2021-01-02]
+Times = [01:02:03, 04:05:06.5]
+DateTimes = [2021-01-01T01:02:03]
+Pointers = [2021-01-01T00:00:00Z]
+Interfaces = [2021-01-01, 01:02:03]
+`
+
+	var c config
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+
+	first := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.Equal(t, []time.Time{first, time.Date(2021, 1, 2, 0, 0, 0, 0, time.FixedZone("", 3600))}, c.Events)
+	require.Equal(t, [2]time.Time{first, first.AddDate(0, 0, 1)}, c.Fixed)
+	require.Equal(t, []toml.LocalDate{{2021, 1, 1}, {2021, 1, 2}}, c.Dates)
+	require.Equal(t, []toml.LocalTime{{1, 2, 3, 0, 0}, {4, 5, 6, 500000000, 1}}, c.Times)
+	require.Equal(t, []toml.LocalDateTime{{toml.LocalDate{2021, 1, 1}, toml.LocalTime{1, 2, 3, 0, 0}}}, c.DateTimes)
+	require.Equal(t, []*time.Time{&first}, c.Pointers)
+	require.Equal(t, []interface{}{toml.LocalDate{2021, 1, 1}, toml.LocalTime{1, 2, 3, 0, 0}}, c.Interfaces)
+
+	errors := []struct {
+		doc string
+		err string
 	}{
 		{
-			desc: "local date with invalid digit",
-			data: `a = 20x1-05-21`,
-		},
-		{
-			desc: "local time with fractional",
-			data: `a = 11:22:33.x`,
-		},
-		{
-			desc: "wrong time offset separator",
-			data: `a = 1979-05-27T00:32:00.-07:00`,
+			doc: `Events = [2021-01-01T00:00:00Z, "2021-01-02T00:00:00Z"]`,
+			err: "toml: array element 1: cannot store TOML string into a Go struct",
 		},
 		{
-			desc: "wrong time offset separator",
-			data: `a = 1979-05-27T00:32:00Z07:00`,
+			doc: `Dates = [2021-01-01, 2021-01-02T00:00:00Z]`,
+			err: "toml: array element 1: cannot store TOML offset date-time into a Go toml.LocalDate",
 		},
 		{
-			desc: "float with double _",
-			data: `flt8 = 224_617.445_991__228`,
+			doc: `Times = [01:02:03, 2021-01-02T00:00:00]`,
+			err: "toml: array element 1: cannot store TOML local date-time into a Go toml.LocalTime",
 		},
 		{
-			desc: "float with double .",
-			data: `flt8 = 1..2`,
+			doc: `DateTimes = [2021-01-02]`,
+			err: "toml: array element 0: cannot store TOML local date into a Go toml.LocalDateTime",
 		},
 		{
-			desc: "number with plus sign and leading underscore",
-			data: `a = +_0`,
+			doc: `Names = ["a", 2021-01-02]`,
+			err: "toml: array element 1: cannot store TOML local date into a Go string",
 		},
-		{
-			desc: "number with negative sign and leading underscore",
-			data: `a = -_0`,
+	}
+
+	for _, e := range errors {
+		var v struct {
+			config
+			Names []string
+		}
+		err := toml.Unmarshal([]byte(e.doc), &v)
+		var de *toml.DecodeError
+		require.ErrorAs(t, err, &de, e.doc)
+		require.Equal(t, e.err, de.Error())
+	}
+}
+
+func TestUnmarshalFixedSizeArrays(t *testing.T) {
+	type doc struct {
+		Color [3]uint8
+		Point [2][2]int
+	}
+
+	var d doc
+	err := toml.Unmarshal([]byte("color = [255, 128, 0]\npoint = [[1, 2], [3]]"), &d)
+	require.NoError(t, err)
+	require.Equal(t, doc{Color: [3]uint8{255, 128, 0}, Point: [2][2]int{{1, 2}, {3, 0}}}, d)
+
+	// Elements missing from the document are reset.
+	err = toml.Unmarshal([]byte("color = [1]"), &d)
+	require.NoError(t, err)
+	require.Equal(t, [3]uint8{1, 0, 0}, d.Color)
+
+	err = toml.Unmarshal([]byte("\ncolor = [\n  1,\n  2,\n  3,\n  4,\n]"), &d)
+	var de *toml.DecodeError
+	require.ErrorAs(t, err, &de)
+	require.Equal(t, "toml: array of 4 elements cannot be decoded into a [3]uint8", de.Error())
+	row, col := de.Position()
+	require.Equal(t, 2, row)
+	require.Equal(t, 9, col)
+
+	err = toml.Unmarshal([]byte("point = [[1, 2], [3, 4, 5]]"), &d)
+	require.ErrorAs(t, err, &de)
+	require.Equal(t, "toml: array element 1: array of 3 elements cannot be decoded into a [2]int", de.Error())
+}
+
+func TestUnmarshalMapKeysCaseInStruct(t *testing.T) {
+	type inner struct {
+		MaxRetries int
+	}
+
+	type config struct {
+		ServerName string
+		Inner      inner
+		Labels     map[string]string
+		Any        map[string]interface{}
+		Extra      map[string]interface{} `toml:",remaining"`
+	}
+
+	doc := `
+SERVERNAME = "a"
+Other-Key = 1
+
+[inner]
+max-retries = 2
+
+[Labels]
+Env = "prod"
+UPPER = "x"
+max-retries = "kept"
+
+[ANY.Nested]
+MixedCase = true
+`
+
+	expected := config{
+		ServerName: "a",
+		Inner:      inner{MaxRetries: 2},
+		Labels:     map[string]string{"Env": "prod", "UPPER": "x", "max-retries": "kept"},
+		Any: map[string]interface{}{
+			"Nested": map[string]interface{}{"MixedCase": true},
+		},
+		Extra: map[string]interface{}{"Other-Key": int64(1)},
+	}
+
+	var c config
+	err := toml.NewDecoder(strings.NewReader(doc)).SetKeyMapper(toml.KebabCase).Decode(&c)
+	require.NoError(t, err)
+	require.Equal(t, expected, c)
+
+	var m map[string]interface{}
+	err = toml.Unmarshal([]byte(doc), &m)
+	require.NoError(t, err)
+	require.Contains(t, m, "SERVERNAME")
+	require.Contains(t, m, "ANY")
+	require.Equal(t, map[string]interface{}{"max-retries": int64(2)}, m["inner"])
+}
+
+func TestUnmarshalTextUnmarshalerMapKey(t *testing.T) {
+	doc := `
+'eu/1' = "a"
+
+['us/2']
+name = "b"
+`
+
+	var m map[textMapKey]interface{}
+	err := toml.Unmarshal([]byte(doc), &m)
+	require.NoError(t, err)
+	require.Equal(t, map[textMapKey]interface{}{
+		{region: "eu", id: 1}: "a",
+		{region: "us", id: 2}: map[string]interface{}{"name": "b"},
+	}, m)
+
+	err = toml.Unmarshal([]byte("nope = 1"), &m)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot use key nope as a map key of type toml_test.textMapKey")
+}
+
+func TestDecoderStrict(t *testing.T) {
+	examples := []struct {
+		desc     string
+		input    string
+		expected string
+		target   interface{}
+	}{
+		{
+			desc: "multiple missing root keys",
+			input: `
+key1 = "value1"
+key2 = "missing2"
+key3 = "missing3"
+key4 = "value4"
+`,
+			expected: `
+2| key1 = "value1"
+3| key2 = "missing2"
+ | ~~~~ missing field
+4| key3 = "missing3"
+5| key4 = "value4"
+---
+2| key1 = "value1"
+3| key2 = "missing2"
+4| key3 = "missing3"
+ | ~~~~ missing field
+5| key4 = "value4"
+`,
+			target: &struct {
+				Key1 string
+				Key4 string
+			}{},
+		},
+		{
+			desc:  "multi-part key",
+			input: `a.short.key="foo"`,
+			expected: `
+1| a.short.key="foo"
+ | ~~~~~~~~~~~ missing field
+`,
+		},
+		{
+			desc: "missing table",
+			input: `
+[foo]
+bar = 42
+`,
+			expected: `
+2| [foo]
+ |  ~~~ missing table
+3| bar = 42
+`,
+		},
+
+		{
+			desc: "missing array table",
+			input: `
+[[foo]]
+bar = 42
+`,
+			expected: `
+2| [[foo]]
+ |   ~~~ missing table
+3| bar = 42
+`,
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			t.Run("strict", func(t *testing.T) {
+				r := strings.NewReader(e.input)
+				d := toml.NewDecoder(r)
+				d.DisallowUnknownFields()
+				x := e.target
+				if x == nil {
+					x = &struct{}{}
+				}
+				err := d.Decode(x)
+
+				var tsm *toml.StrictMissingError
+				if errors.As(err, &tsm) {
+					equalStringsIgnoreNewlines(t, e.expected, tsm.String())
+				} else {
+					t.Fatalf("err should have been a *toml.StrictMissingError, but got %s (%T)", err, err)
+				}
+			})
+
+			t.Run("default", func(t *testing.T) {
+				r := strings.NewReader(e.input)
+				d := toml.NewDecoder(r)
+				x := e.target
+				if x == nil {
+					x = &struct{}{}
+				}
+				err := d.Decode(x)
+				require.NoError(t, err)
+			})
+		})
+	}
+}
+
+func TestIssue252(t *testing.T) {
+	type config struct {
+		Val1 string `toml:"val1"`
+		Val2 string `toml:"val2"`
+	}
+
+	configFile := []byte(
+		`
+val1 = "test1"
+`)
+
+	cfg := &config{
+		Val2: "test2",
+	}
+
+	err := toml.Unmarshal(configFile, cfg)
+	require.NoError(t, err)
+	require.Equal(t, "test2", cfg.Val2)
+}
+
+func TestIssue287(t *testing.T) {
+	b := `y=[[{}]]`
+	v := map[string]interface{}{}
+	err := toml.Unmarshal([]byte(b), &v)
+	require.NoError(t, err)
+
+	expected := map[string]interface{}{
+		"y": []interface{}{
+			[]interface{}{
+				map[string]interface{}{},
+			},
+		},
+	}
+	require.Equal(t, expected, v)
+}
+
+type (
+	Map458   map[string]interface{}
+	Slice458 []interface{}
+)
+
+func (m Map458) A(s string) Slice458 {
+	return m[s].([]interface{})
+}
+
+func TestIssue458(t *testing.T) {
+	s := []byte(`[[package]]
+dependencies = ["regex"]
+name = "decode"
+version = "0.1.0"`)
+	m := Map458{}
+	err := toml.Unmarshal(s, &m)
+	require.NoError(t, err)
+	a := m.A("package")
+	expected := Slice458{
+		map[string]interface{}{
+			"dependencies": []interface{}{"regex"},
+			"name":         "decode",
+			"version":      "0.1.0",
+		},
+	}
+	assert.Equal(t, expected, a)
+}
+
+type Integer484 struct {
+	Value int
+}
+
+func (i Integer484) MarshalText() ([]byte, error) {
+	return []byte(strconv.Itoa(i.Value)), nil
+}
+
+func (i *Integer484) UnmarshalText(data []byte) error {
+	conv, err := strconv.Atoi(string(data))
+	if err != nil {
+		return fmt.Errorf("UnmarshalText: %w", err)
+	}
+	i.Value = conv
+
+	return nil
+}
+
+type Config484 struct {
+	Integers []Integer484 `toml:"integers"`
+}
+
+func TestIssue484(t *testing.T) {
+	raw := []byte(`integers = ["1","2","3","100"]`)
+
+	var cfg Config484
+	err := toml.Unmarshal(raw, &cfg)
+	require.NoError(t, err)
+	assert.Equal(t, Config484{
+		Integers: []Integer484{{1}, {2}, {3}, {100}},
+	}, cfg)
+}
+
+func TestIssue494(t *testing.T) {
+	data := `
+foo = 2021-04-08
+bar = 2021-04-08
+`
+
+	type s struct {
+		Foo time.Time `toml:"foo"`
+		Bar time.Time `toml:"bar"`
+	}
+	ss := new(s)
+	err := toml.Unmarshal([]byte(data), ss)
+	require.NoError(t, err)
+}
+
+func TestIssue508(t *testing.T) {
+	type head struct {
+		Title string `toml:"title"`
+	}
+
+	type text struct {
+		head
+	}
+
+	b := []byte(`title = "This is a title"`)
+
+	t1 := text{}
+	err := toml.Unmarshal(b, &t1)
+	require.NoError(t, err)
+	require.Equal(t, "This is a title", t1.head.Title)
+}
+
+func TestIssue507(t *testing.T) {
+	data := []byte{'0', '=', '\n', '0', 'a', 'm', 'e'}
+	m := map[string]interface{}{}
+	err := toml.Unmarshal(data, &m)
+	require.Error(t, err)
+}
+
+type uuid [16]byte
+
+func (u *uuid) UnmarshalText(text []byte) (err error) {
+	// Note: the original reported issue had a more complex implementation
+	// of this function. But the important part is to verify that a
+	// non-struct type implementing UnmarshalText works with the unmarshal
+	// process.
+	placeholder := bytes.Repeat([]byte{0xAA}, 16)
+	copy(u[:], placeholder)
+	return nil
+}
+
+func TestIssue564(t *testing.T) {
+	type Config struct {
+		ID uuid
+	}
+
+	var config Config
+
+	err := toml.Unmarshal([]byte(`id = "0818a52b97b94768941ba1172c76cf6c"`), &config)
+	require.NoError(t, err)
+	require.Equal(t, uuid{0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA, 0xAA}, config.ID)
+}
+
+func TestIssue575(t *testing.T) {
+	b := []byte(`
+[pkg.cargo]
+version = "0.55.0 (5ae8d74b3 2021-06-22)"
+git_commit_hash = "a178d0322ce20e33eac124758e837cbd80a6f633"
+[pkg.cargo.target.aarch64-apple-darwin]
+available = true
+url = "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-apple-darwin.tar.gz"
+hash = "7bac3901d8eb6a4191ffeebe75b29c78bcb270158ec901addb31f588d965d35d"
+xz_url = "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-apple-darwin.tar.xz"
+xz_hash = "5207644fd6379f3e5b8ae60016b854efa55a381b0c363bff7f9b2f25bfccc430"
+
+[pkg.cargo.target.aarch64-pc-windows-msvc]
+available = true
+url = "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-pc-windows-msvc.tar.gz"
+hash = "eb8ccd9b1f6312b06dc749c17896fa4e9c163661c273dcb61cd7a48376227f6d"
+xz_url = "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-pc-windows-msvc.tar.xz"
+xz_hash = "1a48f723fea1f17d786ce6eadd9d00914d38062d28fd9c455ed3c3801905b388"
+`)
+
+	type target struct {
+		XZ_URL string
+	}
+
+	type pkg struct {
+		Target map[string]target
+	}
+
+	type doc struct {
+		Pkg map[string]pkg
+	}
+
+	var dist doc
+	err := toml.Unmarshal(b, &dist)
+	require.NoError(t, err)
+
+	expected := doc{
+		Pkg: map[string]pkg{
+			"cargo": {
+				Target: map[string]target{
+					"aarch64-apple-darwin": {
+						XZ_URL: "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-apple-darwin.tar.xz",
+					},
+					"aarch64-pc-windows-msvc": {
+						XZ_URL: "https://static.rust-lang.org/dist/2021-07-29/cargo-1.54.0-aarch64-pc-windows-msvc.tar.xz",
+					},
+				},
+			},
+		},
+	}
+
+	require.Equal(t, expected, dist)
+}
+
+func TestIssue579(t *testing.T) {
+	var v interface{}
+	err := toml.Unmarshal([]byte(`[foo`), &v)
+	require.Error(t, err)
+}
+
+func TestIssue581(t *testing.T) {
+	var v interface{}
+	err := toml.Unmarshal([]byte(`P=[#`), &v)
+	require.Error(t, err)
+}
+
+func TestIssue585(t *testing.T) {
+	var v interface{}
+	err := toml.Unmarshal([]byte(`a=1979-05127T 0`), &v)
+	require.Error(t, err)
+}
+
+func TestIssue586(t *testing.T) {
+	var v interface{}
+	err := toml.Unmarshal([]byte(`a={ `), &v)
+	require.Error(t, err)
+}
+
+func TestIssue588(t *testing.T) {
+	var v interface{}
+	err := toml.Unmarshal([]byte(`a=[1#`), &v)
+	require.Error(t, err)
+}
+
+// Support lowercase 'T' and 'Z'
+func TestIssue600(t *testing.T) {
+	var v interface{}
+	err := toml.Unmarshal([]byte(`a=1979-05-27t00:32:00z`), &v)
+	require.NoError(t, err)
+}
+
+func TestIssue596(t *testing.T) {
+	var v interface{}
+	err := toml.Unmarshal([]byte(`a=1979-05-27T90:+2:99`), &v)
+	require.Error(t, err)
+}
+
+func TestIssue602(t *testing.T) {
+	var v interface{}
+	err := toml.Unmarshal([]byte(""), &v)
+	require.NoError(t, err)
+
+	var expected interface{} = map[string]interface{}{}
+
+	require.Equal(t, expected, v)
+}
+
+func TestIssue623(t *testing.T) {
+	definition := struct {
+		Things []string
+	}{}
+
+	values := `[things]
+foo = "bar"`
+
+	err := toml.Unmarshal([]byte(values), &definition)
+	require.Error(t, err)
+}
+
+func TestIssue631(t *testing.T) {
+	v := map[string]interface{}{}
+	err := toml.Unmarshal([]byte("\"\\b\u007f\"= 2"), &v)
+	require.Error(t, err)
+}
+
+func TestIssue658(t *testing.T) {
+	var v map[string]interface{}
+	err := toml.Unmarshal([]byte("e={b=1,b=4}"), &v)
+	require.Error(t, err)
+}
+
+func TestIssue662(t *testing.T) {
+	var v map[string]interface{}
+	err := toml.Unmarshal([]byte("a=[{b=1,b=2}]"), &v)
+	require.Error(t, err)
+}
+
+func TestIssue666(t *testing.T) {
+	var v map[string]interface{}
+	err := toml.Unmarshal([]byte("a={}\na={}"), &v)
+	require.Error(t, err)
+}
+
+func TestIssue677(t *testing.T) {
+	doc := `
+[Build]
+Name = "publication build"
+
+[[Build.Dependencies]]
+Name = "command"
+Program = "hugo"
+`
+
+	type _tomlJob struct {
+		Dependencies []map[string]interface{}
+	}
+
+	type tomlParser struct {
+		Build *_tomlJob
+	}
+
+	p := tomlParser{}
+
+	err := toml.Unmarshal([]byte(doc), &p)
+	require.NoError(t, err)
+
+	expected := tomlParser{
+		Build: &_tomlJob{
+			Dependencies: []map[string]interface{}{
+				{
+					"Name":    "command",
+					"Program": "hugo",
+				},
+			},
+		},
+	}
+	require.Equal(t, expected, p)
+}
+
+func TestIssue701(t *testing.T) {
+	// Expected behavior:
+	// Return an error since a cannot be modified. From the TOML spec:
+	//
+	// > Inline tables are fully self-contained and define all
+	// keys and sub-tables within them. Keys and sub-tables cannot
+	// be added outside the braces.
+
+	docs := []string{
+		`
+a={}
+[a.b]
+z=0
+`,
+		`
+a={}
+[[a.b]]
+z=0
+`,
+	}
+
+	for _, doc := range docs {
+		var v interface{}
+		err := toml.Unmarshal([]byte(doc), &v)
+		assert.Error(t, err)
+	}
+}
+
+func TestIssue703(t *testing.T) {
+	var v interface{}
+	err := toml.Unmarshal([]byte("[a]\nx.y=0\n[a.x]"), &v)
+	require.Error(t, err)
+}
+
+func TestIssue708(t *testing.T) {
+	v := map[string]string{}
+	err := toml.Unmarshal([]byte("0=\"\"\"\\\r\n\"\"\""), &v)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"0": ""}, v)
+}
+
+func TestIssue710(t *testing.T) {
+	v := map[string]toml.LocalTime{}
+	err := toml.Unmarshal([]byte(`0=00:00:00.0000000000`), &v)
+	require.NoError(t, err)
+	require.Equal(t, map[string]toml.LocalTime{"0": {Precision: 9}}, v)
+	v1 := map[string]toml.LocalTime{}
+	err = toml.Unmarshal([]byte(`0=00:00:00.0000000001`), &v1)
+	require.NoError(t, err)
+	require.Equal(t, map[string]toml.LocalTime{"0": {Precision: 9}}, v1)
+	v2 := map[string]toml.LocalTime{}
+	err = toml.Unmarshal([]byte(`0=00:00:00.1111111119`), &v2)
+	require.NoError(t, err)
+	require.Equal(t, map[string]toml.LocalTime{"0": {Nanosecond: 111111111, Precision: 9}}, v2)
+}
+
+func TestIssue715(t *testing.T) {
+	var v interface{}
+	err := toml.Unmarshal([]byte("0=+"), &v)
+	require.Error(t, err)
+
+	err = toml.Unmarshal([]byte("0=-"), &v)
+	require.Error(t, err)
+
+	err = toml.Unmarshal([]byte("0=+A"), &v)
+	require.Error(t, err)
+}
+
+func TestIssue714(t *testing.T) {
+	var v interface{}
+	err := toml.Unmarshal([]byte("0."), &v)
+	require.Error(t, err)
+
+	err = toml.Unmarshal([]byte("0={0=0,"), &v)
+	require.Error(t, err)
+}
+
+func TestIssue772(t *testing.T) {
+	type FileHandling struct {
+		FilePattern string `toml:"pattern"`
+	}
+
+	type Config struct {
+		FileHandling `toml:"filehandling"`
+	}
+
+	var defaultConfigFile = []byte(`
+		[filehandling]
+		pattern = "reach-masterdev-"`)
+
+	config := Config{}
+	err := toml.Unmarshal(defaultConfigFile, &config)
+	require.NoError(t, err)
+	require.Equal(t, "reach-masterdev-", config.FileHandling.FilePattern)
+}
+
+func TestIssue774(t *testing.T) {
+	type ScpData struct {
+		Host string `json:"host"`
+	}
+
+	type GenConfig struct {
+		SCP []ScpData `toml:"scp" comment:"Array of Secure Copy Configurations"`
+	}
+
+	c := &GenConfig{}
+	c.SCP = []ScpData{{Host: "main.domain.com"}}
+
+	b, err := toml.Marshal(c)
+	require.NoError(t, err)
+
+	expected := `# Array of Secure Copy Configurations
+[[scp]]
+Host = 'main.domain.com'
+
+`
+
+	require.Equal(t, expected, string(b))
+}
+
+func TestUnmarshalDecodeErrors(t *testing.T) {
+	examples := []struct {
+		desc string
+		data string
+		msg  string
+	}{
+		{
+			desc: "local date with invalid digit",
+			data: `a = 20x1-05-21`,
+		},
+		{
+			desc: "local time with fractional",
+			data: `a = 11:22:33.x`,
+		},
+		{
+			desc: "wrong time offset separator",
+			data: `a = 1979-05-27T00:32:00.-07:00`,
+		},
+		{
+			desc: "wrong time offset separator",
+			data: `a = 1979-05-27T00:32:00Z07:00`,
+		},
+		{
+			desc: "float with double _",
+			data: `flt8 = 224_617.445_991__228`,
+		},
+		{
+			desc: "float with double .",
+			data: `flt8 = 1..2`,
+		},
+		{
+			desc: "number with plus sign and leading underscore",
+			data: `a = +_0`,
+		},
+		{
+			desc: "number with negative sign and leading underscore",
+			data: `a = -_0`,
 		},
 		{
 			desc: "exponent with plus sign and leading underscore",
@@ -2619,599 +3819,1727 @@ world'`,
 			data: `a = faux`,
 		},
 		{
-			desc: "inline table with incorrect separator",
-			data: `a = {b=1;}`,
+			desc: "inline table with incorrect separator",
+			data: `a = {b=1;}`,
+		},
+		{
+			desc: "inline table with invalid value",
+			data: `a = {b=faux}`,
+		},
+		{
+			desc: `incomplete array after whitespace`,
+			data: `a = [ `,
+		},
+		{
+			desc: `array with comma first`,
+			data: `a = [ ,]`,
+		},
+		{
+			desc: `array staring with incomplete newline`,
+			data: "a = [\r]",
+		},
+		{
+			desc: `array with incomplete newline after comma`,
+			data: "a = [1,\r]",
+		},
+		{
+			desc: `array with incomplete newline after value`,
+			data: "a = [1\r]",
+		},
+		{
+			desc: `invalid unicode in basic multiline string`,
+			data: `A = """\u123"""`,
+		},
+		{
+			desc: `invalid long unicode in basic multiline string`,
+			data: `A = """\U0001D11"""`,
+		},
+		{
+			desc: `invalid unicode in basic string`,
+			data: `A = "\u123"`,
+		},
+		{
+			desc: `invalid long unicode in basic string`,
+			data: `A = "\U0001D11"`,
+		},
+		{
+			desc: `invalid escape char basic multiline string`,
+			data: `A = """\z"""`,
+		},
+		{
+			desc: `invalid inf`,
+			data: `A = ick`,
+		},
+		{
+			desc: `invalid nan`,
+			data: `A = non`,
+		},
+		{
+			desc: `invalid character in comment in array`,
+			data: "A = [#\x00\n]",
+		},
+		{
+			desc: "invalid utf8 character in long string with no escape sequence",
+			data: "a = \"aaaa\x80aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\"",
+		},
+		{
+			desc: "invalid ascii character in long string with no escape sequence",
+			data: "a = \"aaaa\x00aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\"",
+		},
+		{
+			desc: "unfinished 2-byte utf8 character in string with no escape sequence",
+			data: "a = \"aaaa\xC2\"",
+		},
+		{
+			desc: "unfinished 3-byte utf8 character in string with no escape sequence",
+			data: "a = \"aaaa\xE2\x00\x00\"",
+		},
+		{
+			desc: "invalid 3rd byte of 3-byte utf8 character in string with no escape sequence",
+			data: "a = \"aaaa\xE2\x80\x00\"",
+		},
+		{
+			desc: "invalid 4rd byte of 4-byte utf8 character in string with no escape sequence",
+			data: "a = \"aaaa\xF2\x81\x81\x00\"",
+		},
+		{
+			desc: "unfinished 2-byte utf8 character in literal string",
+			data: "a = 'aaa\xC2'",
+		},
+		{
+			desc: "unfinished 3-byte utf8 character in literal string",
+			data: "a = 'aaaa\xE2\x00\x00'",
+		},
+		{
+			desc: "invalid 3rd byte of 3-byte utf8 character in literal string",
+			data: "a = 'aaaa\xE2\x80\x00'",
+		},
+		{
+			desc: "invalid 4rd byte of 4-byte utf8 character in literal string",
+			data: "a = 'aaaa\xF2\x81\x81\x00'",
+		},
+		{
+			desc: "invalid start utf8 character in literal string",
+			data: "a = '\x80'",
+		},
+		{
+			desc: "utf8 character with not enough bytes before end in literal string",
+			data: "a = '\xEF'",
+		},
+		{
+			desc: "basic string with newline after the first escape code",
+			data: "a = \"\\t\n\"",
+		},
+		{
+			desc: "basic string with unfinished escape sequence after the first escape code",
+			data: "a = \"\\t\\",
+		},
+		{
+			desc: "basic string with unfinished after the first escape code",
+			data: "a = \"\\t",
+		},
+		{
+			desc: "multiline basic string with unfinished escape sequence after the first escape code",
+			data: "a = \"\"\"\\t\\",
+		},
+		{
+			desc: `impossible date-day`,
+			data: `A = 2021-03-40T23:59:00`,
+			msg:  `impossible date`,
+		},
+		{
+			desc: `leap day in non-leap year`,
+			data: `A = 2021-02-29T23:59:00`,
+			msg:  `impossible date`,
+		},
+		{
+			desc: `missing minute digit`,
+			data: `a=17:4::01`,
+		},
+		{
+			desc: `invalid space in year`,
+			data: `i=19 7-12-21T10:32:00`,
+		},
+		{
+			desc: `missing nanoseconds digits`,
+			data: `a=17:45:56.`,
+		},
+		{
+			desc: `minutes over 60`,
+			data: `a=17:99:00`,
+		},
+		{
+			desc: `invalid second`,
+			data: `a=17:00::0`,
+		},
+		{
+			desc: `invalid hour`,
+			data: `a=1::00:00`,
+		},
+		{
+			desc: `invalid month`,
+			data: `a=2021-0--29`,
+		},
+		{
+			desc: `zero is an invalid day`,
+			data: `a=2021-11-00`,
+		},
+		{
+			desc: `zero is an invalid month`,
+			data: `a=2021-00-11`,
+		},
+		{
+			desc: `invalid number of seconds digits with trailing digit`,
+			data: `a=0000-01-01 00:00:000000Z3`,
+		},
+		{
+			desc: `invalid zone offset hours`,
+			data: `a=0000-01-01 00:00:00+24:00`,
+		},
+		{
+			desc: `invalid zone offset minutes`,
+			data: `a=0000-01-01 00:00:00+00:60`,
+		},
+		{
+			desc: `invalid character in zone offset hours`,
+			data: `a=0000-01-01 00:00:00+0Z:00`,
+		},
+		{
+			desc: `invalid character in zone offset minutes`,
+			data: `a=0000-01-01 00:00:00+00:0Z`,
+		},
+		{
+			desc: `invalid number of seconds`,
+			data: `a=0000-01-01 00:00:00+27000`,
+		},
+		{
+			desc: `carriage return inside basic key`,
+			data: "\"\r\"=42",
 		},
 		{
-			desc: "inline table with invalid value",
-			data: `a = {b=faux}`,
+			desc: `carriage return inside literal key`,
+			data: "'\r'=42",
 		},
 		{
-			desc: `incomplete array after whitespace`,
-			data: `a = [ `,
+			desc: `carriage return inside basic string`,
+			data: "A = \"\r\"",
 		},
 		{
-			desc: `array with comma first`,
-			data: `a = [ ,]`,
+			desc: `carriage return inside basic multiline string`,
+			data: "a=\"\"\"\r\"\"\"",
 		},
 		{
-			desc: `array staring with incomplete newline`,
-			data: "a = [\r]",
+			desc: `carriage return at the trail of basic multiline string`,
+			data: "a=\"\"\"\r",
 		},
 		{
-			desc: `array with incomplete newline after comma`,
-			data: "a = [1,\r]",
+			desc: `carriage return inside literal string`,
+			data: "A = '\r'",
 		},
 		{
-			desc: `array with incomplete newline after value`,
-			data: "a = [1\r]",
+			desc: `carriage return inside multiline literal string`,
+			data: "a='''\r'''",
 		},
 		{
-			desc: `invalid unicode in basic multiline string`,
-			data: `A = """\u123"""`,
+			desc: `carriage return at trail of multiline literal string`,
+			data: "a='''\r",
 		},
 		{
-			desc: `invalid long unicode in basic multiline string`,
-			data: `A = """\U0001D11"""`,
+			desc: `carriage return in comment`,
+			data: "# this is a test\ra=1",
 		},
 		{
-			desc: `invalid unicode in basic string`,
-			data: `A = "\u123"`,
+			desc: `backspace in comment`,
+			data: "# this is a test\ba=1",
 		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			m := map[string]interface{}{}
+			err := toml.Unmarshal([]byte(e.data), &m)
+			require.Error(t, err)
+
+			var de *toml.DecodeError
+			if !errors.As(err, &de) {
+				t.Fatalf("err should have been a *toml.DecodeError, but got %s (%T)", err, err)
+			}
+
+			if e.msg != "" {
+				t.Log("\n" + de.String())
+				require.Equal(t, "toml: "+e.msg, de.Error())
+			}
+		})
+	}
+}
+
+func TestUnmarshalTags(t *testing.T) {
+	type doc struct {
+		Dash   string `toml:"-,"`
+		Ignore string `toml:"-"`
+		A      string `toml:"hello"`
+		B      string `toml:"comma,omitempty"`
+	}
+
+	data := `
+'-' = "dash"
+Ignore = 'me'
+hello = 'content'
+comma = 'ok'
+`
+
+	d := doc{}
+	expected := doc{
+		Dash:   "dash",
+		Ignore: "",
+		A:      "content",
+		B:      "ok",
+	}
+
+	err := toml.Unmarshal([]byte(data), &d)
+	require.NoError(t, err)
+	require.Equal(t, expected, d)
+}
+
+func TestASCIIControlCharacters(t *testing.T) {
+	invalidCharacters := []byte{0x7F}
+	for c := byte(0x0); c <= 0x08; c++ {
+		invalidCharacters = append(invalidCharacters, c)
+	}
+	for c := byte(0x0B); c <= 0x0C; c++ {
+		invalidCharacters = append(invalidCharacters, c)
+	}
+	for c := byte(0x0E); c <= 0x1F; c++ {
+		invalidCharacters = append(invalidCharacters, c)
+	}
+
+	type stringType struct {
+		Delimiter string
+		CanEscape bool
+	}
+
+	stringTypes := map[string]stringType{
+		"basic":            {Delimiter: "\"", CanEscape: true},
+		"basicMultiline":   {Delimiter: "\"\"\"", CanEscape: true},
+		"literal":          {Delimiter: "'", CanEscape: false},
+		"literalMultiline": {Delimiter: "'''", CanEscape: false},
+	}
+
+	checkError := func(t *testing.T, input []byte) {
+		t.Helper()
+		m := map[string]interface{}{}
+		err := toml.Unmarshal(input, &m)
+		require.Error(t, err)
+
+		var de *toml.DecodeError
+		if !errors.As(err, &de) {
+			t.Fatalf("err should have been a *toml.DecodeError, but got %s (%T)", err, err)
+		}
+	}
+
+	for name, st := range stringTypes {
+		t.Run(name, func(t *testing.T) {
+			for _, c := range invalidCharacters {
+				name := fmt.Sprintf("%2X", c)
+				t.Run(name, func(t *testing.T) {
+					data := []byte("A = " + st.Delimiter + string(c) + st.Delimiter)
+					checkError(t, data)
+
+					if st.CanEscape {
+						t.Run("withEscapeBefore", func(t *testing.T) {
+							data := []byte("A = " + st.Delimiter + "\\t" + string(c) + st.Delimiter)
+							checkError(t, data)
+						})
+						t.Run("withEscapeAfter", func(t *testing.T) {
+							data := []byte("A = " + st.Delimiter + string(c) + "\\t" + st.Delimiter)
+							checkError(t, data)
+						})
+					}
+				})
+			}
+		})
+	}
+}
+
+//nolint:funlen
+func TestUnmarshalLocalTimeIntoTime(t *testing.T) {
+	var doc struct {
+		T time.Time
+	}
+
+	err := toml.NewDecoder(strings.NewReader("T = 15:04:05.123")).SetDefaultLocation(time.UTC).Decode(&doc)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(0, time.January, 1, 15, 4, 5, 123000000, time.UTC), doc.T)
+}
+
+func TestLocalDateTime(t *testing.T) {
+	examples := []struct {
+		desc  string
+		input string
+		prec  int
+	}{
 		{
-			desc: `invalid long unicode in basic string`,
-			data: `A = "\U0001D11"`,
+			desc:  "9 digits zero nanoseconds",
+			input: "2006-01-02T15:04:05.000000000",
+			prec:  9,
 		},
 		{
-			desc: `invalid escape char basic multiline string`,
-			data: `A = """\z"""`,
+			desc:  "9 digits",
+			input: "2006-01-02T15:04:05.123456789",
+			prec:  9,
 		},
 		{
-			desc: `invalid inf`,
-			data: `A = ick`,
+			desc:  "8 digits",
+			input: "2006-01-02T15:04:05.12345678",
+			prec:  8,
 		},
 		{
-			desc: `invalid nan`,
-			data: `A = non`,
+			desc:  "7 digits",
+			input: "2006-01-02T15:04:05.1234567",
+			prec:  7,
 		},
 		{
-			desc: `invalid character in comment in array`,
-			data: "A = [#\x00\n]",
+			desc:  "6 digits",
+			input: "2006-01-02T15:04:05.123456",
+			prec:  6,
 		},
 		{
-			desc: "invalid utf8 character in long string with no escape sequence",
-			data: "a = \"aaaa\x80aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\"",
+			desc:  "5 digits",
+			input: "2006-01-02T15:04:05.12345",
+			prec:  5,
 		},
 		{
-			desc: "invalid ascii character in long string with no escape sequence",
-			data: "a = \"aaaa\x00aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\"",
+			desc:  "4 digits",
+			input: "2006-01-02T15:04:05.1234",
+			prec:  4,
 		},
 		{
-			desc: "unfinished 2-byte utf8 character in string with no escape sequence",
-			data: "a = \"aaaa\xC2\"",
+			desc:  "3 digits",
+			input: "2006-01-02T15:04:05.123",
+			prec:  3,
 		},
 		{
-			desc: "unfinished 3-byte utf8 character in string with no escape sequence",
-			data: "a = \"aaaa\xE2\x00\x00\"",
+			desc:  "2 digits",
+			input: "2006-01-02T15:04:05.12",
+			prec:  2,
 		},
 		{
-			desc: "invalid 3rd byte of 3-byte utf8 character in string with no escape sequence",
-			data: "a = \"aaaa\xE2\x80\x00\"",
+			desc:  "1 digit",
+			input: "2006-01-02T15:04:05.1",
+			prec:  1,
 		},
 		{
-			desc: "invalid 4rd byte of 4-byte utf8 character in string with no escape sequence",
-			data: "a = \"aaaa\xF2\x81\x81\x00\"",
+			desc:  "0 digit",
+			input: "2006-01-02T15:04:05",
 		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			t.Log("input:", e.input)
+			doc := `a = ` + e.input
+			m := map[string]toml.LocalDateTime{}
+			err := toml.Unmarshal([]byte(doc), &m)
+			require.NoError(t, err)
+			actual := m["a"]
+			golang, err := time.Parse("2006-01-02T15:04:05.999999999", e.input)
+			require.NoError(t, err)
+			expected := toml.LocalDateTime{
+				toml.LocalDate{golang.Year(), int(golang.Month()), golang.Day()},
+				toml.LocalTime{golang.Hour(), golang.Minute(), golang.Second(), golang.Nanosecond(), e.prec},
+			}
+			require.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestUnmarshal_RecursiveTable(t *testing.T) {
+	type Foo struct {
+		I int
+		F *Foo
+	}
+
+	examples := []struct {
+		desc     string
+		input    string
+		expected string
+		err      bool
+	}{
 		{
-			desc: "unfinished 2-byte utf8 character in literal string",
-			data: "a = 'aaa\xC2'",
+			desc: "simplest",
+			input: `
+				I=1
+			`,
+			expected: `{"I":1,"F":null}`,
 		},
 		{
-			desc: "unfinished 3-byte utf8 character in literal string",
-			data: "a = 'aaaa\xE2\x00\x00'",
+			desc: "depth 1",
+			input: `
+				I=1
+				[F]
+				I=2
+			`,
+			expected: `{"I":1,"F":{"I":2,"F":null}}`,
 		},
 		{
-			desc: "invalid 3rd byte of 3-byte utf8 character in literal string",
-			data: "a = 'aaaa\xE2\x80\x00'",
+			desc: "depth 3",
+			input: `
+				I=1
+				[F]
+				I=2
+				[F.F]
+				I=3
+			`,
+			expected: `{"I":1,"F":{"I":2,"F":{"I":3,"F":null}}}`,
 		},
 		{
-			desc: "invalid 4rd byte of 4-byte utf8 character in literal string",
-			data: "a = 'aaaa\xF2\x81\x81\x00'",
+			desc: "depth 4",
+			input: `
+				I=1
+				[F]
+				I=2
+				[F.F]
+				I=3
+				[F.F.F]
+				I=4
+			`,
+			expected: `{"I":1,"F":{"I":2,"F":{"I":3,"F":{"I":4,"F":null}}}}`,
 		},
 		{
-			desc: "invalid start utf8 character in literal string",
-			data: "a = '\x80'",
+			desc: "skip mid step",
+			input: `
+				I=1
+				[F.F]
+				I=7
+			`,
+			expected: `{"I":1,"F":{"I":0,"F":{"I":7,"F":null}}}`,
 		},
+	}
+
+	for _, ex := range examples {
+		e := ex
+		t.Run(e.desc, func(t *testing.T) {
+			foo := Foo{}
+			err := toml.Unmarshal([]byte(e.input), &foo)
+			if e.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				j, err := json.Marshal(foo)
+				require.NoError(t, err)
+				assert.Equal(t, e.expected, string(j))
+			}
+		})
+	}
+}
+
+func TestUnmarshal_RecursiveTableArray(t *testing.T) {
+	type Foo struct {
+		I int
+		F []*Foo
+	}
+
+	examples := []struct {
+		desc     string
+		input    string
+		expected string
+		err      bool
+	}{
 		{
-			desc: "utf8 character with not enough bytes before end in literal string",
-			data: "a = '\xEF'",
+			desc: "simplest",
+			input: `
+				I=1
+				F=[]
+			`,
+			expected: `{"I":1,"F":[]}`,
 		},
 		{
-			desc: "basic string with newline after the first escape code",
-			data: "a = \"\\t\n\"",
+			desc: "depth 1",
+			input: `
+				I=1
+				[[F]]
+				I=2
+				F=[]
+			`,
+			expected: `{"I":1,"F":[{"I":2,"F":[]}]}`,
 		},
 		{
-			desc: "basic string with unfinished escape sequence after the first escape code",
-			data: "a = \"\\t\\",
+			desc: "depth 2",
+			input: `
+				I=1
+				[[F]]
+				I=2
+				[[F.F]]
+				I=3
+				F=[]
+			`,
+			expected: `{"I":1,"F":[{"I":2,"F":[{"I":3,"F":[]}]}]}`,
 		},
 		{
-			desc: "basic string with unfinished after the first escape code",
-			data: "a = \"\\t",
+			desc: "depth 3",
+			input: `
+				I=1
+				[[F]]
+				I=2
+				[[F.F]]
+				I=3
+				[[F.F.F]]
+				I=4
+				F=[]
+			`,
+			expected: `{"I":1,"F":[{"I":2,"F":[{"I":3,"F":[{"I":4,"F":[]}]}]}]}`,
 		},
 		{
-			desc: "multiline basic string with unfinished escape sequence after the first escape code",
-			data: "a = \"\"\"\\t\\",
+			desc: "depth 4",
+			input: `
+				I=1
+				[[F]]
+				I=2
+				[[F.F]]
+				I=3
+				[[F.F.F]]
+				I=4
+				[[F.F.F.F]]
+				I=5
+				F=[]
+			`,
+			expected: `{"I":1,"F":[{"I":2,"F":[{"I":3,"F":[{"I":4,"F":[{"I":5,"F":[]}]}]}]}]}`,
 		},
+	}
+
+	for _, ex := range examples {
+		e := ex
+		t.Run(e.desc, func(t *testing.T) {
+			foo := Foo{}
+			err := toml.Unmarshal([]byte(e.input), &foo)
+			if e.err {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				j, err := json.Marshal(foo)
+				require.NoError(t, err)
+				assert.Equal(t, e.expected, string(j))
+			}
+		})
+	}
+}
+
+// duration is decoded from a number of seconds, or from an inline table with a
+// value and a unit.
+type duration struct {
+	raw     string
+	seconds int64
+}
+
+func (d *duration) UnmarshalTOML(data []byte) error {
+	d.raw = string(data)
+	doc := append([]byte("v = "), data...)
+
+	if data[0] != '{' {
+		var x struct{ V int64 }
+		err := toml.Unmarshal(doc, &x)
+		d.seconds = x.V
+		return err
+	}
+
+	var x struct {
+		V struct {
+			Value int64
+			Unit  string
+		}
+	}
+	err := toml.Unmarshal(doc, &x)
+	if err != nil {
+		return err
+	}
+
+	switch x.V.Unit {
+	case "s":
+		d.seconds = x.V.Value
+	case "m":
+		d.seconds = x.V.Value * 60
+	default:
+		return fmt.Errorf("unknown unit %q", x.V.Unit)
+	}
+
+	return nil
+}
+
+func TestUnmarshalUnmarshaler(t *testing.T) {
+	type config struct {
+		Timeout  duration
+		Retry    *duration
+		Backoffs []duration
+	}
+
+	doc := `
+timeout = 30
+retry = { value = 2, unit = "m" }
+backoffs = [1, { value = 1, unit = "m" }]
+`
+
+	var c config
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+	require.Equal(t, duration{raw: "30", seconds: 30}, c.Timeout)
+	require.Equal(t, &duration{raw: `{ value = 2, unit = "m" }`, seconds: 120}, c.Retry)
+	require.Equal(t, []duration{
+		{raw: "1", seconds: 1},
+		{raw: `{ value = 1, unit = "m" }`, seconds: 60},
+	}, c.Backoffs)
+
+	errors := []struct {
+		doc string
+		err string
+	}{
 		{
-			desc: `impossible date-day`,
-			data: `A = 2021-03-40T23:59:00`,
-			msg:  `impossible date`,
+			doc: `timeout = { value = 1, unit = "h" }`,
+			err: `toml: unknown unit "h"`,
 		},
 		{
-			desc: `leap day in non-leap year`,
-			data: `A = 2021-02-29T23:59:00`,
-			msg:  `impossible date`,
+			doc: "[timeout]\nvalue = 1",
+			err: "toml: cannot decode a table into toml_test.duration, which implements toml.Unmarshaler: use an inline table (in [timeout])",
 		},
 		{
-			desc: `missing minute digit`,
-			data: `a=17:4::01`,
+			doc: "timeout.value = 1",
+			err: "toml: cannot decode a table into toml_test.duration, which implements toml.Unmarshaler: use an inline table",
 		},
 		{
-			desc: `invalid space in year`,
-			data: `i=19 7-12-21T10:32:00`,
+			doc: "[[backoffs]]\nvalue = 1",
+			err: "toml: cannot decode a table into toml_test.duration, which implements toml.Unmarshaler: use an inline table (in [[backoffs]] element 1)",
 		},
+	}
+
+	for _, e := range errors {
+		t.Run(e.doc, func(t *testing.T) {
+			err := toml.Unmarshal([]byte(e.doc), &config{})
+			require.EqualError(t, err, e.err)
+		})
+	}
+}
+
+// listenAddr is decoded from an inline table with a host and a port, and
+// reports invalid ports at their position.
+type listenAddr struct {
+	host string
+	port int
+}
+
+func (a *listenAddr) UnmarshalTOML(data []byte) error {
+	return fmt.Errorf("UnmarshalTOML should not be called")
+}
+
+func (a *listenAddr) UnmarshalTOMLContext(node *unstable.Node) error {
+	if node.Kind != unstable.InlineTableOpen {
+		return node.Errorf("expected an inline table")
+	}
+
+	for _, c := range node.Children {
+		switch strings.Join(c.Key, ".") {
+		case "host":
+			a.host = strings.Trim(string(c.Raw), `"`)
+		case "port":
+			p, err := strconv.Atoi(string(c.Raw))
+			if err != nil || p < 1 || p > 65535 {
+				return c.Errorf("invalid port %s", c.Raw)
+			}
+			a.port = p
+		default:
+			return fmt.Errorf("unknown key %s", strings.Join(c.Key, "."))
+		}
+	}
+
+	return nil
+}
+
+func TestUnmarshalPointerToMapTable(t *testing.T) {
+	var x struct {
+		M *map[string]int
+	}
+	err := toml.Unmarshal([]byte("[m]\na = 1"), &x)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 1}, *x.M)
+}
+
+func TestUnmarshalContextUnmarshaler(t *testing.T) {
+	type config struct {
+		Listen  listenAddr
+		Targets []listenAddr
+	}
+
+	doc := `
+listen = { host = "localhost", port = 80 }
+targets = [{ port = 81 }]
+`
+
+	var c config
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+	require.Equal(t, listenAddr{host: "localhost", port: 80}, c.Listen)
+	require.Equal(t, []listenAddr{{port: 81}}, c.Targets)
+
+	examples := []struct {
+		desc   string
+		doc    string
+		err    string
+		line   int
+		column int
+	}{
 		{
-			desc: `missing nanoseconds digits`,
-			data: `a=17:45:56.`,
+			desc:   "positioned at the child",
+			doc:    "\nlisten = { host = \"h\", port = 0 }",
+			err:    "toml: invalid port 0",
+			line:   2,
+			column: 31,
 		},
 		{
-			desc: `minutes over 60`,
-			data: `a=17:99:00`,
+			desc:   "positioned at the node",
+			doc:    "targets = [\n  80,\n]",
+			err:    "toml: array element 0: expected an inline table",
+			line:   2,
+			column: 3,
 		},
 		{
-			desc: `invalid second`,
-			data: `a=17:00::0`,
+			desc:   "other errors",
+			doc:    "listen = { a = 1 }",
+			err:    "toml: unknown key a",
+			line:   1,
+			column: 10,
 		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			err := toml.Unmarshal([]byte(e.doc), &config{})
+			require.EqualError(t, err, e.err)
+
+			var derr *toml.DecodeError
+			require.True(t, errors.As(err, &derr))
+			line, column := derr.Position()
+			require.Equal(t, e.line, line)
+			require.Equal(t, e.column, column)
+		})
+	}
+}
+
+type namedServer struct {
+	Name string
+	Port int
+	keys int
+}
+
+func (s *namedServer) SetTOMLKey(key string) {
+	if s.Name == "" {
+		s.Name = key
+	}
+	s.keys++
+}
+
+func TestUnmarshalKeySetter(t *testing.T) {
+	type config struct {
+		Servers  map[string]namedServer
+		Pointers map[string]*namedServer
+	}
+
+	doc := `
+servers.c.port = 3
+pointers = { d = { port = 4 } }
+
+[servers.a]
+port = 1
+
+[servers.b]
+name = "custom"
+port = 2
+`
+
+	var c config
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]namedServer{
+		"a": {Name: "a", Port: 1, keys: 1},
+		"b": {Name: "custom", Port: 2, keys: 1},
+		"c": {Name: "c", Port: 3, keys: 1},
+	}, c.Servers)
+	require.Equal(t, map[string]*namedServer{
+		"d": {Name: "d", Port: 4, keys: 1},
+	}, c.Pointers)
+}
+
+func TestUnmarshalURLAndIPNet(t *testing.T) {
+	type config struct {
+		Endpoint  *url.URL
+		Mirrors   []url.URL
+		IP        net.IP
+		Network   net.IPNet
+		Allowed   map[string]*net.IPNet
+		Fallbacks []*url.URL
+	}
+
+	doc := `Endpoint = 'https://example.com/api?v=2'
+Mirrors = ['https://a.example.com', '/relative']
+IP = '10.0.0.1'
+Network = '10.0.0.1/8'
+Fallbacks = []
+[Allowed]
+local = '::1/128'
+
+`
+
+	var c config
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+	require.Equal(t, "example.com", c.Endpoint.Host)
+	require.Equal(t, "/relative", c.Mirrors[1].Path)
+	require.Equal(t, "10.0.0.0/8", c.Network.String())
+	require.Equal(t, "::1/128", c.Allowed["local"].String())
+
+	b, err := toml.Marshal(c)
+	require.NoError(t, err)
+	require.Equal(t, strings.Replace(doc, "10.0.0.1/8", "10.0.0.0/8", 1), string(b))
+
+	var derr *toml.DecodeError
+
+	err = toml.Unmarshal([]byte("Endpoint = ':invalid'"), &c)
+	require.True(t, errors.As(err, &derr))
+	require.Equal(t, `toml: invalid URL: parse ":invalid": missing protocol scheme`, derr.Error())
+	row, col := derr.Position()
+	require.Equal(t, 1, row)
+	require.Equal(t, 12, col)
+
+	err = toml.Unmarshal([]byte("Network = '10.0.0.1'"), &c)
+	require.True(t, errors.As(err, &derr))
+	require.Equal(t, "toml: invalid CIDR address: 10.0.0.1", derr.Error())
+}
+
+func TestUnmarshalKeyOption(t *testing.T) {
+	type plugin struct {
+		Name    string `toml:",key"`
+		Enabled bool
+	}
+	type config struct {
+		Plugins  map[string]plugin
+		Pointers map[string]*plugin
+	}
+
+	doc := `
+pointers = { c = { enabled = true } }
+plugins.b.enabled = true
+
+[plugins.a]
+enabled = true
+name = "ignored"
+
+[plugins.a.extra]
+`
+
+	var c config
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+	require.Equal(t, map[string]plugin{
+		"a": {Name: "a", Enabled: true},
+		"b": {Name: "b", Enabled: true},
+	}, c.Plugins)
+	require.Equal(t, map[string]*plugin{
+		"c": {Name: "c", Enabled: true},
+	}, c.Pointers)
+
+	b, err := toml.Marshal(plugin{Name: "a", Enabled: true})
+	require.NoError(t, err)
+	require.Equal(t, "Enabled = true\n", string(b))
+}
+
+func TestDecoderWarningsDeprecated(t *testing.T) {
+	type server struct {
+		Host    string `toml:"host,deprecated"`
+		Address string
+	}
+	type config struct {
+		Name    string `toml:"name,deprecated"`
+		Old     server `toml:"old,deprecated"`
+		Servers []server
+	}
+
+	doc := `name = "a"
+
+[old]
+address = "x"
+
+[old.extra]
+
+[[servers]]
+host = "h"
+
+[[servers]]
+address = "b"
+host = "i"
+`
+
+	var c config
+	d := toml.NewDecoder(strings.NewReader(doc))
+	err := d.Decode(&c)
+	require.NoError(t, err)
+	require.Equal(t, "a", c.Name)
+	require.Equal(t, "i", c.Servers[1].Host)
+
+	type warning struct {
+		msg       string
+		row, col  int
+		tableName string
+	}
+	var warnings []warning
+	for _, w := range d.Warnings() {
+		row, col := w.Position()
+		warnings = append(warnings, warning{w.Error(), row, col, w.Table()})
+	}
+	require.Equal(t, []warning{
+		{"toml: key name is deprecated", 1, 1, ""},
+		{"toml: key old is deprecated (in [old])", 3, 2, "[old]"},
+		{"toml: key host is deprecated (in [[servers]] element 1)", 9, 1, "[[servers]] element 1"},
+		{"toml: key host is deprecated (in [[servers]] element 2)", 13, 1, "[[servers]] element 2"},
+	}, warnings)
+
+	err = d.Decode(&c)
+	require.NoError(t, err)
+	require.Empty(t, d.Warnings())
+}
+
+func TestUnmarshalIntegerMapKeys(t *testing.T) {
+	var m map[int]string
+	err := toml.Unmarshal([]byte("1 = 'a'\n\"-2\" = 'b'\n0x10 = 'c'\n0o10 = 'd'\n0b11 = 'e'\n1_000 = 'f'"), &m)
+	require.NoError(t, err)
+	require.Equal(t, map[int]string{1: "a", -2: "b", 16: "c", 8: "d", 3: "e", 1000: "f"}, m)
+
+	var tables map[uint8]map[string]interface{}
+	err = toml.Unmarshal([]byte("[1]\na = 1\n[1.b]\n[2]\na = 2"), &tables)
+	require.NoError(t, err)
+	require.Equal(t, map[uint8]map[string]interface{}{
+		1: {"a": int64(1), "b": map[string]interface{}{}},
+		2: {"a": int64(2)},
+	}, tables)
+
+	examples := []struct {
+		desc string
+		doc  string
+		err  string
+		row  int
+	}{
 		{
-			desc: `invalid hour`,
-			data: `a=1::00:00`,
+			desc: "sign",
+			doc:  "1 = 'a'\n\"+1\" = 'b'",
+			err:  "toml: key +1 is already defined as 1: both are the integer 1",
+			row:  2,
 		},
 		{
-			desc: `invalid month`,
-			data: `a=2021-0--29`,
+			desc: "hexadecimal",
+			doc:  "16 = 'a'\n0x10 = 'b'",
+			err:  "toml: key 0x10 is already defined as 16: both are the integer 16",
+			row:  2,
 		},
 		{
-			desc: `zero is an invalid day`,
-			data: `a=2021-11-00`,
+			desc: "underscores",
+			doc:  "1000 = 'a'\n\n1_000 = 'b'",
+			err:  "toml: key 1_000 is already defined as 1000: both are the integer 1000",
+			row:  3,
 		},
 		{
-			desc: `zero is an invalid month`,
-			data: `a=2021-00-11`,
+			desc: "tables",
+			doc:  "[m.1]\na = 1\n[m.0b1]\na = 2",
+			err:  "toml: key 0b1 is already defined as 1: both are the integer 1 (in [m.0b1])",
+			row:  3,
 		},
 		{
-			desc: `invalid number of seconds digits with trailing digit`,
-			data: `a=0000-01-01 00:00:000000Z3`,
+			desc: "leading zero",
+			doc:  "01 = 'a'",
+			err:  "toml: cannot use key 01 as a map key of type int: leading zero not allowed on decimal number",
+			row:  1,
 		},
 		{
-			desc: `invalid zone offset hours`,
-			data: `a=0000-01-01 00:00:00+24:00`,
+			desc: "not an integer",
+			doc:  "a = 'a'",
+			err:  "toml: cannot use key a as a map key of type int: couldn't parse decimal number: strconv.ParseInt: parsing \"a\": invalid syntax",
+			row:  1,
 		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			var m map[string]map[int]interface{}
+			doc := e.doc
+			if !strings.HasPrefix(doc, "[") {
+				doc = "[m]\n" + doc
+				e.row++
+				e.err += " (in [m])"
+			}
+
+			err := toml.Unmarshal([]byte(doc), &m)
+			var de *toml.DecodeError
+			require.True(t, errors.As(err, &de), "%v", err)
+			require.Equal(t, e.err, de.Error())
+			row, _ := de.Position()
+			require.Equal(t, e.row, row)
+		})
+	}
+}
+
+func TestDecoderDisallowMixedTables(t *testing.T) {
+	examples := []struct {
+		desc   string
+		doc    string
+		err    string
+		strict string
+		row    int
+	}{
 		{
-			desc: `invalid zone offset minutes`,
-			data: `a=0000-01-01 00:00:00+00:60`,
+			desc: "header redefines dotted table",
+			doc:  "[fruit]\napple.color = 'red'\napple.taste.sweet = true\n[fruit.apple]",
+			err:  "toml: table apple already exists (in [fruit.apple])",
+			row:  4,
 		},
 		{
-			desc: `invalid character in zone offset hours`,
-			data: `a=0000-01-01 00:00:00+0Z:00`,
+			desc:   "header redefines nested dotted table",
+			doc:    "[fruit]\napple.color = 'red'\napple.taste.sweet = true\n[fruit.apple.taste]",
+			err:    "toml: table taste already exists (in [fruit.apple.taste])",
+			strict: "toml: table apple is defined by dotted keys and cannot be extended by a table header (in [fruit.apple.taste])",
+			row:    4,
 		},
 		{
-			desc: `invalid character in zone offset minutes`,
-			data: `a=0000-01-01 00:00:00+00:0Z`,
+			desc:   "header extends dotted table",
+			doc:    "[fruit]\napple.color = 'red'\napple.taste.sweet = true\n[fruit.apple.texture]\nsmooth = true",
+			strict: "toml: table apple is defined by dotted keys and cannot be extended by a table header (in [fruit.apple.texture])",
+			row:    4,
 		},
 		{
-			desc: `invalid number of seconds`,
-			data: `a=0000-01-01 00:00:00+27000`,
+			desc:   "header redefines top-level dotted table",
+			doc:    "a.b.c = 1\n[a.b]",
+			err:    "toml: table b already exists (in [a.b])",
+			strict: "toml: table a is defined by dotted keys and cannot be extended by a table header (in [a.b])",
+			row:    2,
 		},
 		{
-			desc: `carriage return inside basic key`,
-			data: "\"\r\"=42",
+			desc:   "header extends top-level dotted table",
+			doc:    "a.b.c = 1\n[a.b.d]",
+			strict: "toml: table a is defined by dotted keys and cannot be extended by a table header (in [a.b.d])",
+			row:    2,
 		},
 		{
-			desc: `carriage return inside literal key`,
-			data: "'\r'=42",
+			desc:   "dotted keys redefine header table",
+			doc:    "[a.b.c]\nz = 9\n[a]\nb.c.t = 'x'",
+			err:    "toml: cannot redefine table c that has already been explicitly defined (in [a])",
+			strict: "toml: table b is defined by a table header and cannot be extended by dotted keys (in [a])",
+			row:    4,
 		},
 		{
-			desc: `carriage return inside basic string`,
-			data: "A = \"\r\"",
+			desc:   "dotted keys extend implicit header table",
+			doc:    "[a.b.c]\nz = 9\n[a]\nb.x = 1",
+			strict: "toml: table b is defined by a table header and cannot be extended by dotted keys (in [a])",
+			row:    4,
 		},
 		{
-			desc: `carriage return inside basic multiline string`,
-			data: "a=\"\"\"\r\"\"\"",
+			desc: "dotted keys extend array table",
+			doc:  "[[a]]\n[a.b]\n[a]\nb.x = 1",
+			err:  "toml: key a should be a table, not a array table (in [a])",
+			row:  3,
 		},
 		{
-			desc: `carriage return at the trail of basic multiline string`,
-			data: "a=\"\"\"\r",
+			desc: "dotted keys in the current table",
+			doc:  "[a]\nb.c = 1\nb.d = 2\n[a.e]\nf.g = 3",
 		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			for _, strict := range []bool{false, true} {
+				expected := e.err
+				if strict && e.strict != "" {
+					expected = e.strict
+				}
+
+				var v map[string]interface{}
+				d := toml.NewDecoder(strings.NewReader(e.doc))
+				if strict {
+					d.DisallowMixedTables()
+				}
+				err := d.Decode(&v)
+
+				if expected == "" {
+					require.NoError(t, err, "strict=%v", strict)
+					continue
+				}
+
+				var de *toml.DecodeError
+				require.True(t, errors.As(err, &de), "strict=%v: %v", strict, err)
+				require.Equal(t, expected, de.Error(), "strict=%v", strict)
+				row, _ := de.Position()
+				require.Equal(t, e.row, row, "strict=%v", strict)
+			}
+		})
+	}
+}
+
+func TestDecoderDisallowDuplicateKeys(t *testing.T) {
+	examples := []struct {
+		desc string
+		doc  string
+		err  string
+		row  int
+		col  int
+	}{
 		{
-			desc: `carriage return inside literal string`,
-			data: "A = '\r'",
+			desc: "key-value",
+			doc:  "a = 1\nb = 2\na = 3",
+			err:  "toml: key a is already defined at line 3, column 1, first defined at line 1, column 1",
+			row:  3,
+			col:  1,
 		},
 		{
-			desc: `carriage return inside multiline literal string`,
-			data: "a='''\r'''",
+			desc: "dotted keys",
+			doc:  "[t]\nx.y = 1\n  x.y = 2",
+			err:  "toml: key y is already defined at line 3, column 5, first defined at line 2, column 3 (in [t])",
+			row:  3,
+			col:  5,
 		},
 		{
-			desc: `carriage return at trail of multiline literal string`,
-			data: "a='''\r",
+			desc: "inline table",
+			doc:  "a = { b = 1 }\na.b = 2",
+			err:  "toml: expected a to be a table, not a value at line 2, column 1, first defined at line 1, column 1",
+			row:  2,
+			col:  1,
 		},
 		{
-			desc: `carriage return in comment`,
-			data: "# this is a test\ra=1",
+			desc: "inside inline table",
+			doc:  "a = { b = 1, b = 2 }",
+			err:  "toml: key b is already defined at line 1, column 14, first defined at line 1, column 7",
+			row:  1,
+			col:  14,
 		},
 		{
-			desc: `backspace in comment`,
-			data: "# this is a test\ba=1",
+			desc: "table header",
+			doc:  "[a]\nb = 1\n[a]",
+			err:  "toml: table a already exists at line 3, column 2, first defined at line 1, column 2 (in [a])",
+			row:  3,
+			col:  2,
 		},
 	}
 
 	for _, e := range examples {
 		e := e
 		t.Run(e.desc, func(t *testing.T) {
-			m := map[string]interface{}{}
-			err := toml.Unmarshal([]byte(e.data), &m)
-			require.Error(t, err)
+			var v map[string]interface{}
+			d := toml.NewDecoder(strings.NewReader(e.doc))
+			d.DisallowDuplicateKeys()
+			err := d.Decode(&v)
+			require.EqualError(t, err, e.err)
 
 			var de *toml.DecodeError
-			if !errors.As(err, &de) {
-				t.Fatalf("err should have been a *toml.DecodeError, but got %s (%T)", err, err)
-			}
-
-			if e.msg != "" {
-				t.Log("\n" + de.String())
-				require.Equal(t, "toml: "+e.msg, de.Error())
-			}
+			require.True(t, errors.As(err, &de))
+			row, col := de.Position()
+			require.Equal(t, e.row, row)
+			require.Equal(t, e.col, col)
 		})
 	}
+
+	t.Run("repeated key as array", func(t *testing.T) {
+		var v map[string]interface{}
+		d := toml.NewDecoder(strings.NewReader("a = 1\na = 2"))
+		d.SetRepeatedKeyAsArray(true).DisallowDuplicateKeys()
+		err := d.Decode(&v)
+		require.EqualError(t, err, "toml: key a is already defined at line 2, column 1, first defined at line 1, column 1")
+	})
 }
 
-func TestUnmarshalTags(t *testing.T) {
-	type doc struct {
-		Dash   string `toml:"-,"`
-		Ignore string `toml:"-"`
-		A      string `toml:"hello"`
-		B      string `toml:"comma,omitempty"`
+func TestUnmarshalSquash(t *testing.T) {
+	type netConfig struct {
+		Host string
+		Port int
 	}
-
-	data := `
-'-' = "dash"
-Ignore = 'me'
-hello = 'content'
-comma = 'ok'
-`
-
-	d := doc{}
-	expected := doc{
-		Dash:   "dash",
-		Ignore: "",
-		A:      "content",
-		B:      "ok",
+	type config struct {
+		Name string
+		Net  netConfig `toml:",squash"`
 	}
 
-	err := toml.Unmarshal([]byte(data), &d)
+	var c config
+	err := toml.Unmarshal([]byte("name = 'a'\nhost = 'localhost'\nport = 80"), &c)
 	require.NoError(t, err)
-	require.Equal(t, expected, d)
-}
+	require.Equal(t, config{Name: "a", Net: netConfig{Host: "localhost", Port: 80}}, c)
 
-func TestASCIIControlCharacters(t *testing.T) {
-	invalidCharacters := []byte{0x7F}
-	for c := byte(0x0); c <= 0x08; c++ {
-		invalidCharacters = append(invalidCharacters, c)
-	}
-	for c := byte(0x0B); c <= 0x0C; c++ {
-		invalidCharacters = append(invalidCharacters, c)
-	}
-	for c := byte(0x0E); c <= 0x1F; c++ {
-		invalidCharacters = append(invalidCharacters, c)
+	var nested struct {
+		Config config `toml:"config"`
 	}
+	err = toml.Unmarshal([]byte("[config]\nhost = 'localhost'"), &nested)
+	require.NoError(t, err)
+	require.Equal(t, "localhost", nested.Config.Net.Host)
 
-	type stringType struct {
-		Delimiter string
-		CanEscape bool
+	type conflict struct {
+		Host string
+		Net  netConfig `toml:",squash"`
 	}
+	err = toml.Unmarshal([]byte("\nhost = 'localhost'"), &conflict{})
+	var de *toml.DecodeError
+	require.True(t, errors.As(err, &de), "%v", err)
+	require.Equal(t, "toml: fields Host and Net.Host of toml_test.conflict both use the key Host", de.Error())
+	row, _ := de.Position()
+	require.Equal(t, 2, row)
+}
 
-	stringTypes := map[string]stringType{
-		"basic":            {Delimiter: "\"", CanEscape: true},
-		"basicMultiline":   {Delimiter: "\"\"\"", CanEscape: true},
-		"literal":          {Delimiter: "'", CanEscape: false},
-		"literalMultiline": {Delimiter: "'''", CanEscape: false},
+func TestUnmarshalHeaderComment(t *testing.T) {
+	type section struct {
+		Doc  string `toml:",headercomment"`
+		Name string
 	}
-
-	checkError := func(t *testing.T, input []byte) {
-		t.Helper()
-		m := map[string]interface{}{}
-		err := toml.Unmarshal(input, &m)
-		require.Error(t, err)
-
-		var de *toml.DecodeError
-		if !errors.As(err, &de) {
-			t.Fatalf("err should have been a *toml.DecodeError, but got %s (%T)", err, err)
-		}
+	type lines struct {
+		Doc []string `toml:",headercomment"`
 	}
-
-	for name, st := range stringTypes {
-		t.Run(name, func(t *testing.T) {
-			for _, c := range invalidCharacters {
-				name := fmt.Sprintf("%2X", c)
-				t.Run(name, func(t *testing.T) {
-					data := []byte("A = " + st.Delimiter + string(c) + st.Delimiter)
-					checkError(t, data)
-
-					if st.CanEscape {
-						t.Run("withEscapeBefore", func(t *testing.T) {
-							data := []byte("A = " + st.Delimiter + "\\t" + string(c) + st.Delimiter)
-							checkError(t, data)
-						})
-						t.Run("withEscapeAfter", func(t *testing.T) {
-							data := []byte("A = " + st.Delimiter + string(c) + "\\t" + st.Delimiter)
-							checkError(t, data)
-						})
-					}
-				})
-			}
-		})
+	type config struct {
+		Database section
+		Cache    *section
+		Servers  []section
+		Named    map[string]section
+		Lines    lines
+		Inline   section
 	}
-}
 
-//nolint:funlen
-func TestLocalDateTime(t *testing.T) {
-	examples := []struct {
-		desc  string
-		input string
-		prec  int
-	}{
-		{
-			desc:  "9 digits zero nanoseconds",
-			input: "2006-01-02T15:04:05.000000000",
-			prec:  9,
-		},
-		{
-			desc:  "9 digits",
-			input: "2006-01-02T15:04:05.123456789",
-			prec:  9,
-		},
-		{
-			desc:  "8 digits",
-			input: "2006-01-02T15:04:05.12345678",
-			prec:  8,
-		},
-		{
-			desc:  "7 digits",
-			input: "2006-01-02T15:04:05.1234567",
-			prec:  7,
-		},
-		{
-			desc:  "6 digits",
-			input: "2006-01-02T15:04:05.123456",
-			prec:  6,
-		},
-		{
-			desc:  "5 digits",
-			input: "2006-01-02T15:04:05.12345",
-			prec:  5,
-		},
-		{
-			desc:  "4 digits",
-			input: "2006-01-02T15:04:05.1234",
-			prec:  4,
-		},
+	doc := "# Not a header.\n" +
+		"inline = { name = 'i' }\n" +
+		"# Not attached: separated by a blank line.\n" +
+		"\n" +
+		"# Database settings.\n" +
+		"#   Indented.\n" +
+		"#\n" +
+		"# Used by all the workers.\n" +
+		"[database] # trailing comments are ignored\n" +
+		"name = 'db'\n" +
+		"\n" +
+		"# The cache.\r\n" +
+		"[cache]\n" +
+		"\n" +
+		"#First server.\n" +
+		"[[servers]]\n" +
+		"name = 'a'\n" +
+		"[[servers]]\n" +
+		"name = 'b'\n" +
+		"\n" +
+		"# Named x.\n" +
+		"[named.x]\n" +
+		"\n" +
+		"# Line 1.\n" +
+		"# Line 2.\n" +
+		"[lines]\n"
+
+	var c config
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+
+	require.Equal(t, section{Doc: "Database settings.\n  Indented.\n\nUsed by all the workers.", Name: "db"}, c.Database)
+	require.Equal(t, &section{Doc: "The cache."}, c.Cache)
+	require.Equal(t, []section{{Doc: "First server.", Name: "a"}, {Name: "b"}}, c.Servers)
+	require.Equal(t, map[string]section{"x": {Doc: "Named x."}}, c.Named)
+	require.Equal(t, lines{Doc: []string{"Line 1.", "Line 2."}}, c.Lines)
+	require.Equal(t, section{Name: "i"}, c.Inline)
+
+	b, err := toml.Marshal(section{Doc: "x", Name: "y"})
+	require.NoError(t, err)
+	require.Equal(t, "Name = 'y'\n", string(b))
+
+	var invalid struct {
+		A struct {
+			Doc int `toml:",headercomment"`
+		}
+	}
+	err = toml.Unmarshal([]byte("# a\n[a]"), &invalid)
+	require.EqualError(t, err, "toml: field tagged with the headercomment option must be a string or a []string, not int (in [a])")
+}
+
+func TestUnmarshalDottedKeysAndTables(t *testing.T) {
+	type leaf struct {
+		F int
+	}
+	type node struct {
+		C int
+		D int
+		E leaf
+		G *leaf
+	}
+	type doc struct {
+		A map[string]node
+	}
+
+	examples := []struct {
+		desc     string
+		input    string
+		expected doc
+	}{
 		{
-			desc:  "3 digits",
-			input: "2006-01-02T15:04:05.123",
-			prec:  3,
+			desc:     "dotted keys then sub-table",
+			input:    "[a]\nb.c = 1\n[a.b.e]\nf = 2",
+			expected: doc{A: map[string]node{"b": {C: 1, E: leaf{F: 2}}}},
 		},
 		{
-			desc:  "2 digits",
-			input: "2006-01-02T15:04:05.12",
-			prec:  2,
+			desc:     "sub-table then dotted keys",
+			input:    "[a.b.e]\nf = 2\n[a]\nb.c = 1",
+			expected: doc{A: map[string]node{"b": {C: 1, E: leaf{F: 2}}}},
 		},
 		{
-			desc:  "1 digit",
-			input: "2006-01-02T15:04:05.1",
-			prec:  1,
+			desc:     "sub-table then dotted keys with pointer",
+			input:    "[a.b.g]\nf = 2\n[a]\nb.c = 1",
+			expected: doc{A: map[string]node{"b": {C: 1, G: &leaf{F: 2}}}},
 		},
 		{
-			desc:  "0 digit",
-			input: "2006-01-02T15:04:05",
+			desc:     "several sub-tables then dotted keys",
+			input:    "[a.b.e]\nf = 2\n[a.x]\nc = 1\n[a]\nb.d = 3",
+			expected: doc{A: map[string]node{"b": {D: 3, E: leaf{F: 2}}, "x": {C: 1}}},
 		},
 	}
 
 	for _, e := range examples {
 		e := e
 		t.Run(e.desc, func(t *testing.T) {
-			t.Log("input:", e.input)
-			doc := `a = ` + e.input
-			m := map[string]toml.LocalDateTime{}
-			err := toml.Unmarshal([]byte(doc), &m)
+			var d doc
+			err := toml.Unmarshal([]byte(e.input), &d)
 			require.NoError(t, err)
-			actual := m["a"]
-			golang, err := time.Parse("2006-01-02T15:04:05.999999999", e.input)
+			require.Equal(t, e.expected, d)
+
+			var m map[string]interface{}
+			err = toml.Unmarshal([]byte(e.input), &m)
 			require.NoError(t, err)
-			expected := toml.LocalDateTime{
-				toml.LocalDate{golang.Year(), int(golang.Month()), golang.Day()},
-				toml.LocalTime{golang.Hour(), golang.Minute(), golang.Second(), golang.Nanosecond(), e.prec},
-			}
-			require.Equal(t, expected, actual)
 		})
 	}
-}
 
-func TestUnmarshal_RecursiveTable(t *testing.T) {
-	type Foo struct {
-		I int
-		F *Foo
+	invalid := []string{
+		"a.b.c = 1\n[a.b]\nd = 2",
+		"[a.b]\nd = 2\n[a]\nb.c = 1",
+		"a.b.c = 1\na.b = 2",
 	}
+	for _, input := range invalid {
+		var d doc
+		err := toml.Unmarshal([]byte(input), &d)
+		require.Error(t, err, input)
+	}
+}
 
+var errSentinel = errors.New("sentinel")
+
+// sentinelText fails to decode with errSentinel, wrapped.
+type sentinelText struct{}
+
+func (s *sentinelText) UnmarshalText(data []byte) error {
+	return fmt.Errorf("decoding %q: %w", data, errSentinel)
+}
+
+// sentinelTOML fails to decode with errSentinel.
+type sentinelTOML struct{}
+
+func (s *sentinelTOML) UnmarshalTOML(data []byte) error {
+	return errSentinel
+}
+
+func TestUnmarshalErrorsUnwrap(t *testing.T) {
 	examples := []struct {
-		desc     string
-		input    string
-		expected string
-		err      bool
+		desc string
+		doc  string
+		v    interface{}
 	}{
 		{
-			desc: "simplest",
-			input: `
-				I=1
-			`,
-			expected: `{"I":1,"F":null}`,
-		},
-		{
-			desc: "depth 1",
-			input: `
-				I=1
-				[F]
-				I=2
-			`,
-			expected: `{"I":1,"F":{"I":2,"F":null}}`,
+			desc: "TextUnmarshaler",
+			doc:  `a = "x"`,
+			v:    &struct{ A sentinelText }{},
 		},
 		{
-			desc: "depth 3",
-			input: `
-				I=1
-				[F]
-				I=2
-				[F.F]
-				I=3
-			`,
-			expected: `{"I":1,"F":{"I":2,"F":{"I":3,"F":null}}}`,
+			desc: "Unmarshaler",
+			doc:  `a = 1`,
+			v:    &struct{ A sentinelTOML }{},
 		},
 		{
-			desc: "depth 4",
-			input: `
-				I=1
-				[F]
-				I=2
-				[F.F]
-				I=3
-				[F.F.F]
-				I=4
-			`,
-			expected: `{"I":1,"F":{"I":2,"F":{"I":3,"F":{"I":4,"F":null}}}}`,
+			desc: "array element",
+			doc:  `a = ["x"]`,
+			v:    &struct{ A []sentinelText }{},
 		},
 		{
-			desc: "skip mid step",
-			input: `
-				I=1
-				[F.F]
-				I=7
-			`,
-			expected: `{"I":1,"F":{"I":0,"F":{"I":7,"F":null}}}`,
+			desc: "AfterDecoder",
+			doc:  "[a]\na = 1",
+			v:    &struct{ A *sentinelAfter }{},
 		},
 	}
 
-	for _, ex := range examples {
-		e := ex
+	for _, e := range examples {
+		e := e
 		t.Run(e.desc, func(t *testing.T) {
-			foo := Foo{}
-			err := toml.Unmarshal([]byte(e.input), &foo)
-			if e.err {
-				require.Error(t, err)
-			} else {
-				require.NoError(t, err)
-				j, err := json.Marshal(foo)
-				require.NoError(t, err)
-				assert.Equal(t, e.expected, string(j))
-			}
+			err := toml.Unmarshal([]byte(e.doc), e.v)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, errSentinel))
 		})
 	}
+
+	var de *toml.DecodeError
+	err := toml.Unmarshal([]byte(`a = "x"`), &struct{ A sentinelText }{})
+	require.True(t, errors.As(err, &de))
+	require.Equal(t, `toml: decoding "x": sentinel`, de.Error())
+	require.True(t, errors.Is(de.Unwrap(), errSentinel))
+
+	err = toml.Unmarshal([]byte(`a = `), &struct{ A int }{})
+	require.True(t, errors.As(err, &de))
+	require.Nil(t, de.Unwrap())
+
+	var derrs *toml.DecodeErrors
+	err = toml.NewDecoder(strings.NewReader("a = 'x'\nb = 'y'")).CollectErrors().Decode(&struct{ A, B sentinelText }{})
+	require.True(t, errors.As(err, &derrs))
+	errs := derrs.Unwrap()
+	require.Len(t, errs, 2)
+	for _, err := range errs {
+		require.True(t, errors.Is(err, errSentinel))
+	}
 }
 
-func TestUnmarshal_RecursiveTableArray(t *testing.T) {
-	type Foo struct {
-		I int
-		F []*Foo
+// sentinelAfter fails to validate with errSentinel.
+type sentinelAfter struct {
+	A int
+}
+
+func (s *sentinelAfter) AfterDecode() error {
+	return errSentinel
+}
+
+// upperText implements encoding.TextUnmarshaler on its pointer only.
+type upperText string
+
+func (u *upperText) UnmarshalText(data []byte) error {
+	*u = upperText(strings.ToUpper(string(data)))
+	return nil
+}
+
+// rawTOML implements Unmarshaler on its pointer only.
+type rawTOML struct {
+	raw string
+}
+
+func (r *rawTOML) UnmarshalTOML(data []byte) error {
+	r.raw = string(data)
+	return nil
+}
+
+func TestUnmarshalPointerUnmarshalersInSlices(t *testing.T) {
+	doc := `
+slice = ['a', 'b']
+array = ['c', 'd']
+nested = [['e'], ['f']]
+pointers = ['g']
+raws = [1, 'h', { i = 2 }]
+inMap.k = ['j']
+`
+
+	var c struct {
+		Slice    []upperText
+		Array    [2]upperText
+		Nested   [][]upperText
+		Pointers []*upperText
+		Raws     []rawTOML
+		InMap    map[string][]upperText
+	}
+	err := toml.Unmarshal([]byte(doc), &c)
+	require.NoError(t, err)
+
+	require.Equal(t, []upperText{"A", "B"}, c.Slice)
+	require.Equal(t, [2]upperText{"C", "D"}, c.Array)
+	require.Equal(t, [][]upperText{{"E"}, {"F"}}, c.Nested)
+	require.Len(t, c.Pointers, 1)
+	require.Equal(t, upperText("G"), *c.Pointers[0])
+	require.Equal(t, []rawTOML{{raw: "1"}, {raw: "'h'"}, {raw: "{ i = 2 }"}}, c.Raws)
+	require.Equal(t, map[string][]upperText{"k": {"J"}}, c.InMap)
+}
+
+func TestUnmarshalUnsupportedFields(t *testing.T) {
+	type config struct {
+		Name     string
+		Callback func()
+		Events   chan string
+		Ratio    complex128
+		private  int
 	}
 
+	var c config
+	err := toml.Unmarshal([]byte("name = 'a'\nprivate = 1"), &c)
+	require.NoError(t, err)
+	require.Equal(t, config{Name: "a"}, c)
+
 	examples := []struct {
-		desc     string
-		input    string
-		expected string
-		err      bool
+		desc string
+		doc  string
+		msg  string
 	}{
 		{
-			desc: "simplest",
-			input: `
-				I=1
-				F=[]
-			`,
-			expected: `{"I":1,"F":[]}`,
-		},
-		{
-			desc: "depth 1",
-			input: `
-				I=1
-				[[F]]
-				I=2
-				F=[]
-			`,
-			expected: `{"I":1,"F":[{"I":2,"F":[]}]}`,
+			desc: "func",
+			doc:  "callback = 'f'",
+			msg:  "toml: cannot decode key callback into struct field toml_test.config.Callback of type func(): func values are not supported",
 		},
 		{
-			desc: "depth 2",
-			input: `
-				I=1
-				[[F]]
-				I=2
-				[[F.F]]
-				I=3
-				F=[]
-			`,
-			expected: `{"I":1,"F":[{"I":2,"F":[{"I":3,"F":[]}]}]}`,
+			desc: "chan table",
+			doc:  "[events]\na = 1",
+			msg:  "toml: cannot decode key events into struct field toml_test.config.Events of type chan string: chan values are not supported (in [events])",
 		},
 		{
-			desc: "depth 3",
-			input: `
-				I=1
-				[[F]]
-				I=2
-				[[F.F]]
-				I=3
-				[[F.F.F]]
-				I=4
-				F=[]
-			`,
-			expected: `{"I":1,"F":[{"I":2,"F":[{"I":3,"F":[{"I":4,"F":[]}]}]}]}`,
+			desc: "func array table",
+			doc:  "[[callback]]",
+			msg:  "toml: cannot decode key callback into struct field toml_test.config.Callback of type func(): func values are not supported (in [[callback]] element 1)",
 		},
 		{
-			desc: "depth 4",
-			input: `
-				I=1
-				[[F]]
-				I=2
-				[[F.F]]
-				I=3
-				[[F.F.F]]
-				I=4
-				[[F.F.F.F]]
-				I=5
-				F=[]
-			`,
-			expected: `{"I":1,"F":[{"I":2,"F":[{"I":3,"F":[{"I":4,"F":[{"I":5,"F":[]}]}]}]}]}`,
+			desc: "complex dotted key",
+			doc:  "ratio.real = 1",
+			msg:  "toml: cannot decode key ratio into struct field toml_test.config.Ratio of type complex128: complex128 values are not supported",
 		},
 	}
 
-	for _, ex := range examples {
-		e := ex
+	for _, e := range examples {
+		e := e
 		t.Run(e.desc, func(t *testing.T) {
-			foo := Foo{}
-			err := toml.Unmarshal([]byte(e.input), &foo)
-			if e.err {
-				require.Error(t, err)
-			} else {
-				require.NoError(t, err)
-				j, err := json.Marshal(foo)
+			var c config
+			err := toml.Unmarshal([]byte(e.doc), &c)
+			var derr *toml.DecodeError
+			require.ErrorAs(t, err, &derr)
+			require.Equal(t, e.msg, derr.Error())
+		})
+	}
+
+	var m map[string]func()
+	err = toml.Unmarshal([]byte("a = 1"), &m)
+	var derr *toml.DecodeError
+	require.ErrorAs(t, err, &derr)
+	require.Contains(t, derr.Error(), "func values are not supported")
+}
+
+func TestDecoderSetRootKey(t *testing.T) {
+	decode := func(doc string, v interface{}) error {
+		return toml.NewDecoder(strings.NewReader(doc)).SetRootKey("items").Decode(v)
+	}
+
+	var s []string
+	err := decode("other = 1\nitems = ['a', 'b']\n[table]\nitems = ['c']", &s)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, s)
+
+	var i int
+	err = decode("items = 42", &i)
+	require.NoError(t, err)
+	require.Equal(t, 42, i)
+
+	type server struct {
+		Name string
+		Port int
+	}
+
+	var servers []server
+	err = decode("[[items]]\nname = 'a'\n[[items]]\nname = 'b'\nport = 81\n[other]\nname = 'c'", &servers)
+	require.NoError(t, err)
+	require.Equal(t, []server{{Name: "a"}, {Name: "b", Port: 81}}, servers)
+
+	srv := server{Port: 80}
+	err = decode("items.name = 'a'", &srv)
+	require.NoError(t, err)
+	require.Equal(t, server{Name: "a", Port: 80}, srv)
+
+	var missing []string
+	err = decode("other = ['a']", &missing)
+	require.NoError(t, err)
+	require.Nil(t, missing)
+
+	var strict server
+	err = toml.NewDecoder(strings.NewReader("[items]\nname = 'a'\n[other]\nx = 1")).SetRootKey("items").DisallowUnknownFields().Decode(&strict)
+	var serr *toml.StrictMissingError
+	require.ErrorAs(t, err, &serr)
+	require.Len(t, serr.Errors, 1)
+	require.Equal(t, toml.Key{"other"}, serr.Errors[0].Key())
+}
+
+func TestUnmarshalIntegerRange(t *testing.T) {
+	const outOfRange = "exceeds the 64-bit range of TOML integers"
+
+	examples := []struct {
+		value    string
+		expected int64
+		err      string
+	}{
+		{value: "9223372036854775807", expected: math.MaxInt64},
+		{value: "-9223372036854775808", expected: math.MinInt64},
+		{value: "9223372036854775808", err: outOfRange},
+		{value: "-9223372036854775809", err: outOfRange},
+		{value: "9_999_999_999_999_999_999_999", err: outOfRange},
+		{value: "0x7fff_ffff_ffff_ffff", expected: math.MaxInt64},
+		{value: "0x8000_0000_0000_0000", err: outOfRange},
+		{value: "0o777_777_777_777_777_777_777", expected: math.MaxInt64},
+		{value: "0o1_000_000_000_000_000_000_000", err: outOfRange},
+		{value: "0b" + strings.Repeat("1", 63), expected: math.MaxInt64},
+		{value: "0b1" + strings.Repeat("0", 63), err: outOfRange},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.value, func(t *testing.T) {
+			doc := "v = " + e.value
+
+			var v interface{}
+			err := toml.Unmarshal([]byte(doc), &v)
+			var i struct{ V int64 }
+			ierr := toml.Unmarshal([]byte(doc), &i)
+
+			if e.err == "" {
 				require.NoError(t, err)
-				assert.Equal(t, e.expected, string(j))
+				require.NoError(t, ierr)
+				require.Equal(t, map[string]interface{}{"v": e.expected}, v)
+				require.Equal(t, e.expected, i.V)
+				return
+			}
+
+			for _, err := range []error{err, ierr} {
+				var derr *toml.DecodeError
+				require.ErrorAs(t, err, &derr)
+				require.Contains(t, derr.Error(), e.value+" "+e.err)
+				row, col := derr.Position()
+				require.Equal(t, 1, row)
+				require.Equal(t, 5, col)
 			}
 		})
 	}
+
+	var widths struct {
+		I8  int8
+		I16 int16
+		I32 int32
+		U8  uint8
+		U16 uint16
+		U32 uint32
+		U64 uint64
+	}
+
+	for _, e := range []struct {
+		key  string
+		ok   []string
+		err  []string
+		kind string
+	}{
+		{key: "i8", ok: []string{"127", "-128"}, err: []string{"128", "-129"}, kind: "int8"},
+		{key: "i16", ok: []string{"32767", "-32768"}, err: []string{"32768", "-32769"}, kind: "int16"},
+		{key: "i32", ok: []string{"2147483647", "-2147483648"}, err: []string{"2147483648", "-2147483649"}, kind: "int32"},
+		{key: "u8", ok: []string{"255", "0"}, err: []string{"256", "-1"}, kind: "uint8"},
+		{key: "u16", ok: []string{"65535"}, err: []string{"65536", "-1"}, kind: "uint16"},
+		{key: "u32", ok: []string{"4294967295"}, err: []string{"4294967296", "-1"}, kind: "uint32"},
+		{key: "u64", ok: []string{"9223372036854775807"}, err: []string{"-1"}, kind: "uint64"},
+	} {
+		for _, v := range e.ok {
+			err := toml.Unmarshal([]byte(e.key+" = "+v), &widths)
+			require.NoError(t, err)
+		}
+		for _, v := range e.err {
+			err := toml.Unmarshal([]byte(e.key+" = "+v), &widths)
+			var derr *toml.DecodeError
+			require.ErrorAs(t, err, &derr)
+			require.Contains(t, derr.Error(), v+" does not fit in an "+e.kind)
+		}
+	}
+	require.Equal(t, int8(-128), widths.I8)
+	require.Equal(t, uint8(0), widths.U8)
+	require.Equal(t, uint32(4294967295), widths.U32)
 }
@@ -0,0 +1,6 @@
+// Package unstable provides low-level access to TOML documents, for tools
+// such as linters and formatters.
+//
+// The APIs in this package are not covered by the compatibility guarantees of
+// the toml package, and may change in any release.
+package unstable
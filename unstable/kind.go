@@ -0,0 +1,106 @@
+package unstable
+
+import "fmt"
+
+// Kind is the type of a Token.
+type Kind int
+
+const (
+	Invalid Kind = iota
+
+	// trivia
+	Whitespace
+	Newline
+	Comment
+
+	// keys
+	Key
+	Dot
+	Equal
+
+	// punctuation
+	Comma
+	TableOpen
+	TableClose
+	ArrayTableOpen
+	ArrayTableClose
+	ArrayOpen
+	ArrayClose
+	InlineTableOpen
+	InlineTableClose
+
+	// values
+	String
+	Bool
+	Float
+	Integer
+	LocalDate
+	LocalTime
+	LocalDateTime
+	DateTime
+)
+
+// IsTrivia returns true for the kinds of tokens that do not change the meaning
+// of the document: whitespace, newlines and comments.
+func (k Kind) IsTrivia() bool {
+	return k >= Whitespace && k <= Comment
+}
+
+// IsValue returns true for the kinds of tokens that are scalar values.
+func (k Kind) IsValue() bool {
+	return k >= String
+}
+
+func (k Kind) String() string {
+	switch k {
+	case Invalid:
+		return "Invalid"
+	case Whitespace:
+		return "Whitespace"
+	case Newline:
+		return "Newline"
+	case Comment:
+		return "Comment"
+	case Key:
+		return "Key"
+	case Dot:
+		return "Dot"
+	case Equal:
+		return "Equal"
+	case Comma:
+		return "Comma"
+	case TableOpen:
+		return "TableOpen"
+	case TableClose:
+		return "TableClose"
+	case ArrayTableOpen:
+		return "ArrayTableOpen"
+	case ArrayTableClose:
+		return "ArrayTableClose"
+	case ArrayOpen:
+		return "ArrayOpen"
+	case ArrayClose:
+		return "ArrayClose"
+	case InlineTableOpen:
+		return "InlineTableOpen"
+	case InlineTableClose:
+		return "InlineTableClose"
+	case String:
+		return "String"
+	case Bool:
+		return "Bool"
+	case Float:
+		return "Float"
+	case Integer:
+		return "Integer"
+	case LocalDate:
+		return "LocalDate"
+	case LocalTime:
+		return "LocalTime"
+	case LocalDateTime:
+		return "LocalDateTime"
+	case DateTime:
+		return "DateTime"
+	}
+	panic(fmt.Errorf("Kind.String() not implemented for '%d'", k))
+}
@@ -0,0 +1,42 @@
+package unstable
+
+import (
+	"fmt"
+)
+
+// Node is a value of a TOML document, as given to the UnmarshalTOMLContext
+// method of the toml.ContextUnmarshaler interface.
+type Node struct {
+	// Kind of the value: one of the value kinds for scalars, ArrayOpen for
+	// arrays and InlineTableOpen for inline tables.
+	Kind Kind
+
+	// Bytes of the value in the document. Strings include their quotes, and
+	// arrays and inline tables their delimiters.
+	Raw []byte
+
+	// Position of the first byte of the value, in the same format as in
+	// Token.
+	Offset int
+	Line   int
+	Column int
+
+	// Parts of the key of the value, for the values of inline tables.
+	Key []string
+
+	// Elements of an array, or values of an inline table, in the order they
+	// appear in the document.
+	Children []Node
+}
+
+// Errorf returns an error about the node. When returned by
+// UnmarshalTOMLContext, the error is reported at the position of the node.
+func (n Node) Errorf(format string, args ...interface{}) error {
+	return &Error{
+		Offset:  n.Offset,
+		Line:    n.Line,
+		Column:  n.Column,
+		Length:  len(n.Raw),
+		Message: fmt.Sprintf(format, args...),
+	}
+}
@@ -0,0 +1,26 @@
+package unstable_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeErrorf(t *testing.T) {
+	n := unstable.Node{
+		Kind:   unstable.Integer,
+		Raw:    []byte("8080"),
+		Offset: 12,
+		Line:   2,
+		Column: 8,
+	}
+
+	err := n.Errorf("invalid port %d", 8080)
+
+	var uerr *unstable.Error
+	require.True(t, errors.As(err, &uerr))
+	require.Equal(t, unstable.Error{Offset: 12, Line: 2, Column: 8, Length: 4, Message: "invalid port 8080"}, *uerr)
+	require.EqualError(t, err, "toml: invalid port 8080 at line 2, column 8")
+}
@@ -0,0 +1,524 @@
+package unstable
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Token is a lexical element of a TOML document.
+type Token struct {
+	Kind Kind
+
+	// Bytes of the token in the document. Strings and quoted keys include
+	// their quotes, and comments their #.
+	Raw []byte
+
+	// Position of the first byte of the token: its offset in the document,
+	// and its line and column, both starting at 1. Columns count bytes.
+	Offset int
+	Line   int
+	Column int
+}
+
+// Error describes an invalid token of a document, or an invalid value reported
+// with Node.Errorf.
+type Error struct {
+	// Position of the first invalid byte, in the same format as in Token.
+	Offset int
+	Line   int
+	Column int
+
+	// Number of bytes the error is about, if known.
+	Length int
+
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("toml: %s at line %d, column %d", e.Message, e.Line, e.Column)
+}
+
+// Scanner splits a TOML document into tokens, in the order they appear in the
+// document. Contrary to the parser of the toml package, it reports whitespace,
+// newlines and comments, so that every byte of the document is part of a
+// token:
+//
+//   s := unstable.NewScanner(data)
+//   for s.Next() {
+//     t := s.Token()
+//     fmt.Println(t.Line, t.Column, t.Kind, string(t.Raw))
+//   }
+//   if err := s.Err(); err != nil {
+//     return err
+//   }
+//
+// The Scanner follows the structure of the document to tell keys from values,
+// and the brackets of table headers from the ones of arrays. It does not
+// verify all the rules of the TOML specification: values are classified by
+// their form, without checking that they are valid numbers or dates, and
+// tables defined twice are not detected. Use toml.Validate or toml.Unmarshal
+// to verify a document.
+type Scanner struct {
+	data []byte
+
+	// Position of the next token.
+	offset int
+	line   int
+	column int
+
+	token Token
+	err   error
+
+	state scannerState
+
+	// Containers the next token is in, innermost last.
+	stack []scannerContext
+}
+
+type scannerState int
+
+const (
+	// Expecting a key, or a table header at the top level.
+	expectKey scannerState = iota
+	// Expecting a dot, an equal sign, or the end of a table header.
+	expectKeyEnd
+	// Expecting a value.
+	expectValue
+	// Expecting a comma, the end of a container, or the end of the line.
+	expectValueEnd
+)
+
+type scannerContext int
+
+const (
+	inTableHeader scannerContext = iota
+	inArrayTableHeader
+	inArray
+	inInlineTable
+)
+
+// NewScanner creates a Scanner reading the TOML document data.
+func NewScanner(data []byte) *Scanner {
+	return &Scanner{
+		data:   data,
+		line:   1,
+		column: 1,
+	}
+}
+
+// Next moves to the next token of the document. It returns false at the end of
+// the document, or when the next token is invalid. In that case, Err returns
+// the error. A document ending in the middle of an expression, like an array
+// or a table header that is not closed, is an error too.
+func (s *Scanner) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	if s.offset >= len(s.data) {
+		s.err = s.endError()
+		return false
+	}
+
+	b := s.data[s.offset:]
+	kind, n, err := s.scan(b)
+	if err != nil {
+		s.err = err
+		return false
+	}
+
+	s.token = Token{
+		Kind:   kind,
+		Raw:    b[:n:n],
+		Offset: s.offset,
+		Line:   s.line,
+		Column: s.column,
+	}
+
+	s.offset += n
+	for _, c := range b[:n] {
+		if c == '\n' {
+			s.line++
+			s.column = 1
+		} else {
+			s.column++
+		}
+	}
+
+	return true
+}
+
+// Token returns the current token. It is only valid after Next returned true.
+func (s *Scanner) Token() Token {
+	return s.token
+}
+
+// Err returns the error that stopped the Scanner, if any.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// errorAt returns an error about the byte at offset i after the position of
+// the next token.
+func (s *Scanner) errorAt(i int, format string, args ...interface{}) error {
+	line, column := s.line, s.column
+	for _, c := range s.data[s.offset : s.offset+i] {
+		if c == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+
+	return &Error{
+		Offset:  s.offset + i,
+		Line:    line,
+		Column:  column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// endError returns the error for a document ending in the middle of an
+// expression, or nil if it ends between expressions.
+func (s *Scanner) endError() error {
+	if t, ok := s.top(); ok {
+		switch t {
+		case inTableHeader, inArrayTableHeader:
+			return s.errorAt(0, "unterminated table header")
+		case inArray:
+			return s.errorAt(0, "unterminated array")
+		default:
+			return s.errorAt(0, "unterminated inline table")
+		}
+	}
+
+	switch s.state {
+	case expectKeyEnd:
+		return s.errorAt(0, "expected an equal sign after a key")
+	case expectValue:
+		return s.errorAt(0, "expected a value")
+	}
+
+	return nil
+}
+
+func (s *Scanner) top() (scannerContext, bool) {
+	if len(s.stack) == 0 {
+		return 0, false
+	}
+	return s.stack[len(s.stack)-1], true
+}
+
+func (s *Scanner) in(c scannerContext) bool {
+	t, ok := s.top()
+	return ok && t == c
+}
+
+func (s *Scanner) push(c scannerContext) {
+	s.stack = append(s.stack, c)
+}
+
+func (s *Scanner) pop() {
+	s.stack = s.stack[:len(s.stack)-1]
+	s.state = expectValueEnd
+}
+
+//nolint:cyclop
+func (s *Scanner) scan(b []byte) (Kind, int, error) {
+	switch b[0] {
+	case ' ', '\t':
+		n := 1
+		for n < len(b) && (b[n] == ' ' || b[n] == '\t') {
+			n++
+		}
+		return Whitespace, n, nil
+	case '\n', '\r':
+		n := 1
+		if b[0] == '\r' {
+			if len(b) < 2 || b[1] != '\n' {
+				return Invalid, 0, s.errorAt(0, "expected a newline after \\r")
+			}
+			n = 2
+		}
+		if len(s.stack) == 0 {
+			s.state = expectKey
+		}
+		return Newline, n, nil
+	case '#':
+		n := 1
+		for n < len(b) && b[n] != '\n' && !(b[n] == '\r' && n+1 < len(b) && b[n+1] == '\n') {
+			if isControl(b[n]) {
+				return Invalid, 0, s.errorAt(n, "invalid character %#U in comment", rune(b[n]))
+			}
+			n++
+		}
+		return Comment, n, nil
+	}
+
+	switch s.state {
+	case expectKey:
+		switch {
+		case b[0] == '[' && len(s.stack) == 0:
+			s.state = expectKey
+			if len(b) > 1 && b[1] == '[' {
+				s.push(inArrayTableHeader)
+				return ArrayTableOpen, 2, nil
+			}
+			s.push(inTableHeader)
+			return TableOpen, 1, nil
+		case b[0] == '}' && s.in(inInlineTable):
+			s.pop()
+			return InlineTableClose, 1, nil
+		case b[0] == '"' || b[0] == '\'':
+			n, err := s.scanString(b, false)
+			if err != nil {
+				return Invalid, 0, err
+			}
+			s.state = expectKeyEnd
+			return Key, n, nil
+		case isBareKeyChar(b[0]):
+			n := 1
+			for n < len(b) && isBareKeyChar(b[n]) {
+				n++
+			}
+			s.state = expectKeyEnd
+			return Key, n, nil
+		}
+		return Invalid, 0, s.errorAt(0, "expected a key but got %#U", rune(b[0]))
+	case expectKeyEnd:
+		switch {
+		case b[0] == '.':
+			s.state = expectKey
+			return Dot, 1, nil
+		case b[0] == '=' && !s.in(inTableHeader) && !s.in(inArrayTableHeader):
+			s.state = expectValue
+			return Equal, 1, nil
+		case b[0] == ']' && s.in(inTableHeader):
+			s.pop()
+			return TableClose, 1, nil
+		case bytes.HasPrefix(b, []byte("]]")) && s.in(inArrayTableHeader):
+			s.pop()
+			return ArrayTableClose, 2, nil
+		}
+		return Invalid, 0, s.errorAt(0, "unexpected character %#U after a key", rune(b[0]))
+	case expectValue:
+		switch b[0] {
+		case '[':
+			s.push(inArray)
+			s.state = expectValue
+			return ArrayOpen, 1, nil
+		case ']':
+			if s.in(inArray) {
+				s.pop()
+				return ArrayClose, 1, nil
+			}
+		case '{':
+			s.push(inInlineTable)
+			s.state = expectKey
+			return InlineTableOpen, 1, nil
+		case '"', '\'':
+			n, err := s.scanString(b, true)
+			if err != nil {
+				return Invalid, 0, err
+			}
+			s.state = expectValueEnd
+			return String, n, nil
+		}
+
+		kind, n, err := s.scanScalar(b)
+		if err != nil {
+			return Invalid, 0, err
+		}
+		s.state = expectValueEnd
+		return kind, n, nil
+	default: // expectValueEnd
+		switch {
+		case b[0] == ',' && s.in(inArray):
+			s.state = expectValue
+			return Comma, 1, nil
+		case b[0] == ',' && s.in(inInlineTable):
+			s.state = expectKey
+			return Comma, 1, nil
+		case b[0] == ']' && s.in(inArray):
+			s.pop()
+			return ArrayClose, 1, nil
+		case b[0] == '}' && s.in(inInlineTable):
+			s.pop()
+			return InlineTableClose, 1, nil
+		}
+		return Invalid, 0, s.errorAt(0, "unexpected character %#U after a value", rune(b[0]))
+	}
+}
+
+// scanString returns the length of the string starting at the beginning of
+// b, including its quotes. Multi-line strings are only recognized when
+// multiline is true.
+func (s *Scanner) scanString(b []byte, multiline bool) (int, error) {
+	q := b[0]
+	escapes := q == '"'
+
+	if multiline && len(b) >= 3 && b[1] == q && b[2] == q {
+		delim := b[:3]
+		for i := 3; i < len(b); i++ {
+			if escapes && b[i] == '\\' {
+				i++
+				continue
+			}
+			if bytes.HasPrefix(b[i:], delim) {
+				// Up to two quotes can precede the closing delimiter.
+				n := i + 3
+				for n < len(b) && n < i+5 && b[n] == q {
+					n++
+				}
+				return n, nil
+			}
+		}
+		return 0, s.errorAt(0, "unterminated multi-line string")
+	}
+
+	for i := 1; i < len(b); i++ {
+		switch {
+		case escapes && b[i] == '\\':
+			i++
+		case b[i] == q:
+			return i + 1, nil
+		case b[i] == '\n' || b[i] == '\r':
+			return 0, s.errorAt(i, "unterminated string")
+		}
+	}
+	return 0, s.errorAt(0, "unterminated string")
+}
+
+// scanScalar returns the kind and the length of the value that is not a
+// string starting at the beginning of b.
+func (s *Scanner) scanScalar(b []byte) (Kind, int, error) {
+	n := 0
+	for n < len(b) {
+		c := b[n]
+		if c == ' ' && isDate(b[:n]) && n+3 < len(b) && isDigit(b[n+1]) && isDigit(b[n+2]) && b[n+3] == ':' {
+			// The date and time of a date-time can be separated by a
+			// space.
+			n++
+			continue
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' || c == ']' || c == '}' || c == '#' {
+			break
+		}
+		n++
+	}
+
+	if n == 0 {
+		return Invalid, 0, s.errorAt(0, "expected a value but got %#U", rune(b[0]))
+	}
+
+	kind := classifyScalar(b[:n])
+	if kind == Invalid {
+		return Invalid, 0, s.errorAt(0, "invalid value %q", b[:n])
+	}
+
+	return kind, n, nil
+}
+
+// classifyScalar returns the kind of the value v, or Invalid if it does not
+// look like a TOML value.
+func classifyScalar(v []byte) Kind {
+	switch string(v) {
+	case "true", "false":
+		return Bool
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		return Float
+	}
+
+	if isDate(v) {
+		if len(v) == 10 {
+			return LocalDate
+		}
+
+		t := v[10:]
+		if t[0] != 'T' && t[0] != 't' && t[0] != ' ' {
+			return Invalid
+		}
+		t = t[1:]
+		if !isTime(t) {
+			return Invalid
+		}
+		if bytes.ContainsAny(t, "Zz+-") {
+			return DateTime
+		}
+		return LocalDateTime
+	}
+
+	if isTime(v) {
+		if !onlyChars(v, "0123456789:.") {
+			return Invalid
+		}
+		return LocalTime
+	}
+
+	body := v
+	if body[0] == '+' || body[0] == '-' {
+		body = body[1:]
+	}
+
+	switch {
+	case len(body) > 2 && body[0] == '0' && (body[1] == 'x' || body[1] == 'o' || body[1] == 'b'):
+		if onlyChars(body[2:], "0123456789abcdefABCDEF_") {
+			return Integer
+		}
+	case len(body) > 0 && onlyChars(body, "0123456789_"):
+		return Integer
+	case len(body) > 0 && isDigit(body[0]) && onlyChars(body, "0123456789_.eE+-"):
+		return Float
+	}
+
+	return Invalid
+}
+
+// isDate returns true if v has the form of a date: YYYY-MM-DD.
+func isDate(v []byte) bool {
+	if len(v) < 10 || v[4] != '-' || v[7] != '-' {
+		return false
+	}
+	for _, i := range []int{0, 1, 2, 3, 5, 6, 8, 9} {
+		if !isDigit(v[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTime returns true if v starts with a time of the form HH:MM:SS, and only
+// contains characters that can follow it.
+func isTime(v []byte) bool {
+	if len(v) < 8 || v[2] != ':' || v[5] != ':' {
+		return false
+	}
+	for _, i := range []int{0, 1, 3, 4, 6, 7} {
+		if !isDigit(v[i]) {
+			return false
+		}
+	}
+	return onlyChars(v[8:], "0123456789:.Zz+-")
+}
+
+func onlyChars(v []byte, chars string) bool {
+	for _, c := range v {
+		if strings.IndexByte(chars, c) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isBareKeyChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || isDigit(c) || c == '_' || c == '-'
+}
+
+func isControl(c byte) bool {
+	return (c < 0x20 && c != '\t') || c == 0x7f
+}
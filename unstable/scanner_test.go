@@ -0,0 +1,205 @@
+package unstable_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+	"github.com/stretchr/testify/require"
+)
+
+type token struct {
+	kind unstable.Kind
+	raw  string
+}
+
+func scanAll(t *testing.T, doc string) []token {
+	t.Helper()
+
+	var tokens []token
+	s := unstable.NewScanner([]byte(doc))
+	for s.Next() {
+		tok := s.Token()
+		tokens = append(tokens, token{tok.Kind, string(tok.Raw)})
+	}
+	require.NoError(t, s.Err())
+
+	return tokens
+}
+
+func TestScanner(t *testing.T) {
+	doc := "# comment\r\n" +
+		"[a.\"b c\"] # header\n" +
+		"k = 'v'\n" +
+		"arr = [1, 2.5, [true], { x = 1979-05-27 07:32:00Z },]\n" +
+		"[[t]]\n" +
+		"s = \"\"\"\nline \"\"\"\"\n" +
+		"d = { a = 1979-05-27, b = 07:32:00, c = 1979-05-27T07:32:00 }\n"
+
+	expected := []token{
+		{unstable.Comment, "# comment"},
+		{unstable.Newline, "\r\n"},
+		{unstable.TableOpen, "["},
+		{unstable.Key, "a"},
+		{unstable.Dot, "."},
+		{unstable.Key, `"b c"`},
+		{unstable.TableClose, "]"},
+		{unstable.Whitespace, " "},
+		{unstable.Comment, "# header"},
+		{unstable.Newline, "\n"},
+		{unstable.Key, "k"},
+		{unstable.Whitespace, " "},
+		{unstable.Equal, "="},
+		{unstable.Whitespace, " "},
+		{unstable.String, "'v'"},
+		{unstable.Newline, "\n"},
+		{unstable.Key, "arr"},
+		{unstable.Whitespace, " "},
+		{unstable.Equal, "="},
+		{unstable.Whitespace, " "},
+		{unstable.ArrayOpen, "["},
+		{unstable.Integer, "1"},
+		{unstable.Comma, ","},
+		{unstable.Whitespace, " "},
+		{unstable.Float, "2.5"},
+		{unstable.Comma, ","},
+		{unstable.Whitespace, " "},
+		{unstable.ArrayOpen, "["},
+		{unstable.Bool, "true"},
+		{unstable.ArrayClose, "]"},
+		{unstable.Comma, ","},
+		{unstable.Whitespace, " "},
+		{unstable.InlineTableOpen, "{"},
+		{unstable.Whitespace, " "},
+		{unstable.Key, "x"},
+		{unstable.Whitespace, " "},
+		{unstable.Equal, "="},
+		{unstable.Whitespace, " "},
+		{unstable.DateTime, "1979-05-27 07:32:00Z"},
+		{unstable.Whitespace, " "},
+		{unstable.InlineTableClose, "}"},
+		{unstable.Comma, ","},
+		{unstable.ArrayClose, "]"},
+		{unstable.Newline, "\n"},
+		{unstable.ArrayTableOpen, "[["},
+		{unstable.Key, "t"},
+		{unstable.ArrayTableClose, "]]"},
+		{unstable.Newline, "\n"},
+		{unstable.Key, "s"},
+		{unstable.Whitespace, " "},
+		{unstable.Equal, "="},
+		{unstable.Whitespace, " "},
+		{unstable.String, "\"\"\"\nline \"\"\"\""},
+		{unstable.Newline, "\n"},
+		{unstable.Key, "d"},
+		{unstable.Whitespace, " "},
+		{unstable.Equal, "="},
+		{unstable.Whitespace, " "},
+		{unstable.InlineTableOpen, "{"},
+		{unstable.Whitespace, " "},
+		{unstable.Key, "a"},
+		{unstable.Whitespace, " "},
+		{unstable.Equal, "="},
+		{unstable.Whitespace, " "},
+		{unstable.LocalDate, "1979-05-27"},
+		{unstable.Comma, ","},
+		{unstable.Whitespace, " "},
+		{unstable.Key, "b"},
+		{unstable.Whitespace, " "},
+		{unstable.Equal, "="},
+		{unstable.Whitespace, " "},
+		{unstable.LocalTime, "07:32:00"},
+		{unstable.Comma, ","},
+		{unstable.Whitespace, " "},
+		{unstable.Key, "c"},
+		{unstable.Whitespace, " "},
+		{unstable.Equal, "="},
+		{unstable.Whitespace, " "},
+		{unstable.LocalDateTime, "1979-05-27T07:32:00"},
+		{unstable.Whitespace, " "},
+		{unstable.InlineTableClose, "}"},
+		{unstable.Newline, "\n"},
+	}
+
+	require.Equal(t, expected, scanAll(t, doc))
+}
+
+func TestScannerPositions(t *testing.T) {
+	s := unstable.NewScanner([]byte("a = [\n  1,\n]"))
+
+	var positions [][3]int
+	for s.Next() {
+		tok := s.Token()
+		positions = append(positions, [3]int{tok.Offset, tok.Line, tok.Column})
+	}
+	require.NoError(t, s.Err())
+
+	require.Equal(t, [][3]int{
+		{0, 1, 1},  // a
+		{1, 1, 2},  // " "
+		{2, 1, 3},  // =
+		{3, 1, 4},  // " "
+		{4, 1, 5},  // [
+		{5, 1, 6},  // \n
+		{6, 2, 1},  // "  "
+		{8, 2, 3},  // 1
+		{9, 2, 4},  // ,
+		{10, 2, 5}, // \n
+		{11, 3, 1}, // ]
+	}, positions)
+}
+
+func TestScannerNumbers(t *testing.T) {
+	examples := map[string]unstable.Kind{
+		"1_000":        unstable.Integer,
+		"-0x_dead":     unstable.Integer,
+		"0o17":         unstable.Integer,
+		"+1.5e-3":      unstable.Float,
+		"-inf":         unstable.Float,
+		"nan":          unstable.Float,
+		"07:32:00.999": unstable.LocalTime,
+	}
+
+	for v, kind := range examples {
+		tokens := scanAll(t, "a = "+v)
+		require.Equal(t, token{kind, v}, tokens[len(tokens)-1], v)
+	}
+}
+
+func TestScannerErrors(t *testing.T) {
+	examples := []struct {
+		desc   string
+		doc    string
+		msg    string
+		line   int
+		column int
+	}{
+		{desc: "unterminated string", doc: "a = 'b\nc = 1", msg: "unterminated string", line: 1, column: 7},
+		{desc: "unterminated multi-line string", doc: "a = \"\"\"b", msg: "unterminated multi-line string", line: 1, column: 5},
+		{desc: "missing equal", doc: "a\n  b 1", msg: "unexpected character U+0031 '1' after a key", line: 2, column: 5},
+		{desc: "invalid value", doc: "a = yes", msg: `invalid value "yes"`, line: 1, column: 5},
+		{desc: "bare carriage return", doc: "a = 1\r", msg: `expected a newline after \r`, line: 1, column: 6},
+		{desc: "value after value", doc: "a = [1 2]", msg: "unexpected character U+0032 '2' after a value", line: 1, column: 8},
+		{desc: "unterminated array", doc: "a = [1, \n", msg: "unterminated array", line: 2, column: 1},
+		{desc: "unterminated table header", doc: "[a", msg: "unterminated table header", line: 1, column: 3},
+		{desc: "unterminated array table header", doc: "[[a.b", msg: "unterminated table header", line: 1, column: 6},
+		{desc: "unterminated inline table", doc: "a = {b = 1", msg: "unterminated inline table", line: 1, column: 11},
+		{desc: "missing value", doc: "a = ", msg: "expected a value", line: 1, column: 5},
+		{desc: "missing equal at end", doc: "a.b", msg: "expected an equal sign after a key", line: 1, column: 4},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			s := unstable.NewScanner([]byte(e.doc))
+			for s.Next() {
+			}
+
+			var err *unstable.Error
+			require.True(t, errors.As(s.Err(), &err), "%v", s.Err())
+			require.Equal(t, e.msg, err.Message)
+			require.Equal(t, e.line, err.Line)
+			require.Equal(t, e.column, err.Column)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package toml
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/pelletier/go-toml/v2/internal/ast"
+	"github.com/pelletier/go-toml/v2/internal/danger"
+)
+
+// Validate checks the syntax of the TOML document data, and returns all the
+// errors it contains. It returns nil if the document is valid.
+//
+// Contrary to Unmarshal, Validate does not stop at the first syntax error.
+// After an error, it skips to the next line that looks like the beginning of a
+// top-level expression (a table header or a key followed by '=') and continues
+// from there. As a result, code that is between the error and that line is not
+// checked. In addition to the syntax, the values of numbers and dates are
+// verified.
+//
+// Errors that depend on the structure of the document, such as keys being
+// defined multiple times, are not reported.
+func Validate(data []byte) []*DecodeError {
+	var errs []*DecodeError
+
+	p := parser{}
+	p.Reset(data)
+
+	for {
+		for p.NextExpression() {
+			err := validateExpression(p.Expression())
+			if err != nil {
+				var de *decodeError
+				if errors.As(err, &de) {
+					errs = append(errs, wrapDecodeError(data, de))
+				}
+			}
+		}
+
+		var de *decodeError
+		if !errors.As(p.Error(), &de) {
+			return errs
+		}
+
+		errs = append(errs, wrapDecodeError(data, de))
+
+		rest := resynchronize(data, de.highlight)
+		if rest == nil {
+			return errs
+		}
+
+		p.left = rest
+		p.err = nil
+		p.first = true
+	}
+}
+
+// ValidateDocument returns the error Unmarshal would return when decoding the
+// TOML document data into an interface{}, or nil if the document is valid. It
+// checks the syntax, the values of numbers and dates, and that keys and tables
+// are not defined multiple times, without building the decoded values.
+//
+// Contrary to Validate, it stops at the first error. The document is parsed
+// one expression at a time, so the memory used does not depend on its size.
+func ValidateDocument(data []byte) error {
+	p := parser{}
+	p.Reset(data)
+	d := decoder{p: &p}
+
+	for p.NextExpression() {
+		expr := p.Expression()
+		d.sections.Enter(expr)
+
+		err := d.seen.CheckExpression(expr)
+		if err != nil {
+			return d.wrapError(err)
+		}
+
+		err = validateExpression(expr)
+		if err != nil {
+			return d.wrapError(err)
+		}
+	}
+
+	if err := p.Error(); err != nil {
+		return d.wrapError(err)
+	}
+
+	return nil
+}
+
+// resynchronize returns the rest of the document starting at the first
+// top-level expression following the highlight of an error, or nil if there is
+// none.
+func resynchronize(data []byte, highlight []byte) []byte {
+	b := data[danger.SubsliceOffset(data, highlight):]
+
+	for {
+		idx := bytes.IndexByte(b, '\n')
+		if idx < 0 {
+			return nil
+		}
+		b = b[idx+1:]
+
+		if isExpressionStart(b) {
+			return b
+		}
+	}
+}
+
+// isExpressionStart returns true if the line at the beginning of b is a table
+// header, or starts with a key followed by '='.
+func isExpressionStart(b []byte) bool {
+	p := parser{}
+	p.Reset(b)
+
+	b = p.parseWhitespace(b)
+	if len(b) == 0 {
+		return false
+	}
+
+	if b[0] == '[' {
+		_, rest, err := p.parseTable(b)
+		if err != nil {
+			return false
+		}
+		rest = p.parseWhitespace(rest)
+		return len(rest) == 0 || rest[0] == '#' || rest[0] == '\n' || rest[0] == '\r'
+	}
+
+	_, rest, err := p.parseKey(b)
+	if err != nil {
+		return false
+	}
+	rest = p.parseWhitespace(rest)
+	return len(rest) > 0 && rest[0] == '='
+}
+
+// validateExpression checks the values of the expression that are only parsed
+// when decoding.
+func validateExpression(expr *ast.Node) error {
+	if expr.Kind != ast.KeyValue {
+		return nil
+	}
+	return validateValue(expr.Value())
+}
+
+func validateValue(value *ast.Node) error {
+	var (
+		rest []byte
+		err  error
+	)
+
+	switch value.Kind {
+	case ast.Integer:
+		_, err = parseInteger(value.Data)
+	case ast.Float:
+		_, err = parseFloat(value.Data)
+	case ast.DateTime:
+		_, err = parseDateTime(value.Data)
+	case ast.LocalDate:
+		_, err = parseLocalDate(value.Data)
+	case ast.LocalTime:
+		_, rest, err = parseLocalTime(value.Data)
+		if err == nil && len(rest) > 0 {
+			err = newDecodeError(rest, "extra characters at the end of a local time")
+		}
+	case ast.LocalDateTime:
+		_, rest, err = parseLocalDateTime(value.Data)
+		if err == nil && len(rest) > 0 {
+			err = newDecodeError(rest, "extra characters at the end of a local date time")
+		}
+	case ast.InlineTable:
+		it := value.Children()
+		for it.Next() && err == nil {
+			err = validateValue(it.Node().Value())
+		}
+	case ast.Array:
+		it := value.Children()
+		for i := 0; it.Next() && err == nil; i++ {
+			err = validateValue(it.Node())
+			if err != nil {
+				err = arrayElementError(i, err)
+			}
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,118 @@
+package toml_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	examples := []struct {
+		desc      string
+		input     string
+		positions [][2]int
+	}{
+		{
+			desc:  "valid",
+			input: "a = 1\n[b]\nc = [1, {d = 2}]\n",
+		},
+		{
+			desc:      "single error",
+			input:     "a = 1\nb = \n",
+			positions: [][2]int{{2, 5}},
+		},
+		{
+			desc: "errors in keys and tables",
+			input: `a = 1
+b c = 2
+d = 3
+[e
+f = 4
+[[g]]
+h = 'unterminated
+i = 5
+`,
+			positions: [][2]int{{2, 3}, {4, 3}, {7, 18}},
+		},
+		{
+			desc: "skip until next expression",
+			input: `a = [
+  1,
+  2,,
+  3,
+]
+b = "ok"
+c = 1__0
+`,
+			positions: [][2]int{{3, 5}, {7, 6}},
+		},
+		{
+			desc: "invalid values",
+			input: `a = 1979-13-27
+b = [1, 2, 0x]
+c = { d = 25:61:00 }
+`,
+			positions: [][2]int{{1, 5}, {2, 12}, {3, 11}},
+		},
+	}
+
+	for _, e := range examples {
+		e := e
+		t.Run(e.desc, func(t *testing.T) {
+			errs := toml.Validate([]byte(e.input))
+
+			var positions [][2]int
+			for _, err := range errs {
+				row, col := err.Position()
+				positions = append(positions, [2]int{row, col})
+			}
+
+			assert.Equal(t, e.positions, positions)
+
+			err := toml.Unmarshal([]byte(e.input), &map[string]interface{}{})
+			if len(e.positions) == 0 {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestValidateDocument(t *testing.T) {
+	docs := []string{
+		"a = 1\n[b]\nc = [1, {d = 2}]\n[[e]]\nf = 1\n[[e]]\nf = 2\n",
+		"",
+		"a = \n",
+		"a = 1\na = 2\n",
+		"[a]\nb = 1\n[a]\n",
+		"a.b = 1\n[a]\nc = 2\n",
+		"a = {b = 1}\n[a.c]\n",
+		"[[e]]\n[e.f]\ng = 1979-13-27\n",
+		"a = [1, 2, 0x]\n",
+		"a = 1__0\n",
+		"a = 99999999999999999999\n",
+		"a = 'unterminated\n",
+	}
+
+	for _, doc := range docs {
+		err := toml.ValidateDocument([]byte(doc))
+		expected := toml.Unmarshal([]byte(doc), new(interface{}))
+
+		if expected == nil {
+			require.NoError(t, err, doc)
+			continue
+		}
+		require.Error(t, err, doc)
+		require.Equal(t, expected.Error(), err.Error(), doc)
+
+		var derr, expectedDerr *toml.DecodeError
+		require.Equal(t, errors.As(expected, &expectedDerr), errors.As(err, &derr), doc)
+		if derr != nil {
+			require.Equal(t, expectedDerr.String(), derr.String(), doc)
+		}
+	}
+}